@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// AltPalette recolors a file by swapping each From color with the To color at
+// the same index, e.g. for player 2 colors
+type AltPalette struct {
+	Name     string
+	From, To []rl.Color
+}
+
+// Remap returns the swapped color. Colors are matched ignoring alpha and the
+// alpha of c is kept
+func (p *AltPalette) Remap(c rl.Color) rl.Color {
+	for i, from := range p.From {
+		if i >= len(p.To) {
+			break
+		}
+		if from.R == c.R && from.G == c.G && from.B == c.B {
+			to := p.To[i]
+			to.A = uint8(int32(to.A) * int32(c.A) / 255)
+			return to
+		}
+	}
+	return c
+}
+
+// DisplayPixel returns the composited pixel as it should be shown on the
+// canvas, which is recolored if an alternate palette is being previewed
+func (f *File) DisplayPixel(loc IntVec2) rl.Color {
+	color := f.CompositePixel(loc)
+	if f.PreviewAltPalette >= 0 && f.PreviewAltPalette < int32(len(f.AltPalettes)) {
+		color = f.AltPalettes[f.PreviewAltPalette].Remap(color)
+	}
+	return color
+}
+
+// SetSwapBase sets the palette which alternate palettes swap colors from
+func (f *File) SetSwapBase(colors []rl.Color) {
+	f.SwapBase = append([]rl.Color{}, colors...)
+}
+
+// AddAltPalette adds an alternate palette which swaps the swap base colors
+// with colors
+func (f *File) AddAltPalette(name string, colors []rl.Color) {
+	if len(f.SwapBase) == 0 {
+		log.Println("Can't add alternate palette: swap base isn't set")
+		return
+	}
+
+	f.AltPalettes = append(f.AltPalettes, &AltPalette{
+		Name: name,
+		From: append([]rl.Color{}, f.SwapBase...),
+		To:   append([]rl.Color{}, colors...),
+	})
+	f.FileChanged = true
+	EditorsUIRebuild()
+}
+
+// DeleteAltPalette deletes the alternate palette being previewed
+func (f *File) DeleteAltPalette() {
+	if f.PreviewAltPalette < 0 || f.PreviewAltPalette >= int32(len(f.AltPalettes)) {
+		return
+	}
+
+	f.AltPalettes = append(f.AltPalettes[:f.PreviewAltPalette], f.AltPalettes[f.PreviewAltPalette+1:]...)
+	f.PreviewAltPalette = -1
+	f.FileChanged = true
+	f.RedrawRenderLayer()
+	EditorsUIRebuild()
+}
+
+// CyclePreviewAltPalette previews the next alternate palette, going back to
+// the original colors after the last one
+func (f *File) CyclePreviewAltPalette() {
+	f.PreviewAltPalette++
+	if f.PreviewAltPalette >= int32(len(f.AltPalettes)) {
+		f.PreviewAltPalette = -1
+		log.Println("Previewing original palette")
+	} else {
+		log.Println("Previewing alternate palette:", f.AltPalettes[f.PreviewAltPalette].Name)
+	}
+	f.RedrawRenderLayer()
+}
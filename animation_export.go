@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultAnimationExport is used for animations without an Export pattern
+const DefaultAnimationExport = "{name}_{animation}.gif"
+
+// animationExportPath returns where the animation is exported to. {name} is
+// replaced with the file name without the extension, {animation} with the
+// animation's name and {dir} with the file's directory. Relative paths are
+// relative to the file's directory, so they can't be used before the file has
+// been saved
+func (f *File) animationExportPath(animation *Animation) (string, error) {
+	pattern := animation.Export
+	if pattern == "" {
+		pattern = DefaultAnimationExport
+	}
+	dest := strings.NewReplacer(
+		"{name}", strings.TrimSuffix(f.Filename, filepath.Ext(f.Filename)),
+		"{animation}", animation.Name,
+		"{dir}", f.PathDir,
+	).Replace(pattern)
+	if !filepath.IsAbs(dest) {
+		if f.PathDir == "" {
+			return "", fmt.Errorf("Can't export animation \"%s\": save the file first or use an absolute path", animation.Name)
+		}
+		dest = filepath.Join(f.PathDir, dest)
+	}
+	return dest, nil
+}
+
+// animationFrames returns the exported layers of each of the animation's
+// frames
+func (f *File) animationFrames(animation *Animation) []*image.NRGBA {
+	include := ExportFilter(nil)
+	frames := make([]*image.NRGBA, 0, animation.FrameEnd-animation.FrameStart+1)
+	for i := animation.FrameStart; i <= animation.FrameEnd; i++ {
+		if i < 0 || i >= f.frameCount() {
+			continue
+		}
+		tile := f.frameTile(i)
+		frame := image.NewNRGBA(image.Rect(0, 0, int(f.TileWidth), int(f.TileHeight)))
+		for y := int32(0); y < f.TileHeight; y++ {
+			for x := int32(0); x < f.TileWidth; x++ {
+				c := f.compositePixel(IntVec2{tile.X + x, tile.Y + y}, include)
+				// Kept as straight alpha, like the pixel data
+				frame.SetNRGBA(int(x), int(y), color.NRGBA{c.R, c.G, c.B, c.A})
+			}
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// ExportAnimation exports the animation to its Export pattern and returns
+// where it was written. ".gif" is an animated gif and ".png" writes a png for
+// each frame, {frame} in the pattern is replaced with the frame's number or
+// it's added before the extension
+func (f *File) ExportAnimation(animation *Animation) (string, error) {
+	frames := f.animationFrames(animation)
+	if len(frames) == 0 {
+		return "", fmt.Errorf("Can't export animation \"%s\": it doesn't have any frames", animation.Name)
+	}
+
+	dest, err := f.animationExportPath(animation)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+
+	ext := strings.ToLower(filepath.Ext(dest))
+	switch ext {
+	case ".gif":
+		// gif delays are in hundredths of a second
+		delay := 10
+		if animation.Timing > 0 {
+			delay = int(100/animation.Timing + 0.5)
+		}
+		anim := &gif.GIF{}
+		for _, frame := range frames {
+			paletted := image.NewPaletted(frame.Rect, timelapsePalette(frame))
+			draw.Draw(paletted, frame.Rect, frame, image.Point{}, draw.Src)
+			anim.Image = append(anim.Image, paletted)
+			anim.Delay = append(anim.Delay, delay)
+			anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+		}
+		return dest, writeFileAtomic(dest, func(w io.Writer) error {
+			return gif.EncodeAll(w, anim)
+		})
+
+	case ".png":
+		if !strings.Contains(dest, "{frame}") {
+			dest = strings.TrimSuffix(dest, filepath.Ext(dest)) + "_{frame}" + filepath.Ext(dest)
+		}
+		for i, frame := range frames {
+			frame := frame
+			framePath := strings.Replace(dest, "{frame}", fmt.Sprintf("%d", i), -1)
+			if err := writeFileAtomic(framePath, func(w io.Writer) error {
+				return png.Encode(w, frame)
+			}); err != nil {
+				return "", err
+			}
+		}
+		return dest, nil
+	}
+	return "", fmt.Errorf("Can't export animation \"%s\": extension \"%s\" not supported", animation.Name, ext)
+}
+
+// ExportAnimations exports every animation of the current file to its Export
+// pattern
+func ExportAnimations() {
+	if len(CurrentFile.Animations) == 0 {
+		UIWarning(T("The file doesn't have any animations"))
+		return
+	}
+
+	failed := false
+	for _, animation := range CurrentFile.Animations {
+		dest, err := CurrentFile.ExportAnimation(animation)
+		if err != nil {
+			log.Println(err)
+			failed = true
+			continue
+		}
+		log.Println("Exported", animation.Name, "to", dest)
+	}
+
+	if failed {
+		PlaySoundCue(SoundError)
+	} else {
+		PlaySoundCue(SoundExported)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// DefaultAutoscrollSpeed is how many screen pixels a second the canvas pans
+// when the mouse is at the window's edge
+const DefaultAutoscrollSpeed int32 = 600
+
+// autoscrollChoices are what the prefs menu cycles through, -1 turns
+// autoscroll off
+var autoscrollChoices = []int32{-1, 300, DefaultAutoscrollSpeed, 1200}
+
+// SettingsAutoscrollSpeed returns how fast the canvas pans at the window's
+// edge, 0 if autoscroll is off
+func SettingsAutoscrollSpeed() int32 {
+	switch {
+	case Settings == nil || Settings.AutoscrollSpeed == 0:
+		return DefaultAutoscrollSpeed
+	case Settings.AutoscrollSpeed < 0:
+		return 0
+	}
+	return Settings.AutoscrollSpeed
+}
+
+// autoscrollAxis returns how far into the margin at either end of length pos
+// is, from -1 at the start to 1 at the end, 0 outside of the margins
+func autoscrollAxis(pos, length, margin float32) float32 {
+	switch {
+	case pos < margin:
+		return -MinFloat32(1, (margin-pos)/margin)
+	case pos > length-margin:
+		return MinFloat32(1, (pos-(length-margin))/margin)
+	}
+	return 0
+}
+
+// Autoscroll pans the camera when the mouse is dragged near the window's
+// edge, faster the closer it is, so that strokes and selections can carry on
+// past what's shown
+func Autoscroll(f *File) {
+	speed := SettingsAutoscrollSpeed()
+	if speed == 0 || PrecisionMode {
+		return
+	}
+	mouse := rl.GetMousePosition()
+	margin := UIButtonHeight
+	step := float32(speed) * rl.GetFrameTime() / f.FileCamera.Zoom
+	f.FileCameraTarget.X += autoscrollAxis(mouse.X, float32(rl.GetScreenWidth()), margin) * step
+	f.FileCameraTarget.Y += autoscrollAxis(mouse.Y, float32(rl.GetScreenHeight()), margin) * step
+	f.FileCamera.Target = f.FileCameraTarget
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// maxBrushImageSize is the largest side of an image brush, bigger images are
+// better pasted
+const maxBrushImageSize = 64
+
+// BrushImage is a small image which the pixel brush stamps along the stroke
+type BrushImage struct {
+	// Pixels are relative to the center of the image, blank pixels are left
+	// out so that they aren't drawn
+	Pixels        map[IntVec2]rl.Color
+	Width, Height int32
+}
+
+// LoadBrushImage loads a .png or .pix file as a brush
+func LoadBrushImage(path string) (*BrushImage, error) {
+	img, err := loadFlattenedImage(path)
+	if err != nil {
+		return nil, err
+	}
+	w, h := int32(img.Bounds().Dx()), int32(img.Bounds().Dy())
+	if w > maxBrushImageSize || h > maxBrushImageSize {
+		return nil, fmt.Errorf("Brush \"%s\" is %dx%d, brushes can't be bigger than %dx%d",
+			filepath.Base(path), w, h, maxBrushImageSize, maxBrushImageSize)
+	}
+
+	brush := &BrushImage{
+		Pixels: make(map[IntVec2]rl.Color),
+		Width:  w,
+		Height: h,
+	}
+	for y := int32(0); y < h; y++ {
+		for x := int32(0); x < w; x++ {
+			c := img.NRGBAAt(int(x), int(y))
+			if c.A > 0 {
+				brush.Pixels[IntVec2{x - w/2, y - h/2}] = rl.NewColor(c.R, c.G, c.B, c.A)
+			}
+		}
+	}
+	if len(brush.Pixels) == 0 {
+		return nil, fmt.Errorf("Brush \"%s\" is empty", filepath.Base(path))
+	}
+	return brush, nil
+}
+
+// Color returns the color the brush stamps at pos. When recoloring, the brush
+// is a mask for color, otherwise it keeps its own colors
+func (b *BrushImage) Color(pos IntVec2, color rl.Color, recolor bool) rl.Color {
+	c := b.Pixels[pos]
+	if !recolor {
+		return c
+	}
+	return rl.NewColor(color.R, color.G, color.B, uint8(int32(color.A)*int32(c.A)/255))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// canvasDimColor is drawn over everything outside of the canvas
+var canvasDimColor = rl.Fade(rl.Black, 0.5)
+
+// canvasBounds returns the area which can be drawn on in pixels, which is
+// where the canvas will be while a resize is being previewed
+func canvasBounds() (x, y, width, height int32) {
+	if CurrentFile.DoingResize {
+		width, height = CurrentFile.CanvasWidthResizePreview, CurrentFile.CanvasHeightResizePreview
+		x, y = ResizeOffset(CurrentFile.CanvasWidth, CurrentFile.CanvasHeight, width, height, CurrentFile.CanvasDirectionResizePreview)
+		return x, y, width, height
+	}
+	return 0, 0, CurrentFile.CanvasWidth, CurrentFile.CanvasHeight
+}
+
+// DrawCanvasBounds dims everything outside of the canvas and draws a border
+// around it in screen space, so that it's sharp at any zoom
+func DrawCanvasBounds(camera rl.Camera2D) {
+	x, y, width, height := canvasBounds()
+	topLeft := PixelToScreen(x, y, camera)
+	bottomRight := PixelToScreen(x+width, y+height, camera)
+	sw, sh := float32(rl.GetScreenWidth()), float32(rl.GetScreenHeight())
+
+	// Above, below, left and right of the canvas, kept on the screen
+	clamp := func(v, max float32) float32 {
+		return rl.Clamp(v, 0, max)
+	}
+	left, right := clamp(topLeft.X, sw), clamp(bottomRight.X, sw)
+	top, bottom := clamp(topLeft.Y, sh), clamp(bottomRight.Y, sh)
+	rl.DrawRectangleRec(rl.NewRectangle(0, 0, sw, top), canvasDimColor)
+	rl.DrawRectangleRec(rl.NewRectangle(0, bottom, sw, sh-bottom), canvasDimColor)
+	rl.DrawRectangleRec(rl.NewRectangle(0, top, left, bottom-top), canvasDimColor)
+	rl.DrawRectangleRec(rl.NewRectangle(right, top, sw-right, bottom-top), canvasDimColor)
+
+	border := rl.NewRectangle(topLeft.X, topLeft.Y, bottomRight.X-topLeft.X, bottomRight.Y-topLeft.Y)
+	rl.DrawRectangleLinesEx(rl.NewRectangle(border.X-2, border.Y-2, border.Width+4, border.Height+4), 1, rl.Black)
+	rl.DrawRectangleLinesEx(rl.NewRectangle(border.X-1, border.Y-1, border.Width+2, border.Height+2), 1, rl.White)
+}
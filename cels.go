@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// A cel is a layer's part of a frame, the frame's tile on that layer. Cels
+// can be linked so that several frames of a layer share the same pixels, the
+// pixels are copied into every linked cel and drawing in one draws in all of
+// them. Layer.CelLinks maps each linked frame to the first frame of its links
+
+// HistoryCelLinks is for linking and unlinking cels, the layer's links are
+// swapped between Prev and Current
+type HistoryCelLinks struct {
+	LayerIndex    int32
+	Prev, Current map[int32]int32
+}
+
+// CelRoot returns the frame which the cel at frame is linked to, or frame if
+// it isn't linked
+func (l *Layer) CelRoot(frame int32) int32 {
+	if root, ok := l.CelLinks[frame]; ok {
+		return root
+	}
+	return frame
+}
+
+// CelLinked returns true if the cel at frame shares its pixels with another
+func (l *Layer) CelLinked(frame int32) bool {
+	if _, ok := l.CelLinks[frame]; ok {
+		return true
+	}
+	for _, root := range l.CelLinks {
+		if root == frame {
+			return true
+		}
+	}
+	return false
+}
+
+// linkedFrames returns the frames whose cels are linked to the cel at frame,
+// not including frame
+func (l *Layer) linkedFrames(frame int32) []int32 {
+	if len(l.CelLinks) == 0 {
+		return nil
+	}
+	root := l.CelRoot(frame)
+	frames := make([]int32, 0, 4)
+	if root != frame {
+		frames = append(frames, root)
+	}
+	for linked, r := range l.CelLinks {
+		if r == root && linked != frame {
+			frames = append(frames, linked)
+		}
+	}
+	return frames
+}
+
+// frameOf returns the frame whose tile has loc in it
+func (f *File) frameOf(loc IntVec2) (int32, bool) {
+	if loc.X < 0 || loc.Y < 0 || loc.X >= f.CanvasWidth || loc.Y >= f.CanvasHeight {
+		return 0, false
+	}
+	tilesX := f.tilesX()
+	tx, ty := loc.X/f.TileWidth, loc.Y/f.TileHeight
+	if tx >= tilesX {
+		return 0, false
+	}
+	return ty*tilesX + tx, true
+}
+
+// LinkedPixels returns where loc is in the cels linked to loc's cel on layer
+func (f *File) LinkedPixels(layer *Layer, loc IntVec2) []IntVec2 {
+	if len(layer.CelLinks) == 0 {
+		return nil
+	}
+	frame, ok := f.frameOf(loc)
+	if !ok {
+		return nil
+	}
+	frames := layer.linkedFrames(frame)
+	if len(frames) == 0 {
+		return nil
+	}
+	tile := f.frameTile(frame)
+	locs := make([]IntVec2, 0, len(frames))
+	for _, linked := range frames {
+		linkedTile := f.frameTile(linked)
+		linkedLoc := IntVec2{linkedTile.X + loc.X - tile.X, linkedTile.Y + loc.Y - tile.Y}
+		if f.InCanvas(linkedLoc) {
+			locs = append(locs, linkedLoc)
+		}
+	}
+	return locs
+}
+
+// syncLinkedCels copies the cels which history changed into the cels linked
+// to them, the copied pixels are added to history. It's for the actions which
+// write pixels without DrawPixel, e.g. flipping or pasting. If several linked
+// cels changed, the root's pixels are kept, or else the first frame's
+func (f *File) syncLinkedCels(history HistoryPixel) {
+	if history.LayerIndex < 0 || history.LayerIndex >= int32(len(f.Layers)) {
+		return
+	}
+	layer := f.Layers[history.LayerIndex]
+	if len(layer.CelLinks) == 0 {
+		return
+	}
+
+	// The frame each root's links are copied from
+	sources := make(map[int32]int32)
+	for loc := range history.PixelState {
+		frame, ok := f.frameOf(loc)
+		if !ok || !layer.CelLinked(frame) {
+			continue
+		}
+		root := layer.CelRoot(frame)
+		if source, ok := sources[root]; !ok || frame == root || (source != root && frame < source) {
+			sources[root] = frame
+		}
+	}
+
+	for _, source := range sources {
+		tile := f.frameTile(source)
+		for y := int32(0); y < f.TileHeight; y++ {
+			for x := int32(0); x < f.TileWidth; x++ {
+				from := IntVec2{tile.X + x, tile.Y + y}
+				if !f.InCanvas(from) {
+					continue
+				}
+				color, ok := layer.PixelData[from]
+				if !ok {
+					color = rl.Blank
+				}
+				for _, to := range f.LinkedPixels(layer, from) {
+					old, ok := layer.PixelData[to]
+					if !ok {
+						old = rl.Blank
+					}
+					if old == color {
+						continue
+					}
+					ps, ok := history.PixelState[to]
+					if !ok {
+						ps.Prev = old
+					}
+					ps.Current = color
+					history.PixelState[to] = ps
+					layer.PixelData[to] = color
+				}
+			}
+		}
+	}
+}
+
+// LinkCel links the cel at frame on the layer to the cel at to, the cel's
+// pixels are replaced with to's. It's one history action
+func (f *File) LinkCel(layerIndex, frame, to int32) error {
+	if err := f.checkEditable("link cel"); err != nil {
+		return err
+	}
+	if layerIndex < 0 || layerIndex >= int32(len(f.Layers)) {
+		return fmt.Errorf("Can't link cel: layer %d doesn't exist", layerIndex)
+	}
+	count := f.frameCount()
+	if frame < 0 || frame >= count || to < 0 || to >= count {
+		return fmt.Errorf("Can't link cel: frame %d or %d is outside of the canvas", frame, to)
+	}
+	layer := f.Layers[layerIndex]
+	root := layer.CelRoot(to)
+	if layer.CelRoot(frame) == root {
+		return nil
+	}
+
+	f.BeginTransaction()
+	prev := copyCelLinks(layer.CelLinks)
+	layer.unlinkCel(frame)
+	tile := f.frameTile(root)
+	f.setFrame(layerIndex, frame, func(x, y int32) rl.Color {
+		if color, ok := layer.PixelData[IntVec2{tile.X + x, tile.Y + y}]; ok {
+			return color
+		}
+		return rl.Blank
+	})
+	if layer.CelLinks == nil {
+		layer.CelLinks = make(map[int32]int32)
+	}
+	layer.CelLinks[frame] = root
+	f.AppendHistory(HistoryCelLinks{layerIndex, prev, copyCelLinks(layer.CelLinks)})
+	f.EndTransaction()
+	f.FileChanged = true
+	return nil
+}
+
+// UnlinkCel stops the cel at frame on the layer sharing its pixels, it keeps
+// the pixels it has. If other cels were linked to it, they stay linked to each
+// other. It's added to the history
+func (f *File) UnlinkCel(layerIndex, frame int32) {
+	if f.ReadOnly {
+		return
+	}
+	if layerIndex < 0 || layerIndex >= int32(len(f.Layers)) {
+		return
+	}
+	layer := f.Layers[layerIndex]
+	if !layer.CelLinked(frame) {
+		return
+	}
+	prev := copyCelLinks(layer.CelLinks)
+	layer.unlinkCel(frame)
+	f.AppendHistory(HistoryCelLinks{layerIndex, prev, copyCelLinks(layer.CelLinks)})
+	f.FileChanged = true
+}
+
+// unlinkCel unlinks the cel without adding it to the history
+func (l *Layer) unlinkCel(frame int32) {
+	if !l.CelLinked(frame) {
+		return
+	}
+	if _, ok := l.CelLinks[frame]; ok {
+		delete(l.CelLinks, frame)
+	} else {
+		// The first of the frames linked to it takes its place
+		linked := l.linkedFrames(frame)
+		sort.Slice(linked, func(i, j int) bool { return linked[i] < linked[j] })
+		delete(l.CelLinks, linked[0])
+		for _, other := range linked[1:] {
+			l.CelLinks[other] = linked[0]
+		}
+	}
+}
+
+// setCelLinks sets the links of the layer when its history is undone or
+// redone
+func (f *File) setCelLinks(layerIndex int32, links map[int32]int32) {
+	if layerIndex < 0 || layerIndex >= int32(len(f.Layers)) {
+		return
+	}
+	f.Layers[layerIndex].CelLinks = copyCelLinks(links)
+}
+
+// remapCelLinks moves the links of every layer to the frames which move
+// returns, frames which are gone are dropped along with links left with one
+// cel. The changed links are added to the history
+func (f *File) remapCelLinks(move func(frame int32) (int32, bool)) {
+	for i, layer := range f.Layers {
+		if len(layer.CelLinks) == 0 {
+			continue
+		}
+		groups := make(map[int32][]int32)
+		for frame, root := range layer.CelLinks {
+			groups[root] = append(groups[root], frame)
+		}
+
+		links := make(map[int32]int32)
+		for root, frames := range groups {
+			sort.Slice(frames, func(a, b int) bool { return frames[a] < frames[b] })
+			// The root stays first so that it's still the root if it's kept
+			moved := make([]int32, 0, len(frames)+1)
+			for _, frame := range append([]int32{root}, frames...) {
+				if to, ok := move(frame); ok {
+					moved = append(moved, to)
+				}
+			}
+			if len(moved) < 2 {
+				continue
+			}
+			for _, frame := range moved[1:] {
+				links[frame] = moved[0]
+			}
+		}
+
+		same := len(links) == len(layer.CelLinks)
+		for frame, root := range links {
+			if r, ok := layer.CelLinks[frame]; !ok || r != root {
+				same = false
+				break
+			}
+		}
+		if same {
+			continue
+		}
+		prev := copyCelLinks(layer.CelLinks)
+		layer.CelLinks = copyCelLinks(links)
+		f.AppendHistory(HistoryCelLinks{int32(i), prev, copyCelLinks(links)})
+	}
+}
+
+// celsPainted returns the frames which have pixels on the layer
+func (f *File) celsPainted(layer *Layer) map[int32]bool {
+	painted := make(map[int32]bool)
+	for loc, color := range layer.PixelData {
+		if color.A == 0 {
+			continue
+		}
+		if frame, ok := f.frameOf(loc); ok {
+			painted[frame] = true
+		}
+	}
+	return painted
+}
+
+// copyCelLinks returns a copy of links, or nil if there aren't any
+func copyCelLinks(links map[int32]int32) map[int32]int32 {
+	if len(links) == 0 {
+		return nil
+	}
+	copied := make(map[int32]int32, len(links))
+	for frame, root := range links {
+		copied[frame] = root
+	}
+	return copied
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// ChunkSize is the width and height of a chunk in pixels
+const ChunkSize = 64
+
+// Chunks is a layer's texture split into ChunkSize*ChunkSize textures. Chunks
+// are only loaded once something is drawn in them, so the blank parts of big
+// canvases don't use any memory and aren't redrawn
+type Chunks struct {
+	Width, Height int32
+
+	textures map[IntVec2]rl.RenderTexture2D
+}
+
+// NewChunks returns chunks for a width*height layer, none are loaded yet
+func NewChunks(width, height int32) *Chunks {
+	return &Chunks{
+		Width:    width,
+		Height:   height,
+		textures: make(map[IntVec2]rl.RenderTexture2D),
+	}
+}
+
+// ChunkOf returns the chunk holding the pixel at loc
+func ChunkOf(loc IntVec2) IntVec2 {
+	return IntVec2{loc.X / ChunkSize, loc.Y / ChunkSize}
+}
+
+// Count returns how many chunks are loaded
+func (c *Chunks) Count() int {
+	return len(c.textures)
+}
+
+// BeginPixel begins texture mode on the chunk holding x, y and returns where
+// x, y is in it. The chunk is loaded if it isn't and create is true, ok is
+// false if it wasn't. EndTextureMode has to be called if ok is true
+func (c *Chunks) BeginPixel(x, y int32, create bool) (cx, cy int32, ok bool) {
+	chunk := ChunkOf(IntVec2{x, y})
+	texture, ok := c.textures[chunk]
+	if !ok {
+		if !create {
+			return 0, 0, false
+		}
+		texture = rl.LoadRenderTexture(ChunkSize, ChunkSize)
+		rl.BeginTextureMode(texture)
+		rl.ClearBackground(rl.Blank)
+		rl.EndTextureMode()
+		c.textures[chunk] = texture
+	}
+	rl.BeginTextureMode(texture)
+	return x - chunk.X*ChunkSize, y - chunk.Y*ChunkSize, true
+}
+
+// Redraw draws pixels again. The loaded chunks are drawn over in place, the
+// ones left without a visible pixel are unloaded
+func (c *Chunks) Redraw(pixels map[IntVec2]rl.Color) {
+	byChunk := make(map[IntVec2][]IntVec2)
+	for loc, color := range pixels {
+		if color.A == 0 || loc.X < 0 || loc.Y < 0 || loc.X >= c.Width || loc.Y >= c.Height {
+			continue
+		}
+		chunk := ChunkOf(loc)
+		byChunk[chunk] = append(byChunk[chunk], loc)
+	}
+	for chunk, texture := range c.textures {
+		if _, ok := byChunk[chunk]; !ok {
+			rl.UnloadRenderTexture(texture)
+			delete(c.textures, chunk)
+		}
+	}
+	for chunk, locs := range byChunk {
+		c.drawChunk(chunk, locs, pixels)
+	}
+}
+
+// RedrawChunks draws only the chunks again, e.g. the ones an undo changed
+func (c *Chunks) RedrawChunks(pixels map[IntVec2]rl.Color, chunks map[IntVec2]bool) {
+	for chunk := range chunks {
+		locs := make([]IntVec2, 0)
+		for y := chunk.Y * ChunkSize; y < MinInt32((chunk.Y+1)*ChunkSize, c.Height); y++ {
+			for x := chunk.X * ChunkSize; x < MinInt32((chunk.X+1)*ChunkSize, c.Width); x++ {
+				if color, ok := pixels[IntVec2{x, y}]; ok && color.A > 0 {
+					locs = append(locs, IntVec2{x, y})
+				}
+			}
+		}
+		if len(locs) > 0 {
+			c.drawChunk(chunk, locs, pixels)
+		} else if texture, ok := c.textures[chunk]; ok {
+			rl.UnloadRenderTexture(texture)
+			delete(c.textures, chunk)
+		}
+	}
+}
+
+// drawChunk clears the chunk and draws the pixels at locs in it, the chunk is
+// loaded if it isn't
+func (c *Chunks) drawChunk(chunk IntVec2, locs []IntVec2, pixels map[IntVec2]rl.Color) {
+	texture, ok := c.textures[chunk]
+	if !ok {
+		texture = rl.LoadRenderTexture(ChunkSize, ChunkSize)
+		c.textures[chunk] = texture
+	}
+	rl.BeginTextureMode(texture)
+	rl.ClearBackground(rl.Blank)
+	for _, loc := range locs {
+		rl.DrawPixel(loc.X-chunk.X*ChunkSize, loc.Y-chunk.Y*ChunkSize, pixels[loc])
+	}
+	rl.EndTextureMode()
+}
+
+// ChunksOf returns the chunks which hold the pixels in pixelState
+func ChunksOf(pixelState map[IntVec2]PixelStateData) map[IntVec2]bool {
+	chunks := make(map[IntVec2]bool)
+	for loc := range pixelState {
+		chunks[ChunkOf(loc)] = true
+	}
+	return chunks
+}
+
+// DrawRegion draws the part of the layer in src, in pixels, scaled to dest.
+// Only the loaded chunks in src are drawn
+func (c *Chunks) DrawRegion(src, dest rl.Rectangle, tint rl.Color) {
+	x0, y0 := MaxInt32(0, int32(src.X)/ChunkSize), MaxInt32(0, int32(src.Y)/ChunkSize)
+	x1 := MinInt32((c.Width-1)/ChunkSize, int32(src.X+src.Width)/ChunkSize)
+	y1 := MinInt32((c.Height-1)/ChunkSize, int32(src.Y+src.Height)/ChunkSize)
+	for cy := y0; cy <= y1; cy++ {
+		for cx := x0; cx <= x1; cx++ {
+			if texture, ok := c.textures[IntVec2{cx, cy}]; ok {
+				drawTextureRegion(texture, IntVec2{cx * ChunkSize, cy * ChunkSize}, src, dest, tint)
+			}
+		}
+	}
+}
+
+// drawTextureRegion draws the part of src, in pixels, which texture covers
+// scaled to dest. The texture's top left is at origin. Render textures are
+// upside down, so they're flipped back
+func drawTextureRegion(texture rl.RenderTexture2D, origin IntVec2, src, dest rl.Rectangle, tint rl.Color) {
+	if src.Width <= 0 || src.Height <= 0 {
+		return
+	}
+	width, height := float32(texture.Texture.Width), float32(texture.Texture.Height)
+	ox, oy := float32(origin.X), float32(origin.Y)
+	x0, y0 := rl.Clamp(src.X, ox, ox+width), rl.Clamp(src.Y, oy, oy+height)
+	x1, y1 := rl.Clamp(src.X+src.Width, ox, ox+width), rl.Clamp(src.Y+src.Height, oy, oy+height)
+	if x1 <= x0 || y1 <= y0 {
+		return
+	}
+	sx, sy := dest.Width/src.Width, dest.Height/src.Height
+	rl.DrawTexturePro(texture.Texture,
+		rl.NewRectangle(x0-ox, height-(y1-oy), x1-x0, -(y1-y0)),
+		rl.NewRectangle(dest.X+(x0-src.X)*sx, dest.Y+(y0-src.Y)*sy, (x1-x0)*sx, (y1-y0)*sy),
+		rl.NewVector2(0, 0),
+		0,
+		tint)
+}
+
+// Draw draws the chunks scaled to fit dest
+func (c *Chunks) Draw(dest rl.Rectangle, tint rl.Color) {
+	sx := dest.Width / float32(c.Width)
+	sy := dest.Height / float32(c.Height)
+	for chunk, texture := range c.textures {
+		rl.DrawTexturePro(texture.Texture,
+			rl.NewRectangle(0, 0, ChunkSize, -ChunkSize),
+			rl.NewRectangle(
+				dest.X+float32(chunk.X*ChunkSize)*sx,
+				dest.Y+float32(chunk.Y*ChunkSize)*sy,
+				ChunkSize*sx,
+				ChunkSize*sy,
+			),
+			rl.NewVector2(0, 0),
+			0,
+			tint)
+	}
+}
+
+// Unload unloads every chunk
+func (c *Chunks) Unload() {
+	for chunk, texture := range c.textures {
+		rl.UnloadRenderTexture(texture)
+		delete(c.textures, chunk)
+	}
+}
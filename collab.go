@@ -0,0 +1,711 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"golang.org/x/net/websocket"
+)
+
+// CollabDefaultAddress is used when hosting, and when the joined address
+// doesn't have a port
+const CollabDefaultAddress = ":7777"
+
+// collabPath is where the session's WebSocket is served
+const collabPath = "/collab"
+
+// collabMaxMessage is the largest message which is received, checkpoints of
+// big canvases are bigger than the default
+const collabMaxMessage = 256 << 20
+
+// CollabMessageKind is the type of a CollabMessage
+type CollabMessageKind int32
+
+// CollabMessageKinds
+const (
+	// CollabMessageCheckpoint replaces the whole file
+	CollabMessageCheckpoint CollabMessageKind = iota
+	// CollabMessagePixels sets pixels of a single layer, from a HistoryPixel
+	CollabMessagePixels
+	// CollabMessageLayerCreate inserts a layer at LayerIndex
+	CollabMessageLayerCreate
+	// CollabMessageLayerDelete deletes the layer at LayerIndex
+	CollabMessageLayerDelete
+	// CollabMessageLayerMove moves the layer at LayerIndex to To
+	CollabMessageLayerMove
+)
+
+// CollabLayer is a layer in a checkpoint or a created layer
+type CollabLayer struct {
+	Name      string
+	Hidden    bool
+	PixelData map[IntVec2]rl.Color
+}
+
+// CollabMessage is sent between the peers. Stamp is when the change was made
+// and is used to keep the newest change when both peers change the same pixel
+type CollabMessage struct {
+	Kind  CollabMessageKind
+	Stamp int64
+
+	// Checkpoint
+	CanvasWidth, CanvasHeight int32
+	TileWidth, TileHeight     int32
+	Layers                    []CollabLayer
+
+	// History actions
+	LayerIndex int32
+	To         int32
+	Layer      CollabLayer
+	Pixels     map[IntVec2]rl.Color
+}
+
+// collabCodec sends every message as one binary WebSocket message
+var collabCodec = websocket.Codec{
+	Marshal: func(v interface{}) ([]byte, byte, error) {
+		var buf bytes.Buffer
+		err := gob.NewEncoder(&buf).Encode(v)
+		return buf.Bytes(), websocket.BinaryFrame, err
+	},
+	Unmarshal: func(data []byte, payloadType byte, v interface{}) error {
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+	},
+}
+
+// collabAction is a history action, or its undo, waiting to be sent. Layer
+// indexes are the ones when it was done, the peer applies them in order
+type collabAction struct {
+	kind       CollabMessageKind
+	index, to  int32
+	layer      *Layer
+	pixelState map[IntVec2]PixelStateData
+}
+
+// collabLayout is the part of a file which isn't sent as history actions, it's
+// what the peer should have. Any other change to it sends a checkpoint
+type collabLayout struct {
+	CanvasWidth, CanvasHeight int32
+	TileWidth, TileHeight     int32
+	Names                     []string
+	Hidden                    []bool
+}
+
+// CollabSession is an experimental session where two instances edit the same
+// file over a WebSocket. The host sends a checkpoint of its file when the peer
+// connects, after that both sides send their history actions as they're done
+// and undone. Conflicts are resolved by keeping the last write
+type CollabSession struct {
+	File   *File
+	IsHost bool
+	Status string
+
+	listener  net.Listener
+	conn      *websocket.Conn
+	accepted  int32
+	connected chan *websocket.Conn
+	incoming  chan CollabMessage
+	outgoing  chan CollabMessage
+	errors    chan error
+	// done is closed when the session ends, it stops the goroutines
+	done chan struct{}
+
+	pending     []collabAction
+	stamps      map[*Layer]map[IntVec2]int64
+	layout      collabLayout
+	layoutStamp int64
+}
+
+// Collab is the current session, nil if there isn't one
+var Collab *CollabSession
+
+func init() {
+	gob.Register(IntVec2{})
+	gob.Register(rl.Color{})
+}
+
+func newCollabSession(file *File, isHost bool) *CollabSession {
+	return &CollabSession{
+		File:      file,
+		IsHost:    isHost,
+		connected: make(chan *websocket.Conn, 1),
+		incoming:  make(chan CollabMessage, 64),
+		outgoing:  make(chan CollabMessage, 64),
+		errors:    make(chan error, 2),
+		done:      make(chan struct{}),
+		stamps:    make(map[*Layer]map[IntVec2]int64),
+	}
+}
+
+// CollabHost starts a session with the current file and waits for a peer
+func CollabHost(address string) {
+	CollabLeave()
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Println(err)
+		PlaySoundCue(SoundError)
+		return
+	}
+
+	s := newCollabSession(CurrentFile, true)
+	s.listener = listener
+	s.Status = Tf("waiting for peer on %s", listener.Addr().String())
+	Collab = s
+
+	mux := http.NewServeMux()
+	mux.Handle(collabPath, websocket.Handler(func(conn *websocket.Conn) {
+		// Only one peer is supported
+		if !atomic.CompareAndSwapInt32(&s.accepted, 0, 1) {
+			return
+		}
+		select {
+		case s.connected <- conn:
+		case <-s.done:
+			return
+		}
+		// The connection is closed when the handler returns
+		<-s.done
+	}))
+	go func() {
+		// The listener is closed once the peer has connected
+		if err := http.Serve(listener, mux); err != nil && atomic.LoadInt32(&s.accepted) == 0 {
+			s.fail(err)
+		}
+	}()
+}
+
+// CollabJoin connects to a host, the host's file is opened as a new file
+func CollabJoin(address string) {
+	CollabLeave()
+
+	if !strings.Contains(address, ":") {
+		address += CollabDefaultAddress
+	}
+
+	s := newCollabSession(nil, false)
+	s.Status = Tf("connecting to %s", address)
+	Collab = s
+
+	go func() {
+		config, err := websocket.NewConfig("ws://"+address+collabPath, "http://localhost/")
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		config.Dialer = &net.Dialer{Timeout: time.Second * 10}
+		conn, err := websocket.DialConfig(config)
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		select {
+		case s.connected <- conn:
+		case <-s.done:
+			conn.Close()
+		}
+	}()
+}
+
+// CollabLeave ends the session, the file is kept
+func CollabLeave() {
+	if Collab == nil {
+		return
+	}
+	Collab.close()
+	Collab = nil
+}
+
+// close closes the connection and the channels, the goroutines return
+func (s *CollabSession) close() {
+	close(s.done)
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	close(s.outgoing)
+}
+
+// fail reports err from a goroutine, unless the session has ended
+func (s *CollabSession) fail(err error) {
+	select {
+	case s.errors <- err:
+	case <-s.done:
+	}
+}
+
+// start starts reading and writing messages
+func (s *CollabSession) start(conn *websocket.Conn) {
+	s.conn = conn
+	s.conn.MaxPayloadBytes = collabMaxMessage
+	if s.listener != nil {
+		// Only one peer is supported
+		s.listener.Close()
+		s.listener = nil
+	}
+	if s.IsHost {
+		s.Status = Tf("connected to %s", conn.Request().RemoteAddr)
+	} else {
+		s.Status = Tf("connected to %s", conn.Config().Location.Host)
+	}
+
+	go func() {
+		for {
+			var msg CollabMessage
+			if err := collabCodec.Receive(conn, &msg); err != nil {
+				s.fail(err)
+				return
+			}
+			select {
+			case s.incoming <- msg:
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	go func() {
+		for msg := range s.outgoing {
+			if err := collabCodec.Send(conn, msg); err != nil {
+				s.fail(err)
+				return
+			}
+		}
+	}()
+
+	if s.IsHost {
+		s.sendCheckpoint()
+	}
+}
+
+// CollabUpdate applies the peer's changes and sends local ones, it's called
+// every frame
+func CollabUpdate() {
+	s := Collab
+	if s == nil {
+		return
+	}
+	if s.File != nil && !s.fileIsOpen() {
+		CollabLeave()
+		return
+	}
+
+	select {
+	case conn := <-s.connected:
+		s.start(conn)
+	case err := <-s.errors:
+		log.Println(err)
+		PlaySoundCue(SoundError)
+		CollabLeave()
+		return
+	default:
+	}
+
+	for done := false; !done; {
+		select {
+		case msg := <-s.incoming:
+			s.apply(msg)
+		default:
+			done = true
+		}
+	}
+
+	if s.File == nil || s.conn == nil {
+		return
+	}
+	// Wait until strokes, selections and transactions are finished, their
+	// history actions are still being filled in
+	f := s.File
+	if !f.HasDoneMouseUpLeft || !f.HasDoneMouseUpRight || f.SelectionMoving || f.InTransaction() {
+		return
+	}
+	s.sendChanges()
+}
+
+func (s *CollabSession) fileIsOpen() bool {
+	for _, file := range Files {
+		if file == s.File {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *CollabSession) currentLayout() collabLayout {
+	f := s.File
+	layout := collabLayout{
+		CanvasWidth:  f.CanvasWidth,
+		CanvasHeight: f.CanvasHeight,
+		TileWidth:    f.TileWidth,
+		TileHeight:   f.TileHeight,
+		Names:        make([]string, 0, len(f.Layers)),
+		Hidden:       make([]bool, 0, len(f.Layers)),
+	}
+	for _, layer := range f.Layers {
+		layout.Names = append(layout.Names, layer.Name)
+		layout.Hidden = append(layout.Hidden, layer.Hidden)
+	}
+	return layout
+}
+
+// equal returns true if the layouts are the same
+func (l collabLayout) equal(o collabLayout) bool {
+	if l.CanvasWidth != o.CanvasWidth || l.CanvasHeight != o.CanvasHeight ||
+		l.TileWidth != o.TileWidth || l.TileHeight != o.TileHeight || len(l.Names) != len(o.Names) {
+		return false
+	}
+	for i := range l.Names {
+		if l.Names[i] != o.Names[i] || l.Hidden[i] != o.Hidden[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// apply changes the layout like the peer will when it applies msg. It returns
+// false if msg doesn't fit the layout, the peer needs a checkpoint then
+func (l *collabLayout) apply(msg CollabMessage) bool {
+	last := int32(len(l.Names))
+	switch msg.Kind {
+	case CollabMessageLayerCreate:
+		if msg.LayerIndex < 0 || msg.LayerIndex > last {
+			return false
+		}
+		l.Names = append(l.Names[:msg.LayerIndex], append([]string{msg.Layer.Name}, l.Names[msg.LayerIndex:]...)...)
+		l.Hidden = append(l.Hidden[:msg.LayerIndex], append([]bool{msg.Layer.Hidden}, l.Hidden[msg.LayerIndex:]...)...)
+	case CollabMessageLayerDelete:
+		if msg.LayerIndex < 0 || msg.LayerIndex >= last || last <= 1 {
+			return false
+		}
+		l.Names = append(l.Names[:msg.LayerIndex], l.Names[msg.LayerIndex+1:]...)
+		l.Hidden = append(l.Hidden[:msg.LayerIndex], l.Hidden[msg.LayerIndex+1:]...)
+	case CollabMessageLayerMove:
+		if msg.LayerIndex < 0 || msg.LayerIndex >= last || msg.To < 0 || msg.To >= last {
+			return false
+		}
+		name, hidden := l.Names[msg.LayerIndex], l.Hidden[msg.LayerIndex]
+		l.Names = append(l.Names[:msg.LayerIndex], l.Names[msg.LayerIndex+1:]...)
+		l.Hidden = append(l.Hidden[:msg.LayerIndex], l.Hidden[msg.LayerIndex+1:]...)
+		l.Names = append(l.Names[:msg.To], append([]string{name}, l.Names[msg.To:]...)...)
+		l.Hidden = append(l.Hidden[:msg.To], append([]bool{hidden}, l.Hidden[msg.To:]...)...)
+	case CollabMessagePixels:
+		return msg.LayerIndex >= 0 && msg.LayerIndex < last
+	}
+	return true
+}
+
+// collabRecord queues a history action of f to be sent to the peer. undone is
+// true if the action was undone rather than done or redone
+func collabRecord(f *File, action interface{}, undone bool) {
+	s := Collab
+	if s == nil || s.File != f || s.conn == nil {
+		return
+	}
+	s.record(action, undone)
+}
+
+func (s *CollabSession) record(action interface{}, undone bool) {
+	f := s.File
+	switch typed := action.(type) {
+	case CompoundHistory:
+		if undone {
+			for i := len(typed.Actions) - 1; i >= 0; i-- {
+				s.record(typed.Actions[i], undone)
+			}
+			return
+		}
+		for _, a := range typed.Actions {
+			s.record(a, undone)
+		}
+	case HistoryPixel:
+		if typed.LayerIndex < 0 || typed.LayerIndex >= int32(len(f.Layers)) {
+			return
+		}
+		pixelState := typed.PixelState
+		if undone {
+			// It can be released before it's sent, e.g. by drawing after
+			// undoing. Done actions are still being filled in
+			pixelState = make(map[IntVec2]PixelStateData, len(typed.PixelState))
+			for loc, ps := range typed.PixelState {
+				pixelState[loc] = ps
+			}
+		}
+		s.pending = append(s.pending, collabAction{
+			kind:       CollabMessagePixels,
+			index:      typed.LayerIndex,
+			layer:      f.Layers[typed.LayerIndex],
+			pixelState: pixelState,
+		})
+	case HistoryLayer:
+		created := typed.HistoryLayerAction == HistoryLayerActionCreate
+		if undone {
+			created = !created
+		}
+		kind := CollabMessageLayerDelete
+		if created {
+			kind = CollabMessageLayerCreate
+		}
+		s.pending = append(s.pending, collabAction{kind: kind, index: typed.LayerIndex, layer: typed.Layer})
+	case HistoryLayerMove:
+		from, to := typed.From, typed.To
+		if undone {
+			from, to = to, from
+		}
+		s.pending = append(s.pending, collabAction{kind: CollabMessageLayerMove, index: from, to: to})
+	}
+}
+
+// copyPixels returns a copy of the layer's pixels
+func copyPixels(layer *Layer) map[IntVec2]rl.Color {
+	pixels := make(map[IntVec2]rl.Color, len(layer.PixelData))
+	for loc, color := range layer.PixelData {
+		pixels[loc] = color
+	}
+	return pixels
+}
+
+// layerStamps returns when each pixel of the layer was last changed
+func (s *CollabSession) layerStamps(layer *Layer) map[IntVec2]int64 {
+	stamps, ok := s.stamps[layer]
+	if !ok {
+		stamps = make(map[IntVec2]int64)
+		s.stamps[layer] = stamps
+	}
+	return stamps
+}
+
+func (s *CollabSession) send(msg CollabMessage) bool {
+	select {
+	case s.outgoing <- msg:
+		return true
+	default:
+		// The peer isn't keeping up, a checkpoint will be sent instead
+		s.layout = collabLayout{}
+		return false
+	}
+}
+
+func (s *CollabSession) sendCheckpoint() {
+	stamp := time.Now().UnixNano()
+	f := s.File
+	msg := CollabMessage{
+		Kind:         CollabMessageCheckpoint,
+		Stamp:        stamp,
+		CanvasWidth:  f.CanvasWidth,
+		CanvasHeight: f.CanvasHeight,
+		TileWidth:    f.TileWidth,
+		TileHeight:   f.TileHeight,
+	}
+	for _, layer := range s.File.Layers {
+		msg.Layers = append(msg.Layers, CollabLayer{Name: layer.Name, Hidden: layer.Hidden, PixelData: copyPixels(layer)})
+	}
+	s.resetStamps(stamp)
+	s.pending = nil
+	s.send(msg)
+}
+
+// resetStamps marks every pixel as changed at stamp
+func (s *CollabSession) resetStamps(stamp int64) {
+	s.stamps = make(map[*Layer]map[IntVec2]int64, len(s.File.Layers))
+	for _, layer := range s.File.Layers {
+		stamps := s.layerStamps(layer)
+		for loc := range layer.PixelData {
+			stamps[loc] = stamp
+		}
+	}
+	s.layout = s.currentLayout()
+	s.layoutStamp = stamp
+}
+
+// sendChanges sends the history actions which were done or undone since the
+// last time, or a checkpoint if the layout changed some other way
+func (s *CollabSession) sendChanges() {
+	stamp := time.Now().UnixNano()
+	pending := s.pending
+	s.pending = nil
+	for _, action := range pending {
+		msg := CollabMessage{Kind: action.kind, Stamp: stamp, LayerIndex: action.index, To: action.to}
+		switch action.kind {
+		case CollabMessagePixels:
+			if len(action.pixelState) == 0 {
+				continue
+			}
+			// The pixels are sent as they are now, they could have changed
+			// again since
+			stamps := s.layerStamps(action.layer)
+			msg.Pixels = make(map[IntVec2]rl.Color, len(action.pixelState))
+			for loc := range action.pixelState {
+				color, ok := action.layer.PixelData[loc]
+				if !ok {
+					color = rl.Blank
+				}
+				msg.Pixels[loc] = color
+				stamps[loc] = stamp
+			}
+		case CollabMessageLayerCreate:
+			if action.layer == nil {
+				continue
+			}
+			msg.Layer = CollabLayer{Name: action.layer.Name, Hidden: action.layer.Hidden, PixelData: copyPixels(action.layer)}
+		}
+		if !s.layout.apply(msg) {
+			s.sendCheckpoint()
+			return
+		}
+		if !s.send(msg) {
+			break
+		}
+	}
+
+	if !s.layout.equal(s.currentLayout()) {
+		s.sendCheckpoint()
+	}
+}
+
+// newer returns true if the peer's change at stamp wins against the local one
+func (s *CollabSession) newer(stamp, local int64) bool {
+	if stamp == local {
+		return !s.IsHost
+	}
+	return stamp > local
+}
+
+func (s *CollabSession) apply(msg CollabMessage) {
+	if msg.Kind == CollabMessageCheckpoint {
+		if s.File == nil {
+			s.File = NewFile(msg.CanvasWidth, msg.CanvasHeight, msg.TileWidth, msg.TileHeight)
+			s.File.Filename = T("shared") + ".pix"
+			Files = append(Files, s.File)
+			CurrentFile = s.File
+			EditorsUIRebuild()
+		} else if !s.newer(msg.Stamp, s.layoutStamp) {
+			// Our checkpoint is newer and has been sent to the peer
+			return
+		}
+		s.applyCheckpoint(msg)
+		s.resetStamps(msg.Stamp)
+		return
+	}
+	if s.File == nil {
+		return
+	}
+
+	f := s.File
+	last := int32(len(f.Layers)) - 1
+	switch msg.Kind {
+	case CollabMessagePixels:
+		if msg.LayerIndex < 0 || msg.LayerIndex > last {
+			return
+		}
+		layer := f.Layers[msg.LayerIndex]
+		stamps := s.layerStamps(layer)
+		changed := false
+		for loc, color := range msg.Pixels {
+			if !s.newer(msg.Stamp, stamps[loc]) {
+				continue
+			}
+			layer.PixelData[loc] = color
+			stamps[loc] = msg.Stamp
+			changed = true
+		}
+		if changed {
+			layer.Redraw()
+			f.RedrawRenderLayer()
+		}
+	case CollabMessageLayerCreate:
+		layer := NewLayer(f.CanvasWidth, f.CanvasHeight, msg.Layer.Name, rl.Blank, true)
+		layer.Hidden = msg.Layer.Hidden
+		if msg.Layer.PixelData != nil {
+			layer.PixelData = msg.Layer.PixelData
+		}
+		stamps := s.layerStamps(layer)
+		for loc := range layer.PixelData {
+			stamps[loc] = msg.Stamp
+		}
+		if err := f.RestoreLayer(msg.LayerIndex, layer); err != nil {
+			log.Println(err)
+		}
+	case CollabMessageLayerDelete:
+		if msg.LayerIndex < 0 || msg.LayerIndex > last {
+			return
+		}
+		if err := f.DeleteLayer(msg.LayerIndex, false); err != nil {
+			log.Println(err)
+		}
+		f.RedrawRenderLayer()
+	case CollabMessageLayerMove:
+		if err := f.MoveLayer(msg.LayerIndex, msg.To, false); err != nil {
+			log.Println(err)
+		}
+	}
+	s.layout = s.currentLayout()
+	if f == CurrentFile {
+		LayersUIRebuildList()
+	}
+}
+
+// applyCheckpoint replaces the layers of the file. The layers are changed in
+// place and the history is kept, so that the peer can still undo its changes
+func (s *CollabSession) applyCheckpoint(msg CollabMessage) {
+	f := s.File
+	if f.CanvasWidth != msg.CanvasWidth || f.CanvasHeight != msg.CanvasHeight {
+		for _, layer := range f.Layers {
+			layer.ResizeOffset(msg.CanvasWidth, msg.CanvasHeight, 0, 0)
+		}
+		f.RenderLayer.ResizeOffset(msg.CanvasWidth, msg.CanvasHeight, 0, 0)
+	}
+	f.CanvasWidth = msg.CanvasWidth
+	f.CanvasHeight = msg.CanvasHeight
+	f.TileWidth = msg.TileWidth
+	f.TileHeight = msg.TileHeight
+
+	if len(msg.Layers) == 0 {
+		msg.Layers = []CollabLayer{{Name: T("background")}}
+	}
+	for i, l := range msg.Layers {
+		var layer *Layer
+		if i < len(f.Layers) {
+			layer = f.Layers[i]
+		} else {
+			layer = NewLayer(msg.CanvasWidth, msg.CanvasHeight, l.Name, rl.Blank, true)
+			f.Layers = append(f.Layers, layer)
+		}
+		layer.Name = l.Name
+		layer.Hidden = l.Hidden
+		layer.PixelData = l.PixelData
+		if layer.PixelData == nil {
+			layer.PixelData = make(map[IntVec2]rl.Color)
+		}
+		layer.Redraw()
+	}
+	// The layers which the peer doesn't have are kept for the history
+	removed := f.Layers[len(msg.Layers):]
+	f.Layers = f.Layers[:len(msg.Layers)]
+	for _, layer := range removed {
+		referenced := false
+		for _, action := range f.History {
+			if historyReferencesLayer(action, layer) {
+				referenced = true
+				break
+			}
+		}
+		if !referenced {
+			layer.Unload()
+		}
+	}
+	if f.CurrentLayer > int32(len(f.Layers))-1 {
+		f.CurrentLayer = int32(len(f.Layers)) - 1
+	}
+
+	f.RedrawRenderLayer()
+	if f == CurrentFile {
+		LayersUIRebuildList()
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// colorUsageListed is how many colors the histogram lists, the rest are only
+// counted
+const colorUsageListed = 40
+
+var (
+	colorUsageShader       rl.Shader
+	colorUsageShaderLoaded bool
+	colorUsageTargetLoc    int32
+)
+
+// colorUsageFragmentShader shows the pixels of the target color in red and
+// everything else dark and gray, the rest is raylib's default fragment shader
+const colorUsageFragmentShader = `#version 330
+in vec2 fragTexCoord;
+in vec4 fragColor;
+uniform sampler2D texture0;
+uniform vec4 colDiffuse;
+uniform vec4 target;
+out vec4 finalColor;
+
+void main() {
+	vec4 c = texture(texture0, fragTexCoord)*colDiffuse*fragColor;
+	vec4 d = abs(c - target);
+	if (max(max(d.r, d.g), max(d.b, d.a)) < 0.5/255.0) {
+		finalColor = vec4(1.0, 0.0, 0.0, 1.0);
+	} else {
+		float gray = dot(c.rgb, vec3(0.299, 0.587, 0.114))*0.35;
+		finalColor = vec4(gray, gray, gray, c.a);
+	}
+}
+`
+
+// beginColorUsage starts drawing with the pixels of LeftColor highlighted
+func beginColorUsage() {
+	if !colorUsageShaderLoaded {
+		colorUsageShader = rl.LoadShaderFromMemory("", colorUsageFragmentShader)
+		colorUsageTargetLoc = rl.GetShaderLocation(colorUsageShader, "target")
+		colorUsageShaderLoaded = true
+	}
+	target := []float32{
+		float32(LeftColor.R) / 255,
+		float32(LeftColor.G) / 255,
+		float32(LeftColor.B) / 255,
+		float32(LeftColor.A) / 255,
+	}
+	rl.SetShaderValue(colorUsageShader, colorUsageTargetLoc, target, rl.ShaderUniformVec4)
+	rl.BeginShaderMode(colorUsageShader)
+}
+
+// unloadColorUsage unloads the shader
+func unloadColorUsage() {
+	if colorUsageShaderLoaded {
+		rl.UnloadShader(colorUsageShader)
+		colorUsageShaderLoaded = false
+	}
+}
+
+// ColorCount is how many visible pixels have Color
+type ColorCount struct {
+	Color  rl.Color
+	Pixels int
+}
+
+// ColorHistogram returns how many pixels of each color the visible layers
+// have, the most used first. Transparent pixels aren't counted
+func (f *File) ColorHistogram() []ColorCount {
+	counts := make(map[rl.Color]int)
+	for y := int32(0); y < f.CanvasHeight; y++ {
+		for x := int32(0); x < f.CanvasWidth; x++ {
+			if color := f.CompositePixel(IntVec2{x, y}); color.A > 0 {
+				counts[color]++
+			}
+		}
+	}
+
+	histogram := make([]ColorCount, 0, len(counts))
+	for color, pixels := range counts {
+		histogram = append(histogram, ColorCount{color, pixels})
+	}
+	sort.Slice(histogram, func(i, j int) bool {
+		if histogram[i].Pixels != histogram[j].Pixels {
+			return histogram[i].Pixels > histogram[j].Pixels
+		}
+		return ColorToHex(histogram[i].Color) < ColorToHex(histogram[j].Color)
+	})
+	return histogram
+}
+
+// ShowColorHistogram lists the colors of the current file by how often
+// they're used, colors which aren't in the palette are marked
+func ShowColorHistogram() {
+	histogram := CurrentFile.ColorHistogram()
+	if len(histogram) == 0 {
+		UIInfo(T("Color Usage"), T("The visible layers are empty"))
+		return
+	}
+
+	inPalette := make(map[rl.Color]bool)
+	if CurrentFile.CurrentPalette >= 0 && CurrentFile.CurrentPalette < int32(len(Settings.PaletteData)) {
+		for _, color := range Settings.PaletteData[CurrentFile.CurrentPalette].data {
+			inPalette[color] = true
+		}
+	}
+
+	var report strings.Builder
+	report.WriteString(Tf("%d colors, * isn't in the palette:", len(histogram)))
+	for i, count := range histogram {
+		if i == colorUsageListed {
+			report.WriteString("\n")
+			report.WriteString(Tf("%d more", len(histogram)-colorUsageListed))
+			break
+		}
+		report.WriteString("\n")
+		report.WriteString(fmt.Sprintf("#%s: %d", ColorToHex(count.Color), count.Pixels))
+		if !inPalette[count.Color] {
+			report.WriteString(" *")
+		}
+	}
+	UIInfo(T("Color Usage"), report.String())
+}
@@ -0,0 +1,94 @@
+package main
+
+// dirtyTiles are the tiles which have changed since the preview last drew the
+// file, or since the tile colors were checked. Tiles are in tile coordinates,
+// not pixels
+type dirtyTiles struct {
+	tiles map[IntVec2]struct{}
+	// all is true when the whole canvas changed
+	all bool
+}
+
+// mark marks the tile as changed
+func (d *dirtyTiles) mark(tile IntVec2) {
+	if d.all {
+		return
+	}
+	if d.tiles == nil {
+		d.tiles = make(map[IntVec2]struct{})
+	}
+	d.tiles[tile] = struct{}{}
+}
+
+// take returns the tiles which changed and clears them
+func (d *dirtyTiles) take() (tiles map[IntVec2]struct{}, all bool) {
+	tiles, all = d.tiles, d.all
+	*d = dirtyTiles{}
+	return tiles, all
+}
+
+// MarkTileDirty marks the tile and the color tile holding the pixel at loc as
+// changed
+func (f *File) MarkTileDirty(loc IntVec2) {
+	f.exportPreviewStale = true
+	f.dirty.mark(IntVec2{loc.X / f.TileWidth, loc.Y / f.TileHeight})
+	width, height := f.ColorTileSize()
+	f.colorDirty.mark(IntVec2{loc.X / width, loc.Y / height})
+}
+
+// MarkAllTilesDirty marks every tile as changed
+func (f *File) MarkAllTilesDirty() {
+	f.exportPreviewStale = true
+	f.dirty = dirtyTiles{all: true}
+	f.colorDirty = dirtyTiles{all: true}
+}
+
+// TakeDirtyTiles returns the tiles which changed and clears them. all is true
+// if every tile changed
+func (f *File) TakeDirtyTiles() (tiles map[IntVec2]struct{}, all bool) {
+	return f.dirty.take()
+}
+
+// TakeDirtyColorTiles returns the color tiles which changed and clears them.
+// all is true if every tile changed
+func (f *File) TakeDirtyColorTiles() (tiles map[IntVec2]struct{}, all bool) {
+	return f.colorDirty.take()
+}
+
+// tilesX is how many tiles there are in a row
+func (f *File) tilesX() int32 {
+	return MaxInt32(1, f.CanvasWidth/f.TileWidth)
+}
+
+// UsesTile returns true if the tile is one of the animation's frames
+func (a *Animation) UsesTile(tile IntVec2, tilesX int32) bool {
+	frame := tile.Y*tilesX + tile.X
+	return frame >= a.FrameStart && frame <= a.FrameEnd
+}
+
+// AnimationsUsingTiles returns the animations which have any of the tiles as
+// a frame, they have to be previewed again
+func (f *File) AnimationsUsingTiles(tiles map[IntVec2]struct{}) []*Animation {
+	using := make([]*Animation, 0, len(f.Animations))
+	for _, animation := range f.Animations {
+		for tile := range tiles {
+			if animation.UsesTile(tile, f.tilesX()) {
+				using = append(using, animation)
+				break
+			}
+		}
+	}
+	return using
+}
+
+// anyTileDirty returns true if a tile overlapping the pixel rectangle changed
+func (f *File) anyTileDirty(tiles map[IntVec2]struct{}, x, y, width, height int32) bool {
+	for ty := y / f.TileHeight; ty <= (y+height-1)/f.TileHeight; ty++ {
+		for tx := x / f.TileWidth; tx <= (x+width-1)/f.TileWidth; tx++ {
+			if _, ok := tiles[IntVec2{tx, ty}]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
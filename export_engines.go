@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// writeEngineFiles writes the import data for the preset's engine next to the
+// spritesheet, base is the spritesheet's path without the extension
+func writeEngineFiles(base string, sheet Sheet, engine string) error {
+	switch engine {
+	case "":
+		return nil
+	case "godot":
+		return ioutil.WriteFile(base+".tres", []byte(godotSpriteFrames(sheet)), 0644)
+	case "unity":
+		j, err := json.MarshalIndent(unitySpriteSheet(sheet), "", "  ")
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(base+".unity.json", j, 0644)
+	}
+	return fmt.Errorf("Can't export for engine \"%s\": not supported", engine)
+}
+
+// godotSpriteFrames returns a Godot 4 SpriteFrames resource with an
+// AtlasTexture for every frame. The texture path is relative, so the resource
+// has to be kept next to the image. Files without animations get a "default"
+// animation with every frame
+func godotSpriteFrames(sheet Sheet) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "[gd_resource type=\"SpriteFrames\" load_steps=%d format=3]\n\n", len(sheet.Frames)+2)
+	fmt.Fprintf(&b, "[ext_resource type=\"Texture2D\" path=%q id=\"1\"]\n\n", sheet.Meta.Image)
+
+	for i, frame := range sheet.Frames {
+		fmt.Fprintf(&b, "[sub_resource type=\"AtlasTexture\" id=\"AtlasTexture_%d\"]\n", i)
+		b.WriteString("atlas = ExtResource(\"1\")\n")
+		fmt.Fprintf(&b, "region = Rect2(%d, %d, %d, %d)\n", frame.Frame.X, frame.Frame.Y, frame.Frame.W, frame.Frame.H)
+		if frame.Trimmed {
+			// The margin puts the trimmed frame back where it was in the tile
+			fmt.Fprintf(&b, "margin = Rect2(%d, %d, %d, %d)\n",
+				frame.SpriteSourceSize.X, frame.SpriteSourceSize.Y,
+				frame.SourceSize.W-frame.Frame.W, frame.SourceSize.H-frame.Frame.H)
+		}
+		b.WriteString("\n")
+	}
+
+	animations := sheet.Meta.Animations
+	if len(animations) == 0 {
+		animations = []SheetAnimation{{Name: "default", From: 0, To: int32(len(sheet.Frames)) - 1, Timing: 5}}
+	}
+
+	b.WriteString("[resource]\nanimations = [")
+	for i, animation := range animations {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("{\n\"frames\": [")
+		first := true
+		for f := animation.From; f <= animation.To; f++ {
+			if f < 0 || f >= int32(len(sheet.Frames)) {
+				continue
+			}
+			if !first {
+				b.WriteString(", ")
+			}
+			first = false
+			fmt.Fprintf(&b, "{\n\"duration\": 1.0,\n\"texture\": SubResource(\"AtlasTexture_%d\")\n}", f)
+		}
+		fmt.Fprintf(&b, "],\n\"loop\": true,\n\"name\": &%q,\n\"speed\": %.1f\n}", animation.Name, animation.Timing)
+	}
+	b.WriteString("]\n")
+
+	return b.String()
+}
+
+// UnitySprite is a slice in the same format as the sprites of a Unity
+// TextureImporter's spriteSheet, rects start at the bottom left of the texture
+type UnitySprite struct {
+	Name   string     `json:"name"`
+	Rect   UnityRect  `json:"rect"`
+	Pivot  UnityPivot `json:"pivot"`
+	Border [4]int32   `json:"border"`
+}
+
+// UnityRect is a rect in Unity's texture space
+type UnityRect struct {
+	X      int32 `json:"x"`
+	Y      int32 `json:"y"`
+	Width  int32 `json:"width"`
+	Height int32 `json:"height"`
+}
+
+// UnityPivot is the normalized pivot of a sprite
+type UnityPivot struct {
+	X float32 `json:"x"`
+	Y float32 `json:"y"`
+}
+
+// UnitySpriteSheet is the slicing data for a Unity importer script
+type UnitySpriteSheet struct {
+	Texture       string           `json:"texture"`
+	Width         int32            `json:"width"`
+	Height        int32            `json:"height"`
+	SpriteMode    int32            `json:"spriteMode"`
+	PixelsPerUnit int32            `json:"spritePixelsToUnits"`
+	FilterMode    int32            `json:"filterMode"`
+	Sprites       []UnitySprite    `json:"sprites"`
+	Animations    []SheetAnimation `json:"animations"`
+}
+
+// unitySpriteSheet converts the sheet to Unity's coordinates. The pivot keeps
+// trimmed frames lined up with the middle of their tile
+func unitySpriteSheet(sheet Sheet) UnitySpriteSheet {
+	u := UnitySpriteSheet{
+		Texture:       sheet.Meta.Image,
+		Width:         sheet.Meta.Size.W,
+		Height:        sheet.Meta.Size.H,
+		SpriteMode:    2, // multiple
+		PixelsPerUnit: 16,
+		FilterMode:    0, // point, so the pixels stay sharp
+		Sprites:       make([]UnitySprite, len(sheet.Frames)),
+		Animations:    sheet.Meta.Animations,
+	}
+	if len(sheet.Frames) > 0 {
+		u.PixelsPerUnit = sheet.Frames[0].SourceSize.H / sheet.Meta.Scale
+	}
+
+	for i, frame := range sheet.Frames {
+		pivot := UnityPivot{X: 0.5, Y: 0.5}
+		if frame.Frame.W > 0 && frame.Frame.H > 0 {
+			pivot.X = (float32(frame.SourceSize.W)/2 - float32(frame.SpriteSourceSize.X)) / float32(frame.Frame.W)
+			// Unity's y is flipped
+			bottom := frame.SourceSize.H - frame.SpriteSourceSize.Y - frame.Frame.H
+			pivot.Y = (float32(frame.SourceSize.H)/2 - float32(bottom)) / float32(frame.Frame.H)
+		}
+		u.Sprites[i] = UnitySprite{
+			Name: frame.Filename,
+			Rect: UnityRect{
+				X:      frame.Frame.X,
+				Y:      sheet.Meta.Size.H - frame.Frame.Y - frame.Frame.H,
+				Width:  frame.Frame.W,
+				Height: frame.Frame.H,
+			},
+			Pivot: pivot,
+		}
+	}
+	return u
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// exportPreviewState is what the export preview was composited for
+type exportPreviewState struct {
+	file                      *File
+	history                   int
+	offset                    int32
+	canvasWidth, canvasHeight int32
+	pixels, hidden, noExport  int
+}
+
+var (
+	// ExportPreview shows the canvas composited by the same code as exporting
+	// a png, instead of the render layer, so that differences between them
+	// can be seen
+	ExportPreview bool
+
+	exportPreviewLast    exportPreviewState
+	exportPreviewTexture rl.Texture2D
+	exportPreviewLoaded  bool
+)
+
+// ToggleExportPreview turns the export preview on or off
+func ToggleExportPreview() {
+	ExportPreview = !ExportPreview
+	exportPreviewLast = exportPreviewState{}
+	if !ExportPreview && exportPreviewLoaded {
+		rl.UnloadTexture(exportPreviewTexture)
+		exportPreviewLoaded = false
+	}
+}
+
+// updateExportPreview composites the export preview again if the file has
+// changed since it was last composited
+func updateExportPreview() {
+	state := exportPreviewState{
+		file:         CurrentFile,
+		history:      len(CurrentFile.History),
+		offset:       CurrentFile.historyOffset,
+		canvasWidth:  CurrentFile.CanvasWidth,
+		canvasHeight: CurrentFile.CanvasHeight,
+	}
+	for _, layer := range CurrentFile.Layers {
+		state.pixels += len(layer.PixelData)
+		if layer.Hidden {
+			state.hidden++
+		}
+		if layer.NoExport {
+			state.noExport++
+		}
+	}
+	if exportPreviewLoaded && state == exportPreviewLast && !CurrentFile.exportPreviewStale {
+		return
+	}
+	exportPreviewLast = state
+	CurrentFile.exportPreviewStale = false
+
+	if exportPreviewLoaded {
+		rl.UnloadTexture(exportPreviewTexture)
+	}
+	exportPreviewTexture = LoadTextureFromNRGBA(CurrentFile.compositeImage(nil, 1, nil, ExportFilter(nil)))
+	exportPreviewLoaded = true
+}
+
+// DrawExportPreview draws the canvas as it would be exported in place of the
+// render layer, it's drawn in the canvas' camera. It returns false if the
+// export preview is off
+func DrawExportPreview() bool {
+	if !ExportPreview {
+		return false
+	}
+	updateExportPreview()
+
+	// The texture isn't upside down like the render textures, so the parts
+	// are drawn as they are
+	CurrentFile.seamCheckRegions(func(src, dest rl.Rectangle) {
+		rl.DrawTexturePro(exportPreviewTexture, src, dest, rl.NewVector2(0, 0), 0, rl.White)
+	})
+	return true
+}
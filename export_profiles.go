@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+)
+
+// ExportFilter returns whether a layer is exported. If names isn't empty only
+// the layers with those names are, otherwise the layers with export on are.
+// Hidden layers are only exported if Settings.ExportHiddenLayers is set
+func ExportFilter(names []string) func(layer *Layer) bool {
+	if len(names) == 0 {
+		return func(layer *Layer) bool {
+			return !layer.NoExport && (!layer.Hidden || Settings.ExportHiddenLayers)
+		}
+	}
+	named := make(map[string]bool, len(names))
+	for _, name := range names {
+		named[name] = true
+	}
+	return func(layer *Layer) bool {
+		return named[layer.Name]
+	}
+}
+
+// Include returns whether a layer is exported by the preset, by LayerIDs if
+// it has any or else by Layers, see ExportFilter
+func (p ExportPreset) Include() func(layer *Layer) bool {
+	if len(p.LayerIDs) == 0 {
+		return ExportFilter(p.Layers)
+	}
+	ids := make(map[int32]bool, len(p.LayerIDs))
+	for _, id := range p.LayerIDs {
+		ids[id] = true
+	}
+	return func(layer *Layer) bool {
+		return ids[layer.ID]
+	}
+}
+
+// LinkExportProfiles saves a copy of the export presets with the file. The
+// layers which are exported now are saved with them, so toggling a layer's
+// export afterwards doesn't change what's re-exported. The layers are saved
+// by ID so that renaming them doesn't either
+func (f *File) LinkExportProfiles() {
+	include := ExportFilter(nil)
+	layers := make([]int32, 0, len(f.Layers))
+	for _, layer := range f.Layers {
+		if include(layer) {
+			layers = append(layers, layer.ID)
+		}
+	}
+
+	f.ExportProfiles = make([]ExportPreset, len(Settings.ExportPresets))
+	for i, preset := range Settings.ExportPresets {
+		preset.Layers = nil
+		preset.LayerIDs = append([]int32{}, layers...)
+		f.ExportProfiles[i] = preset
+	}
+	f.FileChanged = true
+}
+
+// linkProfileLayerIDs moves the export profiles which were saved with layer
+// names, before layers had IDs, over to the IDs of the layers with those names
+func (f *File) linkProfileLayerIDs() {
+	for i, preset := range f.ExportProfiles {
+		if len(preset.Layers) == 0 || len(preset.LayerIDs) > 0 {
+			continue
+		}
+		include := ExportFilter(preset.Layers)
+		for _, layer := range f.Layers {
+			if include(layer) {
+				preset.LayerIDs = append(preset.LayerIDs, layer.ID)
+			}
+		}
+		preset.Layers = nil
+		f.ExportProfiles[i] = preset
+	}
+}
+
+// UnlinkExportProfiles removes the export presets saved with the file
+func (f *File) UnlinkExportProfiles() {
+	if len(f.ExportProfiles) == 0 {
+		return
+	}
+	f.ExportProfiles = nil
+	f.FileChanged = true
+}
+
+// ReExport exports the current file with the export profiles linked to it
+func ReExport() {
+	if CurrentFile == nil {
+		return
+	}
+	if len(CurrentFile.ExportProfiles) == 0 {
+		UIWarning(T("No export profiles are linked to this file, link them from the file menu"))
+		return
+	}
+
+	failed := false
+	for _, preset := range CurrentFile.ExportProfiles {
+		dest, err := CurrentFile.ExportPreset(preset)
+		if err != nil {
+			log.Println(err)
+			failed = true
+			continue
+		}
+		log.Println("Exported", CurrentFile.Filename, "to", dest)
+	}
+
+	if failed {
+		PlaySoundCue(SoundError)
+	} else {
+		PlaySoundCue(SoundExported)
+	}
+}
@@ -5,12 +5,13 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/png"
+	"io"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
 )
@@ -32,6 +33,13 @@ type Tool interface {
 	DrawUI(camera rl.Camera2D)
 }
 
+// CursorTool is implemented by tools which draw their own cursor over the
+// canvas instead of the default crosshair
+type CursorTool interface {
+	// DrawCursor is drawn in screen space after DrawUI
+	DrawCursor(camera rl.Camera2D)
+}
+
 // HistoryLayerAction specifies the action which has been called upon the layer
 type HistoryLayerAction int32
 
@@ -39,8 +47,6 @@ type HistoryLayerAction int32
 const (
 	HistoryLayerActionDelete HistoryLayerAction = iota
 	HistoryLayerActionCreate
-	HistoryLayerActionMoveUp
-	HistoryLayerActionMoveDown
 )
 
 //CompoundHistory is a group of history actions
@@ -48,10 +54,18 @@ type CompoundHistory struct {
 	Actions []interface{}
 }
 
-// HistoryLayer is for layer operations
+// HistoryLayer is for layer operations. Layer is the layer which was deleted
+// or created, so that it can be put back without relying on other history
 type HistoryLayer struct {
 	HistoryLayerAction
 	LayerIndex int32
+	Layer      *Layer
+}
+
+// HistoryLayerMove is for moving a layer from one index to another. Undoing
+// moves it from To back to From
+type HistoryLayerMove struct {
+	From, To int32
 }
 
 // PixelStateData stores what the state was previously and currently
@@ -66,6 +80,69 @@ type HistoryPixel struct {
 	LayerIndex int32
 }
 
+// pixelStatePool reuses the PixelState maps, one is needed for every mouse
+// press
+var pixelStatePool = sync.Pool{
+	New: func() interface{} {
+		return make(map[IntVec2]PixelStateData)
+	},
+}
+
+// NewHistoryPixel returns a HistoryPixel for the layer with an empty map from
+// the pool
+func NewHistoryPixel(layerIndex int32) HistoryPixel {
+	return HistoryPixel{pixelStatePool.Get().(map[IntVec2]PixelStateData), layerIndex}
+}
+
+// releaseHistory puts the maps of a history action which has been removed
+// from the history back into the pool and unloads layers which only the
+// history was keeping. It has to be removed from f.History first so that the
+// layers it shares with the actions which are left aren't unloaded. The action
+// can't be used afterwards
+func (f *File) releaseHistory(action interface{}) {
+	switch typed := action.(type) {
+	case CompoundHistory:
+		for _, a := range typed.Actions {
+			f.releaseHistory(a)
+		}
+	case HistoryPixel:
+		for loc := range typed.PixelState {
+			delete(typed.PixelState, loc)
+		}
+		pixelStatePool.Put(typed.PixelState)
+	case HistoryLayer:
+		if typed.Layer == nil {
+			return
+		}
+		for _, layer := range f.Layers {
+			if layer == typed.Layer {
+				return
+			}
+		}
+		for _, remaining := range f.History {
+			if historyReferencesLayer(remaining, typed.Layer) {
+				return
+			}
+		}
+		typed.Layer.Unload()
+	}
+}
+
+// historyReferencesLayer returns true if the history action keeps layer
+func historyReferencesLayer(action interface{}, layer *Layer) bool {
+	switch typed := action.(type) {
+	case CompoundHistory:
+		for _, a := range typed.Actions {
+			if historyReferencesLayer(a, layer) {
+				return true
+			}
+		}
+	case HistoryLayer:
+		return typed.Layer == layer
+	}
+	return false
+}
+
 // HistoryResize is for resize operations
 type HistoryResize struct {
 	// PrevLayerState is a slice consisting of all layer's PixelData
@@ -75,28 +152,81 @@ type HistoryResize struct {
 	CurrentWidth, CurrentHeight int32
 }
 
-// RedrawRenderLayer redraws the render layer
+// HistoryPalette is for palette edits. The palettes are in the settings, so
+// only the colors of PaletteIndex are kept. PaletteIndex is -1 once the
+// palette has been removed, undoing or redoing it does nothing
+type HistoryPalette struct {
+	PaletteIndex  int32
+	Prev, Current []rl.Color
+}
+
+// CompositePixel returns the color of every visible layer at loc blended
+// together. The layers are walked top-down to find the highest opaque pixel,
+// anything below it can't be seen so blending starts from there.
+func (f *File) CompositePixel(loc IntVec2) rl.Color {
+	return f.compositePixel(loc, func(layer *Layer) bool {
+		return !layer.Hidden
+	})
+}
+
+// compositePixel blends the layers which include returns true for at loc
+func (f *File) compositePixel(loc IntVec2, include func(layer *Layer) bool) rl.Color {
+	layers := f.Layers
+
+	start := 0
+	for i := len(layers) - 1; i >= 0; i-- {
+		layer := layers[i]
+		if !include(layer) {
+			continue
+		}
+		if layerColor, ok := layer.EffectPixel(loc); ok && layerColor.A == 255 && layer.BlendMode == rl.BlendAlpha {
+			start = i
+			break
+		}
+	}
+
+	color := rl.Blank
+	for _, layer := range layers[start:] {
+		if include(layer) {
+			if layerColor, ok := layer.EffectPixel(loc); ok {
+				color = BlendWithOpacity(color, layerColor, layer.BlendMode)
+			}
+		}
+	}
+	return color
+}
+
+// RedrawRenderLayer redraws the render layer. Its pixels are opaque, over
+// the black which is drawn under the canvas
 func (f *File) RedrawRenderLayer() {
-	rl.BeginTextureMode(f.RenderLayer.Canvas)
-	rl.ClearBackground(rl.Black)
-	rl.BeginBlendMode(rl.BlendAlpha)
-	for x := int32(0); x < f.CanvasWidth; x++ {
-		for y := int32(0); y < f.CanvasHeight; y++ {
-			color := rl.Blank
-			loc := IntVec2{x, y}
-			for _, layer := range f.Layers[:len(f.Layers)-1] {
-				if !layer.Hidden {
-					if layerColor, ok := layer.PixelData[loc]; ok {
-						color = BlendWithOpacity(color, layerColor, layer.BlendMode)
-					}
-				}
+	f.MarkAllTilesDirty()
+	// Only painted pixels are drawn, so it costs as much as the painted area
+	// rather than the whole canvas. The rest stay blank
+	f.RenderLayer.PixelData = make(map[IntVec2]rl.Color)
+	for _, layer := range f.Layers {
+		if layer.Hidden {
+			continue
+		}
+		for loc := range layer.EffectPixels() {
+			if _, ok := f.RenderLayer.PixelData[loc]; ok {
+				continue
+			}
+			if color := f.DisplayPixel(loc); color.A > 0 {
+				f.RenderLayer.PixelData[loc] = overBlack(color)
 			}
-			f.RenderLayer.PixelData[loc] = color
-			rl.DrawPixel(x, y, color)
 		}
 	}
-	rl.EndBlendMode()
-	rl.EndTextureMode()
+	f.RenderLayer.Chunks.Redraw(f.RenderLayer.PixelData)
+}
+
+// overBlack returns color drawn over black, it's opaque
+func overBlack(color rl.Color) rl.Color {
+	return rl.Color{
+		R: uint8(uint16(color.R) * uint16(color.A) / 255),
+		G: uint8(uint16(color.G) * uint16(color.A) / 255),
+		B: uint8(uint16(color.B) * uint16(color.A) / 255),
+		A: 255,
+	}
 }
 
 // DrawPixel draws a pixel. It records actions into history.
@@ -112,61 +242,163 @@ func (f *File) DrawPixel(x, y int32, color rl.Color, layer *Layer) {
 			oldColor = rl.Blank
 		}
 
-		// Blend color on passed layer
+		// Blend color on passed layer. Colors which build up are only blended
+		// once per stroke, so holding the mouse still doesn't darken the pixel
+		linkedPixels := f.LinkedPixels(layer, loc)
 		if color != rl.Blank {
-			color = BlendWithOpacity(oldColor, color, layer.BlendMode)
+			if f.strokePixels != nil && (color.A < 255 || layer.BlendMode != rl.BlendAlpha) {
+				if f.strokePixels[loc] {
+					return
+				}
+				f.strokePixels[loc] = true
+				for _, linked := range linkedPixels {
+					f.strokePixels[linked] = true
+				}
+			}
+			color = f.lockedColor(BlendWithOpacity(oldColor, color, layer.BlendMode))
 		}
-		layer.PixelData[loc] = color
+		f.setPixel(loc, oldColor, color, layer)
 
-		// Prevent overwriting the old color with the new color since this function is called every frame
-		// Always draws to the last element of f.History since the offset is removed automatically on mouse down
-		if oldColor != color {
-			latestHistoryInterface := f.History[len(f.History)-1]
-			latestHistory, ok := latestHistoryInterface.(HistoryPixel)
-			if ok {
-				ps := latestHistory.PixelState[IntVec2{x, y}]
-				ps.Current = color
-				ps.Prev = oldColor
-				latestHistory.PixelState[IntVec2{x, y}] = ps
+		// Linked cels share their pixels
+		for _, linked := range linkedPixels {
+			linkedOld, ok := layer.PixelData[linked]
+			if !ok {
+				linkedOld = rl.Blank
 			}
+			f.setPixel(linked, linkedOld, color, layer)
+		}
+	}
+}
+
+// BeginStroke starts tracking the pixels blended by DrawPixel, until EndStroke
+func (f *File) BeginStroke() {
+	if f.strokePixels == nil {
+		f.strokePixels = make(map[IntVec2]bool)
+	}
+}
+
+// EndStroke stops tracking the pixels blended by DrawPixel
+func (f *File) EndStroke() {
+	f.strokePixels = nil
+}
+
+// setPixel sets the pixel at loc to color without blending, recording it into
+// history and drawing it to the layer and the render layer
+func (f *File) setPixel(loc IntVec2, oldColor, color rl.Color, layer *Layer) {
+	x, y := loc.X, loc.Y
+	layer.PixelData[loc] = color
+
+	// Prevent overwriting the old color with the new color since this function is called every frame
+	// Always draws to the last element of f.History since the offset is removed automatically on mouse down
+	if oldColor != color {
+		latestHistoryInterface := f.History[len(f.History)-1]
+		latestHistory, ok := latestHistoryInterface.(HistoryPixel)
+		if ok {
+			ps := latestHistory.PixelState[IntVec2{x, y}]
+			ps.Current = color
+			ps.Prev = oldColor
+			latestHistory.PixelState[IntVec2{x, y}] = ps
 		}
+	}
 
-		// Draw to passed layer
-		rl.BeginTextureMode(layer.Canvas)
+	// Draw to passed layer, blank chunks aren't loaded for erasing
+	if cx, cy, ok := layer.Chunks.BeginPixel(x, y, color != rl.Blank); ok {
 		if color == rl.Blank {
-			rl.DrawPixel(x, y, rl.Black)
+			rl.DrawPixel(cx, cy, rl.Black)
 		} else {
 			rl.BeginBlendMode(layer.BlendMode)
-			rl.DrawPixel(x, y, rl.Black)
-			rl.DrawPixel(x, y, color)
+			rl.DrawPixel(cx, cy, rl.Black)
+			rl.DrawPixel(cx, cy, color)
 			rl.EndBlendMode()
 		}
 		rl.EndTextureMode()
+	}
 
-		// Draw to render layer
-		rl.BeginTextureMode(f.RenderLayer.Canvas)
+	f.redrawRenderPixel(loc)
+	// The effects around the pixel change with it
+	for _, affected := range layer.Effects.affected(loc) {
+		if f.InCanvas(affected) {
+			f.redrawRenderPixel(affected)
+		}
+	}
+}
 
-		// Erase current pixel color
-		rl.BeginBlendMode(rl.BlendSubtractColors)
-		rl.DrawPixel(x, y, f.RenderLayer.PixelData[loc])
-		rl.EndBlendMode()
+// redrawRenderPixel draws the composited pixel at loc to the render layer
+func (f *File) redrawRenderPixel(loc IntVec2) {
+	f.MarkTileDirty(loc)
+	color := f.DisplayPixel(loc)
+	if color.A > 0 {
+		f.RenderLayer.PixelData[loc] = overBlack(color)
+	} else {
+		delete(f.RenderLayer.PixelData, loc)
+	}
 
-		rl.BeginBlendMode(rl.BlendAlpha)
-		nc := rl.Blank
-		for _, layer := range f.Layers[:len(f.Layers)-1] {
-			if !layer.Hidden {
-				if layerColor, ok := layer.PixelData[loc]; ok {
-					nc = BlendWithOpacity(nc, layerColor, layer.BlendMode)
-				}
-			}
-		}
-		f.RenderLayer.PixelData[loc] = nc
-		rl.DrawPixel(x, y, rl.Black)
-		rl.DrawPixel(x, y, nc)
-		rl.EndBlendMode()
+	// The pixels are opaque so they replace what was there, blank chunks
+	// aren't loaded for erasing
+	if cx, cy, ok := f.RenderLayer.Chunks.BeginPixel(loc.X, loc.Y, color.A > 0); ok {
+		rl.DrawPixel(cx, cy, overBlack(color))
 		rl.EndTextureMode()
+	}
+}
+
+// PreviewPixel returns what the canvas would show at loc if color was drawn
+// there on layer, so that previews of semi-transparent colors look like what's
+// drawn. It's opaque like the render layer, so it covers the pixel
+func (f *File) PreviewPixel(loc IntVec2, color rl.Color, layer *Layer) rl.Color {
+	if layer.Hidden || loc.X < 0 || loc.Y < 0 || loc.X >= f.CanvasWidth || loc.Y >= f.CanvasHeight {
+		return color
+	}
+	oldColor, ok := layer.PixelData[loc]
+	drawn := color
+	if color != rl.Blank {
+		drawn = f.lockedColor(BlendWithOpacity(oldColor, color, layer.BlendMode))
+	}
+
+	layer.PixelData[loc] = drawn
+	shown := f.DisplayPixel(loc)
+	if ok {
+		layer.PixelData[loc] = oldColor
+	} else {
+		delete(layer.PixelData, loc)
+	}
+
+	// Over black, like the render layer
+	return overBlack(shown)
+}
+
+// PreviewLayer returns the layer which tools draw their previews to
+func (f *File) PreviewLayer() *Layer {
+	return f.previewLayer
+}
 
+// updatePreviewLayer moves the preview layer to the part of the canvas which
+// is on the screen. It's aligned to chunks so that it isn't replaced whenever
+// the camera moves. The seam check shows all of the canvas so it covers it
+// all then. The preview is redrawn every frame so nothing needs to be kept
+func (f *File) updatePreviewLayer(camera rl.Camera2D) {
+	x0, y0, x1, y1 := int32(0), int32(0), f.CanvasWidth, f.CanvasHeight
+	if !SeamCheck {
+		a := ScreenToPixel(rl.NewVector2(0, 0), camera)
+		b := ScreenToPixel(rl.NewVector2(float32(rl.GetScreenWidth()), float32(rl.GetScreenHeight())), camera)
+		clamp := func(v, max int32) int32 {
+			return MaxInt32(0, MinInt32(v, max))
+		}
+		x0 = clamp(MinInt32(a.X, b.X)-1, f.CanvasWidth) / ChunkSize * ChunkSize
+		y0 = clamp(MinInt32(a.Y, b.Y)-1, f.CanvasHeight) / ChunkSize * ChunkSize
+		x1 = MinInt32(f.CanvasWidth, (clamp(MaxInt32(a.X, b.X)+1, f.CanvasWidth)+ChunkSize-1)/ChunkSize*ChunkSize)
+		y1 = MinInt32(f.CanvasHeight, (clamp(MaxInt32(a.Y, b.Y)+1, f.CanvasHeight)+ChunkSize-1)/ChunkSize*ChunkSize)
+	}
+	width, height := MaxInt32(1, x1-x0), MaxInt32(1, y1-y0)
+	origin := IntVec2{x0, y0}
+	if f.previewOrigin == origin && f.previewLayer.Width == width && f.previewLayer.Height == height {
+		return
 	}
+	f.previewLayer.Unload()
+	f.previewLayer = NewCanvasLayer(width, height, "preview")
+	f.previewOrigin = origin
+	rl.BeginTextureMode(f.previewLayer.Canvas)
+	rl.ClearBackground(rl.Blank)
+	rl.EndTextureMode()
 }
 
 // ClearBackground fills the initial PixelData
@@ -185,17 +417,70 @@ func (f *File) ClearBackground(color rl.Color) {
 type FileSer struct {
 	DrawGrid                                         bool
 	CanvasWidth, CanvasHeight, TileWidth, TileHeight int32
+	PixelAspect                                      float32
+
+	Layers      []*LayerSer
+	Animations  []*AnimationSer
+	AltPalettes []*AltPalette
+	// SwapBase is the palette the AltPalettes swap colors from
+	SwapBase  []rl.Color
+	Guides    []Guide
+	Metadata  Metadata
+	NineSlice NineSlice
+	// ExportProfiles are the presets linked to the file
+	ExportProfiles []ExportPreset
+	MaxTileColors  int32
+	// ColorTileWidth and ColorTileHeight are 0 in files saved before the
+	// color tiles could be set, they use DefaultColorTileSize
+	ColorTileWidth, ColorTileHeight int32
+	// Palette is the palette saved in the file as hex colors, it's empty if the
+	// file only uses global palettes
+	Palette     []string
+	PaletteName string
+	// NoPreviewLayer is false for files saved when the preview layer was the
+	// last of the Layers, it's left out when they're opened
+	NoPreviewLayer bool
+}
+
+// SavedLayers returns the layers which were drawn on, without the preview
+// layer older files have
+func (fs *FileSer) SavedLayers() []*LayerSer {
+	if !fs.NoPreviewLayer && len(fs.Layers) > 1 {
+		return fs.Layers[:len(fs.Layers)-1]
+	}
+	return fs.Layers
+}
 
-	Layers     []*LayerSer
-	Animations []*AnimationSer
+// compositeImage composites the saved layers without opening the file, the
+// same way File.compositeImage does. It doesn't need a window
+func (fs *FileSer) compositeImage(alt *AltPalette, include func(layer *Layer) bool) *image.NRGBA {
+	f := &File{CanvasWidth: fs.CanvasWidth, CanvasHeight: fs.CanvasHeight}
+	for _, layer := range fs.SavedLayers() {
+		f.Layers = append(f.Layers, &Layer{
+			ID:        layer.ID,
+			Hidden:    layer.Hidden,
+			NoExport:  layer.NoExport,
+			Name:      layer.Name,
+			Width:     layer.Width,
+			Height:    layer.Height,
+			BlendMode: rl.BlendAlpha,
+			Effects:   layer.Effects,
+			PixelData: layer.PixelData,
+		})
+	}
+	return f.compositeImage(alt, 1, nil, include)
 }
 
 // LayerSer contains only the fields that need to be serialized
 type LayerSer struct {
+	ID            int32
 	Hidden        bool
+	NoExport      bool
+	CelLinks      map[int32]int32
 	Name          string
 	PixelData     map[IntVec2]rl.Color
 	Width, Height int32
+	Effects       LayerEffects
 }
 
 // AnimationSer contains only the fields that need to be serialized
@@ -203,6 +488,7 @@ type AnimationSer struct {
 	Name                 string
 	FrameStart, FrameEnd int32
 	Timing               float32
+	Export               string
 }
 
 // Animation contains data about an animation
@@ -210,6 +496,9 @@ type Animation struct {
 	Name                 string
 	FrameStart, FrameEnd int32
 	Timing               float32 // time between frames
+	// Export is where the animation is exported to, see ExportAnimation.
+	// Empty uses DefaultAnimationExport
+	Export string
 }
 
 // File contains all the methods and data required to alter a file
@@ -222,18 +511,35 @@ type File struct {
 
 	// FileChanged is true if a change has been made since saving
 	FileChanged bool
-
-	Layers       []*Layer // The last one is for tool previews
-	RenderLayer  *Layer   // Blends all layers and renders only this layer
+	// ReadOnly disables the tools and history
+	ReadOnly bool
+	// lockedPath is the path which this instance has locked for editing
+	lockedPath string
+	// saving is true while the file is being written in the background
+	saving bool
+
+	Layers       []*Layer
+	RenderLayer  *Layer // Blends all layers and renders only this layer
 	CurrentLayer int32
+	// previewLayer is what tools draw their previews to, it isn't one of the
+	// Layers so it's never saved or exported
+	previewLayer *Layer
+	// previewOrigin is where the preview layer's top left is on the canvas,
+	// it only covers the part of the canvas on the screen
+	previewOrigin IntVec2
 
 	Animations       []*Animation
 	CurrentAnimation int32
 
 	History           []interface{}
 	HistoryMaxActions int32
-	historyOffset     int32    // How many undos have been made
-	deletedLayers     []*Layer // stack of layers, AddNewLayer destroys history chain
+	historyOffset     int32 // How many undos have been made
+	// historyEvicted is how many of the oldest actions were dropped to stay
+	// under the history limits
+	historyEvicted int32
+	// Actions appended during a transaction are grouped when it ends
+	transactionDepth   int32
+	transactionActions int32
 
 	// For preventing multiple event firing
 	HasDoneMouseUpLeft  bool
@@ -242,6 +548,28 @@ type File struct {
 	// If grid should be drawn
 	DrawGrid bool
 
+	// PixelAspect is the width of a pixel relative to its height. It only
+	// changes how the canvas is displayed, not the stored data
+	PixelAspect float32
+
+	// dirty are the tiles which changed since the preview was drawn
+	dirty dirtyTiles
+	// colorDirty are the color tiles which changed since the tile color
+	// warnings were checked, see ColorTileSize
+	colorDirty dirtyTiles
+	// exportPreviewStale is true when the canvas changed since the export
+	// preview was composited
+	exportPreviewStale bool
+
+	// lastBrushPos is where the pencil or eraser last drew, shift-clicking
+	// draws a line from it
+	lastBrushPos    IntVec2
+	hasLastBrushPos bool
+
+	// strokePixels are the pixels blended during the current stroke, nil
+	// when there isn't one
+	strokePixels map[IntVec2]bool
+
 	// Used by system_file.go
 	FileCameraTarget rl.Vector2 // temp storage for calculations
 	FileCamera       rl.Camera2D
@@ -263,8 +591,39 @@ type File struct {
 	OrigSelectionBounds [4]int32
 	// True if paste event has just happened
 	IsSelectionPasted bool
+	// SelectionPreview is a flip or rotation waiting to be accepted
+	SelectionPreview *SelectionPreview
 
 	CurrentPalette int32
+	// MaxTileColors is how many colors each tile can have, tiles with more
+	// are highlighted. It's 0 when there's no limit
+	MaxTileColors int32
+	// ColorTileWidth and ColorTileHeight are the size of the hardware tiles
+	// which MaxTileColors applies to. They're separate from TileWidth and
+	// TileHeight, which are the animation frames. See ColorTileSize
+	ColorTileWidth, ColorTileHeight int32
+
+	// Guides are drawn over the canvas, they're usually from a template
+	Guides []Guide
+
+	Metadata Metadata
+
+	// NineSlice are the margins used when a tile is stretched, they're
+	// exported with spritesheets
+	NineSlice NineSlice
+
+	// ExportProfiles are export presets saved with the file, so that
+	// re-exporting it gives the same outputs every time
+	ExportProfiles []ExportPreset
+
+	// Alternate palettes recolor the file, SwapBase is the palette they swap
+	// colors from. PreviewAltPalette is -1 when the original colors are shown
+	AltPalettes       []*AltPalette
+	SwapBase          []rl.Color
+	PreviewAltPalette int32
+
+	// Timelapse is nil until a recording is started
+	Timelapse *Timelapse
 
 	// Canvas and tile dimensions
 	CanvasWidth, CanvasHeight, TileWidth, TileHeight int32
@@ -297,24 +656,27 @@ func NewFile(canvasWidth, canvasHeight, tileWidth, tileHeight int32) *File {
 		PathDir:  pathDir,
 		Filename: "filename",
 		Layers: []*Layer{
-			NewLayer(canvasWidth, canvasHeight, "background", rl.Blank, true),
-			NewLayer(canvasWidth, canvasHeight, "hidden", rl.Blank, true),
+			NewLayer(canvasWidth, canvasHeight, T("background"), rl.Blank, true),
 		},
-		RenderLayer: NewLayer(canvasWidth, canvasHeight, "render", rl.Blank, true),
+		RenderLayer:  NewLayer(canvasWidth, canvasHeight, "render", rl.Blank, false),
+		previewLayer: NewCanvasLayer(1, 1, "preview"),
 
 		FileChanged: false,
 
 		Animations: make([]*Animation, 0),
 
 		History:           make([]interface{}, 0, 50),
-		HistoryMaxActions: 500, // TODO get from config
-		deletedLayers:     make([]*Layer, 0, 10),
+		HistoryMaxActions: SettingsHistoryMaxActions(),
 
 		HasDoneMouseUpLeft:  true,
 		HasDoneMouseUpRight: true,
 
 		DrawGrid: canvasHeight <= 64, // don't draw the grid for anything bigger than default size
 
+		PixelAspect: 1,
+
+		PreviewAltPalette: -1,
+
 		FileCamera: rl.Camera2D{Zoom: 12.0 * scaleRatio,
 			Offset: rl.NewVector2(
 				float32(rl.GetScreenWidth())/2,
@@ -361,22 +723,69 @@ const (
 
 // ResizeCanvas resizes the canvas from a specified edge
 func (f *File) ResizeCanvas(width, height int32, direction ResizeDirection) {
+	dx, dy := ResizeOffset(f.CanvasWidth, f.CanvasHeight, width, height, direction)
+	f.resizeCanvasOffset(width, height, dx, dy)
+}
+
+// ExpandCanvas adds the amount of pixels to each side of the canvas, it
+// returns false if it was too big to undo
+func (f *File) ExpandCanvas(left, top, right, bottom int32) bool {
+	return f.resizeCanvasOffset(f.CanvasWidth+left+right, f.CanvasHeight+top+bottom, -left, -top)
+}
+
+// resizeCanvasOffset resizes every layer, see Layer.ResizeOffset. It returns
+// false if it was too big to undo
+func (f *File) resizeCanvasOffset(width, height, dx, dy int32) bool {
+	pixels := 0
+	for _, layer := range f.Layers {
+		pixels += len(layer.PixelData) * 2
+	}
+	if f.WarnHistoryCap(pixels) {
+		return false
+	}
+
 	prevLayerDatas := make([]map[IntVec2]rl.Color, 0, len(f.Layers))
 	currentLayerDatas := make([]map[IntVec2]rl.Color, 0, len(f.Layers))
 
 	for _, layer := range f.Layers {
 		prevLayerDatas = append(prevLayerDatas, layer.PixelData)
-		layer.Resize(width, height, direction)
+		layer.ResizeOffset(width, height, dx, dy)
 		currentLayerDatas = append(currentLayerDatas, layer.PixelData)
 	}
-	f.RenderLayer.Resize(width, height, direction)
+	f.RenderLayer.ResizeOffset(width, height, dx, dy)
 
+	f.BeginTransaction()
 	f.AppendHistory(HistoryResize{prevLayerDatas, currentLayerDatas, f.CanvasWidth, f.CanvasHeight, width, height})
+	// Cels move with their pixels, the tiles have to stay on the grid
+	tilesX := f.tilesX()
 	f.CanvasWidth = width
 	f.CanvasHeight = height
+	f.remapCelLinks(func(frame int32) (int32, bool) {
+		if dx%f.TileWidth != 0 || dy%f.TileHeight != 0 {
+			return 0, false
+		}
+		x, y := (frame%tilesX)*f.TileWidth, (frame/tilesX)*f.TileHeight
+		return f.frameOf(IntVec2{x - dx, y - dy})
+	})
+	f.EndTransaction()
 
 	f.RedrawRenderLayer()
 	LayersUIRebuildList()
+	return true
+}
+
+// CyclePixelAspect switches between square, wide (2:1) and tall (1:2) pixels
+func (f *File) CyclePixelAspect() {
+	switch f.PixelAspect {
+	case 1:
+		f.PixelAspect = 2
+	case 2:
+		f.PixelAspect = 0.5
+	default:
+		f.PixelAspect = 1
+	}
+	f.FileChanged = true
+	EditorsUIRebuild()
 }
 
 // ResizeTileSize resizes the tile size
@@ -384,6 +793,11 @@ func (f *File) ResizeTileSize(width, height int32) {
 	f.RedrawRenderLayer()
 	f.TileWidth = width
 	f.TileHeight = height
+	// Cels keep their frame, like animations do
+	count := f.frameCount()
+	f.remapCelLinks(func(frame int32) (int32, bool) {
+		return frame, frame < count
+	})
 }
 
 // DeleteSelection deletes the selection
@@ -398,11 +812,58 @@ func (f *File) DeleteSelection() {
 // CancelSelection cancels the selection
 func (f *File) CancelSelection() {
 	f.RedrawRenderLayer()
+	f.SelectionPreview = nil
 	f.Selection = make(map[IntVec2]rl.Color)
 	f.SelectionMoving = false
 	f.DoingSelection = false
 }
 
+// SelectOpaque selects every non-transparent pixel on the current layer. The
+// selection bounds cover the silhouette but only the opaque pixels are selected
+func (f *File) SelectOpaque() {
+	// TODO better way to switch tool
+	if interactable, ok := toolSelector.GetInteractable(); ok {
+		interactable.OnMouseUp(toolSelector, rl.MouseRightButton)
+	}
+	f.CommitSelection()
+
+	cl := f.GetCurrentLayer()
+	minX, minY := f.CanvasWidth, f.CanvasHeight
+	maxX, maxY := int32(-1), int32(-1)
+	for loc, color := range cl.PixelData {
+		if color.A == 0 {
+			continue
+		}
+		minX = MinInt32(minX, loc.X)
+		minY = MinInt32(minY, loc.Y)
+		maxX = MaxInt32(maxX, loc.X)
+		maxY = MaxInt32(maxY, loc.Y)
+	}
+
+	// Nothing to select
+	if maxX < 0 {
+		return
+	}
+
+	f.SelectionBounds = [4]int32{minX, minY, maxX, maxY}
+	f.OrigSelectionBounds = f.SelectionBounds
+	f.Selection = make(map[IntVec2]rl.Color)
+	f.SelectionPixels = make([]rl.Color, 0, (maxX-minX+1)*(maxY-minY+1))
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			loc := IntVec2{x, y}
+			color := cl.PixelData[loc]
+			if color.A > 0 {
+				f.Selection[loc] = color
+			}
+			f.SelectionPixels = append(f.SelectionPixels, color)
+		}
+	}
+
+	// Selection is being displayed on screen
+	f.DoingSelection = true
+}
+
 // Copy the selection
 func (f *File) Copy() {
 	CopiedSelection = make(map[IntVec2]rl.Color)
@@ -470,12 +931,13 @@ func (f *File) Paste() {
 // dx and dy is how much the selection has moved
 func (f *File) MoveSelection(dx, dy int32) {
 	cl := f.GetCurrentLayer()
+	f.SelectionPreview = nil
 
 	if len(f.Selection) > 0 {
 		if !f.SelectionMoving {
 			f.SelectionMoving = true
 
-			f.AppendHistory(HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer})
+			f.AppendHistory(NewHistoryPixel(CurrentFile.CurrentLayer))
 
 			for loc := range f.Selection {
 				// Alter history
@@ -494,6 +956,9 @@ func (f *File) MoveSelection(dx, dy int32) {
 					cl.PixelData[loc] = rl.Blank
 				}
 			}
+			if history, ok := f.History[len(f.History)-1].(HistoryPixel); ok {
+				f.syncLinkedCels(history)
+			}
 		}
 
 		// Move selection
@@ -521,11 +986,19 @@ func (f *File) MoveSelection(dx, dy int32) {
 // CommitSelection "stamps" the floating selection in place
 func (f *File) CommitSelection() {
 	f.IsSelectionPasted = false
+	f.SelectionPreview = nil
 	f.DoingSelection = false
 
 	if f.SelectionMoving {
 		f.SelectionMoving = false
 
+		// The pixels are written into the lift's HistoryPixel, or a new one
+		// if something else was done since, e.g. ExpandCanvasToSelection
+		if len(f.History) == 0 {
+			f.AppendHistory(NewHistoryPixel(f.CurrentLayer))
+		} else if _, ok := f.History[len(f.History)-1].(HistoryPixel); !ok {
+			f.AppendHistory(NewHistoryPixel(f.CurrentLayer))
+		}
 		if len(f.History) <= 0 {
 			return
 		}
@@ -535,7 +1008,7 @@ func (f *File) CommitSelection() {
 		// Alter PixelData and history
 		for loc, color := range f.Selection {
 			// Out of canvas bounds, ignore
-			if !(loc.X >= 0 && loc.X < f.CanvasWidth && loc.Y >= 0 && loc.Y < f.CanvasHeight) {
+			if !f.InCanvas(loc) {
 				continue
 			}
 
@@ -564,6 +1037,9 @@ func (f *File) CommitSelection() {
 
 			}
 		}
+		if history, ok := f.History[len(f.History)-1].(HistoryPixel); ok {
+			f.syncLinkedCels(history)
+		}
 
 		cl.Redraw()
 		f.RedrawRenderLayer()
@@ -611,7 +1087,7 @@ func (f *File) GetAnimation(index int32) (*Animation, error) {
 // AddNewAnimation adds a new animation
 func (f *File) AddNewAnimation() {
 	f.Animations = append(f.Animations, &Animation{
-		Name:       fmt.Sprintf("Anim %d", len(f.Animations)),
+		Name:       Tf("Anim %d", len(f.Animations)),
 		FrameStart: 0,
 		FrameEnd:   0,
 		Timing:     5.0, // 5 fps
@@ -648,6 +1124,19 @@ func (f *File) SetAnimationName(index int32, name string) {
 	anim.Name = name
 }
 
+// SetAnimationExport sets where the animation is exported to
+func (f *File) SetAnimationExport(index int32, pattern string) {
+	anim, err := f.GetAnimation(index)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if anim.Export != pattern {
+		anim.Export = pattern
+		f.FileChanged = true
+	}
+}
+
 // SetCurrentLayer sets the current layer
 func (f *File) SetCurrentLayer(index int32) {
 	f.CurrentLayer = index
@@ -662,16 +1151,21 @@ func (f *File) GetCurrentLayer() *Layer {
 // Won't delete anything if only one visible layer exists
 // Sets the current layer to the top-most layer
 func (f *File) DeleteLayer(index int32, appendHistory bool) error {
-	if len(f.Layers) > 2 {
-		f.deletedLayers = append(f.deletedLayers, f.Layers[index])
+	if appendHistory {
+		if err := f.checkEditable("delete layer"); err != nil {
+			return err
+		}
+	}
+	if len(f.Layers) > 1 {
+		deleted := f.Layers[index]
 		f.Layers = append(f.Layers[:index], f.Layers[index+1:]...)
 
 		if appendHistory {
-			f.AppendHistory(HistoryLayer{HistoryLayerActionDelete, index})
+			f.AppendHistory(HistoryLayer{HistoryLayerActionDelete, index, deleted})
 		}
 
-		if f.CurrentLayer > int32(len(f.Layers)-2) {
-			f.SetCurrentLayer(int32(len(f.Layers) - 2))
+		if f.CurrentLayer > int32(len(f.Layers)-1) {
+			f.SetCurrentLayer(int32(len(f.Layers) - 1))
 		}
 
 		return nil
@@ -680,40 +1174,49 @@ func (f *File) DeleteLayer(index int32, appendHistory bool) error {
 	return fmt.Errorf("Couldn't delete layer as it's the only one visible")
 }
 
-// RestoreLayer restores the last layer from f.deletedLayers to the position of
-// index in f.Layers
-func (f *File) RestoreLayer(index int32) error {
-	if len(f.deletedLayers) == 0 {
-		return fmt.Errorf("No layers to restore")
+// RestoreLayer puts a deleted layer back at index in f.Layers
+func (f *File) RestoreLayer(index int32, layer *Layer) error {
+	if layer == nil {
+		return fmt.Errorf("No layer to restore")
+	}
+	if index < 0 || index > int32(len(f.Layers)) {
+		return fmt.Errorf("Couldn't restore layer at %d", index)
 	}
 
 	f.Layers = append(
 		f.Layers[:index],
 		append(
-			[]*Layer{f.deletedLayers[len(f.deletedLayers)-1]},
+			[]*Layer{layer},
 			f.Layers[index:]...)...)
-	f.deletedLayers = append(
-		f.deletedLayers[:len(f.deletedLayers)-1],
-		f.deletedLayers[len(f.deletedLayers):]...)
 
+	// Its textures could have been unloaded while it was only in the history
+	layer.Redraw()
 	f.RedrawRenderLayer()
 	return nil
 }
 
 // MergeLayerDown merges the layer with the one below
 func (f *File) MergeLayerDown(index int32) error {
-	if len(f.Layers) <= 2 {
+	if err := f.checkEditable("merge layer down"); err != nil {
+		return err
+	}
+	if len(f.Layers) <= 1 {
 		return fmt.Errorf("Couldn't merge layer down: Not enough layers")
 	}
 	if index == 0 {
 		return fmt.Errorf("Couldn't merge layer down: Can't merge lowest layer")
 	}
 
-	// old layer pixel state
-	historyPixel := HistoryPixel{make(map[IntVec2]PixelStateData), index - 1}
 	from := f.Layers[index]
+	if f.WarnHistoryCap(len(from.PixelData)) {
+		return fmt.Errorf("Couldn't merge layer down: Bigger than the undo memory limit")
+	}
+
+	// old layer pixel state
+	historyPixel := NewHistoryPixel(index - 1)
 	to := f.Layers[index-1]
-	for loc, color := range from.PixelData {
+	// The effects of the merged layer are baked in
+	for loc, color := range from.EffectPixels() {
 		hist := historyPixel.PixelState[loc]
 		hist.Prev = to.PixelData[loc]
 		newColor := BlendWithOpacity(to.PixelData[loc], color, from.BlendMode)
@@ -726,6 +1229,7 @@ func (f *File) MergeLayerDown(index int32) error {
 		if color != rl.Blank && color != to.PixelData[loc] {
 		}
 	}
+	f.syncLinkedCels(historyPixel)
 	to.Redraw()
 
 	if err := f.DeleteLayer(index, false); err != nil {
@@ -735,7 +1239,7 @@ func (f *File) MergeLayerDown(index int32) error {
 	comp := CompoundHistory{
 		Actions: []interface{}{
 			historyPixel,
-			HistoryLayer{HistoryLayerActionDelete, index},
+			HistoryLayer{HistoryLayerActionDelete, index, from},
 		},
 	}
 	f.AppendHistory(comp)
@@ -745,82 +1249,200 @@ func (f *File) MergeLayerDown(index int32) error {
 }
 
 // AddNewLayer inserts a new layer
-func (f *File) AddNewLayer() {
-	newLayer := NewLayer(f.CanvasWidth, f.CanvasHeight, "new layer", rl.Blank, true)
-	f.Layers = append(f.Layers[:len(f.Layers)-1], newLayer, f.Layers[len(f.Layers)-1])
-	f.SetCurrentLayer(int32(len(f.Layers) - 2)) // -2 bc temp layer is excluded
+func (f *File) AddNewLayer() error {
+	if err := f.checkEditable("add layer"); err != nil {
+		return err
+	}
+	newLayer := NewLayer(f.CanvasWidth, f.CanvasHeight, T("new layer"), rl.Blank, true)
+	f.Layers = append(f.Layers, newLayer)
+	f.SetCurrentLayer(int32(len(f.Layers) - 1))
 
-	f.AppendHistory(HistoryLayer{HistoryLayerActionCreate, f.CurrentLayer})
+	f.AppendHistory(HistoryLayer{HistoryLayerActionCreate, f.CurrentLayer, newLayer})
 	f.RedrawRenderLayer()
+	return nil
 }
 
-// MoveLayerUp moves the layer up
-func (f *File) MoveLayerUp(index int32, appendHistory bool) error {
-	if index < int32(len(f.Layers)-2) {
-		toMove := f.Layers[index]
-		f.Layers = append(f.Layers[:index], f.Layers[index+1:]...)
-		f.Layers = append(f.Layers[:index], append([]*Layer{f.Layers[index], toMove}, f.Layers[index+1:]...)...)
-
-		if appendHistory {
-			f.AppendHistory(HistoryLayer{HistoryLayerActionMoveUp, index})
+// StampVisible adds a layer on top with the visible layers flattened into it,
+// the layers themselves are left as they are
+func (f *File) StampVisible() {
+	if f.WarnHistoryCap(int(f.CanvasWidth * f.CanvasHeight)) {
+		return
+	}
+	stamp := NewLayer(f.CanvasWidth, f.CanvasHeight, T("stamp visible"), rl.Blank, false)
+	for y := int32(0); y < f.CanvasHeight; y++ {
+		for x := int32(0); x < f.CanvasWidth; x++ {
+			loc := IntVec2{x, y}
+			if color := f.CompositePixel(loc); color.A > 0 {
+				stamp.PixelData[loc] = color
+			}
 		}
-		f.RedrawRenderLayer()
-		return nil
 	}
+	stamp.Redraw()
 
-	return fmt.Errorf("Couldn't move layer up")
+	f.Layers = append(f.Layers, stamp)
+	f.SetCurrentLayer(int32(len(f.Layers) - 1))
+	f.AppendHistory(HistoryLayer{HistoryLayerActionCreate, f.CurrentLayer, stamp})
+	f.RedrawRenderLayer()
 }
 
-// MoveLayerDown moves the layer down
-func (f *File) MoveLayerDown(index int32, appendHistory bool) error {
-	if index > 0 {
-		toMove := f.Layers[index]
-		f.Layers = append(f.Layers[:index], f.Layers[index+1:]...)
-		if index-1 == 0 {
-			f.Layers = append([]*Layer{toMove}, append(f.Layers[:index], f.Layers[index:]...)...)
-		} else {
-			f.Layers = append(f.Layers[:index-1], append([]*Layer{toMove}, f.Layers[index-1:]...)...)
+// MoveLayer moves the layer at from so that it's at to, shifting the layers in
+// between. The current layer stays selected
+func (f *File) MoveLayer(from, to int32, appendHistory bool) error {
+	if appendHistory {
+		if err := f.checkEditable("move layer"); err != nil {
+			return err
 		}
+	}
+	last := int32(len(f.Layers) - 1)
+	if from < 0 || from > last || to < 0 || to > last || from == to {
+		return fmt.Errorf("Couldn't move layer from %d to %d", from, to)
+	}
 
-		if appendHistory {
-			f.AppendHistory(HistoryLayer{HistoryLayerActionMoveDown, index})
-		}
-		f.RedrawRenderLayer()
-		return nil
+	toMove := f.Layers[from]
+	if from < to {
+		copy(f.Layers[from:to], f.Layers[from+1:to+1])
+	} else {
+		copy(f.Layers[to+1:from+1], f.Layers[to:from])
+	}
+	f.Layers[to] = toMove
+
+	current := f.CurrentLayer
+	switch {
+	case current == from:
+		current = to
+	case from < to && current > from && current <= to:
+		current--
+	case from > to && current >= to && current < from:
+		current++
+	}
+	f.SetCurrentLayer(current)
+
+	if appendHistory {
+		f.AppendHistory(HistoryLayerMove{from, to})
+	}
+	f.RedrawRenderLayer()
+	return nil
+}
+
+// MoveLayerUp moves the layer up
+func (f *File) MoveLayerUp(index int32, appendHistory bool) error {
+	if err := f.MoveLayer(index, index+1, appendHistory); err != nil {
+		return fmt.Errorf("Couldn't move layer up")
 	}
+	return nil
+}
 
-	return fmt.Errorf("Couldn't move layer down")
+// MoveLayerDown moves the layer down
+func (f *File) MoveLayerDown(index int32, appendHistory bool) error {
+	if err := f.MoveLayer(index, index-1, appendHistory); err != nil {
+		return fmt.Errorf("Couldn't move layer down")
+	}
+	return nil
+}
 
+// checkEditable returns an error if the file is read-only. Edits check it
+// before changing anything, AppendHistory only drops their history
+func (f *File) checkEditable(action string) error {
+	if f.ReadOnly {
+		return fmt.Errorf("Can't %s: the file is read-only", action)
+	}
+	return nil
 }
 
 // AppendHistory inserts a new history interface{} to f.History depending on the
 // historyOffset
 func (f *File) AppendHistory(action interface{}) {
+	if f.ReadOnly {
+		return
+	}
 	f.FileChanged = true
 	// Clear everything past the offset if a change has been made after undoing
-	f.History = f.History[0 : int32(len(f.History))-f.historyOffset]
+	end := int32(len(f.History)) - f.historyOffset
+	undone := f.History[end:]
+	f.History = f.History[0:end]
+	for _, action := range undone {
+		f.releaseHistory(action)
+	}
 	f.historyOffset = 0
 
-	if int32(len(f.History)) >= f.HistoryMaxActions {
-		f.History = append(f.History[int32(len(f.History))-f.HistoryMaxActions+1:f.HistoryMaxActions], action)
-	} else {
-		f.History = append(f.History, action)
+	f.History = append(f.History, action)
+	f.enforceHistoryLimits()
+	if f.transactionDepth > 0 {
+		f.transactionActions++
+	}
+
+	f.recordTimelapse()
+	collabRecord(f, action, false)
+	EditorsUIRebuild()
+}
+
+// DiscardEmptyPixelHistory removes the latest history action if it's a
+// HistoryPixel which nothing was drawn into, e.g. when clicking outside of the
+// canvas or with the same color. historyLen is the length of the history after
+// the action was appended, nothing is removed if more actions were appended
+func (f *File) DiscardEmptyPixelHistory(historyLen int) {
+	if f.historyOffset != 0 || len(f.History) == 0 || len(f.History) != historyLen {
+		return
+	}
+	latest, ok := f.History[len(f.History)-1].(HistoryPixel)
+	if !ok || len(latest.PixelState) > 0 {
+		return
+	}
+
+	f.History = f.History[:len(f.History)-1]
+	if f.transactionDepth > 0 && f.transactionActions > 0 {
+		f.transactionActions--
+	}
+	f.releaseHistory(latest)
+}
+
+// BeginTransaction groups the actions appended to the history until the
+// matching EndTransaction into one CompoundHistory so that they're undone in
+// one step, e.g. while a slider is being dragged. Transactions can be nested,
+// only the outermost one groups the actions
+func (f *File) BeginTransaction() {
+	f.transactionDepth++
+}
+
+// EndTransaction ends the transaction started by BeginTransaction
+func (f *File) EndTransaction() {
+	if f.transactionDepth == 0 {
+		log.Println("EndTransaction called without BeginTransaction")
+		return
+	}
+	f.transactionDepth--
+	if f.transactionDepth > 0 {
+		return
+	}
+
+	count := f.transactionActions
+	f.transactionActions = 0
+	if count > int32(len(f.History)) {
+		// Some were dropped by the history limits
+		count = int32(len(f.History))
+	}
+	if count < 2 {
+		return
 	}
 
+	start := int32(len(f.History)) - count
+	comp := CompoundHistory{
+		Actions: append([]interface{}{}, f.History[start:]...),
+	}
+	f.History = append(f.History[:start], comp)
 	EditorsUIRebuild()
 }
 
+// InTransaction returns true if a transaction hasn't been ended yet
+func (f *File) InTransaction() bool {
+	return f.transactionDepth > 0
+}
+
 // DrawPixelDataToCanvas redraws the canvas using the pixel data
 // This is useful for removing pixels since DrawPixel is additive, meaning that
 // a pixel can never be erased
 func (f *File) DrawPixelDataToCanvas() {
-	layer := f.GetCurrentLayer()
-	rl.BeginTextureMode(layer.Canvas)
-	rl.ClearBackground(rl.Blank)
-	for v, color := range layer.PixelData {
-		rl.DrawPixel(v.X, v.Y, color)
-	}
-	rl.EndTextureMode()
+	f.GetCurrentLayer().Redraw()
 }
 
 // Outline draws the left color around any non-transparent pixels (and is
@@ -831,7 +1453,7 @@ func (f *File) Outline() {
 	var sx, sy int32 = 0, 0
 	mx, my := f.CanvasWidth, f.CanvasHeight
 
-	latestHistory := HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer}
+	latestHistory := NewHistoryPixel(CurrentFile.CurrentLayer)
 	if f.DoingSelection {
 		// latestHistory is essentially ignored and whatever is in the selection
 		// is accounted for by f.MoveSelection
@@ -839,9 +1461,6 @@ func (f *File) Outline() {
 		sy = f.SelectionBounds[1]
 		mx = f.SelectionBounds[2] + 1
 		my = f.SelectionBounds[3] + 1
-	} else {
-		// New history
-		CurrentFile.AppendHistory(latestHistory)
 	}
 
 	cl := f.GetCurrentLayer()
@@ -878,8 +1497,17 @@ func (f *File) Outline() {
 		}
 	}
 
-	for _, loc := range pixelLocations {
-		l := latestHistory.PixelState[loc]
+	if !f.DoingSelection {
+		if f.WarnHistoryCap(len(pixelLocations)) {
+			f.releaseHistory(latestHistory)
+			return
+		}
+		// New history
+		CurrentFile.AppendHistory(latestHistory)
+	}
+
+	for _, loc := range pixelLocations {
+		l := latestHistory.PixelState[loc]
 		l.Prev = rl.Blank // Only replacing transparent pixels
 		l.Current = LeftColor
 		latestHistory.PixelState[loc] = l
@@ -895,245 +1523,326 @@ func (f *File) Outline() {
 		// Allow CommitSelection to detect a change
 		f.MoveSelection(0, 0)
 		f.CommitSelection()
+	} else if !f.DoingSelection {
+		f.syncLinkedCels(latestHistory)
 	}
 
 	cl.Redraw()
 	f.RedrawRenderLayer()
 }
 
-// FlipHorizontal flips the layer horizontally, or flips the selection if anything
-// is selected
-func (f *File) FlipHorizontal() {
-	latestHistory := HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer}
-
-	var sx, sy int32 = 0, 0
-	mx, my := f.CanvasWidth, f.CanvasHeight
-
-	if f.DoingSelection {
-		sx = f.SelectionBounds[0]
-		sy = f.SelectionBounds[1]
-		mx = (f.SelectionBounds[0] + f.SelectionBounds[2]) + 1
-		my = f.SelectionBounds[3] + 1
-	} else {
-		// If selection is modified, it will be added to history on commit
-		CurrentFile.AppendHistory(latestHistory)
+// RemoveBackground replaces color with transparency on the current layer.
+// If contiguous is true, only the pixels connected to the edges of the canvas
+// are removed, otherwise every pixel which matches is removed
+func (f *File) RemoveBackground(color rl.Color, contiguous bool) {
+	if color == rl.Blank {
+		return
 	}
 
-	// Swap the pixels over
 	cl := f.GetCurrentLayer()
+	latestHistory := NewHistoryPixel(f.CurrentLayer)
+
+	remove := func(loc IntVec2) {
+		ps := latestHistory.PixelState[loc]
+		ps.Prev = cl.PixelData[loc]
+		ps.Current = rl.Blank
+		latestHistory.PixelState[loc] = ps
+		cl.PixelData[loc] = rl.Blank
+	}
 
-	for y := sy; y < my; y++ {
-		for x := sx; x < mx/2; x++ {
-			lpos := IntVec2{x, y}
-			rpos := IntVec2{mx - x - 1, y}
+	if contiguous {
+		// Seed the fill with every matching pixel on the edges
+		stack := make([]IntVec2, 0, f.CanvasWidth*2+f.CanvasHeight*2)
+		for x := int32(0); x < f.CanvasWidth; x++ {
+			stack = append(stack, IntVec2{x, 0}, IntVec2{x, f.CanvasHeight - 1})
+		}
+		for y := int32(0); y < f.CanvasHeight; y++ {
+			stack = append(stack, IntVec2{0, y}, IntVec2{f.CanvasWidth - 1, y})
+		}
 
-			lcur := cl.PixelData[lpos]
-			rcur := cl.PixelData[rpos]
+		for len(stack) > 0 {
+			loc := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
 
-			// Update selection
-			if f.DoingSelection {
-				f.Selection[lpos], f.Selection[rpos] = f.Selection[rpos], f.Selection[lpos]
-			} else {
-				l := latestHistory.PixelState[lpos]
-				l.Prev = lcur
-				l.Current = rcur
-				latestHistory.PixelState[lpos] = l
-
-				r := latestHistory.PixelState[rpos]
-				r.Prev = rcur
-				r.Current = lcur
-				latestHistory.PixelState[rpos] = r
-
-				cl.PixelData[lpos] = rcur
-				cl.PixelData[rpos] = lcur
+			if loc.X < 0 || loc.Y < 0 || loc.X >= f.CanvasWidth || loc.Y >= f.CanvasHeight {
+				continue
+			}
+			if cl.PixelData[loc] != color {
+				continue
 			}
 
+			remove(loc)
+			stack = append(stack,
+				IntVec2{loc.X + 1, loc.Y},
+				IntVec2{loc.X - 1, loc.Y},
+				IntVec2{loc.X, loc.Y + 1},
+				IntVec2{loc.X, loc.Y - 1},
+			)
+		}
+	} else {
+		for loc, c := range cl.PixelData {
+			if c == color {
+				remove(loc)
+			}
 		}
 	}
 
-	if f.DoingSelection && !f.SelectionMoving {
-		// Allow CommitSelection to detect a change
-		f.MoveSelection(0, 0)
+	if len(latestHistory.PixelState) == 0 {
+		return
+	}
+	if f.WarnHistoryCap(len(latestHistory.PixelState)) {
+		// Only pixels of color were removed
+		for loc := range latestHistory.PixelState {
+			cl.PixelData[loc] = color
+		}
+		f.releaseHistory(latestHistory)
+		return
 	}
 
+	f.syncLinkedCels(latestHistory)
+	f.AppendHistory(latestHistory)
 	cl.Redraw()
 	f.RedrawRenderLayer()
 }
 
-// FlipVertical flips the layer vertically, or flips the selection if anything
-// is selected
+// FlipHorizontal flips the layer horizontally, or previews flipping the
+// selection if anything is selected
+func (f *File) FlipHorizontal() {
+	if f.DoingSelection {
+		f.PreviewFlipSelection(true)
+		return
+	}
+	f.FlipLayer(true)
+}
+
+// FlipVertical flips the layer vertically, or previews flipping the selection
+// if anything is selected
 func (f *File) FlipVertical() {
-	latestHistory := HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer}
+	if f.DoingSelection {
+		f.PreviewFlipSelection(false)
+		return
+	}
+	f.FlipLayer(false)
+}
 
-	var sx, sy int32 = 0, 0
-	mx, my := f.CanvasWidth, f.CanvasHeight
+// FlipLayer flips the current layer, the selection is committed first
+func (f *File) FlipLayer(horizontal bool) {
+	if f.WarnHistoryCap(int(f.CanvasWidth * f.CanvasHeight)) {
+		return
+	}
+	f.CommitSelection()
+	f.flipLayer(f.CurrentLayer, horizontal)
+	f.RedrawRenderLayer()
+}
 
-	if f.DoingSelection {
-		sx = f.SelectionBounds[0]
-		sy = f.SelectionBounds[1]
-		mx = f.SelectionBounds[2] + 1
-		my = (f.SelectionBounds[1] + f.SelectionBounds[3]) + 1
-	} else {
-		// If selection is modified, it will be added to history on commit
-		CurrentFile.AppendHistory(latestHistory)
+// FlipAllLayers flips every layer, which flips the whole image, as one
+// history action
+func (f *File) FlipAllLayers(horizontal bool) {
+	if f.WarnHistoryCap(int(f.CanvasWidth*f.CanvasHeight) * len(f.Layers)) {
+		return
+	}
+	f.CommitSelection()
+	f.BeginTransaction()
+	for i := range f.Layers {
+		f.flipLayer(int32(i), horizontal)
 	}
+	f.EndTransaction()
+	f.RedrawRenderLayer()
+}
+
+// flipLayer flips the layer at index and adds it to the history
+func (f *File) flipLayer(index int32, horizontal bool) {
+	latestHistory := NewHistoryPixel(index)
+	f.AppendHistory(latestHistory)
 
 	// Swap the pixels over
-	cl := f.GetCurrentLayer()
-	for x := sx; x < mx; x++ {
-		for y := sy; y < my/2; y++ {
+	layer := f.Layers[index]
+	mx, my := f.CanvasWidth, f.CanvasHeight
+	if horizontal {
+		mx /= 2
+	} else {
+		my /= 2
+	}
+	for y := int32(0); y < my; y++ {
+		for x := int32(0); x < mx; x++ {
 			lpos := IntVec2{x, y}
-			rpos := IntVec2{x, my - y - 1}
+			rpos := IntVec2{f.CanvasWidth - x - 1, y}
+			if !horizontal {
+				rpos = IntVec2{x, f.CanvasHeight - y - 1}
+			}
 
-			lcur := cl.PixelData[lpos]
-			rcur := cl.PixelData[rpos]
+			lcur := layer.PixelData[lpos]
+			rcur := layer.PixelData[rpos]
 
-			// Update selection
-			if f.DoingSelection {
-				f.Selection[lpos], f.Selection[rpos] = f.Selection[rpos], f.Selection[lpos]
-			} else {
-				l := latestHistory.PixelState[lpos]
-				l.Prev = lcur
-				l.Current = rcur
-				latestHistory.PixelState[lpos] = l
-
-				r := latestHistory.PixelState[rpos]
-				r.Prev = rcur
-				r.Current = lcur
-				latestHistory.PixelState[rpos] = r
-
-				cl.PixelData[lpos] = rcur
-				cl.PixelData[rpos] = lcur
-			}
+			l := latestHistory.PixelState[lpos]
+			l.Prev = lcur
+			l.Current = rcur
+			latestHistory.PixelState[lpos] = l
 
-		}
-	}
+			r := latestHistory.PixelState[rpos]
+			r.Prev = rcur
+			r.Current = lcur
+			latestHistory.PixelState[rpos] = r
 
-	if f.DoingSelection && !f.SelectionMoving {
-		// Allow CommitSelection to detect a change
-		f.MoveSelection(0, 0)
+			layer.PixelData[lpos] = rcur
+			layer.PixelData[rpos] = lcur
+		}
 	}
+	f.syncLinkedCels(latestHistory)
 
-	cl.Redraw()
-	f.RedrawRenderLayer()
+	layer.Redraw()
 }
 
 // Undo undoes an action
 func (f *File) Undo() {
+	// The grouped actions would be split up
+	if f.InTransaction() {
+		return
+	}
+
 	if f.historyOffset < int32(len(f.History)) {
 		f.historyOffset++
 		index := int32(len(f.History)) - f.historyOffset
 		history := f.History[index]
 
-		var process func(historyItem interface{})
-		process = func(historyItem interface{}) {
-			switch typed := historyItem.(type) {
-			case CompoundHistory:
-				for i := 0; i < len(typed.Actions); i++ {
-					process(typed.Actions[i])
-				}
-			case HistoryPixel:
-				if f.DoingSelection {
-					f.Selection = make(map[IntVec2]rl.Color)
-					f.DoingSelection = false
-					f.SelectionMoving = false
-				}
-				current := f.CurrentLayer
-				f.SetCurrentLayer(typed.LayerIndex)
-				layer := f.GetCurrentLayer()
-				for pos, psd := range typed.PixelState {
-					layer.PixelData[pos] = psd.Prev
-				}
-				layer.Redraw()
-				f.SetCurrentLayer(current)
-			case HistoryLayer:
-				switch typed.HistoryLayerAction {
-				case HistoryLayerActionDelete:
-					f.RestoreLayer(typed.LayerIndex)
-				case HistoryLayerActionCreate:
-					f.DeleteLayer(typed.LayerIndex, false)
-				case HistoryLayerActionMoveUp:
-					f.MoveLayerUp(typed.LayerIndex, false)
-				case HistoryLayerActionMoveDown:
-					f.MoveLayerDown(typed.LayerIndex, false)
-				}
-			case HistoryResize:
-				f.CanvasWidthResizePreview = typed.PrevWidth
-				f.CanvasHeightResizePreview = typed.PrevHeight
-				f.CanvasWidth = typed.PrevWidth
-				f.CanvasHeight = typed.PrevHeight
-				for i, layer := range typed.PrevLayerState {
-					f.Layers[i].PixelData = layer
-					f.Layers[i].Resize(typed.PrevWidth, typed.PrevHeight, ResizeTL)
-				}
-			}
-		}
-
-		process(history)
+		f.undoAction(history)
+		collabRecord(f, history, true)
 
 		LayersUIRebuildList()
 		f.RedrawRenderLayer()
 	}
 }
 
+// undoAction reverts action. Undo rebuilds the UI and redraws the render
+// layer afterwards
+func (f *File) undoAction(action interface{}) {
+	switch typed := action.(type) {
+	case CompoundHistory:
+		// Undone in the reverse order that they were done
+		for i := len(typed.Actions) - 1; i >= 0; i-- {
+			f.undoAction(typed.Actions[i])
+		}
+	case HistoryPixel:
+		if f.DoingSelection {
+			f.Selection = make(map[IntVec2]rl.Color)
+			f.DoingSelection = false
+			f.SelectionMoving = false
+			f.SelectionPreview = nil
+		}
+		current := f.CurrentLayer
+		f.SetCurrentLayer(typed.LayerIndex)
+		layer := f.GetCurrentLayer()
+		for pos, psd := range typed.PixelState {
+			layer.PixelData[pos] = psd.Prev
+		}
+		layer.Chunks.RedrawChunks(layer.PixelData, ChunksOf(typed.PixelState))
+		f.SetCurrentLayer(current)
+	case HistoryLayer:
+		switch typed.HistoryLayerAction {
+		case HistoryLayerActionDelete:
+			f.RestoreLayer(typed.LayerIndex, typed.Layer)
+		case HistoryLayerActionCreate:
+			f.DeleteLayer(typed.LayerIndex, false)
+		}
+	case HistoryLayerMove:
+		f.MoveLayer(typed.To, typed.From, false)
+	case HistoryPalette:
+		f.setPaletteColors(typed.PaletteIndex, typed.Prev)
+	case HistoryLayerEffects:
+		f.setLayerEffects(typed.LayerIndex, typed.Prev)
+	case HistoryCelLinks:
+		f.setCelLinks(typed.LayerIndex, typed.Prev)
+	case HistoryResize:
+		f.CanvasWidthResizePreview = typed.PrevWidth
+		f.CanvasHeightResizePreview = typed.PrevHeight
+		f.CanvasWidth = typed.PrevWidth
+		f.CanvasHeight = typed.PrevHeight
+		for i, layer := range typed.PrevLayerState {
+			f.Layers[i].PixelData = layer
+			f.Layers[i].Resize(typed.PrevWidth, typed.PrevHeight, ResizeTL)
+		}
+		f.RenderLayer.ResizeOffset(typed.PrevWidth, typed.PrevHeight, 0, 0)
+	}
+}
+
 // Redo redoes an action
 func (f *File) Redo() {
+	if f.InTransaction() {
+		return
+	}
+
 	if f.historyOffset > 0 {
 		index := int32(len(f.History)) - f.historyOffset
 		f.historyOffset--
 		history := f.History[index]
 
-		var process func(historyItem interface{})
-		process = func(historyItem interface{}) {
-			switch typed := historyItem.(type) {
-			case CompoundHistory:
-				for i := len(typed.Actions) - 1; i >= 0; i-- {
-					process(typed.Actions[i])
-				}
-			case HistoryPixel:
-				current := f.CurrentLayer
-				f.SetCurrentLayer(typed.LayerIndex)
-				layer := f.GetCurrentLayer()
-				for pos, psd := range typed.PixelState {
-					layer.PixelData[pos] = psd.Current
-				}
-				layer.Redraw()
-				f.SetCurrentLayer(current)
-			case HistoryLayer:
-				switch typed.HistoryLayerAction {
-				case HistoryLayerActionDelete:
-					f.DeleteLayer(typed.LayerIndex, false)
-				case HistoryLayerActionCreate:
-					f.RestoreLayer(typed.LayerIndex)
-				case HistoryLayerActionMoveUp:
-					f.MoveLayerUp(typed.LayerIndex, false)
-				case HistoryLayerActionMoveDown:
-					f.MoveLayerDown(typed.LayerIndex, false)
-				}
-			case HistoryResize:
-				f.CanvasWidthResizePreview = typed.CurrentWidth
-				f.CanvasHeightResizePreview = typed.CurrentHeight
-				f.CanvasWidth = typed.CurrentWidth
-				f.CanvasHeight = typed.CurrentHeight
-				for i, layer := range typed.CurrentLayerState {
-					f.Layers[i].PixelData = layer
-					f.Layers[i].Resize(typed.CurrentWidth, typed.CurrentHeight, ResizeTL)
-				}
-			}
-		}
-
-		process(history)
+		f.redoAction(history)
+		collabRecord(f, history, false)
 
 		LayersUIRebuildList()
 		f.RedrawRenderLayer()
 	}
 }
 
+// redoAction applies action again. Redo rebuilds the UI and redraws the
+// render layer afterwards
+func (f *File) redoAction(action interface{}) {
+	switch typed := action.(type) {
+	case CompoundHistory:
+		for i := 0; i < len(typed.Actions); i++ {
+			f.redoAction(typed.Actions[i])
+		}
+	case HistoryPixel:
+		current := f.CurrentLayer
+		f.SetCurrentLayer(typed.LayerIndex)
+		layer := f.GetCurrentLayer()
+		for pos, psd := range typed.PixelState {
+			layer.PixelData[pos] = psd.Current
+		}
+		layer.Chunks.RedrawChunks(layer.PixelData, ChunksOf(typed.PixelState))
+		f.SetCurrentLayer(current)
+	case HistoryLayer:
+		switch typed.HistoryLayerAction {
+		case HistoryLayerActionDelete:
+			f.DeleteLayer(typed.LayerIndex, false)
+		case HistoryLayerActionCreate:
+			f.RestoreLayer(typed.LayerIndex, typed.Layer)
+		}
+	case HistoryLayerMove:
+		f.MoveLayer(typed.From, typed.To, false)
+	case HistoryPalette:
+		f.setPaletteColors(typed.PaletteIndex, typed.Current)
+	case HistoryLayerEffects:
+		f.setLayerEffects(typed.LayerIndex, typed.Current)
+	case HistoryCelLinks:
+		f.setCelLinks(typed.LayerIndex, typed.Current)
+	case HistoryResize:
+		f.CanvasWidthResizePreview = typed.CurrentWidth
+		f.CanvasHeightResizePreview = typed.CurrentHeight
+		f.CanvasWidth = typed.CurrentWidth
+		f.CanvasHeight = typed.CurrentHeight
+		for i, layer := range typed.CurrentLayerState {
+			f.Layers[i].PixelData = layer
+			f.Layers[i].Resize(typed.CurrentWidth, typed.CurrentHeight, ResizeTL)
+		}
+		f.RenderLayer.ResizeOffset(typed.CurrentWidth, typed.CurrentHeight, 0, 0)
+	}
+}
+
 // Destroy unloads each layer's canvas
 func (f *File) Destroy() {
+	f.Unlock()
+	f.removeFilePalette()
+	history := f.History
+	f.History = nil
+	for _, action := range history {
+		f.releaseHistory(action)
+	}
 	for _, layer := range f.Layers {
-		rl.UnloadRenderTexture(layer.Canvas)
+		layer.Unload()
 	}
+	f.RenderLayer.Unload()
+	f.previewLayer.Unload()
 
 	for i, file := range Files {
 		if file == f {
@@ -1145,56 +1854,38 @@ func (f *File) Destroy() {
 
 // SaveAs saves the file differently depending on the extension
 func (f *File) SaveAs(path string) {
-	file, err := os.Create(path)
-	if err != nil {
-		log.Fatal(err)
+	if f.ReadOnly && path == f.FileDir {
+		UIWarning(Tf("\"%s\" is read-only", f.Filename))
+		return
+	}
+	if f.saving {
+		UIWarning(Tf("\"%s\" is still being saved", f.Filename))
+		return
 	}
-	defer file.Close()
 
+	// Everything is copied or encoded here, the file is written in the
+	// background so it can be drawn on in the meantime
+	var write func(w io.Writer) error
+	alts := make(map[string]func(w io.Writer) error)
 	ext := filepath.Ext(path)
 	switch ext {
 	case ".png":
-		// Create a colored image of the given width and height.
-		img := image.NewNRGBA(image.Rect(0, 0, int(f.CanvasWidth), int(f.CanvasHeight)))
-
-		for x := int32(0); x < f.CanvasWidth; x++ {
-			for y := int32(0); y < f.CanvasHeight; y++ {
-				col := rl.Blank
-				loc := IntVec2{x, y}
-				for _, layer := range f.Layers[:len(f.Layers)-1] {
-					if !layer.Hidden {
-						if layerColor, ok := layer.PixelData[loc]; ok {
-							col = BlendWithOpacity(col, layerColor, layer.BlendMode)
-						}
-					}
-				}
-
-				img.Set(int(x), int(y), color.NRGBA{
-					col.R,
-					col.G,
-					col.B,
-					col.A,
-				})
-			}
+		img := f.compositeImage(nil, 1, nil, ExportFilter(nil))
+		text := f.Metadata.pngText()
+		write = func(w io.Writer) error {
+			return encodePNGWithText(w, img, text)
 		}
 
-		file, err := os.Create(path)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		if err := png.Encode(file, img); err != nil {
-			file.Close()
-			log.Fatal(err)
-		}
-
-		if err := file.Close(); err != nil {
-			log.Fatal(err)
+		// Every alternate palette is exported next to the original
+		base := strings.TrimSuffix(path, ext)
+		for _, alt := range f.AltPalettes {
+			altImg := f.compositeImage(alt, 1, nil, ExportFilter(nil))
+			alts[base+"_"+safeFilename(alt.Name)+ext] = func(w io.Writer) error {
+				return encodePNGWithText(w, altImg, text)
+			}
 		}
 
 	case ".pix":
-		enc := gob.NewEncoder(file)
-
 		gob.Register(rl.Color{})
 		gob.Register(IntVec2{})
 
@@ -1204,16 +1895,43 @@ func (f *File) SaveAs(path string) {
 			CanvasHeight: f.CanvasHeight,
 			TileWidth:    f.TileWidth,
 			TileHeight:   f.TileHeight,
+			PixelAspect:  f.PixelAspect,
 			Layers:       make([]*LayerSer, len(f.Layers)),
 			Animations:   make([]*AnimationSer, len(f.Animations)),
+			AltPalettes:  make([]*AltPalette, len(f.AltPalettes)),
+			SwapBase:     append([]rl.Color{}, f.SwapBase...),
+			Guides:       append([]Guide{}, f.Guides...),
+			Metadata:     f.Metadata,
+			NineSlice:    f.NineSlice,
+
+			ExportProfiles:  append([]ExportPreset{}, f.ExportProfiles...),
+			MaxTileColors:   f.MaxTileColors,
+			ColorTileWidth:  f.ColorTileWidth,
+			ColorTileHeight: f.ColorTileHeight,
+			NoPreviewLayer:  true,
+		}
+		if index := f.filePalette(); index >= 0 {
+			palette := Settings.PaletteData[index]
+			fSer.PaletteName = palette.Name
+			for _, color := range palette.data {
+				fSer.Palette = append(fSer.Palette, ColorToHex(color))
+			}
 		}
 		for l := range f.Layers {
+			pixelData := make(map[IntVec2]rl.Color, len(f.Layers[l].PixelData))
+			for loc, color := range f.Layers[l].PixelData {
+				pixelData[loc] = color
+			}
 			fSer.Layers[l] = &LayerSer{
+				ID:        f.Layers[l].ID,
 				Name:      f.Layers[l].Name,
 				Hidden:    f.Layers[l].Hidden,
-				PixelData: f.Layers[l].PixelData,
+				NoExport:  f.Layers[l].NoExport,
+				CelLinks:  copyCelLinks(f.Layers[l].CelLinks),
+				PixelData: pixelData,
 				Width:     f.Layers[l].Width,
 				Height:    f.Layers[l].Height,
+				Effects:   f.Layers[l].Effects,
 			}
 		}
 		for a := range f.Animations {
@@ -1222,15 +1940,71 @@ func (f *File) SaveAs(path string) {
 				FrameStart: f.Animations[a].FrameStart,
 				FrameEnd:   f.Animations[a].FrameEnd,
 				Timing:     f.Animations[a].Timing,
+				Export:     f.Animations[a].Export,
 			}
 		}
-
-		if err := enc.Encode(fSer); err != nil {
-			log.Println(err)
+		for a, alt := range f.AltPalettes {
+			fSer.AltPalettes[a] = &AltPalette{
+				Name: alt.Name,
+				From: append([]rl.Color{}, alt.From...),
+				To:   append([]rl.Color{}, alt.To...),
+			}
+		}
+		write = func(w io.Writer) error {
+			return gob.NewEncoder(w).Encode(fSer)
 		}
 
 	default:
 		log.Printf("Can't save: extension \"%s\" not supported\n", ext)
+		PlaySoundCue(SoundError)
+		return
+	}
+
+	// The lock is only taken once it's certain the file will be written
+	if err := f.Lock(path); err != nil {
+		log.Println(err)
+		UIWarning(err.Error())
+		return
+	}
+
+	// Changes made while it's being written mark it as changed again
+	f.saving = true
+	f.FileChanged = false
+	pendingSaves++
+	backups, backupDir := Settings.Backups, Settings.BackupDir
+	go func() {
+		if err := rotateBackups(path, backups, backupDir); err != nil {
+			log.Println(err)
+		}
+		err := writeFileAtomic(path, write)
+		if err == nil {
+			for altPath, writeAlt := range alts {
+				if err := writeFileAtomic(altPath, writeAlt); err != nil {
+					log.Println(err)
+				}
+			}
+		}
+		saveResults <- saveResult{f, path, err}
+	}()
+}
+
+// finishSave is called once the file has been written to path
+func (f *File) finishSave(path string, err error) {
+	f.saving = false
+	if err != nil {
+		log.Println(err)
+		f.FileChanged = true
+		// The file is still where it was, so it takes that lock back
+		if path != f.FileDir {
+			f.Unlock()
+			if len(f.FileDir) > 0 && !f.ReadOnly {
+				if err := f.Lock(f.FileDir); err != nil {
+					log.Println(err)
+				}
+			}
+		}
+		UIWarning(Tf("Couldn't save \"%s\": %s", filepath.Base(path), err.Error()))
+		EditorsUIRebuild()
 		return
 	}
 
@@ -1240,97 +2014,306 @@ func (f *File) SaveAs(path string) {
 	f.PathDir = strings.Join(spl[:len(spl)-1], "/")
 	f.FileDir = path
 	log.Println(f.Filename, f.PathDir, f.FileDir)
-	f.FileChanged = false
 	EditorsUIRebuild()
+	PlaySoundCue(SoundSaved)
 }
 
-// Open a file
-func Open(openPath string) *File {
-	var f *File
+// encodePNG writes the composited layers as a png, recolored by alt if it
+// isn't nil. Every pixel is drawn as a scale*scale square. If finish isn't nil
+// it's applied to every color last, see ExportPreset.finishColor. Only the
+// layers include returns true for are composited
+func (f *File) encodePNG(w io.Writer, alt *AltPalette, scale int32, finish func(rl.Color) rl.Color, include func(layer *Layer) bool) error {
+	if scale < 1 {
+		return fmt.Errorf("Scale must be at least 1, got %d", scale)
+	}
+	return encodePNGWithText(w, f.compositeImage(alt, scale, finish, include), f.Metadata.pngText())
+}
 
-	fi, err := os.Stat(openPath)
+// compositeImage returns the layers composited as an image, see encodePNG
+func (f *File) compositeImage(alt *AltPalette, scale int32, finish func(rl.Color) rl.Color, include func(layer *Layer) bool) *image.NRGBA {
+	// Create a colored image of the given width and height.
+	img := image.NewNRGBA(image.Rect(0, 0, int(f.CanvasWidth*scale), int(f.CanvasHeight*scale)))
+
+	for x := int32(0); x < f.CanvasWidth; x++ {
+		for y := int32(0); y < f.CanvasHeight; y++ {
+			col := f.compositePixel(IntVec2{x, y}, include)
+			if alt != nil {
+				col = alt.Remap(col)
+			}
+			if finish != nil {
+				col = finish(col)
+			}
+			for sx := int32(0); sx < scale; sx++ {
+				for sy := int32(0); sy < scale; sy++ {
+					img.Set(int(x*scale+sx), int(y*scale+sy), color.NRGBA{
+						col.R,
+						col.G,
+						col.B,
+						col.A,
+					})
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// safeFilename replaces the characters of name which can't be in a file
+// name, for names which are put into export paths
+func safeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// ExportPreset exports the file using the preset, returning where it was
+// exported to
+func (f *File) ExportPreset(preset ExportPreset) (string, error) {
+	if preset.Format != "png" && preset.Format != "spritesheet" {
+		return "", fmt.Errorf("Export format \"%s\" not supported", preset.Format)
+	}
+
+	name := strings.TrimSuffix(f.Filename, filepath.Ext(f.Filename))
+	dest := strings.NewReplacer(
+		"{name}", name,
+		"{scale}", fmt.Sprintf("%d", preset.Scale),
+		"{dir}", f.PathDir,
+	).Replace(preset.Destination)
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(f.PathDir, dest)
+	}
+
+	if preset.Format == "spritesheet" {
+		return dest, f.ExportSpritesheet(dest, preset)
+	}
+
+	file, err := os.Create(dest)
 	if err != nil {
-		log.Println(err)
+		return "", err
 	}
-	if fi.Mode().IsRegular() {
+	defer file.Close()
 
-		switch filepath.Ext(openPath) {
-		case ".pix":
-			reader, err := os.Open(openPath)
-			if err != nil {
-				log.Fatal(err)
+	finish, err := preset.finishColor()
+	if err != nil {
+		return "", err
+	}
+	return dest, f.encodePNG(file, nil, preset.Scale, finish, preset.Include())
+}
+
+// finishColor returns the function which applies the preset's matte or
+// premultiplied alpha to an exported color
+func (preset ExportPreset) finishColor() (func(rl.Color) rl.Color, error) {
+	if preset.Matte != "" {
+		matte, err := HexToColor(preset.Matte)
+		if err != nil {
+			return nil, fmt.Errorf("Can't export with matte \"%s\": %v", preset.Matte, err)
+		}
+		matte.A = 255
+		return func(c rl.Color) rl.Color {
+			return AlphaOver(matte, c)
+		}, nil
+	}
+	if preset.Premultiplied {
+		return func(c rl.Color) rl.Color {
+			premultiply := func(v uint8) uint8 {
+				return uint8((uint16(v)*uint16(c.A) + 127) / 255)
 			}
-			defer reader.Close()
-			dec := gob.NewDecoder(reader)
-			fileSer := &FileSer{}
-			if err := dec.Decode(&fileSer); err != nil {
+			return rl.NewColor(premultiply(c.R), premultiply(c.G), premultiply(c.B), c.A)
+		}, nil
+	}
+	return func(c rl.Color) rl.Color {
+		return c
+	}, nil
+}
+
+// finishImage applies finish to every pixel of img
+func finishImage(img *image.NRGBA, finish func(rl.Color) rl.Color) {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			col := finish(rl.NewColor(c.R, c.G, c.B, c.A))
+			img.SetNRGBA(x, y, color.NRGBA{col.R, col.G, col.B, col.A})
+		}
+	}
+}
+
+// BatchExport exports every open file with every export preset
+func BatchExport() {
+	failed := false
+	for _, f := range Files {
+		for _, preset := range Settings.ExportPresets {
+			dest, err := f.ExportPreset(preset)
+			if err != nil {
 				log.Println(err)
+				failed = true
+				continue
 			}
+			log.Println("Exported", f.Filename, "to", dest)
+		}
+	}
 
-			f = NewFile(fileSer.CanvasWidth, fileSer.CanvasHeight, fileSer.TileWidth, fileSer.TileHeight)
-			f.PathDir = path.Dir(openPath)
-			f.FileDir = openPath
-			f.DrawGrid = fileSer.DrawGrid
-
-			f.Layers = make([]*Layer, len(fileSer.Layers))
-			for i, layer := range fileSer.Layers {
-				f.Layers[i] = &Layer{
-					Name:      layer.Name,
-					Hidden:    layer.Hidden,
-					PixelData: layer.PixelData,
-					Width:     layer.Width,
-					Height:    layer.Height,
-					Canvas:    rl.LoadRenderTexture(layer.Width, layer.Height),
+	if failed {
+		PlaySoundCue(SoundError)
+	} else {
+		PlaySoundCue(SoundExported)
+	}
+}
+
+// Open a file
+func Open(openPath string) (*File, error) {
+	var f *File
+
+	fi, err := os.Stat(openPath)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("Can't open \"%s\": not a file", openPath)
+	}
+	switch filepath.Ext(openPath) {
+	case ".pix":
+		reader, err := os.Open(openPath)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		dec := gob.NewDecoder(reader)
+		fileSer := &FileSer{}
+		if err := dec.Decode(&fileSer); err != nil {
+			return nil, err
+		}
+
+		f = NewFile(fileSer.CanvasWidth, fileSer.CanvasHeight, fileSer.TileWidth, fileSer.TileHeight)
+		f.PathDir = path.Dir(openPath)
+		f.FileDir = openPath
+		f.DrawGrid = fileSer.DrawGrid
+		// Files saved before PixelAspect existed have square pixels
+		if fileSer.PixelAspect > 0 {
+			f.PixelAspect = fileSer.PixelAspect
+		}
+		f.AltPalettes = fileSer.AltPalettes
+		f.SwapBase = fileSer.SwapBase
+		f.Guides = fileSer.Guides
+		f.Metadata = fileSer.Metadata
+		f.NineSlice = fileSer.NineSlice
+		f.ExportProfiles = fileSer.ExportProfiles
+		f.MaxTileColors = fileSer.MaxTileColors
+		f.ColorTileWidth, f.ColorTileHeight = fileSer.ColorTileWidth, fileSer.ColorTileHeight
+		if len(fileSer.Palette) > 0 {
+			colors := make([]rl.Color, 0, len(fileSer.Palette))
+			for _, hex := range fileSer.Palette {
+				if color, err := HexToColor(hex); err == nil {
+					colors = append(colors, color)
 				}
-				f.Layers[i].Redraw()
 			}
-			f.RenderLayer = NewLayer(f.CanvasWidth, f.CanvasHeight, "render", rl.Blank, true)
-			f.Animations = make([]*Animation, len(fileSer.Animations))
-			for i, animation := range fileSer.Animations {
-				f.Animations[i] = &Animation{
-					Name:       animation.Name,
-					FrameStart: animation.FrameStart,
-					FrameEnd:   animation.FrameEnd,
-					Timing:     animation.Timing,
-				}
+			f.setFilePalette(fileSer.PaletteName, colors)
+		}
+
+		for _, layer := range f.Layers {
+			layer.Unload()
+		}
+		savedLayers := fileSer.SavedLayers()
+		f.Layers = make([]*Layer, len(savedLayers))
+		for i, layer := range savedLayers {
+			f.Layers[i] = &Layer{
+				ID:        reserveLayerID(layer.ID),
+				Name:      layer.Name,
+				Hidden:    layer.Hidden,
+				NoExport:  layer.NoExport,
+				CelLinks:  copyCelLinks(layer.CelLinks),
+				PixelData: layer.PixelData,
+				Width:     layer.Width,
+				Height:    layer.Height,
+				Effects:   layer.Effects,
+				Chunks:    NewChunks(layer.Width, layer.Height),
 			}
+			f.Layers[i].Redraw()
+		}
+		f.linkProfileLayerIDs()
+		f.RenderLayer.Unload()
+		f.RenderLayer = NewLayer(f.CanvasWidth, f.CanvasHeight, "render", rl.Blank, false)
+		f.Animations = make([]*Animation, len(fileSer.Animations))
+		for i, animation := range fileSer.Animations {
+			f.Animations[i] = &Animation{
+				Name:       animation.Name,
+				FrameStart: animation.FrameStart,
+				FrameEnd:   animation.FrameEnd,
+				Timing:     animation.Timing,
+				Export:     animation.Export,
+			}
+		}
 
-			spl := strings.Split(openPath, "/")
-			f.Filename = spl[len(spl)-1]
+		spl := strings.Split(openPath, "/")
+		f.Filename = spl[len(spl)-1]
 
-			CurrentFile = f
+		CurrentFile = f
 
-			AnimationsUIRebuildList()
-			LayersUIRebuildList()
+		AnimationsUIRebuildList()
+		LayersUIRebuildList()
+		if paletteName != nil {
+			PaletteUIRebuildPalette()
+		}
 
-		case ".png":
-			tex := rl.LoadTexture(openPath)
-			pixelColors := rl.LoadImageColors(rl.LoadImageFromTexture(tex))
+	case ".png":
+		tex := rl.LoadTexture(openPath)
+		pixelColors := rl.LoadImageColors(rl.LoadImageFromTexture(tex))
 
-			f = NewFile(tex.Width, tex.Height, 8, 8)
-			f.PathDir = path.Dir(openPath)
-			f.FileDir = openPath
+		f = NewFile(tex.Width, tex.Height, 8, 8)
+		f.PathDir = path.Dir(openPath)
+		f.FileDir = openPath
 
-			editedLayer := NewLayer(f.CanvasWidth, f.CanvasHeight, "background", rl.Blank, false)
+		editedLayer := NewLayer(f.CanvasWidth, f.CanvasHeight, "background", rl.Blank, false)
 
-			rl.BeginTextureMode(editedLayer.Canvas)
-			for y := int32(0); y < f.CanvasHeight; y++ {
-				for x := int32(0); x < f.CanvasWidth; x++ {
-					color := pixelColors[x+y*f.CanvasWidth]
+		// Transparent pixels are left out so that they don't load chunks
+		for y := int32(0); y < f.CanvasHeight; y++ {
+			for x := int32(0); x < f.CanvasWidth; x++ {
+				if color := pixelColors[x+y*f.CanvasWidth]; color.A > 0 {
 					editedLayer.PixelData[IntVec2{x, y}] = color
 				}
 			}
-			f.RenderLayer = NewLayer(f.CanvasWidth, f.CanvasHeight, "render", rl.Blank, true)
-			rl.EndTextureMode()
-			editedLayer.Redraw()
+		}
+		editedLayer.Redraw()
 
-			f.Layers = []*Layer{
-				editedLayer,
-				NewLayer(f.CanvasWidth, f.CanvasHeight, "hidden", rl.Blank, true),
-			}
+		for _, layer := range f.Layers {
+			layer.Unload()
+		}
+		f.Layers = []*Layer{editedLayer}
 
-			spl := strings.Split(openPath, "/")
-			f.Filename = spl[len(spl)-1]
+		if text, err := readPNGText(openPath); err == nil {
+			f.Metadata.setPNGText(text)
+		} else {
+			log.Println(err)
+		}
+
+		spl := strings.Split(openPath, "/")
+		f.Filename = spl[len(spl)-1]
+	case ".pxo":
+		f, err = ImportPixelorama(openPath)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Can't open \"%s\": extension not supported", openPath)
+	}
+
+	if len(f.FileDir) > 0 {
+		if ViewOnly {
+			f.ReadOnly = true
+		} else if err := f.Lock(f.FileDir); err != nil {
+			// Viewing is still fine, saving would overwrite the other
+			// instance's changes
+			log.Println(err)
+			UIWarning(Tf("%s, it was opened read-only", err.Error()))
+			f.ReadOnly = true
 		}
 	}
 
@@ -1338,5 +2321,25 @@ func Open(openPath string) *File {
 	f.RedrawRenderLayer()
 	EditorsUIRebuild()
 
-	return f
+	return f, nil
+}
+
+// OpenPaths opens every path as a tab, used for files passed as arguments.
+// Paths which can't be opened are logged and skipped. Returns the opened files
+func OpenPaths(paths []string) []*File {
+	opened := make([]*File, 0, len(paths))
+	for _, p := range paths {
+		// Saving needs the full path
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+
+		f, err := Open(p)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		opened = append(opened, f)
+	}
+	return opened
 }
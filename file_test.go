@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// newTestFile returns a file with blank layers named after their index.
+// Nothing is drawn on them, so no textures are loaded and no window is needed
+func newTestFile(layers int) *File {
+	f := &File{
+		CanvasWidth:       16,
+		CanvasHeight:      16,
+		TileWidth:         8,
+		TileHeight:        8,
+		HistoryMaxActions: 100,
+		RenderLayer:       NewLayer(16, 16, "render", rl.Blank, false),
+	}
+	for i := 0; i < layers; i++ {
+		f.Layers = append(f.Layers, NewLayer(16, 16, strconv.Itoa(i), rl.Blank, false))
+	}
+	return f
+}
+
+// layerOrder returns the names of the layers from the bottom up
+func layerOrder(f *File) string {
+	order := ""
+	for _, layer := range f.Layers {
+		order += layer.Name
+	}
+	return order
+}
+
+// moveLayers moves the layers without the UI and returns the history which
+// AppendHistory would have kept
+func moveLayers(t *testing.T, f *File, moves [][2]int32) []interface{} {
+	history := make([]interface{}, 0, len(moves))
+	for _, move := range moves {
+		if err := f.MoveLayer(move[0], move[1], false); err != nil {
+			t.Fatal(err)
+		}
+		history = append(history, HistoryLayerMove{move[0], move[1]})
+	}
+	return history
+}
+
+func TestMoveLayer(t *testing.T) {
+	tests := []struct {
+		from, to int32
+		order    string
+	}{
+		{0, 3, "1230"},
+		{3, 0, "3012"},
+		{1, 2, "0213"},
+		{2, 1, "0213"},
+	}
+	for _, test := range tests {
+		f := newTestFile(4)
+		if err := f.MoveLayer(test.from, test.to, false); err != nil {
+			t.Fatal(err)
+		}
+		if order := layerOrder(f); order != test.order {
+			t.Errorf("MoveLayer(%d, %d) = %s, want %s", test.from, test.to, order, test.order)
+		}
+	}
+}
+
+func TestMoveLayerOutOfRange(t *testing.T) {
+	f := newTestFile(3)
+	for _, move := range [][2]int32{{0, 3}, {-1, 1}, {1, 1}, {2, 3}} {
+		if err := f.MoveLayer(move[0], move[1], false); err == nil {
+			t.Errorf("MoveLayer(%d, %d) didn't fail", move[0], move[1])
+		}
+	}
+	if order := layerOrder(f); order != "012" {
+		t.Errorf("layers = %s after failed moves, want 012", order)
+	}
+}
+
+func TestMoveLayerKeepsCurrentLayer(t *testing.T) {
+	f := newTestFile(4)
+	f.SetCurrentLayer(2)
+	moveLayers(t, f, [][2]int32{{2, 0}, {1, 3}, {0, 3}})
+	if name := f.GetCurrentLayer().Name; name != "2" {
+		t.Errorf("current layer = %s, want 2", name)
+	}
+}
+
+func TestUndoRedoLayerMoves(t *testing.T) {
+	tests := [][][2]int32{
+		// Up repeatedly, like pressing move up several times
+		{{0, 1}, {1, 2}, {2, 3}},
+		// Down repeatedly
+		{{3, 2}, {2, 1}, {1, 0}},
+		// Up and down again, undoing must not replay the last direction
+		{{1, 2}, {2, 1}, {1, 2}},
+		// Dragged across several layers
+		{{0, 3}, {3, 1}, {2, 0}},
+	}
+	for _, moves := range tests {
+		f := newTestFile(4)
+		f.SetCurrentLayer(1)
+
+		// The order and current layer before each move
+		orders := []string{layerOrder(f)}
+		currents := []int32{f.CurrentLayer}
+		history := make([]interface{}, 0, len(moves))
+		for _, move := range moves {
+			history = append(history, moveLayers(t, f, [][2]int32{move})...)
+			orders = append(orders, layerOrder(f))
+			currents = append(currents, f.CurrentLayer)
+		}
+
+		for i := len(history) - 1; i >= 0; i-- {
+			f.undoAction(history[i])
+			if order := layerOrder(f); order != orders[i] {
+				t.Errorf("%v: layers = %s after undoing move %d, want %s", moves, order, i, orders[i])
+			}
+			if f.CurrentLayer != currents[i] {
+				t.Errorf("%v: current layer = %d after undoing move %d, want %d", moves, f.CurrentLayer, i, currents[i])
+			}
+		}
+		for i, action := range history {
+			f.redoAction(action)
+			if order := layerOrder(f); order != orders[i+1] {
+				t.Errorf("%v: layers = %s after redoing move %d, want %s", moves, order, i, orders[i+1])
+			}
+		}
+	}
+}
+
+func TestUndoCompoundLayerMoves(t *testing.T) {
+	f := newTestFile(4)
+	history := CompoundHistory{moveLayers(t, f, [][2]int32{{0, 2}, {3, 0}, {1, 3}})}
+	after := layerOrder(f)
+
+	f.undoAction(history)
+	if order := layerOrder(f); order != "0123" {
+		t.Errorf("layers = %s after undoing, want 0123", order)
+	}
+	f.redoAction(history)
+	if order := layerOrder(f); order != after {
+		t.Errorf("layers = %s after redoing, want %s", order, after)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// fillBatchSize is how many spans the fill worker sends at once
+const fillBatchSize = 256
+
+// fillSpan is a row of filled pixels from X0 to X1 inclusive
+type fillSpan struct {
+	Y, X0, X1 int32
+}
+
+// fillJob is a fill which is worked out on another goroutine so that big
+// fills don't stall the UI. The spans are previewed as they're found and
+// drawn to the layer once they've all been found
+type fillJob struct {
+	file  *File
+	layer *Layer
+	// colorAt is the color each filled pixel gets
+	colorAt func(x, y int32) rl.Color
+
+	batches chan []fillSpan
+	spans   []fillSpan
+}
+
+// currentFill is the fill being worked out, nil if there isn't one
+var currentFill *fillJob
+
+// StartFill starts filling the pixels of layer which are the same color as
+// the one at x, y. If contiguous is false every pixel of that color is
+// filled, otherwise only the ones connected to x, y are
+func StartFill(f *File, layer *Layer, x, y int32, contiguous bool, colorAt func(x, y int32) rl.Color) {
+	if currentFill != nil || x < 0 || y < 0 || x >= f.CanvasWidth || y >= f.CanvasHeight {
+		return
+	}
+
+	// The worker reads a flat copy of the layer, so the layer can still be
+	// drawn on while it works
+	width, height := f.CanvasWidth, f.CanvasHeight
+	pixels := make([]rl.Color, width*height)
+	for loc, color := range layer.PixelData {
+		if loc.X >= 0 && loc.Y >= 0 && loc.X < width && loc.Y < height {
+			pixels[loc.Y*width+loc.X] = color
+		}
+	}
+
+	job := &fillJob{
+		file:    f,
+		layer:   layer,
+		colorAt: colorAt,
+		batches: make(chan []fillSpan, 16),
+	}
+	currentFill = job
+
+	go func() {
+		defer close(job.batches)
+		batch := make([]fillSpan, 0, fillBatchSize)
+		emit := func(span fillSpan) {
+			batch = append(batch, span)
+			if len(batch) == fillBatchSize {
+				job.batches <- batch
+				batch = make([]fillSpan, 0, fillBatchSize)
+			}
+		}
+		if contiguous {
+			scanlineFill(pixels, width, height, x, y, emit)
+		} else {
+			replaceFill(pixels, width, height, x, y, emit)
+		}
+		if len(batch) > 0 {
+			job.batches <- batch
+		}
+	}()
+}
+
+// scanlineFill finds the spans connected to x, y which are the same color.
+// Each span is filled out to its ends before the rows above and below it are
+// looked at, so every pixel is only visited a few times
+func scanlineFill(pixels []rl.Color, width, height, x, y int32, emit func(fillSpan)) {
+	target := pixels[y*width+x]
+	visited := make([]bool, len(pixels))
+	matches := func(x, y int32) bool {
+		i := y*width + x
+		return !visited[i] && pixels[i] == target
+	}
+
+	seeds := []IntVec2{{x, y}}
+	for len(seeds) > 0 {
+		seed := seeds[len(seeds)-1]
+		seeds = seeds[:len(seeds)-1]
+		if !matches(seed.X, seed.Y) {
+			continue
+		}
+
+		x0, x1 := seed.X, seed.X
+		for x0 > 0 && matches(x0-1, seed.Y) {
+			x0--
+		}
+		for x1 < width-1 && matches(x1+1, seed.Y) {
+			x1++
+		}
+		for sx := x0; sx <= x1; sx++ {
+			visited[seed.Y*width+sx] = true
+		}
+		emit(fillSpan{seed.Y, x0, x1})
+
+		// Only the start of each run above and below needs to be a seed
+		for _, ny := range []int32{seed.Y - 1, seed.Y + 1} {
+			if ny < 0 || ny >= height {
+				continue
+			}
+			inRun := false
+			for sx := x0; sx <= x1; sx++ {
+				if matches(sx, ny) {
+					if !inRun {
+						seeds = append(seeds, IntVec2{sx, ny})
+					}
+					inRun = true
+				} else {
+					inRun = false
+				}
+			}
+		}
+	}
+}
+
+// replaceFill finds every span which is the same color as x, y
+func replaceFill(pixels []rl.Color, width, height, x, y int32, emit func(fillSpan)) {
+	target := pixels[y*width+x]
+	for sy := int32(0); sy < height; sy++ {
+		for sx := int32(0); sx < width; sx++ {
+			if pixels[sy*width+sx] != target {
+				continue
+			}
+			x0 := sx
+			for sx < width-1 && pixels[sy*width+sx+1] == target {
+				sx++
+			}
+			emit(fillSpan{sy, x0, sx})
+		}
+	}
+}
+
+// UpdateFill collects the spans the worker has found, once it's finished
+// they're drawn to the layer as one history action. It's called every frame
+func UpdateFill() {
+	job := currentFill
+	if job == nil {
+		return
+	}
+	for {
+		select {
+		case batch, ok := <-job.batches:
+			if !ok {
+				currentFill = nil
+				job.apply()
+				return
+			}
+			job.spans = append(job.spans, batch...)
+		default:
+			return
+		}
+	}
+}
+
+// apply draws the spans to the layer. Pixels are set directly and the layer is
+// redrawn once, drawing them one at a time is too slow for big fills
+func (job *fillJob) apply() {
+	f := job.file
+	layerIndex := int32(-1)
+	for i, layer := range f.Layers {
+		if layer == job.layer {
+			layerIndex = int32(i)
+		}
+	}
+	open := false
+	for _, file := range Files {
+		open = open || file == f
+	}
+	// The file was closed or the layer deleted while filling
+	if !open || layerIndex < 0 {
+		return
+	}
+
+	pixels := 0
+	for _, span := range job.spans {
+		pixels += int(span.X1 - span.X0 + 1)
+	}
+	if f.WarnHistoryCap(pixels) {
+		return
+	}
+
+	history := NewHistoryPixel(layerIndex)
+	for _, span := range job.spans {
+		for x := span.X0; x <= span.X1; x++ {
+			loc := IntVec2{x, span.Y}
+			oldColor, ok := job.layer.PixelData[loc]
+			if !ok {
+				oldColor = rl.Blank
+			}
+			color := job.colorAt(x, span.Y)
+			if color != rl.Blank {
+				color = f.lockedColor(BlendWithOpacity(oldColor, color, job.layer.BlendMode))
+			}
+			if color == oldColor {
+				continue
+			}
+			history.PixelState[loc] = PixelStateData{Prev: oldColor, Current: color}
+			job.layer.PixelData[loc] = color
+			for _, linked := range f.LinkedPixels(job.layer, loc) {
+				linkedOld, ok := job.layer.PixelData[linked]
+				if !ok {
+					linkedOld = rl.Blank
+				}
+				if state, ok := history.PixelState[linked]; ok {
+					linkedOld = state.Prev
+				}
+				history.PixelState[linked] = PixelStateData{Prev: linkedOld, Current: color}
+				job.layer.PixelData[linked] = color
+			}
+		}
+	}
+	if len(history.PixelState) == 0 {
+		f.releaseHistory(history)
+		return
+	}
+	f.AppendHistory(history)
+	job.layer.Redraw()
+	f.RedrawRenderLayer()
+}
+
+// DrawFillPreview draws the spans found so far, it's drawn on the preview
+// layer
+func DrawFillPreview() {
+	job := currentFill
+	if job == nil || job.file != CurrentFile {
+		return
+	}
+	for _, span := range job.spans {
+		color := job.colorAt(span.X0, span.Y)
+		color.A = 192
+		rl.DrawRectangle(span.X0, span.Y, span.X1-span.X0+1, 1, color)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// frameCount returns how many frames (tiles) the canvas has
+func (f *File) frameCount() int32 {
+	return f.tilesX() * ((f.CanvasHeight + f.TileHeight - 1) / f.TileHeight)
+}
+
+// frameTile returns the top left of the frame's tile
+func (f *File) frameTile(frame int32) IntVec2 {
+	tilesX := f.tilesX()
+	return IntVec2{(frame % tilesX) * f.TileWidth, (frame / tilesX) * f.TileHeight}
+}
+
+// setFrame sets every pixel of the frame's tile on the layer to colorAt,
+// which gets the position in the tile. It's one history action
+func (f *File) setFrame(layerIndex, frame int32, colorAt func(x, y int32) rl.Color) {
+	layer := f.Layers[layerIndex]
+	tile := f.frameTile(frame)
+	history := NewHistoryPixel(layerIndex)
+	for y := int32(0); y < f.TileHeight; y++ {
+		for x := int32(0); x < f.TileWidth; x++ {
+			loc := IntVec2{tile.X + x, tile.Y + y}
+			if loc.X >= f.CanvasWidth || loc.Y >= f.CanvasHeight {
+				continue
+			}
+			oldColor, ok := layer.PixelData[loc]
+			if !ok {
+				oldColor = rl.Blank
+			}
+			color := colorAt(x, y)
+			if color == oldColor {
+				continue
+			}
+			history.PixelState[loc] = PixelStateData{Prev: oldColor, Current: color}
+			layer.PixelData[loc] = color
+		}
+	}
+	if len(history.PixelState) == 0 {
+		f.releaseHistory(history)
+		return
+	}
+	f.syncLinkedCels(history)
+	f.AppendHistory(history)
+	layer.Redraw()
+	f.RedrawRenderLayer()
+}
+
+// DuplicateFrame copies the frame on the current layer over the next frame
+func (f *File) DuplicateFrame(frame int32) error {
+	if f.ReadOnly {
+		return fmt.Errorf("Can't duplicate frame %d: the file is read-only", frame)
+	}
+	if frame < 0 || frame+1 >= f.frameCount() {
+		return fmt.Errorf("Can't duplicate frame %d: there isn't a frame after it", frame)
+	}
+	layer := f.GetCurrentLayer()
+	tile := f.frameTile(frame)
+	f.setFrame(f.CurrentLayer, frame+1, func(x, y int32) rl.Color {
+		if color, ok := layer.PixelData[IntVec2{tile.X + x, tile.Y + y}]; ok {
+			return color
+		}
+		return rl.Blank
+	})
+	return nil
+}
+
+// ClearFrame clears the frame on the current layer
+func (f *File) ClearFrame(frame int32) error {
+	if f.ReadOnly {
+		return fmt.Errorf("Can't clear frame %d: the file is read-only", frame)
+	}
+	if frame < 0 || frame >= f.frameCount() {
+		return fmt.Errorf("Can't clear frame %d: it's outside of the canvas", frame)
+	}
+	f.setFrame(f.CurrentLayer, frame, func(x, y int32) rl.Color {
+		return rl.Blank
+	})
+	return nil
+}
+
+// DuplicateCurrentFrame copies the frame shown by the animation preview to the
+// next frame and shows that one
+func DuplicateCurrentFrame() {
+	if err := CurrentFile.DuplicateFrame(previewAnimationFrame); err != nil {
+		UIWarning(err.Error())
+		return
+	}
+	previewAnimationIsPaused = true
+	previewAnimationFrame++
+}
+
+// ClearCurrentFrame clears the frame shown by the animation preview
+func ClearCurrentFrame() {
+	if err := CurrentFile.ClearFrame(previewAnimationFrame); err != nil {
+		UIWarning(err.Error())
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// LayersToFrames returns a new file with each layer of f as a frame, for
+// files drawn with a layer per frame. The frames are the tiles of one row,
+// in the order of the layers, and an animation plays all of them
+func (f *File) LayersToFrames() *File {
+	count := int32(len(f.Layers))
+	converted := NewFile(f.CanvasWidth*count, f.CanvasHeight, f.CanvasWidth, f.CanvasHeight)
+	converted.copyConvertedFrom(f, "_frames")
+
+	layer := converted.Layers[0]
+	for i, source := range f.Layers {
+		for loc, color := range source.PixelData {
+			if loc.X < 0 || loc.Y < 0 || loc.X >= f.CanvasWidth || loc.Y >= f.CanvasHeight {
+				continue
+			}
+			layer.PixelData[IntVec2{loc.X + int32(i)*f.CanvasWidth, loc.Y}] = color
+		}
+	}
+	layer.Redraw()
+
+	if count > 1 {
+		converted.Animations = append(converted.Animations, &Animation{
+			Name:       T("frames"),
+			FrameStart: 0,
+			FrameEnd:   count - 1,
+			Timing:     5.0, // 5 fps
+		})
+	}
+	converted.RedrawRenderLayer()
+	return converted
+}
+
+// TilesToLayers returns a new file the size of one tile with each tile of f
+// as a layer, in the same order as the frames of animations. The visible
+// layers of each tile are flattened and empty tiles at the end are left out
+func (f *File) TilesToLayers() *File {
+	tilesX := (f.CanvasWidth + f.TileWidth - 1) / f.TileWidth
+	tilesY := (f.CanvasHeight + f.TileHeight - 1) / f.TileHeight
+
+	layers := make([]*Layer, 0, tilesX*tilesY)
+	lastPainted := -1
+	for ty := int32(0); ty < tilesY; ty++ {
+		for tx := int32(0); tx < tilesX; tx++ {
+			layer := NewLayer(f.TileWidth, f.TileHeight, Tf("frame %d", len(layers)), rl.Blank, false)
+			for y := int32(0); y < f.TileHeight; y++ {
+				for x := int32(0); x < f.TileWidth; x++ {
+					loc := IntVec2{tx*f.TileWidth + x, ty*f.TileHeight + y}
+					if loc.X >= f.CanvasWidth || loc.Y >= f.CanvasHeight {
+						continue
+					}
+					if color := f.CompositePixel(loc); color.A > 0 {
+						layer.PixelData[IntVec2{x, y}] = color
+					}
+				}
+			}
+			if len(layer.PixelData) > 0 {
+				lastPainted = len(layers)
+			}
+			layers = append(layers, layer)
+		}
+	}
+	// There's always at least one layer
+	if lastPainted < 0 {
+		lastPainted = 0
+	}
+	for _, layer := range layers[lastPainted+1:] {
+		layer.Unload()
+	}
+	layers = layers[:lastPainted+1]
+
+	converted := NewFile(f.TileWidth, f.TileHeight, f.TileWidth, f.TileHeight)
+	converted.copyConvertedFrom(f, "_layers")
+	for _, layer := range converted.Layers {
+		layer.Unload()
+	}
+	converted.Layers = layers
+	for _, layer := range converted.Layers {
+		layer.Redraw()
+	}
+	converted.RedrawRenderLayer()
+	return converted
+}
+
+// copyConvertedFrom copies what doesn't depend on the layout from f, the name
+// gets suffix so that saving doesn't overwrite f
+func (f *File) copyConvertedFrom(source *File, suffix string) {
+	f.PathDir = source.PathDir
+	f.Filename = strings.TrimSuffix(source.Filename, path.Ext(source.Filename)) + suffix + ".pix"
+	f.PixelAspect = source.PixelAspect
+	f.CurrentPalette = source.CurrentPalette
+	f.MaxTileColors = source.MaxTileColors
+	f.Metadata = source.Metadata
+	for _, alt := range source.AltPalettes {
+		f.AltPalettes = append(f.AltPalettes, &AltPalette{
+			Name: alt.Name,
+			From: append([]rl.Color{}, alt.From...),
+			To:   append([]rl.Color{}, alt.To...),
+		})
+	}
+	f.FileChanged = true
+}
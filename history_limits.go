@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log"
+)
+
+// DefaultHistoryMaxActions is used when Settings.HistoryMaxActions isn't set
+const DefaultHistoryMaxActions = 500
+
+var (
+	// historyMaxActionsChoices and historyMaxMemoryChoices are what the prefs
+	// menu cycles through, memory is in megabytes and 0 isn't capped
+	historyMaxActionsChoices = []int32{100, 250, 500, 1000, 2000}
+	historyMaxMemoryChoices  = []int32{0, 64, 256, 1024}
+)
+
+// SettingsHistoryMaxActions returns how many actions each file can undo
+func SettingsHistoryMaxActions() int32 {
+	if Settings != nil && Settings.HistoryMaxActions > 0 {
+		return Settings.HistoryMaxActions
+	}
+	return DefaultHistoryMaxActions
+}
+
+// SettingsHistoryMaxMemory returns roughly how many bytes each file's history
+// can use, 0 if it isn't capped
+func SettingsHistoryMaxMemory() int64 {
+	if Settings != nil && Settings.HistoryMaxMemory > 0 {
+		return int64(Settings.HistoryMaxMemory) * 1024 * 1024
+	}
+	return 0
+}
+
+// nextHistoryChoice returns the choice after current, wrapping around
+func nextHistoryChoice(choices []int32, current int32) int32 {
+	for i, choice := range choices {
+		if choice == current {
+			return choices[(i+1)%len(choices)]
+		}
+	}
+	return choices[0]
+}
+
+// enforceHistoryLimits drops the oldest actions until the history is within
+// HistoryMaxActions and the memory cap. The latest action and the ones which
+// have been undone are always kept
+func (f *File) enforceHistoryLimits() {
+	maxMemory := SettingsHistoryMaxMemory()
+	var memory int64
+	if maxMemory > 0 {
+		memory = f.HistoryMemoryUsage()
+	}
+
+	drop := 0
+	for int32(len(f.History)-drop) > f.historyOffset+1 {
+		overActions := int32(len(f.History)-drop) > f.HistoryMaxActions
+		overMemory := maxMemory > 0 && memory > maxMemory
+		if !overActions && !overMemory {
+			break
+		}
+		if maxMemory > 0 {
+			memory -= f.historyActionMemoryUsage(f.History[drop])
+		}
+		drop++
+	}
+	if drop == 0 {
+		return
+	}
+
+	dropped := append([]interface{}{}, f.History[:drop]...)
+	f.History = append(f.History[:0], f.History[drop:]...)
+	for _, action := range dropped {
+		f.releaseHistory(action)
+	}
+	f.historyEvicted += int32(drop)
+}
+
+// HistoryEvicted returns how many actions were dropped to stay under the
+// history limits
+func (f *File) HistoryEvicted() int32 {
+	return f.historyEvicted
+}
+
+// WarnHistoryCap warns if an action changing this many pixels would use more
+// than the history memory cap by itself. Returns true if it warned, the
+// caller doesn't do the action since it would drop every older action
+func (f *File) WarnHistoryCap(pixels int) bool {
+	maxMemory := SettingsHistoryMaxMemory()
+	if maxMemory <= 0 || int64(pixels)*pixelStateEntrySize <= maxMemory {
+		return false
+	}
+	log.Println("Action is bigger than the history memory cap")
+	UIWarning(Tf("Changing %d pixels uses more than the %s undo memory limit, raise the limit in prefs to do it",
+		pixels, FormatBytes(maxMemory)))
+	return true
+}
+
+// ApplyHistoryLimits applies changed history settings to every open file
+func ApplyHistoryLimits() {
+	for _, file := range Files {
+		file.HistoryMaxActions = SettingsHistoryMaxActions()
+		file.enforceHistoryLimits()
+	}
+	EditorsUIRebuild()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is the locale the UI strings are written in
+const DefaultLocale = "en"
+
+// Locale is a translation file. Strings maps the English string to the
+// translated string, missing strings are shown in English
+type Locale struct {
+	Name    string
+	Strings map[string]string
+}
+
+var (
+	// locales are the available locales by their code, e.g. "de"
+	locales = map[string]*Locale{
+		DefaultLocale: {Name: "English", Strings: map[string]string{}},
+	}
+	// currentLocale is the locale used by T
+	currentLocale = locales[DefaultLocale]
+)
+
+// LoadLocales loads the translation files from ./res/locales and from
+// ~/pixelLocales. Files in ~/pixelLocales replace the included ones which
+// have the same name
+func LoadLocales() error {
+	entries, err := f.ReadDir("res/locales")
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	for _, entry := range entries {
+		data, err := f.ReadFile(path.Join("res/locales", entry.Name()))
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if err := addLocale(entry.Name(), data); err != nil {
+			log.Println(err)
+		}
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	userDir := path.Join(homeDir, "pixelLocales")
+	userEntries, err := ioutil.ReadDir(userDir)
+	if err != nil {
+		// Not having any user locales is fine
+		return nil
+	}
+	for _, entry := range userEntries {
+		data, err := ioutil.ReadFile(path.Join(userDir, entry.Name()))
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if err := addLocale(entry.Name(), data); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return nil
+}
+
+// addLocale parses a translation file, the locale code is the file name
+// without the extension
+func addLocale(fileName string, data []byte) error {
+	if path.Ext(fileName) != ".json" {
+		return nil
+	}
+
+	locale := &Locale{}
+	if err := json.Unmarshal(data, locale); err != nil {
+		return fmt.Errorf("Couldn't load locale %s: %v", fileName, err)
+	}
+	code := strings.TrimSuffix(fileName, ".json")
+	if locale.Name == "" {
+		locale.Name = code
+	}
+	locales[code] = locale
+	return nil
+}
+
+// Locales returns the codes of the available locales, sorted
+func Locales() []string {
+	codes := make([]string, 0, len(locales))
+	for code := range locales {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// LocaleName returns the name of the locale, e.g. "Deutsch"
+func LocaleName(code string) string {
+	if locale, ok := locales[code]; ok {
+		return locale.Name
+	}
+	return code
+}
+
+// SetLocale sets the locale used by T, falling back to DefaultLocale if it
+// doesn't exist
+func SetLocale(code string) {
+	locale, ok := locales[code]
+	if !ok {
+		if code != "" {
+			log.Println("Unknown locale:", code)
+		}
+		locale = locales[DefaultLocale]
+	}
+	currentLocale = locale
+}
+
+// T returns the translation of the English string s
+func T(s string) string {
+	if translated, ok := currentLocale.Strings[s]; ok && translated != "" {
+		return translated
+	}
+	return s
+}
+
+// Tf translates the format and then formats it like fmt.Sprintf
+func Tf(format string, a ...interface{}) string {
+	return fmt.Sprintf(T(format), a...)
+}
+
+// LocaleRunes returns the characters which have to be loaded into the font,
+// which is printable ASCII, Latin-1 and the characters used by the current
+// locale
+func LocaleRunes() []rune {
+	seen := make(map[rune]struct{})
+	runes := make([]rune, 0, 256)
+	add := func(r rune) {
+		if _, ok := seen[r]; !ok {
+			seen[r] = struct{}{}
+			runes = append(runes, r)
+		}
+	}
+
+	for r := rune(32); r < 127; r++ {
+		add(r)
+	}
+	for r := rune(160); r < 256; r++ {
+		add(r)
+	}
+	for _, translated := range currentLocale.Strings {
+		for _, r := range translated {
+			add(r)
+		}
+	}
+	for _, locale := range locales {
+		for _, r := range locale.Name {
+			add(r)
+		}
+	}
+	return runes
+}
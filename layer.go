@@ -4,13 +4,47 @@ import (
 	rl "github.com/gen2brain/raylib-go/raylib"
 )
 
+// lastLayerID is the highest ID given to a layer, see Layer.ID
+var lastLayerID int32
+
+// newLayerID returns an ID which no layer has
+func newLayerID() int32 {
+	lastLayerID++
+	return lastLayerID
+}
+
+// reserveLayerID keeps the ID of a loaded layer from being given to another
+// layer. It returns a new ID if id is 0, for files saved before layers had IDs
+func reserveLayerID(id int32) int32 {
+	if id == 0 {
+		return newLayerID()
+	}
+	if id > lastLayerID {
+		lastLayerID = id
+	}
+	return id
+}
+
 // Layer contains data for layers
 type Layer struct {
-	Hidden        bool
+	// ID stays the same when the layer is renamed or moved and is saved with
+	// the file, export profiles refer to layers by it
+	ID     int32
+	Hidden bool
+	// NoExport leaves the layer out of exports even when it's visible, for
+	// sketches and references
+	NoExport bool
+	// CelLinks maps linked frames to the frame they're linked to, see cels.go
+	CelLinks map[int32]int32
+	// Canvas is only loaded for the preview layer, which is redrawn whole
+	// every frame. The other layers use Chunks
 	Canvas        rl.RenderTexture2D
+	Chunks        *Chunks
 	Name          string
 	Width, Height int32
 	BlendMode     rl.BlendMode
+	// Effects are drawn when the layers are composited, see layer_effects.go
+	Effects LayerEffects
 
 	// PixelData is the "raw" pixels map
 	PixelData map[IntVec2]rl.Color
@@ -18,6 +52,10 @@ type Layer struct {
 
 // Redraw redraws the layer
 func (l *Layer) Redraw() {
+	if l.Chunks != nil {
+		l.Chunks.Redraw(l.PixelData)
+		return
+	}
 	rl.BeginTextureMode(l.Canvas)
 	rl.ClearBackground(rl.Blank)
 	// rl.BeginBlendMode(l.BlendMode)
@@ -30,30 +68,19 @@ func (l *Layer) Redraw() {
 
 // Resize the layer to the specified width, height and direction
 func (l *Layer) Resize(width, height int32, direction ResizeDirection) {
-	l.Canvas = rl.LoadRenderTexture(width, height)
-
-	w := CurrentFile.CanvasWidth
-	h := CurrentFile.CanvasHeight
-
-	nw := width
-	nh := height
-
-	// offsets
-	var dx int32
-	var dy int32
+	dx, dy := ResizeOffset(CurrentFile.CanvasWidth, CurrentFile.CanvasHeight, width, height, direction)
+	l.ResizeOffset(width, height, dx, dy)
+}
 
-	switch CurrentFile.CanvasDirectionResizePreview {
-	case ResizeTL:
-		dx = 0
-		dy = 0
+// ResizeOffset returns where the resized canvas starts on the old canvas, the
+// offsets are negative if the canvas grows up or to the left
+func ResizeOffset(w, h, nw, nh int32, direction ResizeDirection) (dx, dy int32) {
+	switch direction {
 	case ResizeTC:
 		dx = (w - nw) / 2
-		dy = 0
 	case ResizeTR:
 		dx = w - nw
-		dy = 0
 	case ResizeCL:
-		dx = 0
 		dy = (h - nh) / 2
 	case ResizeCC:
 		dx = (w - nw) / 2
@@ -62,7 +89,6 @@ func (l *Layer) Resize(width, height int32, direction ResizeDirection) {
 		dx = w - nw
 		dy = (h - nh) / 2
 	case ResizeBL:
-		dx = 0
 		dy = h - nh
 	case ResizeBC:
 		dx = (w - nw) / 2
@@ -71,28 +97,47 @@ func (l *Layer) Resize(width, height int32, direction ResizeDirection) {
 		dx = w - nw
 		dy = h - nh
 	}
+	return dx, dy
+}
 
+// ResizeOffset resizes the layer to width and height, moving every pixel by
+// -dx, -dy. Pixels which end up outside of the layer are removed
+func (l *Layer) ResizeOffset(width, height, dx, dy int32) {
 	newPixelData := make(map[IntVec2]rl.Color)
-	rl.BeginTextureMode(l.Canvas)
-	rl.ClearBackground(rl.Blank)
-	for x := dx; x < w; x++ {
-		for y := dy; y < h; y++ {
-			if color, ok := l.PixelData[IntVec2{x, y}]; ok {
-				rl.DrawPixel(x-dx, y-dy, color)
-				newPixelData[IntVec2{x - dx, y - dy}] = color
-			}
+	for loc, color := range l.PixelData {
+		x, y := loc.X-dx, loc.Y-dy
+		if x >= 0 && x < width && y >= 0 && y < height {
+			newPixelData[IntVec2{x, y}] = color
 		}
 	}
-	rl.EndTextureMode()
 	l.PixelData = newPixelData
 	l.Width = width
 	l.Height = height
+
+	if l.Chunks != nil {
+		l.Chunks.Width = width
+		l.Chunks.Height = height
+	} else {
+		rl.UnloadRenderTexture(l.Canvas)
+		l.Canvas = rl.LoadRenderTexture(width, height)
+	}
+	l.Redraw()
+}
+
+// Unload unloads the layer's textures
+func (l *Layer) Unload() {
+	if l.Chunks != nil {
+		l.Chunks.Unload()
+		return
+	}
+	rl.UnloadRenderTexture(l.Canvas)
 }
 
 // NewLayer returns a pointer to a new Layer
 func NewLayer(width, height int32, name string, fillColor rl.Color, shouldFill bool) *Layer {
 	return &Layer{
-		Canvas:    rl.LoadRenderTexture(width, height),
+		ID:        newLayerID(),
+		Chunks:    NewChunks(width, height),
 		PixelData: make(map[IntVec2]rl.Color),
 		Name:      name,
 		Hidden:    false,
@@ -101,3 +146,16 @@ func NewLayer(width, height int32, name string, fillColor rl.Color, shouldFill b
 		BlendMode: rl.BlendAlpha,
 	}
 }
+
+// NewCanvasLayer returns a layer with a single texture covering all of it,
+// for the preview layer which is drawn whole every frame
+func NewCanvasLayer(width, height int32, name string) *Layer {
+	return &Layer{
+		Canvas:    rl.LoadRenderTexture(width, height),
+		PixelData: make(map[IntVec2]rl.Color),
+		Name:      name,
+		Width:     width,
+		Height:    height,
+		BlendMode: rl.BlendAlpha,
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// LayerEffects are drawn around and over a layer's pixels when the layers are
+// composited, the pixels themselves aren't changed. They're baked into
+// exports and stamps, or into the layer with ApplyLayerEffects
+type LayerEffects struct {
+	// Outline is drawn on the empty pixels next to the layer's pixels
+	Outline      bool
+	OutlineColor rl.Color
+	// Shadow is drawn on the empty pixels ShadowX, ShadowY from the layer's
+	// pixels
+	Shadow           bool
+	ShadowColor      rl.Color
+	ShadowX, ShadowY int32
+	// Overlay tints the layer's pixels, its alpha is how strong it is
+	Overlay      bool
+	OverlayColor rl.Color
+}
+
+// HistoryLayerEffects is for changing the effects of a layer
+type HistoryLayerEffects struct {
+	LayerIndex    int32
+	Prev, Current LayerEffects
+}
+
+// Vars
+const (
+	// layerShadowOffset is how far the shadow is from the pixels
+	layerShadowOffset = 1
+)
+
+// Any returns true if any of the effects are on
+func (e LayerEffects) Any() bool {
+	return e.Outline || e.Shadow || e.Overlay
+}
+
+// affected returns the pixels whose effects could change when the pixel at
+// loc changes
+func (e LayerEffects) affected(loc IntVec2) []IntVec2 {
+	var locs []IntVec2
+	if e.Outline {
+		locs = append(locs, IntVec2{loc.X - 1, loc.Y}, IntVec2{loc.X + 1, loc.Y}, IntVec2{loc.X, loc.Y - 1}, IntVec2{loc.X, loc.Y + 1})
+	}
+	if e.Shadow {
+		locs = append(locs, IntVec2{loc.X + e.ShadowX, loc.Y + e.ShadowY})
+	}
+	return locs
+}
+
+// painted returns true if the layer has a visible pixel at loc
+func (l *Layer) painted(loc IntVec2) bool {
+	color, ok := l.PixelData[loc]
+	return ok && color.A > 0
+}
+
+// EffectPixel returns the layer's color at loc with its effects
+func (l *Layer) EffectPixel(loc IntVec2) (rl.Color, bool) {
+	color, ok := l.PixelData[loc]
+	e := l.Effects
+	if !e.Any() {
+		return color, ok
+	}
+
+	if ok && color.A > 0 {
+		if e.Overlay {
+			strength := int32(e.OverlayColor.A)
+			mix := func(a, b uint8) uint8 {
+				return uint8((int32(a)*(255-strength) + int32(b)*strength) / 255)
+			}
+			color = rl.NewColor(mix(color.R, e.OverlayColor.R), mix(color.G, e.OverlayColor.G), mix(color.B, e.OverlayColor.B), color.A)
+		}
+		return color, true
+	}
+	if e.Outline {
+		for _, next := range (LayerEffects{Outline: true}).affected(loc) {
+			if l.painted(next) {
+				return e.OutlineColor, true
+			}
+		}
+	}
+	if e.Shadow && l.painted(IntVec2{loc.X - e.ShadowX, loc.Y - e.ShadowY}) {
+		return e.ShadowColor, true
+	}
+	return color, ok
+}
+
+// EffectPixels returns the layer's pixels with its effects, the pixels which
+// are only effects are included. It's PixelData if there aren't any effects
+func (l *Layer) EffectPixels() map[IntVec2]rl.Color {
+	if !l.Effects.Any() {
+		return l.PixelData
+	}
+	pixels := make(map[IntVec2]rl.Color, len(l.PixelData))
+	add := func(loc IntVec2) {
+		if _, ok := pixels[loc]; ok || loc.X < 0 || loc.Y < 0 || loc.X >= l.Width || loc.Y >= l.Height {
+			return
+		}
+		if color, ok := l.EffectPixel(loc); ok {
+			pixels[loc] = color
+		}
+	}
+	for loc := range l.PixelData {
+		add(loc)
+		for _, next := range l.Effects.affected(loc) {
+			add(next)
+		}
+	}
+	return pixels
+}
+
+// EffectPixels returns the saved layer's pixels with its effects, for
+// flattening .pix files without opening them
+func (l *LayerSer) EffectPixels() map[IntVec2]rl.Color {
+	layer := Layer{PixelData: l.PixelData, Width: l.Width, Height: l.Height, Effects: l.Effects}
+	return layer.EffectPixels()
+}
+
+// SetLayerEffects changes the effects of the layer at index and adds it to
+// the history
+func (f *File) SetLayerEffects(index int32, effects LayerEffects) {
+	layer := f.Layers[index]
+	if layer.Effects == effects {
+		return
+	}
+	f.AppendHistory(HistoryLayerEffects{index, layer.Effects, effects})
+	f.setLayerEffects(index, effects)
+}
+
+// setLayerEffects changes the effects without adding it to the history
+func (f *File) setLayerEffects(index int32, effects LayerEffects) {
+	if index < 0 || index >= int32(len(f.Layers)) {
+		return
+	}
+	f.Layers[index].Effects = effects
+	f.RedrawRenderLayer()
+}
+
+// ToggleLayerEffect turns an effect of the current layer on or off, it's
+// turned on with the left color
+func (f *File) ToggleLayerEffect(toggle func(effects *LayerEffects, color rl.Color)) {
+	effects := f.GetCurrentLayer().Effects
+	toggle(&effects, LeftColor)
+	f.SetLayerEffects(f.CurrentLayer, effects)
+}
+
+// ToggleOutlineEffect turns the outline of the current layer on or off
+func (f *File) ToggleOutlineEffect() {
+	f.ToggleLayerEffect(func(effects *LayerEffects, color rl.Color) {
+		effects.Outline = !effects.Outline
+		effects.OutlineColor = color
+	})
+}
+
+// ToggleShadowEffect turns the shadow of the current layer on or off
+func (f *File) ToggleShadowEffect() {
+	f.ToggleLayerEffect(func(effects *LayerEffects, color rl.Color) {
+		effects.Shadow = !effects.Shadow
+		effects.ShadowColor = color
+		effects.ShadowX, effects.ShadowY = layerShadowOffset, layerShadowOffset
+	})
+}
+
+// ToggleOverlayEffect turns the color overlay of the current layer on or
+// off, the alpha of the left color is how strong it is
+func (f *File) ToggleOverlayEffect() {
+	f.ToggleLayerEffect(func(effects *LayerEffects, color rl.Color) {
+		effects.Overlay = !effects.Overlay
+		effects.OverlayColor = color
+	})
+}
+
+// ApplyLayerEffects bakes the effects of the current layer into its pixels
+// and turns them off, as one history action
+func (f *File) ApplyLayerEffects() {
+	layer := f.GetCurrentLayer()
+	if !layer.Effects.Any() {
+		return
+	}
+
+	pixels := layer.EffectPixels()
+	if f.WarnHistoryCap(len(pixels)) {
+		return
+	}
+
+	f.BeginTransaction()
+	history := NewHistoryPixel(f.CurrentLayer)
+	for loc, color := range pixels {
+		ps := history.PixelState[loc]
+		ps.Prev = layer.PixelData[loc]
+		ps.Current = color
+		history.PixelState[loc] = ps
+	}
+	f.AppendHistory(history)
+	for loc, ps := range history.PixelState {
+		layer.PixelData[loc] = ps.Current
+	}
+	f.syncLinkedCels(history)
+	layer.Redraw()
+	f.SetLayerEffects(f.CurrentLayer, LayerEffects{})
+	f.EndTransaction()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/ncruces/zenity"
+)
+
+// ViewOnly opens every file read-only, set with --view
+var ViewOnly bool
+
+// lockPath returns the path of the lockfile for the file at path, it's a
+// hidden file next to it
+func lockPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".lock")
+}
+
+// lockOwner returns the pid of the instance which has the file at path open,
+// or 0 if it isn't open anywhere else. Locks left behind by instances which
+// have quit are ignored
+func lockOwner(path string) int {
+	data, err := ioutil.ReadFile(lockPath(path))
+	if err != nil {
+		return 0
+	}
+	lines := strings.SplitN(string(data), "\n", 2)
+	pid, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil || pid == os.Getpid() {
+		return 0
+	}
+	if host, err := os.Hostname(); err == nil && len(lines) > 1 && strings.TrimSpace(lines[1]) != host {
+		// The process can't be checked, assume it's still open
+		return pid
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return 0
+	}
+	// FindProcess only fails on windows, everywhere else the process has to
+	// be signalled to check it
+	if runtime.GOOS != "windows" && process.Signal(syscall.Signal(0)) != nil {
+		return 0
+	}
+	return pid
+}
+
+// Lock creates the lockfile for path, releasing the previous one. Fails if
+// another instance has the file open
+func (f *File) Lock(path string) error {
+	if path == f.lockedPath {
+		return nil
+	}
+	err := createLock(path)
+	if os.IsExist(err) && lockOwner(path) == 0 {
+		// Left behind by an instance which has quit
+		os.Remove(lockPath(path))
+		err = createLock(path)
+	}
+	if os.IsExist(err) {
+		return fmt.Errorf("\"%s\" is already open in another instance (pid %d)", filepath.Base(path), lockOwner(path))
+	}
+	if err != nil {
+		return err
+	}
+	f.Unlock()
+	f.lockedPath = path
+	return nil
+}
+
+// createLock creates the lockfile for path with this instance's pid. It's
+// created exclusively, so that only one instance can take the lock
+func createLock(path string) error {
+	file, err := os.OpenFile(lockPath(path), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	_, err = fmt.Fprintf(file, "%d\n%s\n", os.Getpid(), host)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Unlock removes the lockfile, if there is one
+func (f *File) Unlock() {
+	if f.lockedPath == "" {
+		return
+	}
+	if err := os.Remove(lockPath(f.lockedPath)); err != nil && !os.IsNotExist(err) {
+		log.Println(err)
+	}
+	f.lockedPath = ""
+}
+
+// SetReadOnly switches between viewing and editing. Editing needs the lock,
+// so it fails if the file is open in another instance
+func (f *File) SetReadOnly(readOnly bool) error {
+	if readOnly {
+		f.Unlock()
+	} else if len(f.FileDir) > 0 {
+		if err := f.Lock(f.FileDir); err != nil {
+			return err
+		}
+	}
+	f.ReadOnly = readOnly
+	EditorsUIRebuild()
+	return nil
+}
+
+// ToggleReadOnly toggles read-only, warning if the file can't be edited
+func (f *File) ToggleReadOnly() {
+	if err := f.SetReadOnly(!f.ReadOnly); err != nil {
+		log.Println(err)
+		UIWarning(err.Error())
+	}
+}
+
+// UIInfo shows an info dialog without waiting for it to be closed
+func UIInfo(title, message string) {
+	go func() {
+		if err := zenity.Info(message, zenity.Title(title)); err != nil && err != zenity.ErrCanceled {
+			log.Println(err)
+		}
+	}()
+}
+
+// UIWarning shows a warning dialog without waiting for it to be closed
+func UIWarning(message string) {
+	PlaySoundCue(SoundError)
+	go func() {
+		if err := zenity.Warning(message, zenity.Title(T("Warning"))); err != nil && err != zenity.ErrCanceled {
+			log.Println(err)
+		}
+	}()
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
 
@@ -24,6 +26,32 @@ var (
 	LeftColor         rl.Color
 	RightColor        rl.Color
 
+	// GlobalBrushImage is stamped by BrushShapeImage, it's nil until one is
+	// loaded. It's recolored to the active color if GlobalBrushRecolor is true
+	GlobalBrushImage   *BrushImage
+	GlobalBrushRecolor       = true
+	GlobalBrushSpacing int32 = 1
+	// GlobalBrushOpacity is multiplied into the pencil's color, in percent
+	GlobalBrushOpacity int32 = 100
+
+	// The pencil and eraser smooth strokes separately
+	GlobalBrushStabilizer  = Stabilizer{Length: 4}
+	GlobalEraserStabilizer = Stabilizer{Length: 4}
+
+	GlobalFillMode         = FillModeColor
+	GlobalFillPatternAlign = FillPatternAlignOrigin
+	GlobalFillContiguous   = true
+
+	// New selections are constrained by the shape and snapped to the tiles
+	GlobalSelectionShape        = SelectionShapeFree
+	GlobalSelectionSnap         = false
+	GlobalSelectionWidth  int32 = 16
+	GlobalSelectionHeight int32 = 16
+
+	// Radius and density (percent) of the scatter brush
+	GlobalScatterSize    int32 = 4
+	GlobalScatterDensity int32 = 20
+
 	// CopiedSelection holds the selection when File.Copy is called
 	CopiedSelection map[IntVec2]rl.Color
 	// CopiedSelectionPixels is a different format of the above
@@ -35,21 +63,74 @@ var (
 
 	// ShowDebug enables debug overlays when true
 	ShowDebug = false
+	// ShowCoordinates shows the coordinates of the hovered pixel next to the
+	// cursor
+	ShowCoordinates = false
+	// PrecisionMode freezes the camera, zooming and panning are ignored so
+	// that the canvas can't be moved by accident
+	PrecisionMode = false
+	// ShowHelp shows the keybindings overlay
+	ShowHelp = false
 )
 
 func main() {
 	log.SetFlags(log.Lshortfile)
 
+	// Subcommands don't open a window
+	if len(os.Args) > 1 && os.Args[1] == "thumb" {
+		if err := RunThumbnailer(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "recolor" {
+		if err := RunRecolorWatcher(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [--view] [file.pix|file.png ...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s thumb in.pix out.png [--size 128]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s recolor dir [--out dir] [--palettes red,blue] [--interval 1s] [--once]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.BoolVar(&ViewOnly, "view", false, "open files read-only, without locking them")
+	flag.Parse()
+
 	SetupFiles()
 
+	// Loaded before the window is created so that the geometry can be restored
+	err := LoadSettings()
+	if err != nil {
+		log.Println(err)
+	}
+	if err := LoadLocales(); err != nil {
+		log.Println(err)
+	}
+	SetLocale(Settings.Locale)
+
+	var width, height int32 = 1920 * 0.75, 1080 * 0.75
+	if w := Settings.Window; w != nil && w.Width > 0 && w.Height > 0 {
+		width, height = w.Width, w.Height
+	}
+
 	rl.SetTraceLog(rl.LogError)
 	rl.SetConfigFlags(rl.FlagWindowResizable | rl.FlagWindowUnfocused)
-	rl.InitWindow(1920*0.75, 1080*0.75, "MelonPixel")
-	// rl.SetWindowPosition(3840-1920*0.75-96, 128)
-	rl.SetWindowPosition(-1920*0.75-128, 128)
+	rl.InitWindow(width, height, "MelonPixel")
+	if w := Settings.Window; w != nil {
+		rl.SetWindowPosition(int(w.X), int(w.Y))
+		if w.Maximized {
+			rl.MaximizeWindow()
+		}
+	}
 	rl.SetTargetFPS(60)
 	rl.SetExitKey(0)
 	rl.SetWindowIcon(*rl.LoadImage(GetFile("./res/icon.png")))
+	InitSounds()
 
 	Files = []*File{}
 
@@ -60,39 +141,30 @@ func main() {
 	LeftTool = NewPixelBrushTool("Pixel Brush L", false)
 	RightTool = NewPixelBrushTool("Pixel Brush R", false)
 
-	err := LoadSettings()
-	if err != nil {
-		log.Println(err)
-	}
-
 	CurrentFile = NewFile(64, 64, 8, 8)
 	Files = append(Files, CurrentFile)
 
 	InitUI(NewKeymap(Settings.KeymapData))
 
-	if len(os.Args) > 1 {
-		// delete starting/empty file
-		Files = []*File{}
-
-		for _, argPath := range os.Args[1:] {
-			// Try using explicit/full path
-			fi, err := os.Stat(argPath)
-			if err == nil {
-				if fi.Mode().IsRegular() {
-					newFile := Open(argPath)
-					Files = append(Files, newFile)
-					continue
-				}
-			} else {
-				log.Println(err)
-				return
-			}
+	// Files passed as arguments are opened as tabs, this is also how file
+	// associations open files
+	if flag.NArg() > 0 {
+		empty := CurrentFile
+		if opened := OpenPaths(flag.Args()); len(opened) > 0 {
+			// Replace the starting/empty file
+			empty.Destroy()
+			Files = opened
+			CurrentFile = opened[len(opened)-1]
 		}
 	}
 
 	// shows filename(s) in tab
 	EditorsUIRebuild()
 
+	if !Settings.TourSeen {
+		TourUIStart()
+	}
+
 	for !rl.WindowShouldClose() {
 		if rl.IsWindowFocused() {
 			rl.SetTargetFPS(60)
@@ -100,6 +172,7 @@ func main() {
 			rl.SetTargetFPS(1)
 		}
 
+		TrackWindowGeometry()
 		UpdateUI()
 
 		rl.BeginDrawing()
@@ -108,6 +181,11 @@ func main() {
 		rl.EndDrawing()
 	}
 
+	if err := SaveSettings(); err != nil {
+		log.Println(err)
+	}
+	WaitForSaves()
+
 	// Destroy resources
 	for _, file := range Files {
 		file.Destroy()
@@ -115,5 +193,7 @@ func main() {
 	DestroyUI()
 	UIControlSystemCmds <- UIControlChanData{CommandType: CommandTypeQuit}
 
+	CloseSounds()
+	UnloadViewFilter()
 	rl.CloseWindow()
 }
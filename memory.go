@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// Go's maps use roughly twice the size of the key and value once their
+// buckets and overflow are counted. It's only an estimate, it's for showing
+// when a file is getting big rather than exact accounting
+const mapOverhead = 2
+
+var (
+	pixelDataEntrySize  = int64(unsafe.Sizeof(IntVec2{})+unsafe.Sizeof(rl.Color{})) * mapOverhead
+	pixelStateEntrySize = int64(unsafe.Sizeof(IntVec2{})+unsafe.Sizeof(PixelStateData{})) * mapOverhead
+)
+
+// MemoryUsage returns roughly how many bytes the layer's PixelData and
+// textures use
+func (l *Layer) MemoryUsage() (pixels, texture int64) {
+	pixels = int64(len(l.PixelData)) * pixelDataEntrySize
+	if l.Chunks != nil {
+		return pixels, int64(l.Chunks.Count()) * ChunkSize * ChunkSize * 4
+	}
+	return pixels, int64(l.Width) * int64(l.Height) * 4
+}
+
+// LayersMemoryUsage returns roughly how many bytes all of the layers use,
+// including the render and preview layers
+func (f *File) LayersMemoryUsage() int64 {
+	var total int64
+	for _, layer := range append([]*Layer{f.RenderLayer, f.previewLayer}, f.Layers...) {
+		pixels, texture := layer.MemoryUsage()
+		total += pixels + texture
+	}
+	return total
+}
+
+// HistoryMemoryUsage returns roughly how many bytes the history uses
+func (f *File) HistoryMemoryUsage() int64 {
+	var total int64
+	for _, action := range f.History {
+		total += f.historyActionMemoryUsage(action)
+	}
+	return total
+}
+
+// historyActionMemoryUsage returns roughly how many bytes a history action
+// uses. Deleted layers are kept by the history so they're counted too
+func (f *File) historyActionMemoryUsage(action interface{}) int64 {
+	var total int64
+	switch typed := action.(type) {
+	case CompoundHistory:
+		for _, a := range typed.Actions {
+			total += f.historyActionMemoryUsage(a)
+		}
+	case HistoryPixel:
+		total += int64(len(typed.PixelState)) * pixelStateEntrySize
+	case HistoryLayer:
+		if typed.Layer == nil {
+			return 0
+		}
+		for _, layer := range f.Layers {
+			if layer == typed.Layer {
+				return 0
+			}
+		}
+		pixels, texture := typed.Layer.MemoryUsage()
+		total += pixels + texture
+	case HistoryPalette:
+		total += int64(len(typed.Prev)+len(typed.Current)) * 4
+	case HistoryResize:
+		for _, state := range typed.PrevLayerState {
+			total += int64(len(state)) * pixelDataEntrySize
+		}
+		for _, state := range typed.CurrentLayerState {
+			total += int64(len(state)) * pixelDataEntrySize
+		}
+	}
+	return total
+}
+
+// FormatBytes returns n as B, KB, MB or GB
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMG"[exp])
+}
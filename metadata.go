@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+)
+
+// Metadata is optional information about the file. It's saved in .pix files
+// and written to exported pngs as text chunks
+type Metadata struct {
+	Author      string
+	License     string
+	Description string
+}
+
+// pngHeaderLength is the length of the png signature and the IHDR chunk, text
+// chunks are inserted after it
+const pngHeaderLength = 8 + 4 + 4 + 13 + 4
+
+// pngText returns the png keywords and their text, empty fields are skipped
+func (m Metadata) pngText() [][2]string {
+	text := make([][2]string, 0, 3)
+	for _, kv := range [][2]string{
+		{"Author", m.Author},
+		{"Copyright", m.License},
+		{"Description", m.Description},
+	} {
+		if kv[1] != "" {
+			text = append(text, kv)
+		}
+	}
+	return text
+}
+
+// setPNGText sets the fields from the png keywords, unknown keywords are
+// ignored
+func (m *Metadata) setPNGText(text map[string]string) {
+	m.Author = text["Author"]
+	m.License = text["Copyright"]
+	m.Description = text["Description"]
+}
+
+// encodePNGWithText encodes the image as a png with the text chunks after the
+// header
+func encodePNGWithText(w io.Writer, img image.Image, text [][2]string) error {
+	if len(text) == 0 {
+		return png.Encode(w, img)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	if _, err := w.Write(data[:pngHeaderLength]); err != nil {
+		return err
+	}
+	for _, kv := range text {
+		chunkType, chunkData := pngTextChunk(kv[0], kv[1])
+		if err := writePNGChunk(w, chunkType, chunkData); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(data[pngHeaderLength:])
+	return err
+}
+
+// pngTextChunk returns a tEXt chunk if the text is Latin-1, otherwise an
+// uncompressed iTXt chunk since it can hold UTF-8
+func pngTextChunk(keyword, text string) (string, []byte) {
+	latin1 := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r > 255 {
+			data := []byte(keyword)
+			// null separator, no compression, no language or translated keyword
+			data = append(data, 0, 0, 0, 0, 0)
+			return "iTXt", append(data, text...)
+		}
+		latin1 = append(latin1, byte(r))
+	}
+
+	data := append([]byte(keyword), 0)
+	return "tEXt", append(data, latin1...)
+}
+
+func writePNGChunk(w io.Writer, chunkType string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], chunkType)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readPNGText returns the text of the tEXt and uncompressed iTXt chunks in the
+// png at path
+func readPNGText(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 || string(data[1:4]) != "PNG" {
+		return nil, fmt.Errorf("\"%s\" isn't a png", path)
+	}
+
+	text := make(map[string]string)
+	for i := 8; i+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		chunkType := string(data[i+4 : i+8])
+		if i+12+length > len(data) {
+			break
+		}
+		chunk := data[i+8 : i+8+length]
+		i += 12 + length
+
+		switch chunkType {
+		case "tEXt":
+			if sep := bytes.IndexByte(chunk, 0); sep > 0 {
+				runes := make([]rune, 0, len(chunk)-sep-1)
+				for _, b := range chunk[sep+1:] {
+					runes = append(runes, rune(b))
+				}
+				text[string(chunk[:sep])] = string(runes)
+			}
+		case "iTXt":
+			// keyword, compression flag and method, then the language,
+			// translated keyword and text separated by nulls
+			sep := bytes.IndexByte(chunk, 0)
+			if sep <= 0 || len(chunk) < sep+3 || chunk[sep+1] != 0 {
+				// Compressed text isn't supported
+				continue
+			}
+			if rest := bytes.SplitN(chunk[sep+3:], []byte{0}, 3); len(rest) == 3 {
+				text[string(chunk[:sep])] = string(rest[2])
+			}
+		case "IEND":
+			return text, nil
+		}
+	}
+	return text, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// physicalMouseButton returns the mouse button which is used as button. The
+// left and right buttons are swapped if Settings.SwapMouseButtons is set, so
+// the right button uses LeftTool and LeftColor
+func physicalMouseButton(button int32) int32 {
+	if Settings.SwapMouseButtons {
+		switch button {
+		case rl.MouseLeftButton:
+			return rl.MouseRightButton
+		case rl.MouseRightButton:
+			return rl.MouseLeftButton
+		}
+	}
+	return button
+}
+
+// IsMouseButtonDown is rl.IsMouseButtonDown with the buttons swapped if
+// Settings.SwapMouseButtons is set
+func IsMouseButtonDown(button int32) bool {
+	return rl.IsMouseButtonDown(physicalMouseButton(button))
+}
+
+// IsMouseButtonPressed is rl.IsMouseButtonPressed with the buttons swapped if
+// Settings.SwapMouseButtons is set
+func IsMouseButtonPressed(button int32) bool {
+	return rl.IsMouseButtonPressed(physicalMouseButton(button))
+}
+
+// HandleMouseHistoryButtons undoes with the back mouse button and redoes with
+// the forward one, unless Settings.NoMouseHistoryButtons is set
+func HandleMouseHistoryButtons() {
+	if Settings.NoMouseHistoryButtons || CurrentFile.ReadOnly || UIEntityCapturedInput != nil {
+		return
+	}
+	// Strokes would be split up
+	if !CurrentFile.HasDoneMouseUpLeft || !CurrentFile.HasDoneMouseUpRight {
+		return
+	}
+	// Mice report back and forward as either the side and extra buttons or
+	// the back and forward buttons
+	switch {
+	case rl.IsMouseButtonPressed(rl.MouseSideButton) || rl.IsMouseButtonPressed(rl.MouseBackButton):
+		CurrentFile.Undo()
+	case rl.IsMouseButtonPressed(rl.MouseExtraButton) || rl.IsMouseButtonPressed(rl.MouseForwardButton):
+		CurrentFile.Redo()
+	}
+}
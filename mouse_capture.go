@@ -0,0 +1,55 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// mouseCapture keeps drags going when the cursor leaves the window. Once a
+// drag reaches the edge, the cursor is locked to the window so that its
+// position keeps being tracked and the button's release isn't missed. It's
+// unlocked where it was released, clamped to the window
+var mouseCapture struct {
+	// dragging is true while a button which was pressed in the window is held
+	dragging bool
+	// locked is true while the cursor is locked
+	locked bool
+}
+
+// mouseAnyButtonDown returns true if any of the mouse buttons are held
+func mouseAnyButtonDown() bool {
+	return rl.IsMouseButtonDown(rl.MouseLeftButton) ||
+		rl.IsMouseButtonDown(rl.MouseRightButton) ||
+		rl.IsMouseButtonDown(rl.MouseMiddleButton)
+}
+
+// UpdateMouseCapture locks or unlocks the cursor, it's called once a frame
+// before the systems read the mouse
+func UpdateMouseCapture() {
+	switch {
+	case !mouseAnyButtonDown():
+		if mouseCapture.locked {
+			pos := rl.GetMousePosition()
+			x := MaxInt32(0, MinInt32(int32(pos.X), int32(rl.GetScreenWidth())-1))
+			y := MaxInt32(0, MinInt32(int32(pos.Y), int32(rl.GetScreenHeight())-1))
+			rl.EnableCursor()
+			rl.SetMousePosition(int(x), int(y))
+			mouseCapture.locked = false
+		}
+		mouseCapture.dragging = false
+	case !mouseCapture.dragging:
+		// Drags which started outside of the window aren't ours
+		mouseCapture.dragging = rl.IsCursorOnScreen()
+	case !mouseCapture.locked && !rl.IsCursorOnScreen():
+		// The locked cursor carries on from where it left
+		pos := rl.GetMousePosition()
+		rl.DisableCursor()
+		rl.SetMousePosition(int(pos.X), int(pos.Y))
+		mouseCapture.locked = true
+	}
+}
+
+// MouseCaptured returns true while the cursor is locked by a drag. The cursor
+// can't be shown or hidden until it's released
+func MouseCaptured() bool {
+	return mouseCapture.locked
+}
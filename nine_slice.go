@@ -0,0 +1,70 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// NineSlice are the margins of the corners of each tile. When a tile is
+// stretched the corners keep their size, the edges stretch along one axis and
+// the middle stretches along both
+type NineSlice struct {
+	Left   int32 `json:"left"`
+	Top    int32 `json:"top"`
+	Right  int32 `json:"right"`
+	Bottom int32 `json:"bottom"`
+}
+
+// IsSet returns true if any of the margins have been set
+func (n NineSlice) IsSet() bool {
+	return n != NineSlice{}
+}
+
+// Scaled returns the margins multiplied by scale, for exports
+func (n NineSlice) Scaled(scale int32) NineSlice {
+	return NineSlice{n.Left * scale, n.Top * scale, n.Right * scale, n.Bottom * scale}
+}
+
+// margin returns the left, top, right or bottom margin for 0 to 3
+func (n *NineSlice) margin(i int) *int32 {
+	return [4]*int32{&n.Left, &n.Top, &n.Right, &n.Bottom}[i]
+}
+
+// SetNineSlice sets the margins, clamping them so that they fit in a tile
+func (f *File) SetNineSlice(n NineSlice) {
+	n.Left = MaxInt32(0, MinInt32(n.Left, f.TileWidth))
+	n.Right = MaxInt32(0, MinInt32(n.Right, f.TileWidth-n.Left))
+	n.Top = MaxInt32(0, MinInt32(n.Top, f.TileHeight))
+	n.Bottom = MaxInt32(0, MinInt32(n.Bottom, f.TileHeight-n.Top))
+	if n != f.NineSlice {
+		f.NineSlice = n
+		f.FileChanged = true
+	}
+}
+
+// nineSliceSpans splits size into the start margin, middle and end margin. The
+// margins are shrunk if they don't fit
+func nineSliceSpans(start, end, size float32) [4]float32 {
+	if start+end > size && start+end > 0 {
+		scale := size / (start + end)
+		start *= scale
+		end *= scale
+	}
+	return [4]float32{0, start, size - end, size}
+}
+
+// Parts returns the source and destination rectangles of the nine
+// parts of a w by h tile drawn at dw by dh. Both are relative to the top left
+func (n NineSlice) Parts(w, h, dw, dh float32) (src, dst [9]rl.Rectangle) {
+	sx := nineSliceSpans(float32(n.Left), float32(n.Right), w)
+	sy := nineSliceSpans(float32(n.Top), float32(n.Bottom), h)
+	dx := nineSliceSpans(float32(n.Left), float32(n.Right), dw)
+	dy := nineSliceSpans(float32(n.Top), float32(n.Bottom), dh)
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			i := row*3 + col
+			src[i] = rl.NewRectangle(sx[col], sy[row], sx[col+1]-sx[col], sy[row+1]-sy[row])
+			dst[i] = rl.NewRectangle(dx[col], dy[row], dx[col+1]-dx[col], dy[row+1]-dy[row])
+		}
+	}
+	return src, dst
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// nearestColor returns the color of palette closest to color, or color if the
+// palette is empty
+func nearestColor(color rl.Color, palette []rl.Color) rl.Color {
+	nearest := color
+	best := int32(-1)
+	for _, c := range palette {
+		dr := int32(c.R) - int32(color.R)
+		dg := int32(c.G) - int32(color.G)
+		db := int32(c.B) - int32(color.B)
+		da := int32(c.A) - int32(color.A)
+		distance := dr*dr + dg*dg + db*db + da*da
+		if best < 0 || distance < best {
+			nearest = c
+			best = distance
+			if distance == 0 {
+				break
+			}
+		}
+	}
+	return nearest
+}
+
+// lockedColor snaps color to the file's palette when Settings.LockToPalette is
+// set. Erasing isn't changed, so transparent pixels are always allowed
+func (f *File) lockedColor(color rl.Color) rl.Color {
+	if !Settings.LockToPalette || color.A == 0 {
+		return color
+	}
+	if f.CurrentPalette < 0 || f.CurrentPalette >= int32(len(Settings.PaletteData)) {
+		return color
+	}
+	return nearestColor(color, Settings.PaletteData[f.CurrentPalette].data)
+}
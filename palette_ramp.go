@@ -0,0 +1,98 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// Vars
+const (
+	// rampSteps is how many colors a ramp has, the base color is in the middle
+	rampSteps = 5
+	// Shadows shift toward purple and highlights toward yellow
+	rampShadowHue    = 270
+	rampHighlightHue = 60
+)
+
+var (
+	// rampHueShifts are how many degrees the hue of the darkest and lightest
+	// colors of a ramp are shifted
+	rampHueShifts = []int32{0, 15, 30, 45}
+	rampHueShift  = rampHueShifts[2]
+)
+
+// hueToward moves hue up to shift degrees toward target, the short way around
+func hueToward(hue, target, shift float32) float32 {
+	diff := target - hue
+	if diff > 180 {
+		diff -= 360
+	} else if diff < -180 {
+		diff += 360
+	}
+	if diff > shift {
+		diff = shift
+	} else if diff < -shift {
+		diff = -shift
+	}
+	hue += diff
+	if hue < 0 {
+		hue += 360
+	} else if hue >= 360 {
+		hue -= 360
+	}
+	return hue
+}
+
+// HueShiftRamp returns steps colors from dark to light with base in the
+// middle. Shadows get darker, more saturated and shift toward purple,
+// highlights get lighter, less saturated and shift toward yellow
+func HueShiftRamp(base rl.Color, steps int, shift float32) []rl.Color {
+	hsv := rl.ColorToHSV(base)
+	half := float32(steps-1) / 2
+	ramp := make([]rl.Color, 0, steps)
+	for i := 0; i < steps; i++ {
+		t := float32(0)
+		if half > 0 {
+			t = (float32(i) - half) / half
+		}
+		hue, saturation, value := hsv.X, hsv.Y, hsv.Z
+		// Grays don't have a hue, they're tinted toward the target instead
+		switch {
+		case t < 0:
+			if saturation == 0 {
+				hue = rampShadowHue
+			}
+			hue = hueToward(hue, rampShadowHue, shift*-t)
+			saturation += (1 - saturation) * 0.2 * -t
+			value -= value * 0.5 * -t
+		case t > 0:
+			if saturation == 0 {
+				hue = rampHighlightHue
+			}
+			hue = hueToward(hue, rampHighlightHue, shift*t)
+			saturation -= saturation * 0.4 * t
+			value += (1 - value) * 0.6 * t
+		}
+		color := rl.ColorFromHSV(hue, saturation, value)
+		color.A = base.A
+		ramp = append(ramp, color)
+	}
+	// The middle is exactly the base color, converting could round it
+	if steps%2 == 1 {
+		ramp[steps/2] = base
+	}
+	return ramp
+}
+
+// InsertHueShiftRamp adds a ramp of the left color to the palette, in place
+// of the left color if it's in the palette or at the end if it isn't
+func InsertHueShiftRamp() {
+	ramp := HueShiftRamp(LeftColor, rampSteps, float32(rampHueShift))
+	paletteEdit(func(colors []rl.Color) []rl.Color {
+		for i, color := range colors {
+			if color == LeftColor {
+				return append(colors[:i], append(ramp, colors[i+1:]...)...)
+			}
+		}
+		return append(colors, ramp...)
+	})
+}
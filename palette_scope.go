@@ -0,0 +1,160 @@
+package main
+
+import (
+	"log"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// Palettes are either global, saved in the settings, or belong to a file and
+// are saved in its .pix. File palettes are kept in Settings.PaletteData with
+// the global ones so that they're picked and edited the same way, but they
+// aren't written to the settings
+
+// IsFilePalette returns true if the palette is saved in a file
+func (p Palette) IsFilePalette() bool {
+	return p.file != nil
+}
+
+// globalPalettes returns the palettes which are saved in the settings
+func (d PaletteData) globalPalettes() PaletteData {
+	global := make(PaletteData, 0, len(d))
+	for _, palette := range d {
+		if !palette.IsFilePalette() {
+			global = append(global, palette)
+		}
+	}
+	return global
+}
+
+// filePalette returns the index of the palette saved in f, or -1
+func (f *File) filePalette() int32 {
+	for i, palette := range Settings.PaletteData {
+		if palette.file == f {
+			return int32(i)
+		}
+	}
+	return -1
+}
+
+// UsesFilePalette returns true if the file's current palette is saved in it
+func (f *File) UsesFilePalette() bool {
+	return f.CurrentPalette >= 0 && f.CurrentPalette < int32(len(Settings.PaletteData)) &&
+		Settings.PaletteData[f.CurrentPalette].file == f
+}
+
+// setFilePalette makes colors the palette saved in f and picks it
+func (f *File) setFilePalette(name string, colors []rl.Color) {
+	palette := Palette{
+		Name: name,
+		data: append([]rl.Color{}, colors...),
+		file: f,
+	}
+	if index := f.filePalette(); index >= 0 {
+		Settings.PaletteData[index] = palette
+		f.CurrentPalette = index
+		return
+	}
+	Settings.PaletteData = append(Settings.PaletteData, palette)
+	f.CurrentPalette = int32(len(Settings.PaletteData) - 1)
+}
+
+// addGlobalPalette adds palette to the settings and returns its index. A
+// global palette with the same name and colors is reused instead, so that
+// importing the same file again doesn't add it again
+func addGlobalPalette(palette Palette) int32 {
+	for i, existing := range Settings.PaletteData {
+		if !existing.IsFilePalette() && existing.Name == palette.Name && sameColors(existing.data, palette.data) {
+			return int32(i)
+		}
+	}
+	Settings.PaletteData = append(Settings.PaletteData, palette)
+	if err := SaveSettings(); err != nil {
+		log.Println(err)
+	}
+	return int32(len(Settings.PaletteData) - 1)
+}
+
+// sameColors returns true if a and b are the same colors in the same order
+func sameColors(a, b []rl.Color) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// removePalette removes the palette at index, the files which were using it
+// use the first palette instead. The palette edits in every file's history
+// are moved to the palettes' new indexes
+func removePalette(index int32) {
+	Settings.PaletteData = append(Settings.PaletteData[:index], Settings.PaletteData[index+1:]...)
+	for _, file := range Files {
+		if file.CurrentPalette == index {
+			file.CurrentPalette = 0
+		} else if file.CurrentPalette > index {
+			file.CurrentPalette--
+		}
+		for i, action := range file.History {
+			file.History[i] = remapPaletteHistory(action, index)
+		}
+	}
+}
+
+// remapPaletteHistory returns the action with its palette index moved down
+// past the removed palette. Edits of the removed palette get an index of -1
+func remapPaletteHistory(action interface{}, removed int32) interface{} {
+	switch typed := action.(type) {
+	case CompoundHistory:
+		for i, a := range typed.Actions {
+			typed.Actions[i] = remapPaletteHistory(a, removed)
+		}
+	case HistoryPalette:
+		if typed.PaletteIndex == removed {
+			typed.PaletteIndex = -1
+		} else if typed.PaletteIndex > removed {
+			typed.PaletteIndex--
+		}
+		return typed
+	}
+	return action
+}
+
+// removeFilePalette removes the palette saved in f when it's closed
+func (f *File) removeFilePalette() {
+	if index := f.filePalette(); index >= 0 {
+		removePalette(index)
+	}
+}
+
+// CopyPaletteToFile saves a copy of the current palette in the current file,
+// replacing the palette which was saved in it
+func CopyPaletteToFile() {
+	if CurrentFile.UsesFilePalette() {
+		return
+	}
+	palette := Settings.PaletteData[CurrentFile.CurrentPalette]
+	CurrentFile.setFilePalette(palette.Name, palette.data)
+	CurrentFile.FileChanged = true
+	PaletteUIRebuildPalette()
+}
+
+// CopyPaletteToGlobal adds a copy of the current palette to the global
+// palettes and picks it
+func CopyPaletteToGlobal() {
+	if !CurrentFile.UsesFilePalette() {
+		return
+	}
+	palette := Settings.PaletteData[CurrentFile.CurrentPalette]
+	Settings.PaletteData = append(Settings.PaletteData, Palette{
+		Name: palette.Name,
+		data: append([]rl.Color{}, palette.data...),
+	})
+	CurrentFile.CurrentPalette = int32(len(Settings.PaletteData) - 1)
+	SaveSettings()
+	PaletteUIRebuildPalette()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"sort"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	// paletteMergeThresholds are how far apart each channel of two colors can
+	// be for them to be merged
+	paletteMergeThresholds = []int32{8, 16, 32, 64}
+	paletteMergeThreshold  = paletteMergeThresholds[1]
+)
+
+// AppendPaletteHistory adds the edit of the palette from prev to its current
+// colors to the history. Global palettes aren't saved with the file, so it
+// isn't marked as changed for them
+func (f *File) AppendPaletteHistory(palette int32, prev []rl.Color) {
+	changed := f.FileChanged
+	f.AppendHistory(HistoryPalette{
+		PaletteIndex: palette,
+		Prev:         prev,
+		Current:      append([]rl.Color{}, Settings.PaletteData[palette].data...),
+	})
+	if !Settings.PaletteData[palette].IsFilePalette() {
+		f.FileChanged = changed
+	}
+}
+
+// setPaletteColors sets the palette's colors when its history is undone or
+// redone
+func (f *File) setPaletteColors(palette int32, colors []rl.Color) {
+	if palette < 0 || palette >= int32(len(Settings.PaletteData)) {
+		return
+	}
+	Settings.PaletteData[palette].data = append([]rl.Color{}, colors...)
+	SaveSettings()
+	if palette == f.CurrentPalette {
+		PaletteUIRebuildPalette()
+	}
+}
+
+// paletteEdit replaces the current palette's colors with the result of edit
+// as one history action
+func paletteEdit(edit func(colors []rl.Color) []rl.Color) {
+	palette := CurrentFile.CurrentPalette
+	if palette < 0 || palette >= int32(len(Settings.PaletteData)) {
+		return
+	}
+	old := Settings.PaletteData[palette].data
+	colors := edit(append([]rl.Color{}, old...))
+	if len(colors) == len(old) {
+		same := true
+		for i := range colors {
+			if colors[i] != old[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return
+		}
+	}
+
+	Settings.PaletteData[palette].data = colors
+	CurrentFile.AppendPaletteHistory(palette, old)
+	SaveSettings()
+	PaletteUIRebuildPalette()
+}
+
+// colorLuminance returns how bright color looks, from 0 to 255
+func colorLuminance(color rl.Color) float32 {
+	return 0.2126*float32(color.R) + 0.7152*float32(color.G) + 0.0722*float32(color.B)
+}
+
+// SortPaletteByHue sorts the palette by hue, grays go first from dark to light
+func SortPaletteByHue() {
+	paletteEdit(func(colors []rl.Color) []rl.Color {
+		hsv := make(map[rl.Color]rl.Vector3, len(colors))
+		for _, color := range colors {
+			hsv[color] = rl.ColorToHSV(color)
+		}
+		sort.SliceStable(colors, func(i, j int) bool {
+			a, b := hsv[colors[i]], hsv[colors[j]]
+			grayA, grayB := a.Y == 0, b.Y == 0
+			switch {
+			case grayA != grayB:
+				return grayA
+			case grayA || a.X == b.X:
+				return a.Z < b.Z
+			}
+			return a.X < b.X
+		})
+		return colors
+	})
+}
+
+// SortPaletteByLuminance sorts the palette from dark to light
+func SortPaletteByLuminance() {
+	paletteEdit(func(colors []rl.Color) []rl.Color {
+		sort.SliceStable(colors, func(i, j int) bool {
+			return colorLuminance(colors[i]) < colorLuminance(colors[j])
+		})
+		return colors
+	})
+}
+
+// RemovePaletteDuplicates removes colors which are already in the palette,
+// the first of them is kept
+func RemovePaletteDuplicates() {
+	paletteEdit(func(colors []rl.Color) []rl.Color {
+		seen := make(map[rl.Color]bool, len(colors))
+		kept := colors[:0]
+		for _, color := range colors {
+			if !seen[color] {
+				seen[color] = true
+				kept = append(kept, color)
+			}
+		}
+		return kept
+	})
+}
+
+// RemoveUnusedPaletteColors removes colors which aren't on any layer of the
+// current file
+func RemoveUnusedPaletteColors() {
+	used := make(map[rl.Color]bool)
+	for _, layer := range CurrentFile.Layers {
+		for _, color := range layer.PixelData {
+			used[color] = true
+		}
+	}
+	paletteEdit(func(colors []rl.Color) []rl.Color {
+		kept := colors[:0]
+		for _, color := range colors {
+			if used[color] {
+				kept = append(kept, color)
+			}
+		}
+		return kept
+	})
+}
+
+// colorsSimilar returns true if no channel of a and b is more than threshold
+// apart
+func colorsSimilar(a, b rl.Color, threshold int32) bool {
+	return AbsInt32(int32(a.R)-int32(b.R)) <= threshold &&
+		AbsInt32(int32(a.G)-int32(b.G)) <= threshold &&
+		AbsInt32(int32(a.B)-int32(b.B)) <= threshold &&
+		AbsInt32(int32(a.A)-int32(b.A)) <= threshold
+}
+
+// MergeSimilarPaletteColors removes colors which are within
+// paletteMergeThreshold of a color before them in the palette
+func MergeSimilarPaletteColors() {
+	paletteEdit(func(colors []rl.Color) []rl.Color {
+		kept := colors[:0]
+		for _, color := range colors {
+			similar := false
+			for _, k := range kept {
+				if colorsSimilar(color, k, paletteMergeThreshold) {
+					similar = true
+					break
+				}
+			}
+			if !similar {
+				kept = append(kept, color)
+			}
+		}
+		return kept
+	})
+}
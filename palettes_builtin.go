@@ -0,0 +1,101 @@
+package main
+
+import (
+	"log"
+)
+
+// BuiltinPalette is a palette of a console or computer, MaxTileColors is how
+// many colors each of its tiles or sprites can have, 0 if there's no limit
+type BuiltinPalette struct {
+	Palette       Palette
+	MaxTileColors int32
+}
+
+// builtinPalettes are listed in the palette menu, picking one copies it into
+// the settings
+var builtinPalettes = []BuiltinPalette{
+	// The original Game Boy's four greens, 4 colors per 8x8 tile
+	{
+		Palette: Palette{
+			Name: "Game Boy",
+			Strings: []string{
+				"0f380fff", "306230ff", "8bac0fff", "9bbc0fff",
+			},
+		},
+		MaxTileColors: 4,
+	},
+	// The NES's PPU colors, sprites have 3 colors and transparency
+	{
+		Palette: Palette{
+			Name: "NES",
+			Strings: []string{
+				"000000ff", "7c7c7cff", "0000fcff", "0000bcff", "4428bcff", "940084ff", "a80020ff", "a81000ff",
+				"881400ff", "503000ff", "007800ff", "006800ff", "005800ff", "004058ff", "bcbcbcff", "0078f8ff",
+				"0058f8ff", "6844fcff", "d800ccff", "e40058ff", "f83800ff", "e45c10ff", "ac7c00ff", "00b800ff",
+				"00a800ff", "00a844ff", "008888ff", "f8f8f8ff", "3cbcfcff", "6888fcff", "9878f8ff", "f878f8ff",
+				"f85898ff", "f87858ff", "fca044ff", "f8b800ff", "b8f818ff", "58d854ff", "58f898ff", "00e8d8ff",
+				"787878ff", "fcfcfcff", "a4e4fcff", "b8b8f8ff", "d8b8f8ff", "f8b8f8ff", "f8a4c0ff", "f0d0b0ff",
+				"fce0a8ff", "f8d878ff", "d8f878ff", "b8f8b8ff", "b8f8d8ff", "00fcfcff", "f8d8f8ff",
+			},
+		},
+		MaxTileColors: 3,
+	},
+	// PICO-8's 16 colors, there's no limit per tile
+	{
+		Palette: Palette{
+			Name: "PICO-8",
+			Strings: []string{
+				"000000ff", "1d2b53ff", "7e2553ff", "008751ff", "ab5236ff", "5f574fff", "c2c3c7ff", "fff1e8ff",
+				"ff004dff", "ffa300ff", "ffec27ff", "00e436ff", "29adffff", "83769cff", "ff77a8ff", "ffccaaff",
+			},
+		},
+	},
+	// The C64's 16 colors, multicolor sprites have 3 colors and transparency
+	{
+		Palette: Palette{
+			Name: "C64",
+			Strings: []string{
+				"000000ff", "ffffffff", "68372bff", "70a4b2ff", "6f3d86ff", "588d43ff", "352879ff", "b8c76fff",
+				"6f4f25ff", "433900ff", "9a6759ff", "444444ff", "6c6c6cff", "9ad284ff", "6c5eb5ff", "959595ff",
+			},
+		},
+		MaxTileColors: 3,
+	},
+}
+
+// PickBuiltinPalette makes the built-in palette the current palette and sets
+// the file's tile color limit to the palette's. The palette is added to the
+// settings unless a palette with the same name already is
+func PickBuiltinPalette(index int) {
+	builtin := builtinPalettes[index]
+	current := -1
+	for i, palette := range Settings.PaletteData {
+		if palette.Name == builtin.Palette.Name {
+			current = i
+			break
+		}
+	}
+	if current < 0 {
+		palette := Palette{
+			Name:    builtin.Palette.Name,
+			Strings: append([]string{}, builtin.Palette.Strings...),
+		}
+		for _, hex := range palette.Strings {
+			color, err := HexToColor(hex)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			palette.data = append(palette.data, color)
+		}
+		Settings.PaletteData = append(Settings.PaletteData, palette)
+		current = len(Settings.PaletteData) - 1
+		if err := SaveSettings(); err != nil {
+			log.Println(err)
+		}
+	}
+
+	CurrentFile.CurrentPalette = int32(current)
+	CurrentFile.SetMaxTileColors(builtin.MaxTileColors)
+	PaletteUIRebuildPalette()
+}
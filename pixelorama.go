@@ -0,0 +1,175 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// pxoProject is the part of a Pixelorama project's data.json which is imported
+type pxoProject struct {
+	SizeX  int32      `json:"size_x"`
+	SizeY  int32      `json:"size_y"`
+	FPS    float32    `json:"fps"`
+	Layers []pxoLayer `json:"layers"`
+	Frames []pxoFrame `json:"frames"`
+	Tags   []pxoTag   `json:"tags"`
+}
+
+// pxoLayer and pxoCel use pointers since older projects don't have every field,
+// and the defaults aren't the zero values
+type pxoLayer struct {
+	Name    string   `json:"name"`
+	Visible *bool    `json:"visible"`
+	Opacity *float32 `json:"opacity"`
+	// Type is 0 for pixel layers, groups and 3d layers don't have pixels
+	Type int32 `json:"type"`
+}
+
+type pxoFrame struct {
+	Cels []pxoCel `json:"cels"`
+}
+
+type pxoCel struct {
+	Opacity *float32 `json:"opacity"`
+}
+
+// pxoTag is an animation, From and To are 1 based frame indices
+type pxoTag struct {
+	Name string `json:"name"`
+	From int32  `json:"from"`
+	To   int32  `json:"to"`
+}
+
+// ImportPixelorama imports a Pixelorama (0.11 or newer) project. Every frame
+// becomes a tile in a single row, tags become animations and the colors which
+// are used are added as a palette
+func ImportPixelorama(openPath string) (*File, error) {
+	archive, err := zip.OpenReader(openPath)
+	if err != nil {
+		return nil, fmt.Errorf("Can't open \"%s\": only zip based .pxo files (Pixelorama 0.11+) are supported: %v", openPath, err)
+	}
+	defer archive.Close()
+
+	entries := make(map[string]*zip.File, len(archive.File))
+	for _, entry := range archive.File {
+		entries[entry.Name] = entry
+	}
+	readEntry := func(name string) ([]byte, error) {
+		entry, ok := entries[name]
+		if !ok {
+			return nil, fmt.Errorf("Can't open \"%s\": %s is missing", openPath, name)
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+
+	data, err := readEntry("data.json")
+	if err != nil {
+		return nil, err
+	}
+	project := &pxoProject{}
+	if err := json.Unmarshal(data, project); err != nil {
+		return nil, fmt.Errorf("Can't open \"%s\": %v", openPath, err)
+	}
+	if project.SizeX <= 0 || project.SizeY <= 0 || len(project.Frames) == 0 {
+		return nil, fmt.Errorf("Can't open \"%s\": the project is empty", openPath)
+	}
+
+	frameCount := int32(len(project.Frames))
+	f := NewFile(project.SizeX*frameCount, project.SizeY, project.SizeX, project.SizeY)
+	f.PathDir = path.Dir(openPath)
+	f.Filename = strings.TrimSuffix(path.Base(openPath), path.Ext(openPath)) + ".pix"
+
+	for _, layer := range f.Layers {
+		layer.Unload()
+	}
+	f.Layers = make([]*Layer, 0, len(project.Layers))
+
+	colors := make(map[rl.Color]struct{})
+	palette := Palette{Name: strings.TrimSuffix(path.Base(openPath), path.Ext(openPath))}
+
+	celSize := int(project.SizeX * project.SizeY * 4)
+	for li, pl := range project.Layers {
+		if pl.Type != 0 {
+			continue
+		}
+		layer := NewLayer(f.CanvasWidth, f.CanvasHeight, pl.Name, rl.Blank, false)
+		layer.Hidden = pl.Visible != nil && !*pl.Visible
+
+		for fi, frame := range project.Frames {
+			pixels, err := readEntry(fmt.Sprintf("image_data/frames/%d/layer_%d", fi+1, li+1))
+			if err != nil || len(pixels) < celSize {
+				// Empty cels aren't always saved
+				continue
+			}
+			opacity := float32(1)
+			if pl.Opacity != nil {
+				opacity = *pl.Opacity
+			}
+			if li < len(frame.Cels) && frame.Cels[li].Opacity != nil {
+				opacity *= *frame.Cels[li].Opacity
+			}
+
+			for i := 0; i < celSize; i += 4 {
+				if pixels[i+3] == 0 {
+					continue
+				}
+				color := rl.NewColor(pixels[i], pixels[i+1], pixels[i+2], uint8(float32(pixels[i+3])*opacity))
+				x := int32(i/4)%project.SizeX + int32(fi)*project.SizeX
+				y := int32(i/4) / project.SizeX
+				layer.PixelData[IntVec2{x, y}] = color
+
+				opaque := color
+				opaque.A = 255
+				if _, ok := colors[opaque]; !ok {
+					colors[opaque] = struct{}{}
+					palette.data = append(palette.data, opaque)
+					palette.Strings = append(palette.Strings, ColorToHex(opaque))
+				}
+			}
+		}
+
+		layer.Redraw()
+		f.Layers = append(f.Layers, layer)
+	}
+	if len(f.Layers) == 0 {
+		f.Layers = append(f.Layers, NewLayer(f.CanvasWidth, f.CanvasHeight, T("background"), rl.Blank, true))
+	}
+
+	fps := project.FPS
+	if fps <= 0 {
+		fps = 5
+	}
+	for _, tag := range project.Tags {
+		f.Animations = append(f.Animations, &Animation{
+			Name:       tag.Name,
+			FrameStart: tag.From - 1,
+			FrameEnd:   tag.To - 1,
+			Timing:     fps,
+		})
+	}
+	if len(f.Animations) == 0 && frameCount > 1 {
+		f.Animations = append(f.Animations, &Animation{
+			Name:       palette.Name,
+			FrameStart: 0,
+			FrameEnd:   frameCount - 1,
+			Timing:     fps,
+		})
+	}
+
+	if len(palette.data) > 0 {
+		f.CurrentPalette = addGlobalPalette(palette)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	// previewStackSpacing is how many pixels each layer is drawn above the
+	// one below it and previewStackSpeed is how many degrees the stack turns
+	// each second, 0 stops it
+	previewStackSpacing int32 = 1
+	previewStackSpeed   int32 = 45
+	previewStackAngle   float32
+
+	// previewStackSlices are the current tile of each visible layer, bottom
+	// first. They're only remade when the tile changes
+	previewStackSlices []rl.RenderTexture2D
+)
+
+// previewUIUpdateStack turns the stack and remakes the slices if the tile
+// under x, y has changed
+func previewUIUpdateStack(x, y int32, changed bool) {
+	previewStackAngle = float32(math.Mod(float64(previewStackAngle+rl.GetFrameTime()*float32(previewStackSpeed)), 360))
+	if !changed && previewStackSlices != nil {
+		return
+	}
+
+	previewUIUnloadStack()
+	clampedPos := GetClampedCoordinates(x, y)
+	tilePos := GetTilePosition(clampedPos.X, clampedPos.Y)
+	previewStackSlices = make([]rl.RenderTexture2D, 0, len(CurrentFile.Layers))
+	for _, layer := range CurrentFile.Layers {
+		if layer.Hidden {
+			continue
+		}
+		slice := rl.LoadRenderTexture(CurrentFile.TileWidth, CurrentFile.TileHeight)
+		rl.BeginTextureMode(slice)
+		rl.ClearBackground(rl.Blank)
+		for py := int32(0); py < CurrentFile.TileHeight; py++ {
+			for px := int32(0); px < CurrentFile.TileWidth; px++ {
+				if color, ok := layer.PixelData[IntVec2{tilePos.X + px, tilePos.Y + py}]; ok && color.A > 0 {
+					rl.DrawPixel(px, py, color)
+				}
+			}
+		}
+		rl.EndTextureMode()
+		previewStackSlices = append(previewStackSlices, slice)
+	}
+}
+
+// previewUIUnloadStack unloads the slices
+func previewUIUnloadStack() {
+	for _, slice := range previewStackSlices {
+		rl.UnloadRenderTexture(slice)
+	}
+	previewStackSlices = nil
+}
+
+// previewUIDrawStack draws the slices turned by previewStackAngle, each one
+// previewStackSpacing above the last, scaled to fit width*height
+func previewUIDrawStack(width, height float32) {
+	rl.DrawRectangle(0, 0, int32(width), int32(height), rl.DarkGray)
+	if len(previewStackSlices) == 0 {
+		return
+	}
+
+	tileWidth := float32(CurrentFile.TileWidth) * CurrentFile.PixelAspect
+	tileHeight := float32(CurrentFile.TileHeight)
+	// The turned tile always fits in its diagonal
+	diagonal := float32(math.Hypot(float64(tileWidth), float64(tileHeight)))
+	stackHeight := float32(previewStackSpacing) * float32(len(previewStackSlices)-1)
+	scale := width / diagonal
+	if s := height / (diagonal + stackHeight); s < scale {
+		scale = s
+	}
+
+	center := rl.NewVector2(width/2, height/2+stackHeight*scale/2)
+	for i, slice := range previewStackSlices {
+		rl.DrawTexturePro(
+			slice.Texture,
+			rl.NewRectangle(0, 0, float32(CurrentFile.TileWidth), -float32(CurrentFile.TileHeight)),
+			rl.NewRectangle(
+				center.X,
+				center.Y-float32(i)*float32(previewStackSpacing)*scale,
+				tileWidth*scale,
+				tileHeight*scale),
+			rl.NewVector2(tileWidth*scale/2, tileHeight*scale/2),
+			previewStackAngle,
+			rl.White,
+		)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RunRecolorWatcher is the recolor subcommand, it watches a folder of .pix
+// files and writes a png of each file recolored by each of its alternate
+// palettes whenever the file changes. The usage is
+// "pixel recolor dir [--out dir] [--palettes red,blue] [--interval 1s] [--once]"
+func RunRecolorWatcher(args []string) error {
+	flags := flag.NewFlagSet("recolor", flag.ContinueOnError)
+	out := flags.String("out", "", "where the variants are written, defaults to next to each file")
+	palettes := flags.String("palettes", "", "comma separated names of the alternate palettes to use, defaults to all of them")
+	interval := flags.Duration("interval", time.Second, "how often the folder is checked for changes")
+	once := flags.Bool("once", false, "write every variant once and exit instead of watching")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s recolor dir [--out dir] [--palettes red,blue] [--interval 1s] [--once]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	// Flags can come after the path
+	paths := make([]string, 0, 1)
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		paths = append(paths, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(paths) != 1 {
+		flags.Usage()
+		return fmt.Errorf("Expected a folder to watch, got %d paths", len(paths))
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("Interval must be more than 0, got %v", *interval)
+	}
+	var names []string
+	if *palettes != "" {
+		names = strings.Split(*palettes, ",")
+	}
+
+	// The blending setting changes how layers are flattened
+	if err := LoadSettings(); err != nil {
+		log.Println(err)
+	}
+
+	dir := paths[0]
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0755); err != nil {
+			return err
+		}
+	}
+	modified := make(map[string]time.Time)
+	for {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.Mode().IsRegular() || filepath.Ext(entry.Name()) != ".pix" {
+				continue
+			}
+			p := filepath.Join(dir, entry.Name())
+			if last, ok := modified[p]; ok && last.Equal(entry.ModTime()) {
+				continue
+			}
+			modified[p] = entry.ModTime()
+
+			written, err := WriteRecolors(p, *out, names)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			for _, w := range written {
+				log.Println("Recolored", entry.Name(), "to", w)
+			}
+		}
+		if *once {
+			return nil
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// WriteRecolors flattens the .pix file at p and writes a png of it recolored
+// by each of its alternate palettes named in names, or all of them if names
+// is empty. They're written to outDir, or next to the file if it's empty, as
+// name_palette.png like saving as a png does. It returns the written paths
+func WriteRecolors(p, outDir string, names []string) ([]string, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	fileSer := &FileSer{}
+	err = gob.NewDecoder(file).Decode(&fileSer)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Can't recolor \"%s\": %v", p, err)
+	}
+
+	if outDir == "" {
+		outDir = filepath.Dir(p)
+	}
+	base := filepath.Join(outDir, strings.TrimSuffix(filepath.Base(p), filepath.Ext(p)))
+	text := fileSer.Metadata.pngText()
+
+	written := make([]string, 0, len(fileSer.AltPalettes))
+	for _, alt := range fileSer.AltPalettes {
+		if !recolorWanted(alt.Name, names) {
+			continue
+		}
+		img := fileSer.compositeImage(alt, ExportFilter(nil))
+		dest := base + "_" + safeFilename(alt.Name) + ".png"
+		if err := writeFileAtomic(dest, func(w io.Writer) error {
+			return encodePNGWithText(w, img, text)
+		}); err != nil {
+			return written, fmt.Errorf("Can't write \"%s\": %v", dest, err)
+		}
+		written = append(written, dest)
+	}
+	return written, nil
+}
+
+// recolorWanted returns true if name is in names or names is empty
+func recolorWanted(name string, names []string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, n := range names {
+		if strings.TrimSpace(n) == name {
+			return true
+		}
+	}
+	return false
+}
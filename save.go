@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// saveResult is sent once a file has been written in the background
+type saveResult struct {
+	file *File
+	path string
+	err  error
+}
+
+var (
+	// saveResults are collected by UpdateSaves on the main thread
+	saveResults = make(chan saveResult, 8)
+	// pendingSaves is how many files are still being written
+	pendingSaves int
+)
+
+// writeFileAtomic writes to a temporary file next to path and renames it to
+// path once it's been fully written, so a crash while writing leaves whatever
+// was at path untouched
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// The temporary file is gone once it's been renamed
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// Temporary files can only be read by their owner, the file being replaced
+	// keeps its permissions
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// backupChoices is what the prefs menu cycles through
+var backupChoices = []int32{0, 1, 3, 5, 10}
+
+// nextBackupChoice returns the number of backups after current in
+// backupChoices. A number which isn't one of them, e.g. from editing the
+// settings, goes to the next bigger choice
+func nextBackupChoice(current int32) int32 {
+	for _, choice := range backupChoices {
+		if choice > current {
+			return choice
+		}
+	}
+	return backupChoices[0]
+}
+
+// backupPath returns where the nth newest backup of path goes, starting at 1
+func backupPath(path string, n int32, dir string) string {
+	if dir != "" {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(path), dir)
+		}
+		path = filepath.Join(dir, filepath.Base(path))
+	}
+	if n == 1 {
+		return path + ".bak"
+	}
+	return fmt.Sprintf("%s.bak%d", path, n)
+}
+
+// rotateBackups copies the file at path to its newest backup before it's
+// saved over, the older backups are shifted along and the oldest is removed.
+// Nothing happens if count is 0 or there's nothing at path yet
+func rotateBackups(path string, count int32, dir string) error {
+	if count <= 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Couldn't back up \"%s\": %v", filepath.Base(path), err)
+	}
+	if dir != "" {
+		if err := os.MkdirAll(filepath.Dir(backupPath(path, 1, dir)), 0755); err != nil {
+			return fmt.Errorf("Couldn't make the backup directory: %v", err)
+		}
+	}
+
+	if err := pruneBackups(path, count, dir); err != nil {
+		return err
+	}
+	os.Remove(backupPath(path, count, dir))
+	for n := count - 1; n >= 1; n-- {
+		if err := os.Rename(backupPath(path, n, dir), backupPath(path, n+1, dir)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Couldn't rotate the backups of \"%s\": %v", filepath.Base(path), err)
+		}
+	}
+	return writeFileAtomic(backupPath(path, 1, dir), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// pruneBackups removes the backups of path which are older than the count
+// newest, which are left behind when the number of backups is lowered
+func pruneBackups(path string, count int32, dir string) error {
+	newest := backupPath(path, 1, dir)
+	infos, err := ioutil.ReadDir(filepath.Dir(newest))
+	if err != nil {
+		return fmt.Errorf("Couldn't find the old backups of \"%s\": %v", filepath.Base(path), err)
+	}
+	prefix := filepath.Base(newest)
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name(), prefix) {
+			continue
+		}
+		// The newest backup doesn't have a number
+		n, err := strconv.Atoi(strings.TrimPrefix(info.Name(), prefix))
+		if err != nil || int32(n) <= count {
+			continue
+		}
+		if err := os.Remove(filepath.Join(filepath.Dir(newest), info.Name())); err != nil {
+			return fmt.Errorf("Couldn't remove the old backups of \"%s\": %v", filepath.Base(path), err)
+		}
+	}
+	return nil
+}
+
+// UpdateSaves finishes the saves which have been written, it's called every
+// frame
+func UpdateSaves() {
+	for {
+		select {
+		case result := <-saveResults:
+			pendingSaves--
+			result.file.finishSave(result.path, result.err)
+		default:
+			return
+		}
+	}
+}
+
+// WaitForSaves blocks until every file has been written, so that quitting
+// straight after saving doesn't lose the save
+func WaitForSaves() {
+	for pendingSaves > 0 {
+		result := <-saveResults
+		pendingSaves--
+		result.file.finishSave(result.path, result.err)
+	}
+}
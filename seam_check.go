@@ -0,0 +1,159 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// seamCheckThreshold is how different, adding up the differences of each
+// channel, the pixels on opposite edges can be before they're highlighted
+const seamCheckThreshold = 48
+
+// seamCheckState is what the seams were found for, they're only found again
+// when it changes
+type seamCheckState struct {
+	file                      *File
+	history                   int
+	offset                    int32
+	canvasWidth, canvasHeight int32
+	pixels, hidden            int
+}
+
+var (
+	// SeamCheck draws the canvas wrapped around by half of its size, so
+	// that the edges meet in the middle. Drawing wraps around too
+	SeamCheck bool
+
+	seamCheckLast  seamCheckState
+	seamMismatches []IntVec2
+)
+
+// ToggleSeamCheck turns the seam check on or off
+func ToggleSeamCheck() {
+	SeamCheck = !SeamCheck
+}
+
+// seamCheckOffset is how far the canvas is moved by the seam check
+func (f *File) seamCheckOffset() IntVec2 {
+	return IntVec2{f.CanvasWidth / 2, f.CanvasHeight / 2}
+}
+
+// seamCheckRegions calls draw with each part of the canvas, in pixels, and
+// where it's shown in the canvas' camera. The seam check shows the canvas in
+// up to four parts which wrap around, otherwise it's drawn whole
+func (f *File) seamCheckRegions(draw func(src, dest rl.Rectangle)) {
+	left, top := -float32(f.CanvasWidth)/2, -float32(f.CanvasHeight)/2
+	if !SeamCheck {
+		draw(rl.NewRectangle(0, 0, float32(f.CanvasWidth), float32(f.CanvasHeight)),
+			rl.NewRectangle(left, top, float32(f.CanvasWidth), float32(f.CanvasHeight)))
+		return
+	}
+
+	// Each part is where it's taken from, how big it is and where it's shown
+	offset := f.seamCheckOffset()
+	xs := [][3]int32{{offset.X, f.CanvasWidth - offset.X, 0}, {0, offset.X, f.CanvasWidth - offset.X}}
+	ys := [][3]int32{{offset.Y, f.CanvasHeight - offset.Y, 0}, {0, offset.Y, f.CanvasHeight - offset.Y}}
+	for _, y := range ys {
+		for _, x := range xs {
+			if x[1] <= 0 || y[1] <= 0 {
+				continue
+			}
+			draw(rl.NewRectangle(float32(x[0]), float32(y[0]), float32(x[1]), float32(y[1])),
+				rl.NewRectangle(left+float32(x[2]), top+float32(y[2]), float32(x[1]), float32(y[1])))
+		}
+	}
+}
+
+// seamCheckWrap converts where the cursor is on the shown canvas to where it
+// is on the canvas. Positions outside of the canvas aren't changed
+func (f *File) seamCheckWrap(cursor rl.Vector2) rl.Vector2 {
+	if !SeamCheck || cursor.X < 0 || cursor.Y < 0 || cursor.X >= float32(f.CanvasWidth) || cursor.Y >= float32(f.CanvasHeight) {
+		return cursor
+	}
+	offset := f.seamCheckOffset()
+	cursor.X += float32(offset.X)
+	if cursor.X >= float32(f.CanvasWidth) {
+		cursor.X -= float32(f.CanvasWidth)
+	}
+	cursor.Y += float32(offset.Y)
+	if cursor.Y >= float32(f.CanvasHeight) {
+		cursor.Y -= float32(f.CanvasHeight)
+	}
+	return cursor
+}
+
+// seamCheckJumped returns true if the cursor wrapped around between last and
+// cursor, strokes are started again so that they don't cross the canvas
+func (f *File) seamCheckJumped(last, cursor rl.Vector2) bool {
+	if !SeamCheck {
+		return false
+	}
+	dx, dy := cursor.X-last.X, cursor.Y-last.Y
+	return dx*dx*4 > float32(f.CanvasWidth*f.CanvasWidth) || dy*dy*4 > float32(f.CanvasHeight*f.CanvasHeight)
+}
+
+// seamDifferent returns true if a and b are too different to tile cleanly
+func seamDifferent(a, b rl.Color) bool {
+	return AbsInt32(int32(a.R)-int32(b.R))+AbsInt32(int32(a.G)-int32(b.G))+
+		AbsInt32(int32(a.B)-int32(b.B))+AbsInt32(int32(a.A)-int32(b.A)) > seamCheckThreshold
+}
+
+// SeamMismatches returns the edge pixels which are too different from the
+// pixel on the opposite edge, both pixels of each pair are returned
+func (f *File) SeamMismatches() []IntVec2 {
+	mismatches := make([]IntVec2, 0)
+	right, bottom := f.CanvasWidth-1, f.CanvasHeight-1
+	for y := int32(0); y < f.CanvasHeight; y++ {
+		if seamDifferent(f.CompositePixel(IntVec2{0, y}), f.CompositePixel(IntVec2{right, y})) {
+			mismatches = append(mismatches, IntVec2{0, y}, IntVec2{right, y})
+		}
+	}
+	for x := int32(0); x < f.CanvasWidth; x++ {
+		if seamDifferent(f.CompositePixel(IntVec2{x, 0}), f.CompositePixel(IntVec2{x, bottom})) {
+			mismatches = append(mismatches, IntVec2{x, 0}, IntVec2{x, bottom})
+		}
+	}
+	return mismatches
+}
+
+// DrawSeamCheck highlights the pixels which don't tile cleanly where they're
+// shown by the seam check, it's drawn in the canvas' camera
+func DrawSeamCheck() {
+	if !SeamCheck {
+		return
+	}
+
+	state := seamCheckState{
+		file:         CurrentFile,
+		history:      len(CurrentFile.History),
+		offset:       CurrentFile.historyOffset,
+		canvasWidth:  CurrentFile.CanvasWidth,
+		canvasHeight: CurrentFile.CanvasHeight,
+	}
+	for _, layer := range CurrentFile.Layers {
+		state.pixels += len(layer.PixelData)
+		if layer.Hidden {
+			state.hidden++
+		}
+	}
+	if state != seamCheckLast {
+		seamCheckLast = state
+		seamMismatches = CurrentFile.SeamMismatches()
+	}
+
+	offset := CurrentFile.seamCheckOffset()
+	for _, loc := range seamMismatches {
+		// Where the pixel is shown, the opposite of seamCheckWrap
+		x := loc.X - offset.X
+		if x < 0 {
+			x += CurrentFile.CanvasWidth
+		}
+		y := loc.Y - offset.Y
+		if y < 0 {
+			y += CurrentFile.CanvasHeight
+		}
+		rl.DrawRectangleLinesEx(rl.NewRectangle(
+			float32(-CurrentFile.CanvasWidth/2+x),
+			float32(-CurrentFile.CanvasHeight/2+y),
+			1, 1), 0.15, rl.Red)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	// Registers jpeg for image.Decode, png and gif are registered by the
+	// encoders
+	_ "image/jpeg"
+	"os"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// InCanvas returns true if loc is inside of the canvas
+func (f *File) InCanvas(loc IntVec2) bool {
+	return loc.X >= 0 && loc.X < f.CanvasWidth && loc.Y >= 0 && loc.Y < f.CanvasHeight
+}
+
+// SelectionOutOfBounds returns the selected pixels which are outside of the
+// canvas and would be lost when the selection is committed. Blank pixels are
+// ignored since nothing would be lost
+func (f *File) SelectionOutOfBounds() map[IntVec2]rl.Color {
+	outside := make(map[IntVec2]rl.Color)
+	for loc, color := range f.Selection {
+		if color.A > 0 && !f.InCanvas(loc) {
+			outside[loc] = color
+		}
+	}
+	return outside
+}
+
+// selectionRect returns the normalized selection bounds
+func (f *File) selectionRect() (minX, minY, maxX, maxY int32) {
+	b := f.SelectionBounds
+	return MinInt32(b[0], b[2]), MinInt32(b[1], b[3]), MaxInt32(b[0], b[2]), MaxInt32(b[1], b[3])
+}
+
+// ClipSelection removes the selected pixels which are outside of the canvas
+// and shrinks the selection bounds to the canvas
+func (f *File) ClipSelection() {
+	if !f.DoingSelection {
+		return
+	}
+
+	minX, minY, maxX, maxY := f.selectionRect()
+	minX = MaxInt32(minX, 0)
+	minY = MaxInt32(minY, 0)
+	maxX = MinInt32(maxX, f.CanvasWidth-1)
+	maxY = MinInt32(maxY, f.CanvasHeight-1)
+	if maxX < minX || maxY < minY {
+		// Nothing is left, so it's the same as deleting it
+		f.Selection = make(map[IntVec2]rl.Color)
+		f.SelectionPixels = make([]rl.Color, 0)
+		f.CommitSelection()
+		f.RedrawRenderLayer()
+		return
+	}
+
+	clipped := make(map[IntVec2]rl.Color)
+	pixels := make([]rl.Color, 0, (maxX-minX+1)*(maxY-minY+1))
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			loc := IntVec2{x, y}
+			color, ok := f.Selection[loc]
+			if ok {
+				clipped[loc] = color
+			}
+			pixels = append(pixels, color)
+		}
+	}
+	f.Selection = clipped
+	f.SelectionPixels = pixels
+	f.SelectionBounds = [4]int32{minX, minY, maxX, maxY}
+	f.OrigSelectionBounds = f.SelectionBounds
+}
+
+// ExpandCanvasToSelection grows the canvas so that none of the selection is
+// lost when it's committed, e.g. after pasting from a larger file
+func (f *File) ExpandCanvasToSelection() {
+	if !f.DoingSelection || len(f.Selection) == 0 {
+		return
+	}
+
+	minX, minY, maxX, maxY := f.selectionRect()
+	left := MaxInt32(0, -minX)
+	top := MaxInt32(0, -minY)
+	right := MaxInt32(0, maxX-(f.CanvasWidth-1))
+	bottom := MaxInt32(0, maxY-(f.CanvasHeight-1))
+	if left == 0 && top == 0 && right == 0 && bottom == 0 {
+		return
+	}
+
+	// One undo puts the canvas and the pixels back
+	f.BeginTransaction()
+	defer f.EndTransaction()
+
+	// Lifts the selection first so that undoing puts the pixels back where they
+	// were taken from
+	f.MoveSelection(0, 0)
+	if !f.ExpandCanvas(left, top, right, bottom) {
+		return
+	}
+
+	// The selection moves with the canvas content
+	moved := make(map[IntVec2]rl.Color, len(f.Selection))
+	for loc, color := range f.Selection {
+		moved[IntVec2{loc.X + left, loc.Y + top}] = color
+	}
+	f.Selection = moved
+	for i := range f.SelectionBounds {
+		if i%2 == 0 {
+			f.SelectionBounds[i] += left
+		} else {
+			f.SelectionBounds[i] += top
+		}
+	}
+	f.OrigSelectionBounds = f.SelectionBounds
+	f.RedrawRenderLayer()
+}
+
+// maxTransformSize is the biggest a selection can be scaled to, and how far
+// it can be moved, by TransformSelection
+const maxTransformSize = 4096
+
+// TransformSelection moves the selection so its top left is at x, y and
+// scales it to w by h with nearest neighbour, like dragging the selector's
+// handles but exact
+func (f *File) TransformSelection(x, y, w, h int32) {
+	if !f.DoingSelection || w <= 0 || h <= 0 || w > maxTransformSize || h > maxTransformSize {
+		return
+	}
+	if f.WarnHistoryCap(int(w) * int(h)) {
+		return
+	}
+
+	minX, minY, maxX, maxY := f.selectionRect()
+	// Lifts the selection so it can be moved around and undone
+	f.MoveSelection(x-minX, y-minY)
+	oldW, oldH := maxX-minX+1, maxY-minY+1
+	if w == oldW && h == oldH {
+		return
+	}
+
+	scaled := make(map[IntVec2]rl.Color, w*h)
+	pixels := make([]rl.Color, 0, w*h)
+	for dy := int32(0); dy < h; dy++ {
+		for dx := int32(0); dx < w; dx++ {
+			color, ok := f.Selection[IntVec2{x + dx*oldW/w, y + dy*oldH/h}]
+			if ok {
+				scaled[IntVec2{x + dx, y + dy}] = color
+			}
+			pixels = append(pixels, color)
+		}
+	}
+	f.Selection = scaled
+	f.SelectionPixels = pixels
+	f.SelectionBounds = [4]int32{x, y, x + w - 1, y + h - 1}
+	f.OrigSelectionBounds = f.SelectionBounds
+	f.RedrawRenderLayer()
+}
+
+// PasteFromFile decodes the image at path and pastes it as a floating
+// selection on the current layer
+func (f *File) PasteFromFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("Can't paste \"%s\": %v", path, err)
+	}
+	f.PasteImage(img)
+	return nil
+}
+
+// PasteImage pastes img as a floating selection in the middle of the canvas,
+// or at the top left if it's bigger than the canvas
+func (f *File) PasteImage(img image.Image) {
+	b := img.Bounds()
+	w, h := int32(b.Dx()), int32(b.Dy())
+	if w == 0 || h == 0 || f.WarnHistoryCap(int(w*h)) {
+		return
+	}
+	if f.DoingSelection {
+		f.CommitSelection()
+	}
+	ox := MaxInt32(0, (f.CanvasWidth-w)/2)
+	oy := MaxInt32(0, (f.CanvasHeight-h)/2)
+
+	f.Selection = make(map[IntVec2]rl.Color, w*h)
+	f.SelectionPixels = make([]rl.Color, 0, w*h)
+	for y := int32(0); y < h; y++ {
+		for x := int32(0); x < w; x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+int(x), b.Min.Y+int(y))).(color.NRGBA)
+			rc := rl.NewColor(c.R, c.G, c.B, c.A)
+			f.Selection[IntVec2{ox + x, oy + y}] = rc
+			f.SelectionPixels = append(f.SelectionPixels, rc)
+		}
+	}
+	f.SelectionBounds = [4]int32{ox, oy, ox + w - 1, oy + h - 1}
+	f.OrigSelectionBounds = f.SelectionBounds
+
+	// Same as Paste from here
+	f.SelectionMoving = false
+	f.IsSelectionPasted = true
+	f.DoingSelection = true
+	f.MoveSelection(0, 0)
+
+	if interactable, ok := toolSelector.GetInteractable(); ok {
+		interactable.OnMouseUp(toolSelector, rl.MouseRightButton)
+	}
+
+	f.RedrawRenderLayer()
+}
+
+// DrawSelectionOutOfBounds marks the selected pixels which are outside of the
+// canvas, since they can't be seen on the canvas and would be lost
+func DrawSelectionOutOfBounds(camera rl.Camera2D) {
+	if !CurrentFile.DoingSelection {
+		return
+	}
+	outside := CurrentFile.SelectionOutOfBounds()
+	if len(outside) == 0 {
+		return
+	}
+
+	ps := PixelScreenSize(camera)
+	for loc, color := range outside {
+		pos := PixelToScreen(loc.X, loc.Y, camera)
+		rect := rl.NewRectangle(pos.X, pos.Y, ps.X, ps.Y)
+		rl.DrawRectangleRec(rect, color)
+		rl.DrawRectangleRec(rect, rl.Fade(rl.Red, 0.4))
+	}
+
+	minX, minY, _, _ := CurrentFile.selectionRect()
+	pos := PixelToScreen(minX, minY, camera)
+	label := Tf("%d pixels outside of the canvas", len(outside))
+	rl.DrawTextEx(Font, label, rl.NewVector2(pos.X+1, pos.Y-UIFontSize*2+1), UIFontSize, 1, rl.Black)
+	rl.DrawTextEx(Font, label, rl.NewVector2(pos.X, pos.Y-UIFontSize*2), UIFontSize, 1, rl.Red)
+}
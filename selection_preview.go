@@ -0,0 +1,117 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// SelectionPreview is a flip or rotation of the selection which is shown as a
+// ghost until it's accepted or canceled. Nothing is written to the layer or
+// the history until it's accepted
+type SelectionPreview struct {
+	Selection map[IntVec2]rl.Color
+	Bounds    [4]int32
+}
+
+// previewSelection transforms the selection, or the preview if there's
+// already one, into a new preview. move returns where loc goes given the
+// normalized bounds and bounds returns the new bounds
+func (f *File) previewSelection(move func(loc IntVec2, minX, minY, maxX, maxY int32) IntVec2, bounds func(minX, minY, maxX, maxY int32) [4]int32) {
+	selection, b := f.Selection, f.SelectionBounds
+	if f.SelectionPreview != nil {
+		selection, b = f.SelectionPreview.Selection, f.SelectionPreview.Bounds
+	}
+	minX, minY := MinInt32(b[0], b[2]), MinInt32(b[1], b[3])
+	maxX, maxY := MaxInt32(b[0], b[2]), MaxInt32(b[1], b[3])
+
+	moved := make(map[IntVec2]rl.Color, len(selection))
+	for loc, color := range selection {
+		moved[move(loc, minX, minY, maxX, maxY)] = color
+	}
+	f.SelectionPreview = &SelectionPreview{
+		Selection: moved,
+		Bounds:    bounds(minX, minY, maxX, maxY),
+	}
+	f.RedrawRenderLayer()
+}
+
+// sameBounds keeps the bounds of a flipped selection
+func sameBounds(minX, minY, maxX, maxY int32) [4]int32 {
+	return [4]int32{minX, minY, maxX, maxY}
+}
+
+// turnedBounds swaps the width and height of a rotated selection, its top
+// left stays where it is
+func turnedBounds(minX, minY, maxX, maxY int32) [4]int32 {
+	return [4]int32{minX, minY, minX + maxY - minY, minY + maxX - minX}
+}
+
+// PreviewFlipSelection previews flipping the selection
+func (f *File) PreviewFlipSelection(horizontal bool) {
+	f.previewSelection(func(loc IntVec2, minX, minY, maxX, maxY int32) IntVec2 {
+		if horizontal {
+			return IntVec2{minX + maxX - loc.X, loc.Y}
+		}
+		return IntVec2{loc.X, minY + maxY - loc.Y}
+	}, sameBounds)
+}
+
+// PreviewRotateSelection previews rotating the selection by 90 degrees
+func (f *File) PreviewRotateSelection(clockwise bool) {
+	f.previewSelection(func(loc IntVec2, minX, minY, maxX, maxY int32) IntVec2 {
+		if clockwise {
+			return IntVec2{minX + maxY - loc.Y, minY + loc.X - minX}
+		}
+		return IntVec2{minX + loc.Y - minY, minY + maxX - loc.X}
+	}, turnedBounds)
+}
+
+// RotateClockwise previews rotating the selection clockwise
+func (f *File) RotateClockwise() {
+	if f.DoingSelection {
+		f.PreviewRotateSelection(true)
+	}
+}
+
+// RotateCounterClockwise previews rotating the selection counter-clockwise
+func (f *File) RotateCounterClockwise() {
+	if f.DoingSelection {
+		f.PreviewRotateSelection(false)
+	}
+}
+
+// AcceptSelectionPreview makes the preview the selection. The selection is
+// lifted from the layer first so that it's in the history
+func (f *File) AcceptSelectionPreview() {
+	preview := f.SelectionPreview
+	if preview == nil {
+		return
+	}
+	f.SelectionPreview = nil
+
+	if !f.SelectionMoving {
+		f.MoveSelection(0, 0)
+	}
+	f.Selection = preview.Selection
+	f.SelectionBounds = preview.Bounds
+	f.OrigSelectionBounds = preview.Bounds
+
+	minX, minY, maxX, maxY := f.selectionRect()
+	f.SelectionPixels = make([]rl.Color, 0, (maxX-minX+1)*(maxY-minY+1))
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			f.SelectionPixels = append(f.SelectionPixels, f.Selection[IntVec2{x, y}])
+		}
+	}
+
+	f.GetCurrentLayer().Redraw()
+	f.RedrawRenderLayer()
+}
+
+// CancelSelectionPreview drops the preview, the selection is unchanged
+func (f *File) CancelSelectionPreview() {
+	if f.SelectionPreview == nil {
+		return
+	}
+	f.SelectionPreview = nil
+	f.RedrawRenderLayer()
+}
@@ -12,8 +12,105 @@ import (
 
 // SettingsData is the settings object which is read from settings.json
 type SettingsData struct {
-	KeymapData  KeymapData  `binding:"required"`
-	PaletteData PaletteData `binding:"required"`
+	KeymapData    KeymapData  `binding:"required"`
+	PaletteData   PaletteData `binding:"required"`
+	ExportPresets []ExportPreset
+	Window        *WindowSettings `json:",omitempty"`
+	// TourSeen is set once the tour has been finished or skipped
+	TourSeen bool
+	// Locale is the code of the UI language, e.g. "de"
+	Locale string
+	// Sounds plays a sound when saving or exporting finishes or fails
+	Sounds bool
+	// TimelapseInterval is how many actions there are between timelapse frames
+	TimelapseInterval int32
+	// LinearBlending mixes semi-transparent colors in linear light instead of
+	// sRGB when drawing, merging, compositing and exporting
+	LinearBlending bool
+	// DragDelay is how many milliseconds a button has to be held before it's
+	// dragged and DoubleClickInterval is the most milliseconds between the
+	// clicks of a double click. 0 uses the defaults
+	DragDelay           int32 `json:",omitempty"`
+	DoubleClickInterval int32 `json:",omitempty"`
+	// HistoryMaxActions is how many actions each file can undo and
+	// HistoryMaxMemory is roughly how many megabytes its history can use.
+	// 0 uses the default actions and doesn't cap the memory
+	HistoryMaxActions int32 `json:",omitempty"`
+	HistoryMaxMemory  int32 `json:",omitempty"`
+	// Backups is how many copies of the previous save are kept when saving
+	// over a file. They're kept next to the file, or in BackupDir if it's set.
+	// A relative BackupDir is relative to the file's directory
+	Backups   int32  `json:",omitempty"`
+	BackupDir string `json:",omitempty"`
+	// ExportHiddenLayers includes hidden layers in exports. Layers which
+	// aren't exported are always left out
+	ExportHiddenLayers bool `json:",omitempty"`
+	// LockToPalette snaps every drawn color to the nearest color of the
+	// file's palette, so that colors off the palette can't be drawn
+	LockToPalette bool `json:",omitempty"`
+	// AutoscrollSpeed is how many screen pixels a second the canvas pans when
+	// a drag reaches the window's edge. 0 uses the default and -1 turns it off
+	AutoscrollSpeed int32 `json:",omitempty"`
+	// SwapMouseButtons makes the right mouse button the primary one, for
+	// left-handed use. It uses the left tool and color
+	SwapMouseButtons bool `json:",omitempty"`
+	// NoMouseHistoryButtons stops the back and forward mouse buttons from
+	// undoing and redoing
+	NoMouseHistoryButtons bool `json:",omitempty"`
+}
+
+// WindowSettings stores the window geometry so that it can be restored on
+// launch. The size and position are from before the window was maximized
+type WindowSettings struct {
+	X, Y          int32
+	Width, Height int32
+	Maximized     bool
+}
+
+// ExportPreset describes how a file is exported by a batch export
+// Destination is a path pattern, {name} is replaced with the file name without
+// the extension, {scale} with the scale and {dir} with the file's directory.
+// Relative destinations are relative to the file's directory
+// Format is "png" or "spritesheet". Spritesheets have every tile as a frame
+// and a json file with the frames and animations next to the image
+type ExportPreset struct {
+	Name        string
+	Scale       int32
+	Format      string
+	Destination string
+
+	// Padding is added around every frame and Spacing between frames
+	Padding int32 `json:",omitempty"`
+	Spacing int32 `json:",omitempty"`
+	// Trim removes the transparent borders of frames, the json has the
+	// offsets
+	Trim bool `json:",omitempty"`
+	// PowerOfTwo rounds the sheet's width and height up to a power of two
+	PowerOfTwo bool `json:",omitempty"`
+	// Columns is the number of frames in each row, 0 keeps the tile layout
+	Columns int32 `json:",omitempty"`
+	// Pack is "grid" (the default) to place the frames in Columns columns or
+	// "bins" to pack them as tightly as possible
+	Pack string `json:",omitempty"`
+	// AnimationFrames exports only the frames of the animations, in the order
+	// of the animations, instead of every tile
+	AnimationFrames bool `json:",omitempty"`
+	// Matte is a hex color which transparent pixels are blended onto, so
+	// that the export is opaque. Empty keeps the alpha
+	Matte string `json:",omitempty"`
+	// Premultiplied multiplies the colors by their alpha for pipelines which
+	// don't handle straight alpha. It does nothing with a Matte
+	Premultiplied bool `json:",omitempty"`
+	// Engine also writes import data for a game engine next to the
+	// spritesheet, "godot" writes a SpriteFrames .tres and "unity" writes the
+	// sprite slicing as .unity.json
+	Engine string `json:",omitempty"`
+	// Layers are the names of the layers which are exported. Empty exports
+	// the layers which have export on
+	Layers []string `json:",omitempty"`
+	// LayerIDs are the IDs of the layers which are exported, they're used
+	// instead of Layers by the profiles linked to a file
+	LayerIDs []int32 `json:",omitempty"`
 }
 
 // KeymapData stores the action name as the key and a 2d slice of the keys
@@ -26,6 +123,24 @@ type Keymap struct {
 	Data KeymapData
 }
 
+// keysEqual returns true if a and b are the same bindings in the same order
+func keysEqual(a, b [][]Key) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // NewKeymap returns a new Keymap
 // It also sorts the keys to avoid conflicts between bindings as ctrl+z will
 // fire before ctrl+shift+z if it is called first. Longer similar bindings will
@@ -73,6 +188,8 @@ type Palette struct {
 	data []rl.Color
 	// Hex which is converted to rl.Color on read, overwrites everything in data
 	Strings []string
+	// The file the palette is saved in, nil if it's saved in the settings
+	file *File
 }
 
 var (
@@ -82,11 +199,20 @@ var (
 	defaultKeymap = KeymapData{
 		// Handled by tools
 		"drawLine": {{rl.KeyLeftShift}, {rl.KeyRightShift}},
+		"skew":     {{rl.KeyLeftShift}, {rl.KeyRightShift}},
+
+		// Held while left dragging to pan, handled by the file system
+		"pan": {{rl.KeySpace}},
 
 		// Handled by system controls
-		"toggleGrid": {{rl.KeyG}},
-		"showDebug":  {{rl.KeyD}},
-		"resize":     {{rl.KeyLeftControl, rl.KeyR}},
+		"toggleGrid":        {{rl.KeyG}},
+		"toggleCoordinates": {{rl.KeyI}},
+		"cycleViewFilter":   {{rl.KeyK}},
+		"toggleSeamCheck":   {{rl.KeyO}},
+		"precisionMode":     {{rl.KeyP}},
+		"showDebug":         {{rl.KeyD}},
+		"help":              {{rl.KeyF1}, {rl.KeyLeftShift, rl.KeySlash}, {rl.KeyRightShift, rl.KeySlash}},
+		"resize":            {{rl.KeyLeftControl, rl.KeyR}},
 
 		"pixelBrush": {{rl.KeyB}},
 		"eraser":     {{rl.KeyE}},
@@ -94,11 +220,26 @@ var (
 		"picker":     {{rl.KeyM}},
 		"selector":   {{rl.KeyS}},
 
+		"scatterBrush": {{rl.KeyA}},
+		"curve":        {{rl.KeyU}},
+		"warp":         {{rl.KeyW}},
+		"nineSlice":    {{rl.KeyJ}},
+
 		"flipHorizontal": {{rl.KeyZ}},
 		"flipVertical":   {{rl.KeyV}},
 
-		"paletteNext":     {{rl.KeyRightBracket}},
-		"palettePrevious": {{rl.KeyLeftBracket}},
+		"rotateClockwise":        {{rl.KeyR}},
+		"rotateCounterClockwise": {{rl.KeyLeftShift, rl.KeyR}},
+
+		"paletteNext":     {{rl.KeyLeftShift, rl.KeyRightBracket}},
+		"palettePrevious": {{rl.KeyLeftShift, rl.KeyLeftBracket}},
+
+		"frameNext":      {{rl.KeyPeriod}},
+		"framePrevious":  {{rl.KeyComma}},
+		"animationStart": {{rl.KeyLeftBracket}},
+		"animationEnd":   {{rl.KeyRightBracket}},
+		"duplicateFrame": {{rl.KeyLeftControl, rl.KeyD}},
+		"clearFrame":     {{rl.KeyLeftControl, rl.KeyBackspace}},
 
 		"layerUp":   {{rl.KeyLeftShift, rl.KeyUp}},
 		"layerDown": {{rl.KeyLeftShift, rl.KeyDown}},
@@ -109,11 +250,14 @@ var (
 		"toolDown":  {{rl.KeyT}, {rl.KeyDown}},
 
 		"cancel":    {{rl.KeyEscape}},
+		"confirm":   {{rl.KeyEnter}},
 		"copy":      {{rl.KeyLeftControl, rl.KeyC}},
 		"paste":     {{rl.KeyLeftControl, rl.KeyV}},
 		"delete":    {{rl.KeyDelete}},
 		"selectAll": {{rl.KeyLeftControl, rl.KeyA}},
 
+		"selectOpaque": {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyA}},
+
 		"new":    {{rl.KeyLeftControl, rl.KeyN}},
 		"open":   {{rl.KeyLeftControl, rl.KeyO}},
 		"close":  {{rl.KeyLeftControl, rl.KeyW}},
@@ -122,6 +266,33 @@ var (
 		"export": {{rl.KeyLeftControl, rl.KeyE}},
 		"undo":   {{rl.KeyLeftControl, rl.KeyZ}},
 		"redo":   {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyZ}, {rl.KeyLeftControl, rl.KeyY}},
+
+		"repeatLast": {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyR}},
+
+		"batchExport": {{rl.KeyLeftControl, rl.KeyLeftAlt, rl.KeyE}},
+
+		"stampVisible": {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyLeftAlt, rl.KeyE}},
+		"reExport":     {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyE}},
+	}
+
+	// movedBindings are bindings whose old default keys were given to the
+	// binding By
+	movedBindings = []struct {
+		Name, By string
+		Old      [][]Key
+	}{
+		{"batchExport", "reExport", [][]Key{{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyE}}},
+		{"paletteNext", "animationEnd", [][]Key{{rl.KeyRightBracket}}},
+		{"palettePrevious", "animationStart", [][]Key{{rl.KeyLeftBracket}}},
+	}
+
+	defaultExportPresets = []ExportPreset{
+		{
+			Name:        "Default",
+			Scale:       1,
+			Format:      "png",
+			Destination: "{dir}/{name}@{scale}x.png",
+		},
 	}
 
 	// Using the Lospec500 palette as default
@@ -177,6 +348,24 @@ var (
 	}
 )
 
+// TrackWindowGeometry records the window geometry into the settings. The size
+// and position are only recorded while the window isn't maximized so that the
+// window can be restored to them
+func TrackWindowGeometry() {
+	if Settings.Window == nil {
+		Settings.Window = &WindowSettings{}
+	}
+
+	Settings.Window.Maximized = rl.IsWindowMaximized()
+	if !Settings.Window.Maximized {
+		pos := rl.GetWindowPosition()
+		Settings.Window.X = int32(pos.X)
+		Settings.Window.Y = int32(pos.Y)
+		Settings.Window.Width = int32(rl.GetScreenWidth())
+		Settings.Window.Height = int32(rl.GetScreenHeight())
+	}
+}
+
 // SaveSettings writes the settings object into settings.json
 func SaveSettings() error {
 	// Save each color as a hex
@@ -188,7 +377,11 @@ func SaveSettings() error {
 		Settings.PaletteData[pi] = palette
 	}
 
+	// File palettes are saved with their file
+	palettes := Settings.PaletteData
+	Settings.PaletteData = palettes.globalPalettes()
 	j, err := json.MarshalIndent(Settings, "", "  ")
+	Settings.PaletteData = palettes
 	if err != nil {
 		log.Fatal(nil)
 		return err
@@ -225,6 +418,7 @@ func LoadSettings() error {
 		// Make a default settings file using the default data
 		Settings.KeymapData = defaultKeymap
 		Settings.PaletteData = defaultPalettes
+		Settings.ExportPresets = defaultExportPresets
 		for _, color := range Settings.PaletteData[0].Strings {
 			parsedColor, err := HexToColor(color)
 			if err != nil {
@@ -256,10 +450,30 @@ func LoadSettings() error {
 			Settings.KeymapData = defaultKeymap
 			log.Println("⌨️ Keymap was missing from settings, default added")
 		}
+		// Bindings which still have keys a newer binding took get their new
+		// default keys
+		for _, moved := range movedBindings {
+			if _, ok := Settings.KeymapData[moved.By]; ok {
+				continue
+			}
+			if keysEqual(Settings.KeymapData[moved.Name], moved.Old) {
+				Settings.KeymapData[moved.Name] = defaultKeymap[moved.Name]
+			}
+		}
+		// Bindings added since the settings file was written
+		for name, keys := range defaultKeymap {
+			if _, ok := Settings.KeymapData[name]; !ok {
+				Settings.KeymapData[name] = keys
+			}
+		}
 		if palettes := Settings.PaletteData; palettes == nil {
 			Settings.PaletteData = defaultPalettes
 			log.Println("🎨 Palettes were missing from settings, default added")
 		}
+		if Settings.ExportPresets == nil {
+			Settings.ExportPresets = defaultExportPresets
+			log.Println("📦 Export presets were missing from settings, default added")
+		}
 		// Convert hex to rl.Color
 		for pi, palette := range Settings.PaletteData {
 			palette.data = make([]rl.Color, 0)
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// SoundCue is an action which can have a sound played when it happens
+type SoundCue int
+
+// SoundCues
+const (
+	SoundSaved SoundCue = iota
+	SoundExported
+	SoundError
+)
+
+const soundSampleRate = 44100
+
+var (
+	// sounds are generated when the audio device is initialized so that no
+	// sound files have to be shipped
+	sounds = make(map[SoundCue]rl.Sound)
+	// soundNotes are the frequencies of the notes in each cue, each note is
+	// played for soundNoteLength seconds
+	soundNotes = map[SoundCue][]float64{
+		SoundSaved:    {659.25, 987.77},
+		SoundExported: {523.25, 659.25, 783.99},
+		SoundError:    {196.00, 146.83},
+	}
+	soundNoteLength = 0.08
+	soundVolume     = float32(0.25)
+)
+
+// InitSounds opens the audio device and generates the cues
+func InitSounds() {
+	rl.InitAudioDevice()
+	if !rl.IsAudioDeviceReady() {
+		return
+	}
+
+	for cue, notes := range soundNotes {
+		sound := rl.LoadSoundFromWave(generateWave(notes))
+		rl.SetSoundVolume(sound, soundVolume)
+		sounds[cue] = sound
+	}
+}
+
+// CloseSounds unloads the cues and closes the audio device
+func CloseSounds() {
+	for cue, sound := range sounds {
+		rl.UnloadSound(sound)
+		delete(sounds, cue)
+	}
+	rl.CloseAudioDevice()
+}
+
+// PlaySoundCue plays the cue if sounds are enabled in the settings
+func PlaySoundCue(cue SoundCue) {
+	if !Settings.Sounds {
+		return
+	}
+	if sound, ok := sounds[cue]; ok {
+		rl.PlaySound(sound)
+	}
+}
+
+// generateWave returns a mono 16 bit wave of the notes played one after the
+// other. Each note fades out so that it doesn't click
+func generateWave(notes []float64) rl.Wave {
+	perNote := int(soundSampleRate * soundNoteLength)
+	data := make([]byte, 0, perNote*len(notes)*2)
+	for _, freq := range notes {
+		for i := 0; i < perNote; i++ {
+			t := float64(i) / soundSampleRate
+			fade := 1 - float64(i)/float64(perNote)
+			sample := int16(math.Sin(2*math.Pi*freq*t) * fade * math.MaxInt16)
+			data = binary.LittleEndian.AppendUint16(data, uint16(sample))
+		}
+	}
+	return rl.NewWave(uint32(perNote*len(notes)), soundSampleRate, 16, 1, data)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SheetRect is a rectangle in the spritesheet json
+type SheetRect struct {
+	X int32 `json:"x"`
+	Y int32 `json:"y"`
+	W int32 `json:"w"`
+	H int32 `json:"h"`
+}
+
+// SheetSize is a size in the spritesheet json
+type SheetSize struct {
+	W int32 `json:"w"`
+	H int32 `json:"h"`
+}
+
+// SheetFrame is where a tile was placed in the spritesheet. Frame is the area
+// in the sheet, SpriteSourceSize is where the trimmed frame is in the
+// untrimmed tile and SourceSize is the size of the tile
+type SheetFrame struct {
+	Filename         string    `json:"filename"`
+	Frame            SheetRect `json:"frame"`
+	Trimmed          bool      `json:"trimmed"`
+	SpriteSourceSize SheetRect `json:"spriteSourceSize"`
+	SourceSize       SheetSize `json:"sourceSize"`
+}
+
+// SheetAnimation is an animation in the spritesheet json, From and To are
+// indices of Frames and Timing is the frames per second
+type SheetAnimation struct {
+	Name   string  `json:"name"`
+	From   int32   `json:"from"`
+	To     int32   `json:"to"`
+	Timing float32 `json:"timing"`
+}
+
+// SheetMeta describes the spritesheet image
+type SheetMeta struct {
+	Image      string           `json:"image"`
+	Size       SheetSize        `json:"size"`
+	Scale      int32            `json:"scale"`
+	Animations []SheetAnimation `json:"animations"`
+	// NineSlice is only written if the margins have been set
+	NineSlice *NineSlice `json:"nineSlice,omitempty"`
+}
+
+// Sheet is written next to the spritesheet image
+type Sheet struct {
+	Frames []SheetFrame `json:"frames"`
+	Meta   SheetMeta    `json:"meta"`
+}
+
+// sheetFrame is a tile which is being placed in the sheet
+type sheetFrame struct {
+	// bounds of the tile on the canvas
+	tile image.Rectangle
+	// trimmed is the part of tile which is kept, relative to tile
+	trimmed image.Rectangle
+	// pos is the top left of the trimmed frame in the sheet
+	pos image.Point
+}
+
+// ExportSpritesheet exports the tiles, or only the animation frames, as frames
+// of a spritesheet with a json file describing where each frame is
+func (f *File) ExportSpritesheet(dest string, preset ExportPreset) error {
+	if preset.Scale < 1 {
+		return fmt.Errorf("Scale must be at least 1, got %d", preset.Scale)
+	}
+	if preset.Padding < 0 || preset.Spacing < 0 {
+		return fmt.Errorf("Padding and spacing can't be negative")
+	}
+
+	finish, err := preset.finishColor()
+	if err != nil {
+		return err
+	}
+
+	tilesX := (f.CanvasWidth + f.TileWidth - 1) / f.TileWidth
+	tilesY := (f.CanvasHeight + f.TileHeight - 1) / f.TileHeight
+
+	include := preset.Include()
+	composited := make(map[IntVec2]color.NRGBA)
+	for x := int32(0); x < f.CanvasWidth; x++ {
+		for y := int32(0); y < f.CanvasHeight; y++ {
+			c := f.compositePixel(IntVec2{x, y}, include)
+			composited[IntVec2{x, y}] = color.NRGBA{c.R, c.G, c.B, c.A}
+		}
+	}
+
+	// The tiles which are exported, in the order they're in the json
+	tiles := make([]int32, 0, tilesX*tilesY)
+	animations := make([]SheetAnimation, 0, len(f.Animations))
+	if preset.AnimationFrames {
+		// Each animation gets its own copy of its frames so that From and To
+		// can be used even if animations share tiles
+		for _, animation := range f.Animations {
+			from := int32(len(tiles))
+			for i := animation.FrameStart; i <= animation.FrameEnd; i++ {
+				if i >= 0 && i < tilesX*tilesY {
+					tiles = append(tiles, i)
+				}
+			}
+			animations = append(animations, SheetAnimation{
+				Name:   animation.Name,
+				From:   from,
+				To:     int32(len(tiles)) - 1,
+				Timing: animation.Timing,
+			})
+		}
+		if len(tiles) == 0 {
+			return fmt.Errorf("Can't export spritesheet: there aren't any animation frames")
+		}
+	} else {
+		for i := int32(0); i < tilesX*tilesY; i++ {
+			tiles = append(tiles, i)
+		}
+		for _, animation := range f.Animations {
+			animations = append(animations, SheetAnimation{
+				Name:   animation.Name,
+				From:   animation.FrameStart,
+				To:     animation.FrameEnd,
+				Timing: animation.Timing,
+			})
+		}
+	}
+
+	frames := make([]*sheetFrame, len(tiles))
+	for i, t := range tiles {
+		tx, ty := t%tilesX, t/tilesX
+		tile := image.Rect(
+			int(tx*f.TileWidth), int(ty*f.TileHeight),
+			int(MinInt32((tx+1)*f.TileWidth, f.CanvasWidth)), int(MinInt32((ty+1)*f.TileHeight, f.CanvasHeight)))
+		frames[i] = &sheetFrame{tile: tile, trimmed: image.Rect(0, 0, tile.Dx(), tile.Dy())}
+		if preset.Trim {
+			frames[i].trimmed = trimTransparent(composited, tile)
+		}
+	}
+
+	var sheetWidth, sheetHeight int32
+	switch preset.Pack {
+	case "", "grid":
+		columns := preset.Columns
+		if columns <= 0 {
+			columns = tilesX
+		}
+		sheetWidth, sheetHeight = layoutRows(frames, columns, 0, preset)
+	case "bins":
+		sheetWidth, sheetHeight = layoutShelves(frames, preset)
+	default:
+		return fmt.Errorf("Can't export spritesheet: packing \"%s\" not supported", preset.Pack)
+	}
+	if preset.PowerOfTwo {
+		sheetWidth = nextPowerOfTwo(sheetWidth)
+		sheetHeight = nextPowerOfTwo(sheetHeight)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(dest), filepath.Ext(dest))
+	img := image.NewNRGBA(image.Rect(0, 0, int(sheetWidth), int(sheetHeight)))
+	sheet := Sheet{
+		Frames: make([]SheetFrame, len(frames)),
+		Meta: SheetMeta{
+			Image:      filepath.Base(dest),
+			Size:       SheetSize{sheetWidth, sheetHeight},
+			Scale:      preset.Scale,
+			Animations: animations,
+		},
+	}
+	if f.NineSlice.IsSet() {
+		nineSlice := f.NineSlice.Scaled(preset.Scale)
+		sheet.Meta.NineSlice = &nineSlice
+	}
+	for i, frame := range frames {
+		for py := frame.trimmed.Min.Y; py < frame.trimmed.Max.Y; py++ {
+			for px := frame.trimmed.Min.X; px < frame.trimmed.Max.X; px++ {
+				c := composited[IntVec2{int32(frame.tile.Min.X + px), int32(frame.tile.Min.Y + py)}]
+				for sy := 0; sy < int(preset.Scale); sy++ {
+					for sx := 0; sx < int(preset.Scale); sx++ {
+						img.SetNRGBA(
+							frame.pos.X+(px-frame.trimmed.Min.X)*int(preset.Scale)+sx,
+							frame.pos.Y+(py-frame.trimmed.Min.Y)*int(preset.Scale)+sy,
+							c)
+					}
+				}
+			}
+		}
+
+		scale := int(preset.Scale)
+		sheet.Frames[i] = SheetFrame{
+			Filename: fmt.Sprintf("%s_%d", name, i),
+			Frame: SheetRect{
+				int32(frame.pos.X), int32(frame.pos.Y),
+				int32(frame.trimmed.Dx() * scale), int32(frame.trimmed.Dy() * scale)},
+			Trimmed: frame.trimmed != image.Rect(0, 0, frame.tile.Dx(), frame.tile.Dy()),
+			SpriteSourceSize: SheetRect{
+				int32(frame.trimmed.Min.X * scale), int32(frame.trimmed.Min.Y * scale),
+				int32(frame.trimmed.Dx() * scale), int32(frame.trimmed.Dy() * scale)},
+			SourceSize: SheetSize{int32(frame.tile.Dx() * scale), int32(frame.tile.Dy() * scale)},
+		}
+	}
+
+	// The matte is applied last so that frames are trimmed by their own
+	// alpha and the padding gets the matte too
+	finishImage(img, finish)
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := encodePNGWithText(file, img, f.Metadata.pngText()); err != nil {
+		return err
+	}
+
+	j, err := json.MarshalIndent(sheet, "", "  ")
+	if err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(dest, filepath.Ext(dest))
+	if err := ioutil.WriteFile(base+".json", j, 0644); err != nil {
+		return err
+	}
+	return writeEngineFiles(base, sheet, preset.Engine)
+}
+
+// layoutRows places the frames in rows of columns frames, in order. Each row is
+// as tall as its tallest frame. If maxWidth is above 0 then rows also wrap
+// before they're wider than it. Returns the size of the sheet
+func layoutRows(frames []*sheetFrame, columns, maxWidth int32, preset ExportPreset) (int32, int32) {
+	var x, y, rowHeight, rowCount, sheetWidth, sheetHeight int32
+	for _, frame := range frames {
+		w := int32(frame.trimmed.Dx())*preset.Scale + preset.Padding*2
+		h := int32(frame.trimmed.Dy())*preset.Scale + preset.Padding*2
+		if rowCount > 0 && ((columns > 0 && rowCount >= columns) || (maxWidth > 0 && x+w > maxWidth)) {
+			x = 0
+			y += rowHeight + preset.Spacing
+			rowHeight = 0
+			rowCount = 0
+		}
+
+		frame.pos = image.Pt(int(x+preset.Padding), int(y+preset.Padding))
+		x += w + preset.Spacing
+		rowHeight = MaxInt32(rowHeight, h)
+		rowCount++
+		sheetWidth = MaxInt32(sheetWidth, x-preset.Spacing)
+		sheetHeight = MaxInt32(sheetHeight, y+rowHeight)
+	}
+	return sheetWidth, sheetHeight
+}
+
+// layoutShelves packs the frames tallest first into rows about as wide as the
+// sheet is tall, which wastes less space than a grid when frames are trimmed.
+// The frames keep their order in the json. Returns the size of the sheet
+func layoutShelves(frames []*sheetFrame, preset ExportPreset) (int32, int32) {
+	var area, widest int32
+	for _, frame := range frames {
+		w := int32(frame.trimmed.Dx())*preset.Scale + preset.Padding*2 + preset.Spacing
+		h := int32(frame.trimmed.Dy())*preset.Scale + preset.Padding*2 + preset.Spacing
+		area += w * h
+		widest = MaxInt32(widest, w)
+	}
+	maxWidth := MaxInt32(widest, int32(math.Ceil(math.Sqrt(float64(area)))))
+	if preset.PowerOfTwo {
+		maxWidth = nextPowerOfTwo(maxWidth)
+	}
+
+	sorted := append([]*sheetFrame{}, frames...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].trimmed.Dy() > sorted[j].trimmed.Dy()
+	})
+	return layoutRows(sorted, 0, maxWidth, preset)
+}
+
+// trimTransparent returns the smallest rectangle, relative to tile, which
+// holds every visible pixel of tile. Empty tiles are trimmed to a single pixel
+// so that they still have a frame
+func trimTransparent(pixels map[IntVec2]color.NRGBA, tile image.Rectangle) image.Rectangle {
+	trimmed := image.Rectangle{}
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		for x := tile.Min.X; x < tile.Max.X; x++ {
+			if pixels[IntVec2{int32(x), int32(y)}].A == 0 {
+				continue
+			}
+			trimmed = trimmed.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	if trimmed.Empty() {
+		return image.Rect(0, 0, 1, 1)
+	}
+	return trimmed.Sub(tile.Min)
+}
+
+// nextPowerOfTwo returns the smallest power of two which is at least n
+func nextPowerOfTwo(n int32) int32 {
+	p := int32(1)
+	for p < n {
+		p *= 2
+	}
+	return p
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// Stabilizer smooths freehand strokes by pulling the brush behind the cursor
+// on a string. The brush only moves once the string is taut, so jitter
+// shorter than the string is ignored
+type Stabilizer struct {
+	Enabled bool
+	// Length of the string in pixels
+	Length int32
+}
+
+// maxStabilizerLength is the longest string, longer ones lag too far behind
+const maxStabilizerLength = 32
+
+// Pull moves pos towards the cursor at x, y until it's no further than the
+// string length away and returns the pixel it's on
+func (s Stabilizer) Pull(pos *rl.Vector2, x, y int32) (int32, int32) {
+	// The center of the pixel, so that it's symmetrical
+	target := rl.NewVector2(float32(x)+0.5, float32(y)+0.5)
+	dx, dy := target.X-pos.X, target.Y-pos.Y
+	dist := float32(math.Hypot(float64(dx), float64(dy)))
+	if s.Enabled && dist > float32(s.Length) {
+		pull := (dist - float32(s.Length)) / dist
+		pos.X += dx * pull
+		pos.Y += dy * pull
+	} else if !s.Enabled {
+		*pos = target
+	}
+	return int32(math.Floor(float64(pos.X))), int32(math.Floor(float64(pos.Y)))
+}
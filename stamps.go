@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Stamp is a small reusable image from StampsDir which can be pasted as a
+// floating selection
+type Stamp struct {
+	Name  string
+	Path  string
+	Image *image.NRGBA
+}
+
+// StampsDir returns the folder where stamps are kept, ~/pixelStamps
+func StampsDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, "pixelStamps"), nil
+}
+
+// LoadStamps loads every .png and .pix file in StampsDir, sorted by name.
+// Files which can't be loaded are skipped
+func LoadStamps() ([]*Stamp, error) {
+	dir, err := StampsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// No stamps have been saved yet
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	stamps := make([]*Stamp, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		p := filepath.Join(dir, entry.Name())
+		img, err := loadFlattenedImage(p)
+		if err != nil {
+			continue
+		}
+		stamps = append(stamps, &Stamp{
+			Name:  strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())),
+			Path:  p,
+			Image: img,
+		})
+	}
+	sort.Slice(stamps, func(i, j int) bool { return stamps[i].Name < stamps[j].Name })
+	return stamps, nil
+}
+
+// loadFlattenedImage decodes a png, or flattens the visible layers of a pix
+// file. It doesn't need a window, so it's also used by the thumbnailer
+func loadFlattenedImage(p string) (*image.NRGBA, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".png":
+		decoded, err := png.Decode(file)
+		if err != nil {
+			return nil, err
+		}
+		b := decoded.Bounds()
+		img := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				// Kept as straight alpha, like the pixel data
+				img.SetNRGBA(x, y, color.NRGBAModel.Convert(decoded.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA))
+			}
+		}
+		return img, nil
+	case ".pix":
+		fileSer := &FileSer{}
+		if err := gob.NewDecoder(file).Decode(&fileSer); err != nil {
+			return nil, err
+		}
+		return fileSer.compositeImage(nil, func(layer *Layer) bool {
+			return !layer.Hidden
+		}), nil
+	}
+	return nil, fmt.Errorf("Can't load \"%s\": extension not supported", p)
+}
+
+// SaveSelectionAsStamp saves the selection as a png in StampsDir and returns
+// the path it was saved to
+func (f *File) SaveSelectionAsStamp() (string, error) {
+	if !f.DoingSelection || len(f.Selection) == 0 {
+		return "", fmt.Errorf("Can't save stamp: nothing is selected")
+	}
+
+	dir, err := StampsDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	minX, minY, maxX, maxY := f.selectionRect()
+	img := image.NewNRGBA(image.Rect(0, 0, int(maxX-minX+1), int(maxY-minY+1)))
+	for loc, c := range f.Selection {
+		img.SetNRGBA(int(loc.X-minX), int(loc.Y-minY), color.NRGBA{c.R, c.G, c.B, c.A})
+	}
+
+	// Don't overwrite any of the existing stamps
+	var dest string
+	for i := 1; ; i++ {
+		dest = filepath.Join(dir, fmt.Sprintf("stamp_%03d.png", i))
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			break
+		}
+	}
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return dest, png.Encode(file, img)
+}
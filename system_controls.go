@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"math"
+	"strings"
 	"time"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
@@ -40,6 +41,25 @@ type UIControlSystem struct {
 	ScrollScalar int32
 }
 
+// readOnlyBlockedKeys are the bindings which would edit a read-only file
+var readOnlyBlockedKeys = map[string]bool{
+	"delete":                 true,
+	"confirm":                true,
+	"selectAll":              true,
+	"selectOpaque":           true,
+	"flipHorizontal":         true,
+	"flipVertical":           true,
+	"rotateClockwise":        true,
+	"rotateCounterClockwise": true,
+	"resize":                 true,
+	"undo":                   true,
+	"repeatLast":             true,
+	"stampVisible":           true,
+	"redo":                   true,
+	"duplicateFrame":         true,
+	"clearFrame":             true,
+}
+
 // CommandType specifies the type of command the file dialog has done
 type CommandType int
 
@@ -49,16 +69,36 @@ const (
 	CommandTypeSave
 	CommandTypeFail
 	CommandTypeQuit
+	CommandTypeExportTimelapse
+	CommandTypePasteFromFile
+	CommandTypeCollabJoin
+	CommandTypeLoadBrush
+	CommandTypeAnimationExport
 )
 
 // UIControlChanData send/return data from gtk
 type UIControlChanData struct {
 	CommandType CommandType
 	Name        string
+	// Index is the animation whose export is being set
+	Index int32
 }
 
 // NewUIControlSystem creates and returns a new NewUIControlSystem reference
 func NewUIControlSystem(keymap Keymap) *UIControlSystem {
+	s := &UIControlSystem{
+		KeyRepeat:           time.Second / 5,
+		Keymap:              keymap,
+		keysDown:            make(map[Key]bool),
+		keysAwaitingRelease: make(map[Key]bool),
+		ScrollScalar:        16, // TODO get from config
+	}
+
+	// The dialog goroutine outlives the system when the UI is rebuilt
+	if UIControlSystemCmds != nil {
+		return s
+	}
+
 	UIControlSystemCmds = make(chan UIControlChanData)
 	UIControlSystemReturns = make(chan UIControlChanData)
 	go func(cmds, returns chan UIControlChanData) {
@@ -70,12 +110,12 @@ func NewUIControlSystem(keymap Keymap) *UIControlSystem {
 				switch cmd.CommandType {
 				case CommandTypeOpen:
 					strings, err := zenity.SelectFileMultiple(
-						zenity.Title("Open File"),
+						zenity.Title(T("Open File")),
 						zenity.Filename(CurrentFile.PathDir),
 						zenity.FileFilters{
 							{
-								Name:     ".png, .pix",
-								Patterns: []string{"*.png", "*.pix"},
+								Name:     ".png, .pix, .pxo",
+								Patterns: []string{"*.png", "*.pix", "*.pxo"},
 								CaseFold: true},
 						})
 
@@ -93,7 +133,7 @@ func NewUIControlSystem(keymap Keymap) *UIControlSystem {
 
 				case CommandTypeSave:
 					name, err := zenity.SelectFileSave(
-						zenity.Title("Save File"),
+						zenity.Title(T("Save File")),
 						zenity.Filename(CurrentFile.PathDir),
 						zenity.FileFilters{
 							{
@@ -113,6 +153,92 @@ func NewUIControlSystem(keymap Keymap) *UIControlSystem {
 						log.Println("Saved file: ", name)
 						returns <- UIControlChanData{CommandType: CommandTypeSave, Name: name}
 					}
+
+				case CommandTypeExportTimelapse:
+					name, err := zenity.SelectFileSave(
+						zenity.Title(T("Export Timelapse")),
+						zenity.Filename(CurrentFile.PathDir),
+						zenity.FileFilters{
+							{
+								Name:     ".gif",
+								Patterns: []string{"*.gif"},
+								CaseFold: true},
+							{
+								Name:     ".mp4",
+								Patterns: []string{"*.mp4"},
+								CaseFold: true},
+							{
+								Name:     ".png (sequence)",
+								Patterns: []string{"*.png"},
+								CaseFold: true},
+						})
+
+					if err != nil {
+						log.Println(err)
+						returns <- UIControlChanData{CommandType: CommandTypeFail}
+					} else {
+						returns <- UIControlChanData{CommandType: CommandTypeExportTimelapse, Name: name}
+					}
+
+				case CommandTypePasteFromFile:
+					name, err := zenity.SelectFile(
+						zenity.Title(T("Paste From File")),
+						zenity.Filename(CurrentFile.PathDir),
+						zenity.FileFilters{
+							{
+								Name:     ".png, .gif, .jpg",
+								Patterns: []string{"*.png", "*.gif", "*.jpg", "*.jpeg"},
+								CaseFold: true},
+						})
+
+					if err != nil {
+						log.Println(err)
+						returns <- UIControlChanData{CommandType: CommandTypeFail}
+					} else {
+						returns <- UIControlChanData{CommandType: CommandTypePasteFromFile, Name: name}
+					}
+
+				case CommandTypeLoadBrush:
+					name, err := zenity.SelectFile(
+						zenity.Title(T("Load Brush")),
+						zenity.Filename(CurrentFile.PathDir),
+						zenity.FileFilters{
+							{
+								Name:     ".png, .pix",
+								Patterns: []string{"*.png", "*.pix"},
+								CaseFold: true},
+						})
+
+					if err != nil {
+						log.Println(err)
+						returns <- UIControlChanData{CommandType: CommandTypeFail}
+					} else {
+						returns <- UIControlChanData{CommandType: CommandTypeLoadBrush, Name: name}
+					}
+
+				case CommandTypeCollabJoin:
+					address, err := zenity.Entry(T("Host address"),
+						zenity.Title(T("Join Session")),
+						zenity.EntryText("localhost"+CollabDefaultAddress))
+
+					if err != nil {
+						log.Println(err)
+						returns <- UIControlChanData{CommandType: CommandTypeFail}
+					} else {
+						returns <- UIControlChanData{CommandType: CommandTypeCollabJoin, Name: address}
+					}
+
+				case CommandTypeAnimationExport:
+					pattern, err := zenity.Entry(T("Where the animation is exported to, .gif or .png"),
+						zenity.Title(T("Animation Export")),
+						zenity.EntryText(cmd.Name))
+
+					if err != nil {
+						log.Println(err)
+						returns <- UIControlChanData{CommandType: CommandTypeFail}
+					} else {
+						returns <- UIControlChanData{CommandType: CommandTypeAnimationExport, Name: pattern, Index: cmd.Index}
+					}
 				}
 			default:
 				time.Sleep(time.Millisecond * 100)
@@ -120,20 +246,14 @@ func NewUIControlSystem(keymap Keymap) *UIControlSystem {
 		}
 	}(UIControlSystemCmds, UIControlSystemReturns)
 
-	return &UIControlSystem{
-		KeyRepeat:           time.Second / 5,
-		Keymap:              keymap,
-		keysDown:            make(map[Key]bool),
-		keysAwaitingRelease: make(map[Key]bool),
-		ScrollScalar:        16, // TODO get from config
-	}
+	return s
 }
 
 func (s *UIControlSystem) getButtonDown() MouseButton {
 	button := MouseButtonNone
-	if rl.IsMouseButtonDown(rl.MouseLeftButton) {
+	if IsMouseButtonDown(rl.MouseLeftButton) {
 		button = rl.MouseLeftButton
-	} else if rl.IsMouseButtonDown(rl.MouseRightButton) {
+	} else if IsMouseButtonDown(rl.MouseRightButton) {
 		button = rl.MouseRightButton
 	} else if rl.IsMouseButtonDown(rl.MouseMiddleButton) {
 		button = rl.MouseMiddleButton
@@ -177,18 +297,62 @@ func (s *UIControlSystem) process(component interface{}, isProcessingChildren bo
 		return nil
 	}
 
-	if rl.CheckCollisionPointRec(rl.Vector2Subtract(rl.GetMousePosition(), moveable.Offset), moveable.Bounds) {
+	mouse := rl.Vector2Subtract(rl.GetMousePosition(), moveable.Offset)
+
+	// Scrollbar logic
+	var content, view float32
+	if scrollable != nil {
+		content, view = scrollable.scrollExtent(moveable, drawable)
+		scrollable.Animate(content, view)
+		track, thumb, hasScrollbar := scrollable.Scrollbar(moveable.Bounds, content, view)
+		along := func(v rl.Vector2) float32 {
+			if scrollable.ScrollDirection == ScrollDirectionHorizontal {
+				return v.X - track.X
+			}
+			return v.Y - track.Y
+		}
+
+		if scrollable.Dragging {
+			// Keeps dragging when the mouse leaves the list
+			if hasScrollbar && IsMouseButtonDown(rl.MouseLeftButton) {
+				UIHasControl = true
+				scrollable.ScrollbarTo(along(mouse)-scrollable.dragGrab, track, thumb, content, view)
+				return entity
+			}
+			scrollable.Dragging = false
+		}
+
+		if hasScrollbar && rl.CheckCollisionPointRec(mouse, track) {
+			hoverable.Hovered = true
+			UIHasMouseOver = true
+			UIHasControl = true
+			if IsMouseButtonPressed(rl.MouseLeftButton) {
+				thumbStart := along(rl.NewVector2(thumb.X, thumb.Y))
+				switch {
+				case rl.CheckCollisionPointRec(mouse, thumb):
+					scrollable.Dragging = true
+					scrollable.dragGrab = along(mouse) - thumbStart
+				case along(mouse) < thumbStart:
+					// Page towards the start
+					scrollable.ScrollBy(view)
+				default:
+					scrollable.ScrollBy(-view)
+				}
+			}
+			return entity
+		}
+	}
+
+	if rl.CheckCollisionPointRec(mouse, moveable.Bounds) {
 		hoverable.Hovered = true
+		UIHasMouseOver = true
 
 		// Scroll logic
 		scrollAmount := int32(rl.GetMouseWheelMove())
 		if scrollAmount != 0 {
 			if scrollable != nil {
 				UIHasControl = true
-				scrollable.ScrollOffset += scrollAmount * s.ScrollScalar
-				if scrollable.ScrollOffset > 0 {
-					scrollable.ScrollOffset = 0
-				}
+				scrollable.ScrollBy(float32(scrollAmount * s.ScrollScalar))
 			}
 		}
 
@@ -209,6 +373,10 @@ func (s *UIControlSystem) process(component interface{}, isProcessingChildren bo
 		switch t := drawable.DrawableType.(type) {
 		case *DrawableParent:
 			for _, child := range t.Children {
+				// Children which weren't drawn have an old offset
+				if scrollable != nil && !scrollable.CanSee(moveable.Bounds, child) {
+					continue
+				}
 				if r := s.process(child, true); r != nil {
 					return r
 				}
@@ -240,12 +408,43 @@ func UINew() {
 	EditorsUIRebuild()
 }
 
+// UINewFromTemplate makes a new file from the template
+func UINewFromTemplate(t *Template) {
+	CurrentFile = NewFileFromTemplate(t)
+	Files = append(Files, CurrentFile)
+	EditorsUIRebuild()
+	PaletteUIRebuildPalette()
+}
+
+// UIOpenConverted adds a file converted from the current one, see
+// File.LayersToFrames, and switches to it
+func UIOpenConverted(f *File) {
+	CurrentFile = f
+	Files = append(Files, CurrentFile)
+	EditorsUIRebuild()
+}
+
+// UISetAnimationExport asks where the animation is exported to
+func UISetAnimationExport(index int32) {
+	anim, err := CurrentFile.GetAnimation(index)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	pattern := anim.Export
+	if pattern == "" {
+		pattern = DefaultAnimationExport
+	}
+	UIControlSystemCmds <- UIControlChanData{CommandType: CommandTypeAnimationExport, Name: pattern, Index: index}
+}
+
 // UIClose closes a file
 func UIClose() {
 	if len(Files) > 1 {
 		CurrentFile.Destroy()
 		CurrentFile = Files[len(Files)-1]
 		EditorsUIRebuild()
+		PaletteUIRebuildPalette()
 	}
 }
 
@@ -259,8 +458,31 @@ func UISaveAs() {
 	UIControlSystemCmds <- UIControlChanData{CommandType: CommandTypeSave}
 }
 
+// UIExportTimelapse exports the current file's timelapse
+func UIExportTimelapse() {
+	UIControlSystemCmds <- UIControlChanData{CommandType: CommandTypeExportTimelapse}
+}
+
+// UIPasteFromFile pastes an image file into the current file
+func UIPasteFromFile() {
+	UIControlSystemCmds <- UIControlChanData{CommandType: CommandTypePasteFromFile}
+}
+
+// UILoadBrush loads an image to use as the brush
+func UILoadBrush() {
+	UIControlSystemCmds <- UIControlChanData{CommandType: CommandTypeLoadBrush}
+}
+
+// UICollabJoin asks for an address and joins the session hosted there
+func UICollabJoin() {
+	UIControlSystemCmds <- UIControlChanData{CommandType: CommandTypeCollabJoin}
+}
+
 // HandleKeyboardEvents handles keyboard events
 func (s *UIControlSystem) HandleKeyboardEvents() {
+	UpdateMomentaryTool()
+	HandleMouseHistoryButtons()
+
 	// Handle keyboard events
 	for key := range s.keysAwaitingRelease {
 		if !rl.IsKeyDown(int32(key)) {
@@ -310,7 +532,11 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 
 			// Prevent tool switching or anything that could alter the state of the tool being used
 			// Moving the cursor with the keyboard is still allowed
-			if rl.IsMouseButtonDown(rl.MouseLeftButton) || rl.IsMouseButtonDown(rl.MouseRightButton) || rl.IsMouseButtonDown(rl.MouseMiddleButton) {
+			if IsMouseButtonDown(rl.MouseLeftButton) || IsMouseButtonDown(rl.MouseRightButton) || rl.IsMouseButtonDown(rl.MouseMiddleButton) {
+				break
+			}
+
+			if CurrentFile.ReadOnly && readOnlyBlockedKeys[key] {
 				break
 			}
 
@@ -322,6 +548,12 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 				if UIInteractableCapturedInput != nil {
 					// Escape from text entry
 					// TODO
+				} else if ShowHelp {
+					ShowHelp = false
+				} else if curve, ok := LeftTool.(*CurveTool); ok && curve.Active() {
+					curve.Cancel()
+				} else if CurrentFile.SelectionPreview != nil {
+					CurrentFile.CancelSelectionPreview()
 				} else {
 					if CurrentFile.DoingSelection {
 						// CurrentFile.CancelSelection()
@@ -334,7 +566,9 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 				CurrentFile.Copy()
 			case "paste":
 				// Pixel paste
-				CurrentFile.Paste()
+				if !CurrentFile.ReadOnly {
+					CurrentFile.Paste()
+				}
 				// Input paste
 				if UIInteractableCapturedInput != nil && UIInteractableCapturedInput.OnKeyPress != nil {
 					for _, char := range rl.GetClipboardText() {
@@ -359,31 +593,32 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 			switch key {
 			case "toggleGrid":
 				CurrentFile.DrawGrid = !CurrentFile.DrawGrid
+			case "toggleCoordinates":
+				ShowCoordinates = !ShowCoordinates
+			case "cycleViewFilter":
+				CycleViewFilter()
+			case "toggleSeamCheck":
+				ToggleSeamCheck()
+			case "precisionMode":
+				PrecisionMode = !PrecisionMode
 			case "showDebug":
 				ShowDebug = !ShowDebug
+			case "help":
+				ShowHelp = !ShowHelp
 			case "resize":
 				ResizeUIShowDialog()
 
-			case "pixelBrush":
-				// Simulate click event
-				if interactable, ok := toolPencil.GetInteractable(); ok {
-					interactable.OnMouseUp(toolPencil, rl.MouseRightButton)
-				}
-			case "eraser":
-				if interactable, ok := toolEraser.GetInteractable(); ok {
-					interactable.OnMouseUp(toolEraser, rl.MouseRightButton)
+			case "pixelBrush", "eraser", "fill", "picker", "selector", "scatterBrush", "curve", "warp", "nineSlice":
+				PickToolFromBinding(key)
+			case "confirm":
+				if CurrentFile.SelectionPreview != nil {
+					CurrentFile.AcceptSelectionPreview()
 				}
-			case "fill":
-				if interactable, ok := toolFill.GetInteractable(); ok {
-					interactable.OnMouseUp(toolFill, rl.MouseRightButton)
-				}
-			case "picker":
-				if interactable, ok := toolPicker.GetInteractable(); ok {
-					interactable.OnMouseUp(toolPicker, rl.MouseRightButton)
-				}
-			case "selector":
-				if interactable, ok := toolSelector.GetInteractable(); ok {
-					interactable.OnMouseUp(toolSelector, rl.MouseRightButton)
+				switch t := LeftTool.(type) {
+				case *CurveTool:
+					t.Commit()
+				case *WarpTool:
+					CurrentFile.CommitSelection()
 				}
 			case "selectAll":
 				if interactable, ok := toolSelector.GetInteractable(); ok {
@@ -412,20 +647,54 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 					}
 				}
 
+			case "selectOpaque":
+				RunCommand("selectOpaque", func() {
+					CurrentFile.SelectOpaque()
+				})
+
 			case "flipHorizontal":
-				CurrentFile.FlipHorizontal()
+				RunCommand("flipHorizontal", func() {
+					CurrentFile.FlipHorizontal()
+				})
 			case "flipVertical":
-				CurrentFile.FlipVertical()
+				RunCommand("flipVertical", func() {
+					CurrentFile.FlipVertical()
+				})
+			case "rotateClockwise":
+				RunCommand("rotateClockwise", func() {
+					CurrentFile.RotateClockwise()
+				})
+			case "rotateCounterClockwise":
+				RunCommand("rotateCounterClockwise", func() {
+					CurrentFile.RotateCounterClockwise()
+				})
 
 			case "paletteNext":
 				PaletteUINextColor()
 			case "palettePrevious":
 				PaletteUIPreviousColor()
 
+			case "frameNext":
+				PreviewUIStepFrame(1)
+			case "framePrevious":
+				PreviewUIStepFrame(-1)
+			case "animationStart":
+				PreviewUISetAnimationStart()
+			case "animationEnd":
+				PreviewUISetAnimationEnd()
+			case "duplicateFrame":
+				RunCommand("duplicateFrame", func() {
+					DuplicateCurrentFrame()
+				})
+			case "clearFrame":
+				RunCommand("clearFrame", func() {
+					ClearCurrentFrame()
+				})
+
 			case "layerUp":
 				CurrentFile.CurrentLayer++
-				if CurrentFile.CurrentLayer > int32(len(CurrentFile.Layers)-2) {
-					CurrentFile.CurrentLayer = int32(len(CurrentFile.Layers) - 2)
+				if CurrentFile.CurrentLayer > int32(len(CurrentFile.Layers)-1) {
+					CurrentFile.CurrentLayer = int32(len(CurrentFile.Layers) - 1)
 				}
 				LayersUISetCurrentLayer(CurrentFile.CurrentLayer)
 			case "layerDown":
@@ -448,6 +717,17 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 				}
 			case "saveAs":
 				UISaveAs()
+			case "batchExport":
+				BatchExport()
+			case "reExport":
+				ReExport()
+			case "stampVisible":
+				RunCommand("stampVisible", func() {
+					CurrentFile.StampVisible()
+					LayersUIRebuildList()
+				})
+			case "repeatLast":
+				RepeatLastCommand()
 			case "undo":
 				CurrentFile.Undo()
 			case "redo":
@@ -507,29 +787,35 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 				}
 				return false
 			}
+			// Nudging the selection is a command so that it can be repeated
+			nudge := func(name string, dx, dy int32) {
+				RunCommand(name, func() {
+					CurrentFile.MoveSelection(dx, dy)
+				})
+			}
 			switch {
 			case matches(last, s.Keymap.Data["toolRight"]):
 				// Move selection
 				if _, ok := LeftTool.(*SelectorTool); ok {
-					CurrentFile.MoveSelection(1, 0)
+					nudge("toolRight", 1, 0)
 				} else {
 					rl.SetMousePosition(int(x+moveAmount), int(y))
 				}
 			case matches(last, s.Keymap.Data["toolLeft"]):
 				if _, ok := LeftTool.(*SelectorTool); ok {
-					CurrentFile.MoveSelection(-1, 0)
+					nudge("toolLeft", -1, 0)
 				} else {
 					rl.SetMousePosition(int(x-moveAmount), int(y))
 				}
 			case matches(last, s.Keymap.Data["toolDown"]):
 				if _, ok := LeftTool.(*SelectorTool); ok {
-					CurrentFile.MoveSelection(0, 1)
+					nudge("toolDown", 0, 1)
 				} else {
 					rl.SetMousePosition(int(x), int(y+moveAmount))
 				}
 			case matches(last, s.Keymap.Data["toolUp"]):
 				if _, ok := LeftTool.(*SelectorTool); ok {
-					CurrentFile.MoveSelection(0, -1)
+					nudge("toolUp", 0, -1)
 				} else {
 					rl.SetMousePosition(int(x), int(y-moveAmount))
 				}
@@ -557,7 +843,11 @@ func (s *UIControlSystem) Update(dt float32) {
 			if len(cmd.Name) > 0 {
 				// open also sets the currentfile before rebuilding ui
 				log.Println("Opening file", cmd.Name)
-				Files = append(Files, Open(cmd.Name))
+				if file, err := Open(cmd.Name); err == nil {
+					Files = append(Files, file)
+				} else {
+					log.Println(err)
+				}
 				// EditorsUIAddButton(file)
 				EditorsUIRebuild()
 
@@ -566,6 +856,41 @@ func (s *UIControlSystem) Update(dt float32) {
 			if len(cmd.Name) > 0 {
 				CurrentFile.SaveAs(cmd.Name)
 			}
+		case CommandTypeExportTimelapse:
+			if len(cmd.Name) > 0 {
+				if err := CurrentFile.ExportTimelapse(cmd.Name); err != nil {
+					log.Println(err)
+					PlaySoundCue(SoundError)
+				} else {
+					log.Println("Exported timelapse to", cmd.Name)
+					PlaySoundCue(SoundExported)
+				}
+			}
+		case CommandTypePasteFromFile:
+			if len(cmd.Name) > 0 {
+				if err := CurrentFile.PasteFromFile(cmd.Name); err != nil {
+					log.Println(err)
+					PlaySoundCue(SoundError)
+				}
+			}
+		case CommandTypeCollabJoin:
+			if len(cmd.Name) > 0 {
+				CollabJoin(cmd.Name)
+			}
+		case CommandTypeAnimationExport:
+			// An empty pattern goes back to the default
+			CurrentFile.SetAnimationExport(cmd.Index, strings.TrimSpace(cmd.Name))
+		case CommandTypeLoadBrush:
+			if len(cmd.Name) > 0 {
+				brush, err := LoadBrushImage(cmd.Name)
+				if err != nil {
+					log.Println(err)
+					UIWarning(err.Error())
+					break
+				}
+				GlobalBrushImage = brush
+				ToolsUISetBrushShape(BrushShapeImage)
+			}
 		}
 	default:
 	}
@@ -574,7 +899,11 @@ func (s *UIControlSystem) Update(dt float32) {
 		files := rl.LoadDroppedFiles()
 		for _, filePath := range files {
 			log.Println("Opening file", filePath)
-			Files = append(Files, Open(filePath))
+			if file, err := Open(filePath); err == nil {
+				Files = append(Files, file)
+			} else {
+				log.Println(err)
+			}
 			EditorsUIRebuild()
 		}
 		rl.UnloadDroppedFiles()
@@ -585,21 +914,27 @@ func (s *UIControlSystem) Update(dt float32) {
 		return
 	}
 
-	res := s.Scene.QueryTag(s.Scene.Tags["basic"], s.Scene.Tags["interactable"], s.Scene.Tags["scrollable"])
+	res := UISortByZIndex(s.Scene.QueryTag(s.Scene.Tags["basic"], s.Scene.Tags["interactable"], s.Scene.Tags["scrollable"]))
 
 	var entity *Entity
 	UIHasControl = false
+	UIHasMouseOver = false
 
 	// Reverse order so that entities that are on top can get input and return
 	// the entity which would be returned from process()
 	var newEntity *Entity
 	for i := len(res) - 1; i > 0; i-- {
+		if res[i].Entity == canvasEntity {
+			continue
+		}
 		newEntity = s.process(res[i], false)
 		if newEntity != nil {
 			break
 		}
 	}
 
+	CanvasUIUpdateHovered(UIHasMouseOver || newEntity != nil)
+
 	if UIEntityCapturedInput != nil {
 		entity = UIEntityCapturedInput
 	} else {
@@ -630,7 +965,7 @@ func (s *UIControlSystem) Update(dt float32) {
 				}
 
 				isHeld := false
-				if time.Now().Sub(interactable.ButtonDownAt) > time.Second/2 {
+				if time.Now().Sub(interactable.ButtonDownAt) > UIDragDelay() {
 					isHeld = true
 					if moveable, ok := entity.GetMoveable(); ok {
 						if moveable.Draggable {
@@ -639,7 +974,7 @@ func (s *UIControlSystem) Update(dt float32) {
 					}
 				}
 
-				if interactable.OnMouseDown != nil {
+				if interactable.OnMouseDown != nil && !interactable.Disabled {
 					interactable.OnMouseDown(UIEntityCapturedInput, lastButton, isHeld)
 				}
 			}
@@ -683,18 +1018,14 @@ func (s *UIControlSystem) Update(dt float32) {
 		// TODO There is probably a cleaner way of handling this
 		if button == MouseButtonNone && UIInteractableCapturedInput.ButtonReleased == false {
 			UIInteractableCapturedInput.ButtonReleased = true
-			if UIInteractableCapturedInput.OnMouseUp != nil {
-				UIInteractableCapturedInput.OnMouseUp(UIEntityCapturedInput, UIInteractableCapturedInput.ButtonDown)
-			}
+			UIInteractableCapturedInput.MouseUp(UIEntityCapturedInput, UIInteractableCapturedInput.ButtonDown)
 			UIIsDraggingEntity = false
 		}
 	} else if (UIEntityCapturedInput != nil || UIIsDraggingEntity) && button == MouseButtonNone {
 		// Handle mouse up event
 		if UIInteractableCapturedInput.ButtonReleased == false {
 			UIInteractableCapturedInput.ButtonReleased = true
-			if UIInteractableCapturedInput.OnMouseUp != nil {
-				UIInteractableCapturedInput.OnMouseUp(UIEntityCapturedInput, UIInteractableCapturedInput.ButtonDown)
-			}
+			UIInteractableCapturedInput.MouseUp(UIEntityCapturedInput, UIInteractableCapturedInput.ButtonDown)
 			UIIsDraggingEntity = false
 		}
 
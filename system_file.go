@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+
 	rl "github.com/gen2brain/raylib-go/raylib"
 )
 
@@ -15,6 +17,20 @@ type UIRenderFileSystem struct {
 	hasDoneFirstFrameResize bool
 
 	cursor rl.Vector2
+	// lastCursor is where the cursor was on the canvas in the last frame,
+	// after the seam check wrapped it
+	lastCursor rl.Vector2
+
+	// The length of the history after the stroke's HistoryPixel was appended,
+	// 0 if the tool didn't append one
+	strokeHistoryLeft, strokeHistoryRight int
+
+	// spacePanning is true while the left button pans because it was pressed
+	// with the pan key held
+	spacePanning bool
+
+	// previewTool is the tool which last drew to the preview layer
+	previewTool Tool
 }
 
 // NewUIFileSystem returns a new UIFileSystem
@@ -53,6 +69,8 @@ func NewUIFileSystem() *UIRenderFileSystem {
 		}
 	}
 
+	NewCanvasUI()
+
 	// Top bar
 	menu := NewMenuUI(rl.NewRectangle(
 		0,
@@ -132,7 +150,7 @@ func NewUIFileSystem() *UIRenderFileSystem {
 		0,
 		0,
 		rgbWidth+paletteWidth,
-		UIButtonHeight))
+		UIButtonHeight*2))
 	tools.Snap([]SnapData{
 		{currentColor, SideLeft, SideLeft},
 		{currentColor, SideTop, SideBottom},
@@ -231,27 +249,55 @@ func NewUIFileSystem() *UIRenderFileSystem {
 
 	NewResizeUI()
 
+	NewPropertiesUI()
+
+	NewExportUI()
+
+	NewTransformUI()
+
+	NewStampsUI(rl.NewRectangle(
+		rgbWidth+UIFontSize,
+		UIFontSize*6,
+		UIButtonHeight*2*5,
+		UIButtonHeight*2*3+UIButtonHeight,
+	))
+
+	NewTimelineUI(rl.NewRectangle(
+		rgbWidth+UIFontSize,
+		float32(rl.GetScreenHeight())-UIButtonHeight*6,
+		UIButtonHeight*2*6,
+		UIButtonHeight*4,
+	))
+
 	return s
 }
 
 // Draw draws everything from the file to the screen
 func (s *UIRenderFileSystem) Draw() {
 	// Draw temp layer
-	rl.BeginTextureMode(CurrentFile.Layers[len(CurrentFile.Layers)-1].Canvas)
+	CurrentFile.updatePreviewLayer(CurrentFile.FileCamera)
+	rl.BeginTextureMode(CurrentFile.PreviewLayer().Canvas)
+	// Tools draw in canvas pixels, the preview layer starts at previewOrigin
+	rl.Translatef(-float32(CurrentFile.previewOrigin.X), -float32(CurrentFile.previewOrigin.Y), 0)
 	// LeftTool draws last as it's more important
-	if rl.IsMouseButtonDown(rl.MouseRightButton) {
-		RightTool.DrawPreview(int32(s.cursor.X), int32(s.cursor.Y))
-
-	} else {
-		LeftTool.DrawPreview(int32(s.cursor.X), int32(s.cursor.Y))
+	tool := LeftTool
+	if IsMouseButtonDown(rl.MouseRightButton) {
+		tool = RightTool
+	}
+	// Nothing the last tool previewed is left behind when the tool changes
+	if tool != s.previewTool {
+		rl.ClearBackground(rl.Blank)
+		s.previewTool = tool
 	}
+	tool.DrawPreview(int32(s.cursor.X), int32(s.cursor.Y))
+	DrawFillPreview()
 
 	rl.EndTextureMode()
 
 	// Draw layers to the render layer
 	// rl.BeginTextureMode(CurrentFile.RenderLayer.Canvas)
 	// rl.ClearBackground(rl.Black)
-	// for _, layer := range CurrentFile.Layers[:len(CurrentFile.Layers)-1] {
+	// for _, layer := range CurrentFile.Layers {
 	// 	if !layer.Hidden {
 	// 		rl.BeginBlendMode(layer.BlendMode)
 	// 		rl.DrawTextureRec(layer.Canvas.Texture,
@@ -264,21 +310,26 @@ func (s *UIRenderFileSystem) Draw() {
 	// rl.EndTextureMode()
 
 	rl.BeginMode2D(CurrentFile.FileCamera)
+	// Non-square pixels only change how the canvas is displayed
+	rl.PushMatrix()
+	rl.Scalef(CurrentFile.PixelAspect, 1, 1)
 
 	// Draw render layer
+	BeginViewFilter()
 	// rl.BeginBlendMode(CurrentFile.RenderLayer.BlendMode)
-	rl.DrawTextureRec(CurrentFile.RenderLayer.Canvas.Texture,
-		rl.NewRectangle(0, 0, float32(CurrentFile.RenderLayer.Canvas.Texture.Width), -float32(CurrentFile.RenderLayer.Canvas.Texture.Height)),
-		rl.NewVector2(-float32(CurrentFile.RenderLayer.Canvas.Texture.Width)/2, -float32(CurrentFile.RenderLayer.Canvas.Texture.Height)/2),
-		rl.White)
+	if !DrawExportPreview() {
+		rl.DrawRectangle(-CurrentFile.CanvasWidth/2, -CurrentFile.CanvasHeight/2, CurrentFile.CanvasWidth, CurrentFile.CanvasHeight, rl.Black)
+		CurrentFile.seamCheckRegions(func(src, dest rl.Rectangle) {
+			CurrentFile.RenderLayer.Chunks.DrawRegion(src, dest, rl.White)
+		})
+	}
 	// rl.EndBlendMode()
 
 	// Draw preview layer
-	previewLayer := CurrentFile.Layers[len(CurrentFile.Layers)-1]
-	rl.DrawTextureRec(previewLayer.Canvas.Texture,
-		rl.NewRectangle(0, 0, float32(previewLayer.Canvas.Texture.Width), -float32(previewLayer.Canvas.Texture.Height)),
-		rl.NewVector2(-float32(previewLayer.Canvas.Texture.Width)/2, -float32(previewLayer.Canvas.Texture.Height)/2),
-		rl.White)
+	CurrentFile.seamCheckRegions(func(src, dest rl.Rectangle) {
+		drawTextureRegion(CurrentFile.PreviewLayer().Canvas, CurrentFile.previewOrigin, src, dest, rl.White)
+	})
+	EndViewFilter()
 
 	// Grid drawing
 	if CurrentFile.DrawGrid {
@@ -298,96 +349,67 @@ func (s *UIRenderFileSystem) Draw() {
 				-CurrentFile.CanvasHeight/2+y,
 				rl.White)
 		}
-	} else {
-		// Draw canvas outline
-		rl.DrawLine(
-			-CurrentFile.CanvasWidth/2,
-			-CurrentFile.CanvasHeight/2,
-			CurrentFile.CanvasWidth/2,
-			-CurrentFile.CanvasHeight/2,
-			rl.White,
-		)
-		rl.DrawLine(
-			-CurrentFile.CanvasWidth/2,
-			-CurrentFile.CanvasHeight/2,
-			-CurrentFile.CanvasWidth/2,
-			CurrentFile.CanvasHeight/2,
-			rl.White,
-		)
-		rl.DrawLine(
-			-CurrentFile.CanvasWidth/2,
-			CurrentFile.CanvasHeight/2,
-			CurrentFile.CanvasWidth/2,
-			CurrentFile.CanvasHeight/2,
-			rl.White,
-		)
-		rl.DrawLine(
-			CurrentFile.CanvasWidth/2,
-			-CurrentFile.CanvasHeight/2,
-			CurrentFile.CanvasWidth/2,
-			CurrentFile.CanvasHeight/2,
-			rl.White,
-		)
-
 	}
 
-	// Show outline for canvas resize preview
-	if CurrentFile.DoingResize {
-		var x, y float32
-		w := float32(CurrentFile.CanvasWidthResizePreview)
-		h := float32(CurrentFile.CanvasHeightResizePreview)
-
-		// Move offset
-		dw := (w - float32(CurrentFile.CanvasWidth)) / 2
-		dh := (h - float32(CurrentFile.CanvasHeight)) / 2
-
-		switch CurrentFile.CanvasDirectionResizePreview {
-		case ResizeTL:
-			x = -float32(CurrentFile.CanvasWidthResizePreview)/2 + dw
-			y = -float32(CurrentFile.CanvasHeightResizePreview)/2 + dh
-		case ResizeTC:
-			x = -float32(CurrentFile.CanvasWidthResizePreview) / 2
-			y = -float32(CurrentFile.CanvasHeightResizePreview)/2 + dh
-		case ResizeTR:
-			x = -float32(CurrentFile.CanvasWidthResizePreview)/2 - dw
-			y = -float32(CurrentFile.CanvasHeightResizePreview)/2 + dh
-		case ResizeCL:
-			x = -float32(CurrentFile.CanvasWidthResizePreview)/2 + dw
-			y = -float32(CurrentFile.CanvasHeightResizePreview) / 2
-		case ResizeCC:
-			x = -float32(CurrentFile.CanvasWidthResizePreview) / 2
-			y = -float32(CurrentFile.CanvasHeightResizePreview) / 2
-		case ResizeCR:
-			x = -float32(CurrentFile.CanvasWidthResizePreview)/2 - dw
-			y = -float32(CurrentFile.CanvasHeightResizePreview) / 2
-		case ResizeBL:
-			x = -float32(CurrentFile.CanvasWidthResizePreview)/2 + dw
-			y = -float32(CurrentFile.CanvasHeightResizePreview)/2 - dh
-		case ResizeBC:
-			x = -float32(CurrentFile.CanvasWidthResizePreview) / 2
-			y = -float32(CurrentFile.CanvasHeightResizePreview)/2 - dh
-		case ResizeBR:
-			x = -float32(CurrentFile.CanvasWidthResizePreview)/2 - dw
-			y = -float32(CurrentFile.CanvasHeightResizePreview)/2 - dh
+	for _, guide := range CurrentFile.Guides {
+		if guide.Vertical {
+			rl.DrawLine(
+				-CurrentFile.CanvasWidth/2+guide.Position,
+				-CurrentFile.CanvasHeight/2,
+				-CurrentFile.CanvasWidth/2+guide.Position,
+				CurrentFile.CanvasHeight/2,
+				rl.SkyBlue)
+		} else {
+			rl.DrawLine(
+				-CurrentFile.CanvasWidth/2,
+				-CurrentFile.CanvasHeight/2+guide.Position,
+				CurrentFile.CanvasWidth/2,
+				-CurrentFile.CanvasHeight/2+guide.Position,
+				rl.SkyBlue)
 		}
-
-		rl.DrawRectangleLinesEx(
-			rl.NewRectangle(x, y, w, h),
-			1,
-			rl.White,
-		)
 	}
+
+	DrawTileColorWarnings()
+	DrawSeamCheck()
+
+	rl.PopMatrix()
 	rl.EndMode2D()
 
 	rl.BeginMode2D(rl.Camera2D{Zoom: 1.0})
-	if rl.IsMouseButtonDown(rl.MouseRightButton) {
-		RightTool.DrawUI(CurrentFile.FileCamera)
-	} else {
-		LeftTool.DrawUI(CurrentFile.FileCamera)
+	DrawCanvasBounds(CurrentFile.FileCamera)
+	tool.DrawUI(CurrentFile.FileCamera)
+	if rl.IsCursorHidden() {
+		drawToolCursor(tool, CurrentFile.FileCamera)
 	}
 	rl.EndMode2D()
 }
 
+// drawToolCursor draws the tool's cursor in place of the OS cursor. Tools
+// which don't implement CursorTool get a crosshair with the pixel highlighted
+func drawToolCursor(tool Tool, camera rl.Camera2D) {
+	mouse := rl.GetMousePosition()
+	loc := ScreenToPixel(mouse, camera)
+
+	// Subtle highlight on the exact pixel under the cursor, it's hard to tell
+	// which one will be affected when zoomed out
+	pos := PixelToScreen(loc.X, loc.Y, camera)
+	rl.DrawRectangleV(pos, PixelScreenSize(camera), rl.NewColor(255, 255, 255, 64))
+
+	if ct, ok := tool.(CursorTool); ok {
+		ct.DrawCursor(camera)
+	} else {
+		DrawPixelHighlight(loc.X, loc.Y, camera)
+		DrawCrosshair(mouse)
+	}
+
+	if ShowCoordinates {
+		text := fmt.Sprintf("%d, %d", loc.X, loc.Y)
+		textPos := rl.NewVector2(mouse.X+16, mouse.Y-UIFontSize-8)
+		rl.DrawTextEx(Font, text, rl.Vector2Add(textPos, rl.NewVector2(1, 1)), UIFontSize, 1, rl.Black)
+		rl.DrawTextEx(Font, text, textPos, UIFontSize, 1, rl.White)
+	}
+}
+
 func recursiveResize(entity *Entity) {
 	if res, ok := entity.GetResizeable(); ok {
 		if len(res.SnappedTo) > 0 {
@@ -455,12 +477,16 @@ func (s *UIRenderFileSystem) Update(dt float32) {
 		s.Resize()
 	}
 
+	CollabUpdate()
+	UpdateFill()
+	UpdateSaves()
+
 	layer := CurrentFile.GetCurrentLayer()
 	s.mouseX = rl.GetMouseX()
 	s.mouseY = rl.GetMouseY()
 
 	// Scroll towards the cursor's location
-	if !UIHasControl {
+	if !UIHasControl && !PrecisionMode {
 		scrollAmount := rl.GetMouseWheelMove()
 		if scrollAmount != 0 {
 			// TODO scroll scalar in config (0.1)
@@ -471,7 +497,16 @@ func (s *UIRenderFileSystem) Update(dt float32) {
 		}
 	}
 
-	if rl.IsMouseButtonDown(rl.MouseMiddleButton) {
+	// Space and left drag is for mice and trackpads without a middle button,
+	// the key is typed instead while a text input is focused
+	typing := UIInteractableCapturedInput != nil && UIInteractableCapturedInput.OnKeyPress != nil
+	if IsMouseButtonPressed(rl.MouseLeftButton) && CanvasUIHovered() && !typing && isBindingDown("pan") {
+		s.spacePanning = true
+	} else if !IsMouseButtonDown(rl.MouseLeftButton) {
+		s.spacePanning = false
+	}
+
+	if (rl.IsMouseButtonDown(rl.MouseMiddleButton) || s.spacePanning) && !PrecisionMode {
 		CurrentFile.FileCameraTarget.X += float32(s.mouseLastX-s.mouseX) / CurrentFile.FileCamera.Zoom
 		CurrentFile.FileCameraTarget.Y += float32(s.mouseLastY-s.mouseY) / CurrentFile.FileCamera.Zoom
 	}
@@ -479,17 +514,42 @@ func (s *UIRenderFileSystem) Update(dt float32) {
 	s.mouseLastY = s.mouseY
 	CurrentFile.FileCamera.Target = CurrentFile.FileCameraTarget
 
+	s.lastCursor = s.cursor
 	s.cursor = rl.GetScreenToWorld2D(rl.GetMousePosition(), CurrentFile.FileCamera)
+	s.cursor.X /= CurrentFile.PixelAspect
 	s.cursor = rl.Vector2Add(
 		s.cursor,
-		rl.NewVector2(float32(layer.Canvas.Texture.Width)/2, float32(layer.Canvas.Texture.Height)/2),
+		rl.NewVector2(float32(layer.Width)/2, float32(layer.Height)/2),
 	)
+	s.cursor = CurrentFile.seamCheckWrap(s.cursor)
+	// Strokes which wrap around start again on the other side, instead of
+	// drawing a line across the canvas
+	jumped := CurrentFile.seamCheckJumped(s.lastCursor, s.cursor)
 
 	PreviewUIDrawTile(int32(s.cursor.X), int32(s.cursor.Y))
+	TimelineUIUpdate()
+
+	// The tool draws its own cursor over the canvas, but the OS cursor is
+	// needed for the UI
+	if MouseCaptured() {
+		// Showing the cursor would release it
+	} else if UIHasMouseOver && !FileHasControl {
+		if rl.IsCursorHidden() {
+			rl.ShowCursor()
+		}
+	} else if !rl.IsCursorHidden() {
+		rl.HideCursor()
+	}
 
+	// Strokes have to start on the canvas, but they carry on over the UI
+	hadControl := FileHasControl
+	pressed := IsMouseButtonPressed(rl.MouseLeftButton) || IsMouseButtonPressed(rl.MouseRightButton)
 	FileHasControl = false
-	if !UIHasControl {
-		if rl.IsMouseButtonDown(rl.MouseLeftButton) {
+	if s.spacePanning {
+		// Stops the UI from taking the drag
+		FileHasControl = true
+	} else if (hadControl || (pressed && CanvasUIHovered())) && !UIHasControl && !CurrentFile.ReadOnly {
+		if IsMouseButtonDown(rl.MouseLeftButton) {
 
 			FileHasControl = true
 			// Fires once
@@ -500,9 +560,19 @@ func (s *UIRenderFileSystem) Update(dt float32) {
 					// ignore
 				case *SelectorTool:
 					// ignore
+				case *CurveTool:
+					// appends its own history when the curve is finished
+				case *WarpTool:
+					// history is handled by the selection
+				case *NineSliceTool:
+					// only changes the margins
 				default:
-					CurrentFile.AppendHistory(HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer})
+					CurrentFile.AppendHistory(NewHistoryPixel(CurrentFile.CurrentLayer))
+					CurrentFile.BeginStroke()
+					s.strokeHistoryLeft = len(CurrentFile.History)
 				}
+			} else if jumped {
+				LeftTool.MouseUp(int32(s.lastCursor.X), int32(s.lastCursor.Y), rl.MouseLeftButton)
 			}
 			CurrentFile.HasDoneMouseUpLeft = false
 
@@ -513,10 +583,15 @@ func (s *UIRenderFileSystem) Update(dt float32) {
 			if CurrentFile.HasDoneMouseUpLeft == false {
 				CurrentFile.HasDoneMouseUpLeft = true
 				LeftTool.MouseUp(int32(s.cursor.X), int32(s.cursor.Y), rl.MouseLeftButton)
+				if s.strokeHistoryLeft > 0 {
+					CurrentFile.EndStroke()
+					CurrentFile.DiscardEmptyPixelHistory(s.strokeHistoryLeft)
+					s.strokeHistoryLeft = 0
+				}
 			}
 		}
 
-		if rl.IsMouseButtonDown(rl.MouseRightButton) {
+		if IsMouseButtonDown(rl.MouseRightButton) {
 			FileHasControl = true
 			if CurrentFile.HasDoneMouseUpRight {
 				// Create new history action
@@ -525,9 +600,19 @@ func (s *UIRenderFileSystem) Update(dt float32) {
 					// ignore
 				case *SelectorTool:
 					// ignore
+				case *CurveTool:
+					// appends its own history when the curve is finished
+				case *WarpTool:
+					// history is handled by the selection
+				case *NineSliceTool:
+					// only changes the margins
 				default:
-					CurrentFile.AppendHistory(HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer})
+					CurrentFile.AppendHistory(NewHistoryPixel(CurrentFile.CurrentLayer))
+					CurrentFile.BeginStroke()
+					s.strokeHistoryRight = len(CurrentFile.History)
 				}
+			} else if jumped {
+				RightTool.MouseUp(int32(s.lastCursor.X), int32(s.lastCursor.Y), rl.MouseRightButton)
 			}
 			CurrentFile.HasDoneMouseUpRight = false
 			RightTool.MouseDown(int32(s.cursor.X), int32(s.cursor.Y), rl.MouseRightButton)
@@ -535,7 +620,16 @@ func (s *UIRenderFileSystem) Update(dt float32) {
 			if CurrentFile.HasDoneMouseUpRight == false {
 				CurrentFile.HasDoneMouseUpRight = true
 				RightTool.MouseUp(int32(s.cursor.X), int32(s.cursor.Y), rl.MouseRightButton)
+				if s.strokeHistoryRight > 0 {
+					CurrentFile.EndStroke()
+					CurrentFile.DiscardEmptyPixelHistory(s.strokeHistoryRight)
+					s.strokeHistoryRight = 0
+				}
 			}
 		}
+
+		if FileHasControl {
+			Autoscroll(CurrentFile)
+		}
 	}
 }
@@ -10,6 +10,17 @@ import (
 type UIRenderSystem struct {
 	BasicSystem
 	camera rl.Camera2D
+
+	// clips are the bounds of the parents being drawn, children are clipped
+	// to the last one. They're relative to origin, which is where the texture
+	// being drawn to is
+	clips  []rl.Rectangle
+	origin rl.Vector2
+
+	// tweenTexture is where fading or sliding entities are drawn, tweening is
+	// true while drawing to it
+	tweenTexture rl.RenderTexture2D
+	tweening     bool
 }
 
 // NewUIRenderSystem returs a new UIRenderSystem
@@ -19,6 +30,33 @@ func NewUIRenderSystem() *UIRenderSystem {
 	}
 }
 
+// pushClip clips drawing to bounds as well as the current clip
+func (s *UIRenderSystem) pushClip(bounds rl.Rectangle) {
+	clip := rl.NewRectangle(bounds.X-s.origin.X, bounds.Y-s.origin.Y, bounds.Width, bounds.Height)
+	if len(s.clips) > 0 {
+		clip = rl.GetCollisionRec(clip, s.clips[len(s.clips)-1])
+	}
+	s.clips = append(s.clips, clip)
+	s.applyClip()
+}
+
+// popClip goes back to the previous clip
+func (s *UIRenderSystem) popClip() {
+	s.clips = s.clips[:len(s.clips)-1]
+	s.applyClip()
+}
+
+// applyClip sets the scissor rectangle to the current clip. Scissor mode
+// doesn't nest, so it has to be set again whenever the clip changes
+func (s *UIRenderSystem) applyClip() {
+	if len(s.clips) == 0 {
+		rl.EndScissorMode()
+		return
+	}
+	c := s.clips[len(s.clips)-1]
+	rl.BeginScissorMode(int32(c.X), int32(c.Y), int32(c.Width), int32(c.Height))
+}
+
 func (s *UIRenderSystem) draw(component interface{}, isDrawingChildren bool, offset rl.Vector2) {
 	var result *QueryResult
 	switch typed := component.(type) {
@@ -50,6 +88,17 @@ func (s *UIRenderSystem) draw(component interface{}, isDrawingChildren bool, off
 		return
 	}
 
+	// Disabled entities are greyed out
+	tint := rl.White
+	if interactable != nil {
+		if interactable.EnabledWhen != nil {
+			interactable.Disabled = !interactable.EnabledWhen()
+		}
+		if interactable.Disabled {
+			tint = rl.Gray
+		}
+	}
+
 	// Set the offset, doesn't matter if element is a child or not
 	moveable.Offset = offset
 
@@ -114,13 +163,20 @@ func (s *UIRenderSystem) draw(component interface{}, isDrawingChildren bool, off
 		}
 
 		if t.IsPassthrough {
+			s.pushClip(moveable.Bounds)
 			for _, child := range t.Children {
 				// Just draw the child, offset is already set
 				s.draw(child, true, offset)
 			}
+			s.popClip()
 			return
 		}
 
+		// The texture clips the children, so clips start again inside of it
+		clips, origin := s.clips, s.origin
+		s.clips = nil
+		s.applyClip()
+
 		rl.BeginTextureMode(t.Texture)
 		rl.ClearBackground(rl.Blank)
 
@@ -139,19 +195,47 @@ func (s *UIRenderSystem) draw(component interface{}, isDrawingChildren bool, off
 			}
 		}
 
+		s.origin = s.camera.Target
+
 		for _, child := range t.Children {
+			// Only children which can be seen are drawn, so long lists
+			// stay fast
+			if scrollable != nil && !scrollable.CanSee(moveable.Bounds, child) {
+				continue
+			}
 			rl.BeginMode2D(s.camera)
 			s.draw(child, true, childOffset)
 			rl.EndMode2D()
 		}
 
 		rl.EndTextureMode()
+		if s.tweening {
+			// Texture modes don't nest, go back to drawing the tween
+			rl.BeginTextureMode(s.tweenTexture)
+		}
+
+		// Siblings of nested parents are drawn with the outer camera
+		s.clips, s.origin = clips, origin
+		s.camera.Target = origin
+		s.applyClip()
 
 		rl.DrawTextureRec(t.Texture.Texture,
 			rl.NewRectangle(0, 0, float32(t.Texture.Texture.Width), -float32(t.Texture.Texture.Height)),
 			rl.NewVector2(moveable.Bounds.X, moveable.Bounds.Y),
 			rl.White)
 
+		if scrollable != nil {
+			content, view := scrollable.scrollExtent(moveable, drawable)
+			if track, thumb, ok := scrollable.Scrollbar(moveable.Bounds, content, view); ok {
+				rl.DrawRectangleRec(track, rl.NewColor(0, 0, 0, 255*0.6))
+				thumbColor := rl.Gray
+				if scrollable.Dragging || rl.CheckCollisionPointRec(rl.Vector2Subtract(rl.GetMousePosition(), offset), thumb) {
+					thumbColor = rl.LightGray
+				}
+				rl.DrawRectangleRec(thumb, thumbColor)
+			}
+		}
+
 	case *DrawableText:
 		if drawable.DrawBackground {
 			drawBackground(hoverable, moveable)
@@ -183,7 +267,11 @@ func (s *UIRenderSystem) draw(component interface{}, isDrawingChildren bool, off
 					y = moveable.Bounds.Y + moveable.Bounds.Height/2 - fo.Y/2
 				}
 				offsetX += fo.X
-				rl.DrawTextEx(Font, tfd.Text, rl.Vector2{X: x, Y: y}, UIFontSize, 1, tfd.Color)
+				color := tfd.Color
+				if interactable != nil && interactable.Disabled {
+					color = rl.Fade(color, 0.5)
+				}
+				rl.DrawTextEx(Font, tfd.Text, rl.Vector2{X: x, Y: y}, UIFontSize, 1, color)
 			}
 		} else {
 			text := t.Label
@@ -204,7 +292,7 @@ func (s *UIRenderSystem) draw(component interface{}, isDrawingChildren bool, off
 				x = moveable.Bounds.X + moveable.Bounds.Width/2 - fo.X/2
 				y = moveable.Bounds.Y + moveable.Bounds.Height/2 - fo.Y/2
 			}
-			rl.DrawTextEx(Font, text, rl.Vector2{X: x, Y: y}, UIFontSize, 1, rl.White)
+			rl.DrawTextEx(Font, text, rl.Vector2{X: x, Y: y}, UIFontSize, 1, tint)
 		}
 
 	case *DrawableTexture:
@@ -217,7 +305,7 @@ func (s *UIRenderSystem) draw(component interface{}, isDrawingChildren bool, off
 
 		x := moveable.Bounds.X + moveable.Bounds.Width/2 - float32(t.Texture.Width)/2
 		y := moveable.Bounds.Y + moveable.Bounds.Height/2 - float32(t.Texture.Height)/2
-		rl.DrawTexture(t.Texture, int32(x), int32(y), rl.White)
+		rl.DrawTexture(t.Texture, int32(x), int32(y), tint)
 	case *DrawableRenderTexture:
 		// drawBorder(hoverable, moveable)
 		// maybe shrink texture to fit inside border instead of drawing on top?
@@ -227,9 +315,64 @@ func (s *UIRenderSystem) draw(component interface{}, isDrawingChildren bool, off
 			rl.NewVector2(0, 0),
 			0,
 			rl.White)
+	case *DrawableChunks:
+		if drawable.DrawBackground {
+			drawBackground(hoverable, moveable)
+		}
+		t.Chunks.Draw(moveable.Bounds, tint)
+		if drawable.DrawBorder {
+			drawBorder(hoverable, moveable)
+		}
 	default:
 		panic("Drawable not supported")
 	}
+
+	if hoverable.SelectedLeft || hoverable.SelectedRight {
+		s.drawButtonMarkers(hoverable, moveable.Bounds)
+	}
+}
+
+// drawButtonMarkers draws L and R in the bottom corners of entities which are
+// selected for the left or right mouse button. The top left corner of colors
+// is used by the current color indicator
+func (s *UIRenderSystem) drawButtonMarkers(hoverable *Hoverable, bounds rl.Rectangle) {
+	size := UIFontSize * 0.6
+	y := bounds.Y + bounds.Height - size
+	draw := func(label string, x float32) {
+		rl.DrawRectangleRec(rl.NewRectangle(x, y, size, size), rl.NewColor(0, 0, 0, 255*0.8))
+		measured := rl.MeasureTextEx(Font, label, size, 1)
+		rl.DrawTextEx(Font, label, rl.NewVector2(x+size/2-measured.X/2, y+size/2-measured.Y/2), size, 1, rl.White)
+	}
+	if hoverable.SelectedLeft {
+		draw("L", bounds.X)
+	}
+	if hoverable.SelectedRight {
+		draw("R", bounds.X+bounds.Width-size)
+	}
+}
+
+// drawTweened draws the entity to a texture first, so that it can be drawn
+// faded and offset by its tween
+func (s *UIRenderSystem) drawTweened(result *QueryResult, t *Tweenable) {
+	w, h := int32(rl.GetScreenWidth()), int32(rl.GetScreenHeight())
+	if s.tweenTexture.Texture.Width != w || s.tweenTexture.Texture.Height != h {
+		if s.tweenTexture.ID != 0 {
+			rl.UnloadRenderTexture(s.tweenTexture)
+		}
+		s.tweenTexture = rl.LoadRenderTexture(w, h)
+	}
+
+	s.tweening = true
+	rl.BeginTextureMode(s.tweenTexture)
+	rl.ClearBackground(rl.Blank)
+	s.draw(result, false, rl.Vector2{})
+	rl.EndTextureMode()
+	s.tweening = false
+
+	rl.DrawTextureRec(s.tweenTexture.Texture,
+		rl.NewRectangle(0, 0, float32(w), -float32(h)),
+		t.Offset(),
+		rl.Fade(rl.White, t.Alpha()))
 }
 
 // Update updates the system
@@ -239,8 +382,20 @@ func (s *UIRenderSystem) Update(dt float32) {
 // Draw draws the system
 func (s *UIRenderSystem) Draw() {
 	// Draw everything and update the UI ECS' values
-	results := s.Scene.QueryTag(s.Scene.Tags["basic"], s.Scene.Tags["interactable"], s.Scene.Tags["scrollable"])
+	results := UISortByZIndex(s.Scene.QueryTag(s.Scene.Tags["basic"], s.Scene.Tags["interactable"], s.Scene.Tags["scrollable"], s.Scene.Tags["tweenable"]))
 	for _, result := range results {
+		if d, ok := result.Components[s.Scene.ComponentsMap["drawable"]].(*Drawable); ok && !d.IsChild && !d.Hidden {
+			if t, ok := result.Components[s.Scene.ComponentsMap["tweenable"]].(*Tweenable); ok && t.Animating() {
+				if t.Step(rl.GetFrameTime()) {
+					result.Entity.Hide()
+					continue
+				}
+				if t.Alpha() < 1 {
+					s.drawTweened(result, t)
+					continue
+				}
+			}
+		}
 		s.draw(result, false, rl.Vector2{})
 	}
 
@@ -250,6 +405,46 @@ func (s *UIRenderSystem) Draw() {
 		fn()
 	default:
 	}
+
+	// Timelapse recording indicator
+	if CurrentFile.Timelapse != nil && CurrentFile.Timelapse.Recording {
+		x := float32(rl.GetScreenWidth()) - UIFontSize*4
+		rl.DrawCircle(int32(x), int32(UIFontSize), UIFontSize/3, rl.Red)
+		rl.DrawTextEx(Font, fmt.Sprintf("%d", len(CurrentFile.Timelapse.Frames)), rl.NewVector2(x+UIFontSize/2, UIFontSize/2), UIFontSize, 1, rl.Red)
+	}
+	// The canvas isn't showing the real colors
+	if CurrentViewFilter != ViewFilterNone {
+		label := Tf("view: %s", CurrentViewFilter)
+		size := rl.MeasureTextEx(Font, label, UIFontSize, 1)
+		rl.DrawTextEx(Font, label, rl.NewVector2(float32(rl.GetScreenWidth())-size.X-UIFontSize, UIFontSize*2.5), UIFontSize, 1, rl.Yellow)
+	}
+	// Modes which change how the canvas behaves
+	modeY := UIFontSize * 3.5
+	for _, mode := range []struct {
+		on    bool
+		label string
+	}{
+		{SeamCheck, T("seam check")},
+		{ExportPreview, T("export preview")},
+		{PrecisionMode, T("precision mode")},
+	} {
+		if !mode.on {
+			continue
+		}
+		size := rl.MeasureTextEx(Font, mode.label, UIFontSize, 1)
+		rl.DrawTextEx(Font, mode.label, rl.NewVector2(float32(rl.GetScreenWidth())-size.X-UIFontSize, modeY), UIFontSize, 1, rl.Yellow)
+		modeY += UIFontSize
+	}
+	// Collaboration session status
+	if Collab != nil {
+		size := rl.MeasureTextEx(Font, Collab.Status, UIFontSize, 1)
+		x := float32(rl.GetScreenWidth()) - UIFontSize*5 - size.X
+		rl.DrawTextEx(Font, Collab.Status, rl.NewVector2(x, UIFontSize/2), UIFontSize, 1, rl.SkyBlue)
+	}
+	TourUIDraw()
+	if ShowHelp {
+		HelpUIDraw()
+	}
 	CurrentColorToggleAddRemoveGraphic()
 
 	// Debug text
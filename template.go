@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// TemplateExt is the extension of template files
+const TemplateExt = ".pixt"
+
+// Template describes a new file. Templates are json files which are loaded
+// from ./res/templates and ~/pixelTemplates
+type Template struct {
+	Name                                             string
+	CanvasWidth, CanvasHeight, TileWidth, TileHeight int32
+	// Layers are the names of the layers, bottom first
+	Layers []string
+	Guides []Guide
+	// Palette is added to the palettes if there isn't one with the same name
+	Palette *Palette `json:",omitempty"`
+}
+
+// Guide is a line drawn over the canvas to help with layout. Position is the
+// pixel column (or row) which the line is drawn on the left (or top) of
+type Guide struct {
+	Vertical bool
+	Position int32
+}
+
+// LoadTemplates loads the included templates and the ones in ~/pixelTemplates,
+// sorted by name
+func LoadTemplates() []*Template {
+	templates := make([]*Template, 0)
+
+	entries, err := f.ReadDir("res/templates")
+	if err != nil {
+		log.Println(err)
+	}
+	for _, entry := range entries {
+		data, err := f.ReadFile(path.Join("res/templates", entry.Name()))
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if t, err := parseTemplate(entry.Name(), data); err == nil {
+			templates = append(templates, t)
+		} else {
+			log.Println(err)
+		}
+	}
+
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		userDir := path.Join(homeDir, "pixelTemplates")
+		// Not having any user templates is fine
+		userEntries, _ := ioutil.ReadDir(userDir)
+		for _, entry := range userEntries {
+			data, err := ioutil.ReadFile(path.Join(userDir, entry.Name()))
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if t, err := parseTemplate(entry.Name(), data); err == nil {
+				templates = append(templates, t)
+			} else {
+				log.Println(err)
+			}
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates
+}
+
+// parseTemplate parses a template file, the file name is used if the template
+// doesn't have a name
+func parseTemplate(fileName string, data []byte) (*Template, error) {
+	if path.Ext(fileName) != TemplateExt {
+		return nil, fmt.Errorf("Can't load template \"%s\": extension not supported", fileName)
+	}
+
+	t := &Template{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("Couldn't load template %s: %v", fileName, err)
+	}
+	if t.Name == "" {
+		t.Name = fileName[:len(fileName)-len(TemplateExt)]
+	}
+	if t.CanvasWidth <= 0 || t.CanvasHeight <= 0 || t.TileWidth <= 0 || t.TileHeight <= 0 {
+		return nil, fmt.Errorf("Couldn't load template %s: canvas and tile sizes must be set", fileName)
+	}
+	return t, nil
+}
+
+// NewFileFromTemplate returns a new file with the template's sizes, layers,
+// guides and palette
+func NewFileFromTemplate(t *Template) *File {
+	f := NewFile(t.CanvasWidth, t.CanvasHeight, t.TileWidth, t.TileHeight)
+	f.Filename = t.Name
+
+	if len(t.Layers) > 0 {
+		for _, layer := range f.Layers {
+			layer.Unload()
+		}
+		f.Layers = make([]*Layer, 0, len(t.Layers))
+		for _, name := range t.Layers {
+			f.Layers = append(f.Layers, NewLayer(t.CanvasWidth, t.CanvasHeight, name, rl.Blank, true))
+		}
+	}
+
+	f.Guides = append([]Guide{}, t.Guides...)
+
+	if t.Palette != nil {
+		f.CurrentPalette = -1
+		for i, palette := range Settings.PaletteData {
+			if palette.Name == t.Palette.Name {
+				f.CurrentPalette = int32(i)
+				break
+			}
+		}
+		if f.CurrentPalette < 0 {
+			palette := Palette{Name: t.Palette.Name, Strings: t.Palette.Strings}
+			for _, hex := range palette.Strings {
+				if color, err := HexToColor(hex); err == nil {
+					palette.data = append(palette.data, color)
+				}
+			}
+			Settings.PaletteData = append(Settings.PaletteData, palette)
+			f.CurrentPalette = int32(len(Settings.PaletteData) - 1)
+			if err := SaveSettings(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	return f
+}
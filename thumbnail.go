@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+)
+
+// RunThumbnailer is the thumb subcommand, it writes a png thumbnail of a .pix
+// or .png file without opening a window. The usage is
+// "pixel thumb in.pix out.png --size 128"
+func RunThumbnailer(args []string) error {
+	flags := flag.NewFlagSet("thumb", flag.ContinueOnError)
+	size := flags.Int("size", 128, "the largest side of the thumbnail in pixels")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s thumb in.pix out.png [--size 128]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	// Flags can come after the paths
+	paths := make([]string, 0, 2)
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		paths = append(paths, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(paths) != 2 {
+		flags.Usage()
+		return fmt.Errorf("Expected an input and an output path, got %d paths", len(paths))
+	}
+	if *size < 1 {
+		return fmt.Errorf("Size must be at least 1, got %d", *size)
+	}
+
+	// The blending setting changes how layers are flattened
+	if err := LoadSettings(); err != nil {
+		log.Println(err)
+	}
+
+	thumb, err := Thumbnail(paths[0], *size)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(paths[1])
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return png.Encode(out, thumb)
+}
+
+// Thumbnail flattens the file at path and scales it so that its largest side
+// is size. Images smaller than size are scaled up by a whole number so the
+// pixels stay sharp, larger ones are averaged down
+func Thumbnail(path string, size int) (*image.NRGBA, error) {
+	img, err := loadFlattenedImage(path)
+	if err != nil {
+		return nil, err
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("Can't make a thumbnail of \"%s\": the image is empty", path)
+	}
+	largest := w
+	if h > largest {
+		largest = h
+	}
+
+	if largest <= size {
+		scale := size / largest
+		thumb := image.NewNRGBA(image.Rect(0, 0, w*scale, h*scale))
+		for y := 0; y < h*scale; y++ {
+			for x := 0; x < w*scale; x++ {
+				thumb.SetNRGBA(x, y, img.NRGBAAt(x/scale, y/scale))
+			}
+		}
+		return thumb, nil
+	}
+
+	tw, th := w*size/largest, h*size/largest
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+	thumb := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		for tx := 0; tx < tw; tx++ {
+			// Average the pixels which this one covers, weighted by alpha
+			// so that transparent pixels don't darken the edges
+			var r, g, b, a, count int
+			for y := ty * h / th; y < (ty+1)*h/th; y++ {
+				for x := tx * w / tw; x < (tx+1)*w/tw; x++ {
+					c := img.NRGBAAt(x, y)
+					r += int(c.R) * int(c.A)
+					g += int(c.G) * int(c.A)
+					b += int(c.B) * int(c.A)
+					a += int(c.A)
+					count++
+				}
+			}
+			if a == 0 || count == 0 {
+				continue
+			}
+			thumb.SetNRGBA(tx, ty, color.NRGBA{uint8(r / a), uint8(g / a), uint8(b / a), uint8(a / count)})
+		}
+	}
+	return thumb, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// DefaultColorTileSize is the width and height of the hardware tiles which
+// the tile color limit applies to, unless the file sets its own
+const DefaultColorTileSize = 8
+
+// TileColorCount is how many colors the tile at Tile, its top left, has
+type TileColorCount struct {
+	Tile   IntVec2
+	Colors int32
+}
+
+// tileColorsState is what the tiles over the limit were found for, they're
+// all found again when it changes. Otherwise only the changed tiles are
+// counted again, see TakeDirtyColorTiles
+type tileColorsState struct {
+	file                      *File
+	max                       int32
+	canvasWidth, canvasHeight int32
+	tileWidth, tileHeight     int32
+}
+
+var (
+	tileColorsLast tileColorsState
+	// tileColorsOverLimit maps the top left of each tile over the limit to
+	// how many colors it has
+	tileColorsOverLimit map[IntVec2]int32
+)
+
+// SetMaxTileColors sets how many colors each tile can have, 0 removes the
+// limit
+func (f *File) SetMaxTileColors(max int32) {
+	if max == f.MaxTileColors {
+		return
+	}
+	f.MaxTileColors = max
+	f.FileChanged = true
+}
+
+// ColorTileSize returns the size of the tiles which MaxTileColors applies to
+func (f *File) ColorTileSize() (width, height int32) {
+	width, height = f.ColorTileWidth, f.ColorTileHeight
+	if width <= 0 || height <= 0 {
+		return DefaultColorTileSize, DefaultColorTileSize
+	}
+	return width, height
+}
+
+// SetColorTileSize sets the size of the tiles which MaxTileColors applies to
+func (f *File) SetColorTileSize(width, height int32) {
+	if w, h := f.ColorTileSize(); w == width && h == height {
+		return
+	}
+	f.ColorTileWidth, f.ColorTileHeight = width, height
+	f.FileChanged = true
+}
+
+// TilesOverColorLimit returns the color tiles with more visible colors than
+// MaxTileColors. Transparent pixels don't count as a color
+func (f *File) TilesOverColorLimit() []TileColorCount {
+	tiles := make([]TileColorCount, 0)
+	if f.MaxTileColors <= 0 {
+		return tiles
+	}
+	tileWidth, tileHeight := f.ColorTileSize()
+	for ty := int32(0); ty < f.CanvasHeight; ty += tileHeight {
+		for tx := int32(0); tx < f.CanvasWidth; tx += tileWidth {
+			if colors := f.tileColors(IntVec2{tx, ty}); colors > f.MaxTileColors {
+				tiles = append(tiles, TileColorCount{IntVec2{tx, ty}, colors})
+			}
+		}
+	}
+	return tiles
+}
+
+// tileColors returns how many visible colors the color tile at tile, its top
+// left, has
+func (f *File) tileColors(tile IntVec2) int32 {
+	tileWidth, tileHeight := f.ColorTileSize()
+	colors := make(map[rl.Color]struct{})
+	for y := MaxInt32(0, tile.Y); y < tile.Y+tileHeight && y < f.CanvasHeight; y++ {
+		for x := MaxInt32(0, tile.X); x < tile.X+tileWidth && x < f.CanvasWidth; x++ {
+			if color := f.CompositePixel(IntVec2{x, y}); color.A > 0 {
+				colors[color] = struct{}{}
+			}
+		}
+	}
+	return int32(len(colors))
+}
+
+// DrawTileColorWarnings highlights the tiles of the current file with too
+// many colors, it's drawn in the canvas' camera. Only the tiles which changed
+// are counted again, so strokes don't count the whole canvas every frame
+func DrawTileColorWarnings() {
+	dirty, all := CurrentFile.TakeDirtyColorTiles()
+	if CurrentFile.MaxTileColors <= 0 {
+		tileColorsLast = tileColorsState{}
+		return
+	}
+
+	state := tileColorsState{
+		file:         CurrentFile,
+		max:          CurrentFile.MaxTileColors,
+		canvasWidth:  CurrentFile.CanvasWidth,
+		canvasHeight: CurrentFile.CanvasHeight,
+	}
+	state.tileWidth, state.tileHeight = CurrentFile.ColorTileSize()
+	if state != tileColorsLast || all {
+		tileColorsLast = state
+		tileColorsOverLimit = make(map[IntVec2]int32)
+		for _, count := range CurrentFile.TilesOverColorLimit() {
+			tileColorsOverLimit[count.Tile] = count.Colors
+		}
+	} else {
+		for tile := range dirty {
+			loc := IntVec2{tile.X * state.tileWidth, tile.Y * state.tileHeight}
+			if colors := CurrentFile.tileColors(loc); colors > state.max {
+				tileColorsOverLimit[loc] = colors
+			} else {
+				delete(tileColorsOverLimit, loc)
+			}
+		}
+	}
+
+	for tile := range tileColorsOverLimit {
+		bounds := rl.NewRectangle(
+			float32(-CurrentFile.CanvasWidth/2+tile.X),
+			float32(-CurrentFile.CanvasHeight/2+tile.Y),
+			float32(MinInt32(state.tileWidth, CurrentFile.CanvasWidth-tile.X)),
+			float32(MinInt32(state.tileHeight, CurrentFile.CanvasHeight-tile.Y)))
+		rl.DrawRectangleRec(bounds, rl.NewColor(230, 41, 55, 48))
+		rl.DrawRectangleLinesEx(bounds, 1, rl.Red)
+	}
+}
+
+// ShowTileColorReport lists the tiles of the current file with too many
+// colors, by frame number and position
+func ShowTileColorReport() {
+	if CurrentFile.MaxTileColors <= 0 {
+		UIWarning(T("Set a tile color limit from the palette menu first"))
+		return
+	}
+	tiles := CurrentFile.TilesOverColorLimit()
+	if len(tiles) == 0 {
+		UIInfo(T("Tile Colors"), Tf("Every tile has %d colors or fewer", CurrentFile.MaxTileColors))
+		return
+	}
+
+	var report strings.Builder
+	report.WriteString(Tf("%d tiles have more than %d colors:", len(tiles), CurrentFile.MaxTileColors))
+	for _, count := range tiles {
+		frame, _ := CurrentFile.frameOf(count.Tile)
+		report.WriteString("\n")
+		report.WriteString(fmt.Sprintf("%d (%d, %d): ", frame, count.Tile.X, count.Tile.Y))
+		report.WriteString(Tf("%d colors", count.Colors))
+	}
+	UIInfo(T("Tile Colors"), report.String())
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultTimelapseInterval is used when Settings.TimelapseInterval isn't set
+const DefaultTimelapseInterval = 10
+
+// timelapseMinSize is the size which the longest side of exported frames is
+// scaled up to, so that small canvases aren't tiny in the video
+const timelapseMinSize = 512
+
+// Timelapse records snapshots of the flattened canvas while drawing
+type Timelapse struct {
+	Recording bool
+	Frames    []*image.NRGBA
+	// actions counts the history actions since the last snapshot
+	actions int32
+}
+
+// ToggleTimelapse starts or stops recording. Starting a new recording
+// discards the old frames
+func (f *File) ToggleTimelapse() {
+	if f.Timelapse != nil && f.Timelapse.Recording {
+		f.Timelapse.Recording = false
+		log.Println("Stopped timelapse,", len(f.Timelapse.Frames), "frames")
+		return
+	}
+
+	f.Timelapse = &Timelapse{Recording: true}
+	f.Timelapse.Frames = append(f.Timelapse.Frames, f.timelapseSnapshot())
+	log.Println("Recording timelapse")
+}
+
+// recordTimelapse takes a snapshot every Settings.TimelapseInterval history
+// actions. It's called when an action is added to the history, so the
+// snapshot shows the canvas before that action
+func (f *File) recordTimelapse() {
+	if f.Timelapse == nil || !f.Timelapse.Recording {
+		return
+	}
+
+	interval := Settings.TimelapseInterval
+	if interval <= 0 {
+		interval = DefaultTimelapseInterval
+	}
+	f.Timelapse.actions++
+	if f.Timelapse.actions >= interval {
+		f.Timelapse.actions = 0
+		f.Timelapse.Frames = append(f.Timelapse.Frames, f.timelapseSnapshot())
+	}
+}
+
+// timelapseSnapshot returns the flattened canvas
+func (f *File) timelapseSnapshot() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, int(f.CanvasWidth), int(f.CanvasHeight)))
+	for x := int32(0); x < f.CanvasWidth; x++ {
+		for y := int32(0); y < f.CanvasHeight; y++ {
+			c := f.CompositePixel(IntVec2{x, y})
+			img.SetNRGBA(int(x), int(y), color.NRGBA{c.R, c.G, c.B, c.A})
+		}
+	}
+	return img
+}
+
+// timelapseFrames returns the recorded frames followed by the current canvas.
+// The frames are padded to the same size, since the canvas could have been
+// resized, and scaled up to timelapseMinSize
+func (f *File) timelapseFrames() []*image.NRGBA {
+	frames := append(append([]*image.NRGBA{}, f.Timelapse.Frames...), f.timelapseSnapshot())
+
+	var w, h int
+	for _, frame := range frames {
+		if frame.Rect.Dx() > w {
+			w = frame.Rect.Dx()
+		}
+		if frame.Rect.Dy() > h {
+			h = frame.Rect.Dy()
+		}
+	}
+	scale := 1
+	if w > 0 && h > 0 {
+		longest := w
+		if h > longest {
+			longest = h
+		}
+		if longest < timelapseMinSize {
+			scale = timelapseMinSize / longest
+		}
+	}
+
+	scaled := make([]*image.NRGBA, len(frames))
+	for i, frame := range frames {
+		dst := image.NewNRGBA(image.Rect(0, 0, w*scale, h*scale))
+		for y := 0; y < frame.Rect.Dy()*scale; y++ {
+			for x := 0; x < frame.Rect.Dx()*scale; x++ {
+				dst.SetNRGBA(x, y, frame.NRGBAAt(x/scale, y/scale))
+			}
+		}
+		scaled[i] = dst
+	}
+	return scaled
+}
+
+// ExportTimelapse exports the recording. The format depends on the extension,
+// ".gif" is an animated gif, ".png" writes numbered pngs next to path and
+// ".mp4" uses ffmpeg, which has to be installed
+func (f *File) ExportTimelapse(path string) error {
+	if f.Timelapse == nil || len(f.Timelapse.Frames) == 0 {
+		return fmt.Errorf("Nothing has been recorded")
+	}
+
+	frames := f.timelapseFrames()
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".gif":
+		return exportTimelapseGIF(path, frames)
+	case ".png":
+		_, err := exportTimelapsePNGs(strings.TrimSuffix(path, filepath.Ext(path))+"_", frames)
+		return err
+	case ".mp4":
+		return exportTimelapseMP4(path, frames)
+	}
+	return fmt.Errorf("Can't export timelapse: extension \"%s\" not supported", ext)
+}
+
+func exportTimelapseGIF(path string, frames []*image.NRGBA) error {
+	anim := &gif.GIF{}
+	for i, frame := range frames {
+		paletted := image.NewPaletted(frame.Rect, timelapsePalette(frame))
+		draw.Draw(paletted, frame.Rect, frame, image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, paletted)
+
+		delay := 10
+		if i == len(frames)-1 {
+			// Stay on the finished image for a bit
+			delay = 200
+		}
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return gif.EncodeAll(file, anim)
+}
+
+// timelapsePalette returns the colors used by the frame, with transparent
+// first. If there are too many colors for a gif then the web safe palette is
+// used instead
+func timelapsePalette(frame image.Image) color.Palette {
+	p := color.Palette{color.NRGBA{}}
+	seen := map[color.NRGBA]struct{}{{}: {}}
+	b := frame.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(frame.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+			if _, ok := seen[c]; !ok {
+				if len(p) == 256 {
+					return append(color.Palette{color.NRGBA{}}, palette.WebSafe...)
+				}
+				seen[c] = struct{}{}
+				p = append(p, c)
+			}
+		}
+	}
+	return p
+}
+
+// exportTimelapsePNGs writes each frame as prefix00001.png, prefix00002.png
+// etc and returns the ffmpeg style pattern of the file names
+func exportTimelapsePNGs(prefix string, frames []*image.NRGBA) (string, error) {
+	for i, frame := range frames {
+		file, err := os.Create(fmt.Sprintf("%s%05d.png", prefix, i+1))
+		if err != nil {
+			return "", err
+		}
+		err = png.Encode(file, frame)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return prefix + "%05d.png", nil
+}
+
+func exportTimelapseMP4(path string, frames []*image.NRGBA) error {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return fmt.Errorf("Can't export mp4: ffmpeg wasn't found")
+	}
+
+	dir, err := ioutil.TempDir("", "pixel-timelapse")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	pattern, err := exportTimelapsePNGs(filepath.Join(dir, "frame_"), frames)
+	if err != nil {
+		return err
+	}
+
+	// yuv420p needs even dimensions
+	cmd := exec.Command(ffmpeg, "-y", "-framerate", "10", "-i", pattern,
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2", "-pix_fmt", "yuv420p", path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Can't export mp4: %v\n%s", err, out)
+	}
+	return nil
+}
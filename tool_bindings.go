@@ -0,0 +1,72 @@
+package main
+
+import (
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// momentaryToolDelay is how long a tool's binding has to be held for the tool
+// to only be used until it's released. Tapping the binding keeps the tool
+const momentaryToolDelay = 300 * time.Millisecond
+
+// heldTool is the tool picked by a binding which is still held, with the tool
+// which was used before it
+type heldTool struct {
+	binding     string
+	pressedAt   time.Time
+	left, right Tool
+	entity      *Entity
+}
+
+var momentaryTool *heldTool
+
+// toolBindings returns the button of the tool picked by each binding
+func toolBindings() map[string]*Entity {
+	return map[string]*Entity{
+		"pixelBrush":   toolPencil,
+		"eraser":       toolEraser,
+		"fill":         toolFill,
+		"picker":       toolPicker,
+		"selector":     toolSelector,
+		"scatterBrush": toolScatter,
+		"curve":        toolCurve,
+		"warp":         toolWarp,
+		"nineSlice":    toolNineSlice,
+	}
+}
+
+// PickToolFromBinding picks the binding's tool, returns false if it doesn't
+// pick a tool. If the binding is held for longer than momentaryToolDelay, the
+// previous tool is picked again when it's released
+func PickToolFromBinding(binding string) bool {
+	entity, ok := toolBindings()[binding]
+	if !ok || entity == nil {
+		return false
+	}
+	held := &heldTool{binding, time.Now(), LeftTool, RightTool, toolsUICurrent}
+	// Simulate click event
+	if interactable, ok := entity.GetInteractable(); ok {
+		interactable.OnMouseUp(entity, rl.MouseRightButton)
+	}
+	momentaryTool = held
+	return true
+}
+
+// UpdateMomentaryTool picks the previous tool again once a tool's binding
+// which was held is released. Strokes are finished with the held tool first
+func UpdateMomentaryTool() {
+	if momentaryTool == nil || isBindingDown(momentaryTool.binding) {
+		return
+	}
+	if !CurrentFile.HasDoneMouseUpLeft || !CurrentFile.HasDoneMouseUpRight {
+		return
+	}
+	held := momentaryTool
+	momentaryTool = nil
+	if time.Since(held.pressedAt) < momentaryToolDelay || held.entity == nil {
+		return
+	}
+	LeftTool, RightTool = held.left, held.right
+	ToolsUISetCurrentToolSelected(held.entity)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// CurveStage is the point of the curve being placed
+type CurveStage int32
+
+// CurveStage
+const (
+	CurveStageLine CurveStage = iota
+	CurveStageFirstControl
+	CurveStageSecondControl
+)
+
+// CurveTool draws a cubic bezier curve. The line is dragged out first, then
+// each control point is placed with a click. The curve is drawn once the last
+// control point is placed, or when confirm is pressed
+type CurveTool struct {
+	name     string
+	stage    CurveStage
+	dragging bool
+	// start, first control, second control, end
+	points       [4]IntVec2
+	currentColor rl.Color
+}
+
+// NewCurveTool returns the curve tool. Requires a name.
+func NewCurveTool(name string) *CurveTool {
+	return &CurveTool{
+		name: name,
+	}
+}
+
+// Active returns true if a curve is being placed
+func (t *CurveTool) Active() bool {
+	return t.dragging || t.stage > CurveStageLine
+}
+
+// Cancel throws away the curve being placed
+func (t *CurveTool) Cancel() {
+	t.stage = CurveStageLine
+	t.dragging = false
+}
+
+// Commit draws the curve to the current layer as a single history action
+func (t *CurveTool) Commit() {
+	if !t.Active() {
+		return
+	}
+
+	CurrentFile.AppendHistory(NewHistoryPixel(CurrentFile.CurrentLayer))
+	for _, p := range t.rasterize(t.points) {
+		CurrentFile.DrawPixel(p.X, p.Y, t.currentColor, CurrentFile.GetCurrentLayer())
+	}
+	t.Cancel()
+}
+
+// previewPoints returns the points with the control point being placed moved
+// to the cursor
+func (t *CurveTool) previewPoints(x, y int32) [4]IntVec2 {
+	points := t.points
+	switch t.stage {
+	case CurveStageFirstControl:
+		points[1] = IntVec2{x, y}
+		points[2] = IntVec2{x, y}
+	case CurveStageSecondControl:
+		points[2] = IntVec2{x, y}
+	}
+	return points
+}
+
+// rasterize returns the pixels of the curve, each pixel only once
+func (t *CurveTool) rasterize(points [4]IntVec2) []IntVec2 {
+	// Length of the control polygon is always longer than the curve
+	var length float64
+	for i := 1; i < len(points); i++ {
+		dx := float64(points[i].X - points[i-1].X)
+		dy := float64(points[i].Y - points[i-1].Y)
+		length += math.Sqrt(dx*dx + dy*dy)
+	}
+	steps := int(length) + 1
+
+	path := make([]IntVec2, 0, steps)
+	last := points[0]
+	path = append(path, last)
+	for i := 1; i <= steps; i++ {
+		s := float64(i) / float64(steps)
+		a := (1 - s) * (1 - s) * (1 - s)
+		b := 3 * (1 - s) * (1 - s) * s
+		c := 3 * (1 - s) * s * s
+		d := s * s * s
+		next := IntVec2{
+			int32(math.Round(a*float64(points[0].X) + b*float64(points[1].X) + c*float64(points[2].X) + d*float64(points[3].X))),
+			int32(math.Round(a*float64(points[0].Y) + b*float64(points[1].Y) + c*float64(points[2].Y) + d*float64(points[3].Y))),
+		}
+		// Samples can skip pixels, join them up
+		Line(last.X, last.Y, next.X, next.Y, func(x, y int32) {
+			if p := (IntVec2{x, y}); p != path[len(path)-1] {
+				path = append(path, p)
+			}
+		})
+		last = next
+	}
+
+	// The curve can cross itself, which would stack the opacity
+	drawn := make(map[IntVec2]bool)
+	out := make([]IntVec2, 0, len(path))
+	for _, p := range PixelPerfect(path) {
+		if !drawn[p] {
+			drawn[p] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// MouseDown is for mouse down events
+func (t *CurveTool) MouseDown(x, y int32, button MouseButton) {
+	switch t.stage {
+	case CurveStageLine:
+		if !t.dragging {
+			t.dragging = true
+			t.points[0] = IntVec2{x, y}
+			switch button {
+			case rl.MouseLeftButton:
+				t.currentColor = LeftColor
+			case rl.MouseRightButton:
+				t.currentColor = RightColor
+			}
+		}
+		t.points[3] = IntVec2{x, y}
+		t.points[1] = t.points[0]
+		t.points[2] = t.points[3]
+	case CurveStageFirstControl:
+		t.points[1] = IntVec2{x, y}
+		t.points[2] = IntVec2{x, y}
+	case CurveStageSecondControl:
+		t.points[2] = IntVec2{x, y}
+	}
+}
+
+// MouseUp is for mouse up events
+func (t *CurveTool) MouseUp(x, y int32, button MouseButton) {
+	switch t.stage {
+	case CurveStageLine:
+		t.dragging = false
+		t.stage = CurveStageFirstControl
+	case CurveStageFirstControl:
+		t.stage = CurveStageSecondControl
+	case CurveStageSecondControl:
+		t.Commit()
+	}
+}
+
+// DrawPreview is for drawing the preview
+func (t *CurveTool) DrawPreview(x, y int32) {
+	rl.ClearBackground(rl.Blank)
+
+	if !t.Active() {
+		rl.DrawPixel(x, y, rl.NewColor(255, 255, 255, 192))
+		return
+	}
+
+	layer := CurrentFile.GetCurrentLayer()
+	for _, p := range t.rasterize(t.previewPoints(x, y)) {
+		rl.DrawPixel(p.X, p.Y, CurrentFile.PreviewPixel(p, t.currentColor, layer))
+	}
+}
+
+// DrawUI draws the control point handles
+func (t *CurveTool) DrawUI(camera rl.Camera2D) {
+	if !t.Active() {
+		return
+	}
+
+	loc := ScreenToPixel(rl.GetMousePosition(), camera)
+	points := t.previewPoints(loc.X, loc.Y)
+
+	p := PixelScreenSize(camera)
+	center := func(v IntVec2) rl.Vector2 {
+		pos := PixelToScreen(v.X, v.Y, camera)
+		return rl.NewVector2(pos.X+p.X/2, pos.Y+p.Y/2)
+	}
+
+	rl.DrawLineV(center(points[0]), center(points[1]), rl.White)
+	rl.DrawLineV(center(points[3]), center(points[2]), rl.White)
+	for _, v := range points {
+		c := center(v)
+		rl.DrawRectangleLines(int32(c.X)-3, int32(c.Y)-3, 7, 7, rl.Black)
+		rl.DrawRectangleLines(int32(c.X)-2, int32(c.Y)-2, 5, 5, rl.White)
+	}
+}
+
+func (t *CurveTool) String() string {
+	return t.name
+}
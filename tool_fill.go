@@ -4,6 +4,28 @@ import (
 	rl "github.com/gen2brain/raylib-go/raylib"
 )
 
+// fillCursorTexture is loaded the first time the fill cursor is drawn
+var fillCursorTexture rl.Texture2D
+
+// FillMode defines what the fill tool fills with
+type FillMode int32
+
+// FillMode
+const (
+	FillModeColor FillMode = iota
+	// FillModePattern tiles CopiedSelection across the filled area
+	FillModePattern
+)
+
+// FillPatternAlign defines where the pattern starts tiling from
+type FillPatternAlign int32
+
+// FillPatternAlign
+const (
+	FillPatternAlignOrigin FillPatternAlign = iota
+	FillPatternAlignClick
+)
+
 // FillTool fills an area of the same colored pixels
 type FillTool struct {
 	lastPos IntVec2
@@ -18,6 +40,38 @@ func NewFillTool(name string) *FillTool {
 	}
 }
 
+// GetMode returns what the tool fills with
+func (t *FillTool) GetMode() FillMode {
+	return GlobalFillMode
+}
+
+// SetMode sets what the tool fills with
+func (t *FillTool) SetMode(mode FillMode) {
+	GlobalFillMode = mode
+}
+
+// GetContiguous returns true if only the pixels connected to the clicked one
+// are filled, rather than every pixel of its color
+func (t *FillTool) GetContiguous() bool {
+	return GlobalFillContiguous
+}
+
+// SetContiguous sets if only the pixels connected to the clicked one are
+// filled
+func (t *FillTool) SetContiguous(contiguous bool) {
+	GlobalFillContiguous = contiguous
+}
+
+// GetPatternAlign returns where the pattern is aligned to
+func (t *FillTool) GetPatternAlign() FillPatternAlign {
+	return GlobalFillPatternAlign
+}
+
+// SetPatternAlign sets where the pattern is aligned to
+func (t *FillTool) SetPatternAlign(align FillPatternAlign) {
+	GlobalFillPatternAlign = align
+}
+
 // MouseDown is for mouse down events
 func (t *FillTool) MouseDown(x, y int32, button MouseButton) {
 }
@@ -35,43 +89,29 @@ func (t *FillTool) MouseUp(x, y int32, button MouseButton) {
 	pd := CurrentFile.GetCurrentLayer().PixelData
 	clickedColor := pd[IntVec2{x, y}]
 
-	var recFill func(rx, ry int32)
-	recFill = func(rx, ry int32) {
-		if pd[IntVec2{rx, ry}] == clickedColor && color != clickedColor {
-			// Set color
-			oldColor := pd[IntVec2{rx, ry}]
-			// pd[IntVec2{rx, ry}] = color
-			CurrentFile.DrawPixel(rx, ry, color, CurrentFile.GetCurrentLayer())
-
-			// Append history
-			if oldColor != color {
-				latestHistoryInterface := CurrentFile.History[len(CurrentFile.History)-1]
-				latestHistory, ok := latestHistoryInterface.(HistoryPixel)
-				if ok {
-					ps := latestHistory.PixelState[IntVec2{rx, ry}]
-					ps.Current = color
-					ps.Prev = oldColor
-					latestHistory.PixelState[IntVec2{rx, ry}] = ps
-				}
-			}
-
-			if rx+1 < CurrentFile.CanvasWidth {
-				recFill(rx+1, ry)
-			}
-			if rx-1 >= 0 {
-				recFill(rx-1, ry)
-			}
-			if ry+1 < CurrentFile.CanvasHeight {
-				recFill(rx, ry+1)
-			}
-			if ry-1 >= 0 {
-				recFill(rx, ry-1)
-			}
+	// Pattern fill samples the copied selection, wrapping around its bounds.
+	// The fill finishes later, so copying again doesn't change it
+	copied := CopiedSelection
+	pattern := GlobalFillMode == FillModePattern && len(copied) > 0
+	b := CopiedSelectionBounds
+	pw, ph := b[2]-b[0]+1, b[3]-b[1]+1
+	var ax, ay int32
+	if GlobalFillPatternAlign == FillPatternAlignClick {
+		ax, ay = x, y
+	}
+	colorAt := func(rx, ry int32) rl.Color {
+		if !pattern {
+			return color
 		}
+		px := ((rx-ax)%pw + pw) % pw
+		py := ((ry-ay)%ph + ph) % ph
+		return copied[IntVec2{b[0] + px, b[1] + py}]
 	}
-	recFill(x, y)
-	// CurrentFile.GetCurrentLayer().Redraw()
-	// CurrentFile.RedrawRenderLayer()
+	if !pattern && color == clickedColor {
+		return
+	}
+
+	StartFill(CurrentFile, CurrentFile.GetCurrentLayer(), x, y, GlobalFillContiguous, colorAt)
 }
 
 // DrawPreview is for drawing the preview
@@ -92,6 +132,19 @@ func (t *FillTool) DrawUI(camera rl.Camera2D) {
 
 }
 
+// DrawCursor draws the bucket icon next to the highlighted pixel
+func (t *FillTool) DrawCursor(camera rl.Camera2D) {
+	if fillCursorTexture.ID == 0 {
+		fillCursorTexture = rl.LoadTexture(GetFile("./res/icons/fill.png"))
+	}
+
+	mouse := rl.GetMousePosition()
+	loc := ScreenToPixel(mouse, camera)
+	DrawPixelHighlight(loc.X, loc.Y, camera)
+	DrawCrosshair(mouse)
+	rl.DrawTexture(fillCursorTexture, int32(mouse.X)+8, int32(mouse.Y)+8, rl.White)
+}
+
 func (t *FillTool) String() string {
 	return t.name
 }
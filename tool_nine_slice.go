@@ -0,0 +1,105 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// NineSliceTool marks the nine-slice margins. Dragging over a tile marks the
+// middle part, everything around it in the tile is a margin. Right clicking
+// removes the margins
+type NineSliceTool struct {
+	name     string
+	dragging bool
+	first    IntVec2
+	last     IntVec2
+	hover    IntVec2
+}
+
+// NewNineSliceTool returns the nine-slice tool. Requires a name.
+func NewNineSliceTool(name string) *NineSliceTool {
+	return &NineSliceTool{
+		name: name,
+	}
+}
+
+// margins returns the margins of the dragged area, it's kept inside of the
+// tile where the drag started
+func (t *NineSliceTool) margins() NineSlice {
+	tile := GetTilePosition(t.first.X, t.first.Y)
+	clamp := func(v, min, max int32) int32 {
+		return MaxInt32(min, MinInt32(v, max))
+	}
+	maxX := tile.X + CurrentFile.TileWidth - 1
+	maxY := tile.Y + CurrentFile.TileHeight - 1
+	x0, x1 := clamp(t.first.X, tile.X, maxX), clamp(t.last.X, tile.X, maxX)
+	y0, y1 := clamp(t.first.Y, tile.Y, maxY), clamp(t.last.Y, tile.Y, maxY)
+	return NineSlice{
+		Left:   MinInt32(x0, x1) - tile.X,
+		Top:    MinInt32(y0, y1) - tile.Y,
+		Right:  maxX - MaxInt32(x0, x1),
+		Bottom: maxY - MaxInt32(y0, y1),
+	}
+}
+
+// MouseDown is for mouse down events
+func (t *NineSliceTool) MouseDown(x, y int32, button MouseButton) {
+	if button != rl.MouseLeftButton {
+		return
+	}
+	pos := GetClampedCoordinates(x, y)
+	if !t.dragging {
+		t.dragging = true
+		t.first = pos
+	}
+	t.last = pos
+}
+
+// MouseUp is for mouse up events
+func (t *NineSliceTool) MouseUp(x, y int32, button MouseButton) {
+	switch button {
+	case rl.MouseLeftButton:
+		if t.dragging {
+			CurrentFile.SetNineSlice(t.margins())
+			t.dragging = false
+		}
+	case rl.MouseRightButton:
+		CurrentFile.SetNineSlice(NineSlice{})
+	}
+	ToolsUISetCurrentToolSelected(toolNineSlice)
+}
+
+// DrawPreview is for drawing the preview
+func (t *NineSliceTool) DrawPreview(x, y int32) {
+	rl.ClearBackground(rl.Blank)
+	t.hover = GetClampedCoordinates(x, y)
+}
+
+// DrawUI draws the margins on the tile under the cursor, or the tile which is
+// being marked
+func (t *NineSliceTool) DrawUI(camera rl.Camera2D) {
+	margins := CurrentFile.NineSlice
+	tile := GetTilePosition(t.hover.X, t.hover.Y)
+	if t.dragging {
+		margins = t.margins()
+		tile = GetTilePosition(t.first.X, t.first.Y)
+	}
+
+	x0, y0 := tile.X, tile.Y
+	x1, y1 := tile.X+CurrentFile.TileWidth, tile.Y+CurrentFile.TileHeight
+	lines := [][2]IntVec2{
+		{{x0 + margins.Left, y0}, {x0 + margins.Left, y1}},
+		{{x1 - margins.Right, y0}, {x1 - margins.Right, y1}},
+		{{x0, y0 + margins.Top}, {x1, y0 + margins.Top}},
+		{{x0, y1 - margins.Bottom}, {x1, y1 - margins.Bottom}},
+	}
+	for _, line := range lines {
+		start := PixelToScreen(line[0].X, line[0].Y, camera)
+		end := PixelToScreen(line[1].X, line[1].Y, camera)
+		rl.DrawLineEx(start, end, 3, rl.Black)
+		rl.DrawLineEx(start, end, 1, rl.Magenta)
+	}
+}
+
+func (t *NineSliceTool) String() string {
+	return t.name
+}
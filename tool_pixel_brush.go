@@ -11,6 +11,7 @@ type BrushShape int32
 const (
 	BrushShapeSquare BrushShape = iota
 	BrushShapeCircle
+	BrushShapeImage // stamps GlobalBrushImage
 )
 
 // Vars
@@ -62,6 +63,16 @@ type PixelBrushTool struct {
 	shape                  BrushShape
 	// Don't draw over the same pixel multiple times, prevents opacity stacking
 	drawnPixels map[IntVec2]bool
+	// Where the image brush was last stamped, for spacing
+	lastStamp IntVec2
+	// The pencil and eraser have their own stabilizer settings, stabilized is
+	// the smoothed position in canvas pixels
+	stabilizer *Stabilizer
+	stabilized rl.Vector2
+	// lineDrag is true while a shift-click line is being dragged, the line is
+	// previewed until it's drawn on release at lineEnd
+	lineDrag bool
+	lineEnd  IntVec2
 
 	currentColor rl.Color
 	circles      []map[IntVec2]bool
@@ -108,9 +119,11 @@ func NewPixelBrushTool(name string, eraser bool) *PixelBrushTool {
 	if eraser {
 		t.size = GlobalEraserSize
 		t.shape = GlobalErasorShape
+		t.stabilizer = &GlobalEraserStabilizer
 	} else {
 		t.size = GlobalBrushSize
 		t.shape = GlobalBrushShape
+		t.stabilizer = &GlobalBrushStabilizer
 	}
 
 	return t
@@ -160,6 +173,18 @@ func (t *PixelBrushTool) SetShape(shape BrushShape) {
 	}
 }
 
+// GetStabilizer returns the tool's stabilizer settings
+func (t *PixelBrushTool) GetStabilizer() Stabilizer {
+	return *t.stabilizer
+}
+
+// SetStabilizer sets the tool's stabilizer settings
+func (t *PixelBrushTool) SetStabilizer(stabilizer Stabilizer) {
+	if stabilizer.Length >= 0 && stabilizer.Length <= maxStabilizerLength {
+		*t.stabilizer = stabilizer
+	}
+}
+
 // genFillShape d is the diamater/width
 func (t *PixelBrushTool) genFillShape(d int32, shape BrushShape) map[IntVec2]bool {
 	r := make(map[IntVec2]bool)
@@ -186,6 +211,14 @@ func (t *PixelBrushTool) genFillShape(d int32, shape BrushShape) map[IntVec2]boo
 				}
 			}
 		}
+	case BrushShapeImage:
+		if GlobalBrushImage == nil {
+			r[IntVec2{0, 0}] = true
+			break
+		}
+		for pos := range GlobalBrushImage.Pixels {
+			r[pos] = true
+		}
 	default:
 		panic("Shape not specified")
 	}
@@ -198,20 +231,61 @@ func (t *PixelBrushTool) drawPixel(x, y int32, color rl.Color, fileDraw bool) {
 	sh := t.genFillShape(t.size, t.shape)
 	for pos := range sh {
 		sx, sy := x+pos.X, y+pos.Y
-		if !t.exists(IntVec2{sx, sy}) {
+		c := color
+		// Opaque pixels of stamps replace what's there, so they can be drawn
+		// over each other without the opacity stacking
+		overwrite := false
+		if t.shape == BrushShapeImage && GlobalBrushImage != nil && !t.eraser {
+			c = GlobalBrushImage.Color(pos, color, GlobalBrushRecolor)
+			overwrite = c.A == 255
+		}
+		if !t.eraser && GlobalBrushOpacity < 100 {
+			c = brushOpacity(c)
+			overwrite = overwrite && c.A == 255
+		}
+		if overwrite || !t.exists(IntVec2{sx, sy}) {
 			if fileDraw {
-				CurrentFile.DrawPixel(sx, sy, color, CurrentFile.GetCurrentLayer())
+				CurrentFile.DrawPixel(sx, sy, c, CurrentFile.GetCurrentLayer())
 				t.drawnPixels[IntVec2{sx, sy}] = true
+			} else if t.eraser {
+				rl.DrawPixel(sx, sy, c)
 			} else {
-				rl.DrawPixel(sx, sy, color)
+				rl.DrawPixel(sx, sy, CurrentFile.PreviewPixel(IntVec2{sx, sy}, c, CurrentFile.GetCurrentLayer()))
 			}
 		}
 	}
 }
 
+// brushOpacity returns color with GlobalBrushOpacity multiplied into its
+// alpha. Each pixel is only drawn once per stroke so passes don't darken
+// where the stroke overlaps itself
+func brushOpacity(color rl.Color) rl.Color {
+	color.A = uint8(int32(color.A) * MaxInt32(0, GlobalBrushOpacity) / 100)
+	return color
+}
+
+// spaced returns true if the brush should be drawn at x, y. The image brush
+// is only stamped once it's GlobalBrushSpacing pixels from the last stamp
+func (t *PixelBrushTool) spaced(last *IntVec2, x, y int32) bool {
+	if t.shape != BrushShapeImage {
+		return true
+	}
+	if MaxInt32(AbsInt32(x-last.X), AbsInt32(y-last.Y)) < GlobalBrushSpacing {
+		return false
+	}
+	*last = IntVec2{x, y}
+	return true
+}
+
 func (t *PixelBrushTool) isLineModifierDown() bool {
-	for _, keys := range Settings.KeymapData["drawLine"] {
-		allDown := true
+	return isBindingDown("drawLine")
+}
+
+// isBindingDown returns true if every key of any of the named bindings is
+// held, for bindings which modify a drag
+func isBindingDown(name string) bool {
+	for _, keys := range Settings.KeymapData[name] {
+		allDown := len(keys) > 0
 		for _, key := range keys {
 			if !rl.IsKeyDown(int32(key)) {
 				allDown = false
@@ -238,44 +312,152 @@ func (t *PixelBrushTool) MouseDown(x, y int32, button MouseButton) {
 		}
 	}
 
-	if t.shouldConnectToLastPos || t.isLineModifierDown() {
-		Line(t.lastPos.X, t.lastPos.Y, x, y, func(x, y int32) {
-			// prevent drawing over the first pixel and stacking them, with color.A<255, opacity stacks 😠
-			if !(x == t.lastPos.X && y == t.lastPos.Y) {
-				t.drawPixel(x, y, t.currentColor, true)
-			}
-		})
-	} else {
+	// Shift-clicking drags a line from where the pencil or eraser last drew,
+	// it's drawn on release so that it can be lined up first
+	if !t.shouldConnectToLastPos {
+		t.lineDrag = t.isLineModifierDown() && CurrentFile.hasLastBrushPos
+	}
+	if t.lineDrag {
+		t.lineEnd = IntVec2{x, y}
 		t.shouldConnectToLastPos = true
+		return
+	}
+
+	// Straight lines aren't smoothed
+	if !t.shouldConnectToLastPos {
+		t.stabilized = rl.NewVector2(float32(x)+0.5, float32(y)+0.5)
+	}
+	if !t.isLineModifierDown() {
+		x, y = t.stabilizer.Pull(&t.stabilized, x, y)
+	}
+
+	if t.shouldConnectToLastPos {
+		t.drawLine(x, y)
+	} else {
+		t.lastStamp = IntVec2{x, y}
 		t.drawPixel(x, y, t.currentColor, true)
 	}
+	t.shouldConnectToLastPos = true
 	t.lastPos.X = x
 	t.lastPos.Y = y
+	CurrentFile.lastBrushPos = t.lastPos
+	CurrentFile.hasLastBrushPos = true
+}
+
+// drawLine draws from lastPos to x, y
+func (t *PixelBrushTool) drawLine(x, y int32) {
+	Line(t.lastPos.X, t.lastPos.Y, x, y, func(x, y int32) {
+		// prevent drawing over the first pixel and stacking them, with color.A<255, opacity stacks 😠
+		if !(x == t.lastPos.X && y == t.lastPos.Y) && t.spaced(&t.lastStamp, x, y) {
+			t.drawPixel(x, y, t.currentColor, true)
+		}
+	})
 }
 
 // MouseUp is for mouse up events
 func (t *PixelBrushTool) MouseUp(x, y int32, button MouseButton) {
+	// The dragged line is part of the same history entry as a normal stroke
+	if t.lineDrag {
+		t.lineDrag = false
+		t.lastPos = CurrentFile.lastBrushPos
+		t.lastStamp = t.lastPos
+		t.drawLine(t.lineEnd.X, t.lineEnd.Y)
+		t.lastPos = t.lineEnd
+		CurrentFile.lastBrushPos = t.lastPos
+	}
 	t.shouldConnectToLastPos = false
 	t.drawnPixels = make(map[IntVec2]bool)
 	// CurrentFile.GetCurrentLayer().Redraw()
 }
 
-// DrawPreview is for drawing the preview
+// previewingLine returns true if the line from the last drawn point is shown
+func (t *PixelBrushTool) previewingLine() bool {
+	return t.lineDrag || (t.isLineModifierDown() && CurrentFile.hasLastBrushPos && !t.shouldConnectToLastPos)
+}
+
+// previewColor returns the color the next stroke will be drawn with, the
+// eraser's is white so that it can be seen
+func (t *PixelBrushTool) previewColor() rl.Color {
+	switch {
+	case t.lineDrag:
+		if t.eraser {
+			break
+		}
+		return t.currentColor
+	case t.eraser:
+	case IsMouseButtonDown(rl.MouseRightButton):
+		return RightColor
+	default:
+		return LeftColor
+	}
+	return rl.NewColor(255, 255, 255, 192)
+}
+
+// DrawPreview is for drawing the preview. The whole line is shown while one is
+// being lined up
 func (t *PixelBrushTool) DrawPreview(x, y int32) {
 	rl.ClearBackground(rl.Blank)
 
-	if t.isLineModifierDown() {
-		Line(t.lastPos.X, t.lastPos.Y, x, y, func(x, y int32) {
-			t.drawPixel(x, y, rl.NewColor(255, 255, 255, 192), false)
+	color := t.previewColor()
+	if t.previewingLine() {
+		last := CurrentFile.lastBrushPos
+		Line(last.X, last.Y, x, y, func(x, y int32) {
+			if t.spaced(&last, x, y) {
+				t.drawPixel(x, y, color, false)
+			}
 		})
 	}
 
-	t.drawPixel(x, y, rl.NewColor(255, 255, 255, 192), false)
+	t.drawPixel(x, y, color, false)
 }
 
-// DrawUI is for drawing the UI
+// DrawUI draws the stabilizer's string while drawing, and outlines where a
+// line starts while one is being lined up
 func (t *PixelBrushTool) DrawUI(camera rl.Camera2D) {
+	if t.previewingLine() {
+		t.drawOutline(CurrentFile.lastBrushPos, camera)
+	}
+	if !t.shouldConnectToLastPos || !t.stabilizer.Enabled || t.isLineModifierDown() {
+		return
+	}
+	origin := PixelToScreen(0, 0, camera)
+	p := PixelScreenSize(camera)
+	start := rl.NewVector2(origin.X+t.stabilized.X*p.X, origin.Y+t.stabilized.Y*p.Y)
+	end := rl.GetMousePosition()
+	rl.DrawLineEx(start, end, 3, rl.Black)
+	rl.DrawLineEx(start, end, 1, rl.White)
+}
+
+// DrawCursor outlines the pixels the brush would draw to
+func (t *PixelBrushTool) DrawCursor(camera rl.Camera2D) {
+	t.drawOutline(ScreenToPixel(rl.GetMousePosition(), camera), camera)
+}
+
+// drawOutline outlines the brush at loc, it's drawn in screen space so that
+// it's the same thickness at every zoom level
+func (t *PixelBrushTool) drawOutline(loc IntVec2, camera rl.Camera2D) {
+	sh := t.genFillShape(t.size, t.shape)
+	p := PixelScreenSize(camera)
 
+	drawEdges := func(thickness float32, color rl.Color) {
+		for pos := range sh {
+			tl := PixelToScreen(loc.X+pos.X, loc.Y+pos.Y, camera)
+			if !sh[IntVec2{pos.X, pos.Y - 1}] {
+				rl.DrawLineEx(tl, rl.NewVector2(tl.X+p.X, tl.Y), thickness, color)
+			}
+			if !sh[IntVec2{pos.X, pos.Y + 1}] {
+				rl.DrawLineEx(rl.NewVector2(tl.X, tl.Y+p.Y), rl.NewVector2(tl.X+p.X, tl.Y+p.Y), thickness, color)
+			}
+			if !sh[IntVec2{pos.X - 1, pos.Y}] {
+				rl.DrawLineEx(tl, rl.NewVector2(tl.X, tl.Y+p.Y), thickness, color)
+			}
+			if !sh[IntVec2{pos.X + 1, pos.Y}] {
+				rl.DrawLineEx(rl.NewVector2(tl.X+p.X, tl.Y), rl.NewVector2(tl.X+p.X, tl.Y+p.Y), thickness, color)
+			}
+		}
+	}
+	drawEdges(3, rl.Black)
+	drawEdges(1, rl.White)
 }
 
 func (t *PixelBrushTool) String() string {
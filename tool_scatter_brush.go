@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math/rand"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// Vars
+const (
+	maxScatterSize    = 32  // inclusive
+	maxScatterDensity = 100 // percent
+)
+
+// ScatterBrushTool randomly paints pixels within its radius, like a spray can
+type ScatterBrushTool struct {
+	name         string
+	currentColor rl.Color
+}
+
+// NewScatterBrushTool returns the scatter brush tool. Requires a name.
+func NewScatterBrushTool(name string) *ScatterBrushTool {
+	return &ScatterBrushTool{
+		name: name,
+	}
+}
+
+// GetSize returns the radius of the brush
+func (t *ScatterBrushTool) GetSize() int32 {
+	return GlobalScatterSize
+}
+
+// SetSize sets the radius of the brush
+func (t *ScatterBrushTool) SetSize(size int32) {
+	if size > 0 && size <= maxScatterSize {
+		GlobalScatterSize = size
+	}
+}
+
+// GetDensity returns how much of the brush area is painted each frame
+func (t *ScatterBrushTool) GetDensity() int32 {
+	return GlobalScatterDensity
+}
+
+// SetDensity sets how much of the brush area is painted each frame
+func (t *ScatterBrushTool) SetDensity(density int32) {
+	if density > 0 && density <= maxScatterDensity {
+		GlobalScatterDensity = density
+	}
+}
+
+// MouseDown is for mouse down events
+func (t *ScatterBrushTool) MouseDown(x, y int32, button MouseButton) {
+	switch button {
+	case rl.MouseLeftButton:
+		t.currentColor = LeftColor
+	case rl.MouseRightButton:
+		t.currentColor = RightColor
+	}
+
+	r := GlobalScatterSize
+	// Area of the circle scaled by the density, a tenth of it is painted each
+	// frame so that the density builds up while the mouse is held
+	attempts := int(float32(r*r)*3.14*float32(GlobalScatterDensity)/100/10) + 1
+	for i := 0; i < attempts; i++ {
+		dx := rand.Int31n(r*2+1) - r
+		dy := rand.Int31n(r*2+1) - r
+		if dx*dx+dy*dy > r*r {
+			continue
+		}
+		// Semi-transparent colors don't stack, the file only blends each pixel
+		// once per stroke
+		loc := IntVec2{x + dx, y + dy}
+		CurrentFile.DrawPixel(loc.X, loc.Y, t.currentColor, CurrentFile.GetCurrentLayer())
+	}
+}
+
+// MouseUp is for mouse up events
+func (t *ScatterBrushTool) MouseUp(x, y int32, button MouseButton) {
+}
+
+// DrawPreview is for drawing the preview
+func (t *ScatterBrushTool) DrawPreview(x, y int32) {
+	rl.ClearBackground(rl.Blank)
+}
+
+// DrawUI is for drawing the UI
+func (t *ScatterBrushTool) DrawUI(camera rl.Camera2D) {
+
+}
+
+// DrawCursor outlines the area which will be painted
+func (t *ScatterBrushTool) DrawCursor(camera rl.Camera2D) {
+	loc := ScreenToPixel(rl.GetMousePosition(), camera)
+	pos := PixelToScreen(loc.X, loc.Y, camera)
+	p := PixelScreenSize(camera)
+	center := rl.NewVector2(pos.X+p.X/2, pos.Y+p.Y/2)
+	radius := (float32(GlobalScatterSize) + 0.5) * p.Y
+
+	rl.DrawCircleLines(int32(center.X), int32(center.Y), radius+1, rl.Black)
+	rl.DrawCircleLines(int32(center.X), int32(center.Y), radius, rl.White)
+	DrawPixelHighlight(loc.X, loc.Y, camera)
+}
+
+func (t *ScatterBrushTool) String() string {
+	return t.name
+}
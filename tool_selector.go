@@ -8,6 +8,20 @@ import (
 
 // TODO rotate
 
+// SelectionShape constrains the size of new selections
+type SelectionShape int32
+
+// SelectionShape
+const (
+	SelectionShapeFree SelectionShape = iota
+	// SelectionShapeFixedSize is GlobalSelectionWidth*GlobalSelectionHeight
+	// from the cursor, a click is enough to select
+	SelectionShapeFixedSize
+	// SelectionShapeFixedAspect is dragged, but keeps the aspect ratio of
+	// GlobalSelectionWidth:GlobalSelectionHeight
+	SelectionShapeFixedAspect
+)
+
 // SelectorTool allows for a selection to be made
 type SelectorTool struct {
 	firstPos, lastPos IntVec2
@@ -45,6 +59,79 @@ func NewSelectorTool(name string) *SelectorTool {
 	}
 }
 
+// GetShape returns how new selections are constrained
+func (t *SelectorTool) GetShape() SelectionShape {
+	return GlobalSelectionShape
+}
+
+// SetShape sets how new selections are constrained
+func (t *SelectorTool) SetShape(shape SelectionShape) {
+	GlobalSelectionShape = shape
+}
+
+// GetSnap returns true if selections are snapped to the tiles
+func (t *SelectorTool) GetSnap() bool {
+	return GlobalSelectionSnap
+}
+
+// SetSnap sets if selections are snapped to the tiles
+func (t *SelectorTool) SetSnap(snap bool) {
+	GlobalSelectionSnap = snap
+}
+
+// GetFixedSize returns the size used by the fixed shapes
+func (t *SelectorTool) GetFixedSize() (int32, int32) {
+	return GlobalSelectionWidth, GlobalSelectionHeight
+}
+
+// SetFixedSize sets the size used by the fixed shapes, at least 1x1
+func (t *SelectorTool) SetFixedSize(width, height int32) {
+	GlobalSelectionWidth = MaxInt32(1, width)
+	GlobalSelectionHeight = MaxInt32(1, height)
+}
+
+// constrain returns the top left and bottom right of a new selection dragged
+// from first to last, using the shape and snapping
+func (t *SelectorTool) constrain(first, last IntVec2) (IntVec2, IntVec2) {
+	switch GlobalSelectionShape {
+	case SelectionShapeFixedSize:
+		first = last
+		if GlobalSelectionSnap {
+			first = GetTilePosition(first.X, first.Y)
+		}
+		return first, IntVec2{first.X + GlobalSelectionWidth - 1, first.Y + GlobalSelectionHeight - 1}
+	case SelectionShapeFixedAspect:
+		w := AbsInt32(last.X-first.X) + 1
+		h := AbsInt32(last.Y-first.Y) + 1
+		// Grow the shorter side to match
+		if w*GlobalSelectionHeight > h*GlobalSelectionWidth {
+			h = (w*GlobalSelectionHeight + GlobalSelectionWidth - 1) / GlobalSelectionWidth
+		} else {
+			w = (h*GlobalSelectionWidth + GlobalSelectionHeight - 1) / GlobalSelectionHeight
+		}
+		if last.X < first.X {
+			last.X = first.X - w + 1
+		} else {
+			last.X = first.X + w - 1
+		}
+		if last.Y < first.Y {
+			last.Y = first.Y - h + 1
+		} else {
+			last.Y = first.Y + h - 1
+		}
+	}
+
+	min := IntVec2{MinInt32(first.X, last.X), MinInt32(first.Y, last.Y)}
+	max := IntVec2{MaxInt32(first.X, last.X), MaxInt32(first.Y, last.Y)}
+	if GlobalSelectionSnap {
+		min = GetTilePosition(min.X, min.Y)
+		max = GetTilePosition(max.X, max.Y)
+		max.X += CurrentFile.TileWidth - 1
+		max.Y += CurrentFile.TileHeight - 1
+	}
+	return min, max
+}
+
 // MouseDown is for mouse down events
 func (t *SelectorTool) MouseDown(x, y int32, button MouseButton) {
 	// Only get the first position after mouse has just been clicked
@@ -251,14 +338,17 @@ func (t *SelectorTool) MouseDown(x, y int32, button MouseButton) {
 		return
 	}
 
-	// Cancel selection if a click without a drag happens
-	if t.firstPos.X == t.lastPos.X && t.firstPos.Y == t.lastPos.Y {
+	// Cancel selection if a click without a drag happens. Fixed size and
+	// snapped selections are made with a click instead
+	clickSelects := GlobalSelectionShape == SelectionShapeFixedSize || GlobalSelectionSnap
+	if t.firstPos.X == t.lastPos.X && t.firstPos.Y == t.lastPos.Y && !clickSelects {
 		if time.Now().Sub(t.firstDownTime) < time.Millisecond*100 {
 			// Commit whatever was moving to wherever it ended up
 			CurrentFile.CommitSelection()
 			return
 		}
 	}
+	firstPosClone, t.lastPos = t.constrain(t.firstPos, IntVec2{x, y})
 
 	// Reset the selection
 	// TODO it creates a lot of objects, not very efficient
@@ -311,6 +401,16 @@ func (t *SelectorTool) DrawPreview(x, y int32) {
 	rl.ClearBackground(rl.Blank)
 
 	if CurrentFile.DoingSelection {
+		// A flip or rotation waiting to be accepted is drawn faded over the
+		// selection
+		if preview := CurrentFile.SelectionPreview; preview != nil {
+			for loc, color := range preview.Selection {
+				color.A /= 2
+				rl.DrawPixel(loc.X, loc.Y, color)
+			}
+			return
+		}
+
 		// Draw the selected pixels
 		for loc, color := range CurrentFile.Selection {
 			rl.DrawPixel(loc.X, loc.Y, color)
@@ -323,19 +423,26 @@ func (t *SelectorTool) DrawUI(camera rl.Camera2D) {
 	if !CurrentFile.DoingSelection {
 		return
 	}
-	pos := rl.GetWorldToScreen2D(rl.Vector2{X: float32(CurrentFile.SelectionBounds[0]) - float32(CurrentFile.CanvasWidth)/2, Y: float32(CurrentFile.SelectionBounds[1]) - float32(CurrentFile.CanvasHeight)/2}, camera)
+	DrawSelectionOutOfBounds(camera)
+
+	bounds := CurrentFile.SelectionBounds
+	if CurrentFile.SelectionPreview != nil {
+		bounds = CurrentFile.SelectionPreview.Bounds
+	}
+	pos := PixelToScreen(bounds[0], bounds[1], camera)
+	ps := PixelScreenSize(camera)
 	x := pos.X
 	y := pos.Y
-	w := float32(CurrentFile.SelectionBounds[2]-CurrentFile.SelectionBounds[0]+1) * camera.Zoom
-	h := float32(CurrentFile.SelectionBounds[3]-CurrentFile.SelectionBounds[1]+1) * camera.Zoom
+	w := float32(bounds[2]-bounds[0]+1) * ps.X
+	h := float32(bounds[3]-bounds[1]+1) * ps.Y
 
 	if w <= 0 {
-		x += w - 1*camera.Zoom
-		w = w*-1 + 2*camera.Zoom
+		x += w - 1*ps.X
+		w = w*-1 + 2*ps.X
 	}
 	if h <= 0 {
-		y += h - 1*camera.Zoom
-		h = h*-1 + 2*camera.Zoom
+		y += h - 1*ps.Y
+		h = h*-1 + 2*ps.Y
 	}
 
 	if time.Now().Sub(t.selectionFadeColorIncreaseTimeLast) > t.selectionFadeColorIncreaseTimeInterval {
@@ -359,12 +466,12 @@ func (t *SelectorTool) DrawUI(camera rl.Camera2D) {
 	// log.Println(t.selectionFadeColor)
 	c := rl.NewColor(uint8(t.selectionFadeColor), uint8(t.selectionFadeColor), uint8(t.selectionFadeColor), 255)
 
-	p := camera.Zoom                                                   // pixel size
-	rl.DrawRectangleLinesEx(rl.NewRectangle(x, y, w, h), 4, c)         // main
-	rl.DrawRectangleLinesEx(rl.NewRectangle(x-p, y-p, w+p*2, p), 2, c) // top
-	rl.DrawRectangleLinesEx(rl.NewRectangle(x-p, y+h, w+p*2, p), 2, c) // bottom
-	rl.DrawRectangleLinesEx(rl.NewRectangle(x-p, y-p, p, h+p*2), 2, c) // left
-	rl.DrawRectangleLinesEx(rl.NewRectangle(x+w, y-p, p, h+p*2), 2, c) // right
+	px, py := ps.X, ps.Y                                                   // pixel size
+	rl.DrawRectangleLinesEx(rl.NewRectangle(x, y, w, h), 4, c)             // main
+	rl.DrawRectangleLinesEx(rl.NewRectangle(x-px, y-py, w+px*2, py), 2, c) // top
+	rl.DrawRectangleLinesEx(rl.NewRectangle(x-px, y+h, w+px*2, py), 2, c)  // bottom
+	rl.DrawRectangleLinesEx(rl.NewRectangle(x-px, y-py, px, h+py*2), 2, c) // left
+	rl.DrawRectangleLinesEx(rl.NewRectangle(x+w, y-py, px, h+py*2), 2, c)  // right
 }
 
 func (t *SelectorTool) String() string {
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// WarpTool skews or perspective warps the floating selection by dragging its
+// corners. Holding the skew modifier moves the whole edge along itself, which
+// keeps the opposite edge in place
+type WarpTool struct {
+	name    string
+	started bool
+	// Selection pixels before any warping, row by row
+	src        []rl.Color
+	srcW, srcH int32
+	// The bounds last set by the tool, if the selection bounds differ then the
+	// selection was changed by something else
+	bounds [4]int32
+
+	// Top left, top right, bottom right, bottom left. In canvas pixels where
+	// the bottom right corner of a pixel is x+1, y+1
+	corners     [4]rl.Vector2
+	downCorners [4]rl.Vector2
+	downPos     IntVec2
+	dragCorner  int
+}
+
+// NewWarpTool returns the warp tool. Requires a name.
+func NewWarpTool(name string) *WarpTool {
+	return &WarpTool{
+		name:       name,
+		dragCorner: -1,
+	}
+}
+
+// start copies the selection so that it can be warped repeatedly without
+// losing quality. Returns false if there is nothing to warp
+func (t *WarpTool) start() bool {
+	if !CurrentFile.DoingSelection || len(CurrentFile.Selection) == 0 {
+		t.started = false
+		return false
+	}
+	if t.started && t.bounds == CurrentFile.SelectionBounds {
+		return true
+	}
+
+	// Lifts the selection off of the layer and appends the history
+	CurrentFile.MoveSelection(0, 0)
+
+	b := CurrentFile.SelectionBounds
+	t.bounds = b
+	t.srcW = b[2] - b[0] + 1
+	t.srcH = b[3] - b[1] + 1
+	t.src = make([]rl.Color, 0, t.srcW*t.srcH)
+	for y := b[1]; y <= b[3]; y++ {
+		for x := b[0]; x <= b[2]; x++ {
+			t.src = append(t.src, CurrentFile.Selection[IntVec2{x, y}])
+		}
+	}
+
+	x0, y0 := float32(b[0]), float32(b[1])
+	x1, y1 := float32(b[2]+1), float32(b[3]+1)
+	t.corners = [4]rl.Vector2{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}
+	t.started = true
+	return true
+}
+
+// squareToQuad returns the projective transform which maps the unit square to
+// the quad
+func squareToQuad(q [4]rl.Vector2) [9]float64 {
+	x0, y0 := float64(q[0].X), float64(q[0].Y)
+	x1, y1 := float64(q[1].X), float64(q[1].Y)
+	x2, y2 := float64(q[2].X), float64(q[2].Y)
+	x3, y3 := float64(q[3].X), float64(q[3].Y)
+
+	sx := x0 - x1 + x2 - x3
+	sy := y0 - y1 + y2 - y3
+	if sx == 0 && sy == 0 {
+		// Parallelogram, no perspective
+		return [9]float64{
+			x1 - x0, x3 - x0, x0,
+			y1 - y0, y3 - y0, y0,
+			0, 0, 1,
+		}
+	}
+
+	dx1, dx2 := x1-x2, x3-x2
+	dy1, dy2 := y1-y2, y3-y2
+	den := dx1*dy2 - dx2*dy1
+	g := (sx*dy2 - dx2*sy) / den
+	h := (dx1*sy - sx*dy1) / den
+	return [9]float64{
+		x1 - x0 + g*x1, x3 - x0 + h*x3, x0,
+		y1 - y0 + g*y1, y3 - y0 + h*y3, y0,
+		g, h, 1,
+	}
+}
+
+// invert3 inverts a 3x3 matrix, ok is false if it can't be inverted
+func invert3(m [9]float64) (inv [9]float64, ok bool) {
+	a, b, c := m[0], m[1], m[2]
+	d, e, f := m[3], m[4], m[5]
+	g, h, i := m[6], m[7], m[8]
+
+	A := e*i - f*h
+	B := f*g - d*i
+	C := d*h - e*g
+	det := a*A + b*B + c*C
+	if math.Abs(det) < 1e-9 {
+		return inv, false
+	}
+
+	return [9]float64{
+		A / det, (c*h - b*i) / det, (b*f - c*e) / det,
+		B / det, (a*i - c*g) / det, (c*d - a*f) / det,
+		C / det, (b*g - a*h) / det, (a*e - b*d) / det,
+	}, true
+}
+
+// apply warps the copied pixels into the selection using nearest neighbor
+// sampling
+func (t *WarpTool) apply() {
+	inv, ok := invert3(squareToQuad(t.corners))
+	if !ok {
+		return
+	}
+
+	minX, minY := t.corners[0].X, t.corners[0].Y
+	maxX, maxY := minX, minY
+	for _, c := range t.corners[1:] {
+		minX = float32(math.Min(float64(minX), float64(c.X)))
+		minY = float32(math.Min(float64(minY), float64(c.Y)))
+		maxX = float32(math.Max(float64(maxX), float64(c.X)))
+		maxY = float32(math.Max(float64(maxY), float64(c.Y)))
+	}
+	b := [4]int32{
+		int32(math.Floor(float64(minX))),
+		int32(math.Floor(float64(minY))),
+		int32(math.Ceil(float64(maxX))) - 1,
+		int32(math.Ceil(float64(maxY))) - 1,
+	}
+	if b[2] < b[0] || b[3] < b[1] {
+		return
+	}
+
+	CurrentFile.Selection = make(map[IntVec2]rl.Color)
+	CurrentFile.SelectionPixels = make([]rl.Color, 0, (b[2]-b[0]+1)*(b[3]-b[1]+1))
+	for y := b[1]; y <= b[3]; y++ {
+		for x := b[0]; x <= b[2]; x++ {
+			// Sample from the center of the pixel
+			px, py := float64(x)+0.5, float64(y)+0.5
+			w := inv[6]*px + inv[7]*py + inv[8]
+			u := (inv[0]*px + inv[1]*py + inv[2]) / w
+			v := (inv[3]*px + inv[4]*py + inv[5]) / w
+
+			color := rl.Blank
+			if u >= 0 && u < 1 && v >= 0 && v < 1 {
+				color = t.src[int32(v*float64(t.srcH))*t.srcW+int32(u*float64(t.srcW))]
+				CurrentFile.Selection[IntVec2{x, y}] = color
+			}
+			CurrentFile.SelectionPixels = append(CurrentFile.SelectionPixels, color)
+		}
+	}
+
+	CurrentFile.SelectionBounds = b
+	CurrentFile.OrigSelectionBounds = b
+	t.bounds = b
+}
+
+// MouseDown is for mouse down events
+func (t *WarpTool) MouseDown(x, y int32, button MouseButton) {
+	if !t.start() {
+		return
+	}
+
+	pos := IntVec2{x, y}
+	if t.dragCorner < 0 {
+		// Grab the closest corner
+		var closest float32 = math.MaxFloat32
+		for i, c := range t.corners {
+			dx, dy := c.X-float32(x), c.Y-float32(y)
+			if d := dx*dx + dy*dy; d < closest {
+				closest = d
+				t.dragCorner = i
+			}
+		}
+		t.downPos = pos
+		t.downCorners = t.corners
+	}
+
+	dx := float32(pos.X - t.downPos.X)
+	dy := float32(pos.Y - t.downPos.Y)
+	t.corners = t.downCorners
+	c := t.dragCorner
+	if isBindingDown("skew") {
+		if math.Abs(float64(dx)) >= math.Abs(float64(dy)) {
+			// The corner which shares the top or bottom edge
+			n := c ^ 1
+			t.corners[c].X += dx
+			t.corners[n].X += dx
+		} else {
+			// The corner which shares the left or right edge
+			n := 3 - c
+			t.corners[c].Y += dy
+			t.corners[n].Y += dy
+		}
+	} else {
+		t.corners[c].X += dx
+		t.corners[c].Y += dy
+	}
+
+	t.apply()
+}
+
+// MouseUp is for mouse up events
+func (t *WarpTool) MouseUp(x, y int32, button MouseButton) {
+	t.dragCorner = -1
+}
+
+// DrawPreview is for drawing the preview
+func (t *WarpTool) DrawPreview(x, y int32) {
+	rl.ClearBackground(rl.Blank)
+
+	if CurrentFile.DoingSelection {
+		for loc, color := range CurrentFile.Selection {
+			rl.DrawPixel(loc.X, loc.Y, color)
+		}
+	}
+}
+
+// DrawUI draws the warped outline and the corner handles
+func (t *WarpTool) DrawUI(camera rl.Camera2D) {
+	if !CurrentFile.DoingSelection {
+		return
+	}
+
+	DrawSelectionOutOfBounds(camera)
+
+	corners := t.corners
+	if !t.started || t.bounds != CurrentFile.SelectionBounds {
+		b := CurrentFile.SelectionBounds
+		x0, y0 := float32(b[0]), float32(b[1])
+		x1, y1 := float32(b[2]+1), float32(b[3]+1)
+		corners = [4]rl.Vector2{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}
+	}
+
+	origin := PixelToScreen(0, 0, camera)
+	p := PixelScreenSize(camera)
+	screen := make([]rl.Vector2, len(corners))
+	for i, c := range corners {
+		screen[i] = rl.NewVector2(origin.X+c.X*p.X, origin.Y+c.Y*p.Y)
+	}
+
+	for i := range screen {
+		rl.DrawLineEx(screen[i], screen[(i+1)%len(screen)], 3, rl.Black)
+		rl.DrawLineEx(screen[i], screen[(i+1)%len(screen)], 1, rl.White)
+	}
+	for _, s := range screen {
+		rl.DrawRectangle(int32(s.X)-4, int32(s.Y)-4, 9, 9, rl.Black)
+		rl.DrawRectangle(int32(s.X)-3, int32(s.Y)-3, 7, 7, rl.White)
+	}
+}
+
+func (t *WarpTool) String() string {
+	return t.name
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"log"
+	"math"
+	"sort"
 	"time"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
@@ -34,23 +36,29 @@ var (
 	isInited = false
 	// UIIsDraggingEntity is true when something is being dragged
 	UIIsDraggingEntity = false
+	// UIHasMouseOver is true when the mouse is over any UI element
+	UIHasMouseOver = false
 	// Font is the font used
 	Font rl.Font
 	// UIFontSize is the size of the font
 	UIFontSize float32 = 24
 	// UIButtonHeight is the size of the buttons
 	UIButtonHeight float32 = 56.0
+	// uiScale is the DPI scale which UIFontSize and UIButtonHeight are scaled by
+	uiScale float32 = 1
+	// uiRebuildRequested rebuilds the UI at the start of the next update
+	uiRebuildRequested = false
 
 	uiCamera               = rl.Camera2D{Zoom: 1}
 	mouseX, mouseY         int32
 	mouseLastX, mouseLastY = -1, -1
 
 	// Ecs stuffs
-	scene                                                               *Scene
-	moveable, resizeable, interactable, hoverable, drawable, scrollable *Component
-	renderSystem                                                        *UIRenderSystem
-	controlSystem                                                       *UIControlSystem
-	fileSystem                                                          *UIRenderFileSystem
+	scene                                                                          *Scene
+	moveable, resizeable, interactable, hoverable, drawable, scrollable, tweenable *Component
+	renderSystem                                                                   *UIRenderSystem
+	controlSystem                                                                  *UIControlSystem
+	fileSystem                                                                     *UIRenderFileSystem
 )
 
 const (
@@ -167,6 +175,67 @@ type Interactable struct {
 
 	// OnFocus is called when focus is gained on the entity
 	OnFocus func(entity *Entity)
+
+	// OnDoubleClick is called after OnMouseUp when the same button is released
+	// twice within UIDoubleClickInterval
+	OnDoubleClick func(entity *Entity, button MouseButton)
+	// LastClickAt and LastClickButton are from the previous mouse up
+	LastClickAt     time.Time
+	LastClickButton MouseButton
+
+	// Disabled greys out the entity and ignores clicks on it. EnabledWhen
+	// sets it every frame if it isn't nil
+	Disabled    bool
+	EnabledWhen func() bool
+}
+
+// Interaction timings which can be changed in the settings
+const (
+	DefaultDragDelay           = time.Second / 2
+	DefaultDoubleClickInterval = time.Millisecond * 400
+)
+
+// UIDragDelay is how long a button has to be held before it's dragged
+func UIDragDelay() time.Duration {
+	if Settings != nil && Settings.DragDelay > 0 {
+		return time.Duration(Settings.DragDelay) * time.Millisecond
+	}
+	return DefaultDragDelay
+}
+
+// UIDoubleClickInterval is the most time there can be between the clicks of
+// a double click
+func UIDoubleClickInterval() time.Duration {
+	if Settings != nil && Settings.DoubleClickInterval > 0 {
+		return time.Duration(Settings.DoubleClickInterval) * time.Millisecond
+	}
+	return DefaultDoubleClickInterval
+}
+
+// MouseUp calls OnMouseUp, then OnDoubleClick if it's the second click
+func (interactable *Interactable) MouseUp(entity *Entity, button MouseButton) {
+	if interactable.Disabled {
+		return
+	}
+	if hoverable, ok := entity.GetHoverable(); ok && hoverable.Toggle {
+		hoverable.Selected = !hoverable.Selected
+	}
+	if interactable.OnMouseUp != nil {
+		interactable.OnMouseUp(entity, button)
+	}
+	if interactable.OnDoubleClick == nil {
+		return
+	}
+
+	now := time.Now()
+	if button == interactable.LastClickButton && now.Sub(interactable.LastClickAt) <= UIDoubleClickInterval() {
+		// A third click starts a new double click
+		interactable.LastClickAt = time.Time{}
+		interactable.OnDoubleClick(entity, button)
+		return
+	}
+	interactable.LastClickAt = now
+	interactable.LastClickButton = button
 }
 
 // GetInteractable returns the Interactable from the Entity
@@ -209,12 +278,26 @@ const (
 type Scrollable struct {
 	// ScrollDirection states which way the content should scroll
 	ScrollDirection ScrollDirection
-	// ScrollOffset is how much the content should be offset
+	// ScrollOffset is how much the content should be offset, it's 0 at the
+	// start and negative when scrolled
 	ScrollOffset int32
 
-	// TODO stuff for rendering scrollbars differently
+	// Dragging is true while the scrollbar's thumb is being dragged,
+	// dragGrab is where the thumb was grabbed from its start
+	Dragging bool
+	dragGrab float32
+
+	// ScrollOffset eases from position to target
+	position, target float32
 }
 
+// ScrollbarSize is the thickness of scrollbars
+const ScrollbarSize = 8
+
+// scrollEasing is how quickly smooth scrolling catches up, it covers about
+// this fraction of the distance every 1/60th of a second
+const scrollEasing = 0.25
+
 // GetScrollable returns the Scrollable from the Entity
 func (entity *Entity) GetScrollable() (t *Scrollable, ok bool) {
 	if result, err := entity.Scene.QueryID(entity.ID); err == nil {
@@ -223,6 +306,169 @@ func (entity *Entity) GetScrollable() (t *Scrollable, ok bool) {
 	return t, ok
 }
 
+// scrollExtent returns how long the content of a scrollable entity is along
+// the scroll direction and how much of it fits in the entity
+func (scrollable *Scrollable) scrollExtent(moveable *Moveable, drawable *Drawable) (content, view float32) {
+	view = moveable.Bounds.Height
+	if scrollable.ScrollDirection == ScrollDirectionHorizontal {
+		view = moveable.Bounds.Width
+	}
+	parent, ok := drawable.DrawableType.(*DrawableParent)
+	if !ok {
+		return view, view
+	}
+	for _, child := range parent.Children {
+		childMoveable, ok := child.GetMoveable()
+		if !ok {
+			continue
+		}
+		if scrollable.ScrollDirection == ScrollDirectionHorizontal {
+			content = float32(math.Max(float64(content), float64(childMoveable.Bounds.X+childMoveable.Bounds.Width-moveable.Bounds.X)))
+		} else {
+			content = float32(math.Max(float64(content), float64(childMoveable.Bounds.Y+childMoveable.Bounds.Height-moveable.Bounds.Y)))
+		}
+	}
+	return content, view
+}
+
+// Viewport returns the part of the content which can be seen, in the same
+// coordinates as the children's bounds
+func (scrollable *Scrollable) Viewport(bounds rl.Rectangle) rl.Rectangle {
+	if scrollable.ScrollDirection == ScrollDirectionHorizontal {
+		bounds.X -= float32(scrollable.ScrollOffset)
+	} else {
+		bounds.Y -= float32(scrollable.ScrollOffset)
+	}
+	return bounds
+}
+
+// CanSee returns true if any of child is in the viewport of the scrollable
+// entity with bounds
+func (scrollable *Scrollable) CanSee(bounds rl.Rectangle, child *Entity) bool {
+	childMoveable, ok := child.GetMoveable()
+	if !ok {
+		return true
+	}
+	return rl.CheckCollisionRecs(scrollable.Viewport(bounds), childMoveable.Bounds)
+}
+
+// Clamp keeps the offset within the content
+func (scrollable *Scrollable) Clamp(content, view float32) {
+	min := -int32(math.Max(0, float64(content-view)))
+	if scrollable.ScrollOffset < min {
+		scrollable.ScrollOffset = min
+	}
+	if scrollable.ScrollOffset > 0 {
+		scrollable.ScrollOffset = 0
+	}
+}
+
+// sync makes ScrollOffset the new position if it was set directly, which
+// stops smooth scrolling
+func (scrollable *Scrollable) sync() {
+	if int32(math.Round(float64(scrollable.position))) != scrollable.ScrollOffset {
+		scrollable.position = float32(scrollable.ScrollOffset)
+		scrollable.target = scrollable.position
+	}
+}
+
+// ScrollBy smoothly scrolls by amount, negative amounts scroll towards the end
+func (scrollable *Scrollable) ScrollBy(amount float32) {
+	scrollable.sync()
+	scrollable.target += amount
+}
+
+// Animate moves ScrollOffset towards where it's being scrolled to, it should
+// be called every frame. The offset is kept within the content
+func (scrollable *Scrollable) Animate(content, view float32) {
+	scrollable.sync()
+	min := -float32(math.Max(0, float64(content-view)))
+	scrollable.target = float32(math.Max(float64(min), math.Min(0, float64(scrollable.target))))
+
+	step := 1 - float32(math.Pow(1-scrollEasing, float64(rl.GetFrameTime()*60)))
+	scrollable.position += (scrollable.target - scrollable.position) * step
+	if math.Abs(float64(scrollable.target-scrollable.position)) < 0.5 {
+		scrollable.position = scrollable.target
+	}
+	scrollable.ScrollOffset = int32(math.Round(float64(scrollable.position)))
+}
+
+// ScrollTo smoothly scrolls the scrollable entity until child, which can be
+// nested in other children, can be seen
+func (entity *Entity) ScrollTo(child *Entity) {
+	scrollable, ok := entity.GetScrollable()
+	if !ok {
+		return
+	}
+	moveable, ok := entity.GetMoveable()
+	if !ok {
+		return
+	}
+	drawable, ok := entity.GetDrawable()
+	if !ok {
+		return
+	}
+	childMoveable, ok := child.GetMoveable()
+	if !ok {
+		return
+	}
+
+	// The bounds aren't scrolled, so they're from the start of the content
+	start, length := childMoveable.Bounds.Y-moveable.Bounds.Y, childMoveable.Bounds.Height
+	if scrollable.ScrollDirection == ScrollDirectionHorizontal {
+		start, length = childMoveable.Bounds.X-moveable.Bounds.X, childMoveable.Bounds.Width
+	}
+	_, view := scrollable.scrollExtent(moveable, drawable)
+
+	scrollable.sync()
+	switch {
+	case start < -scrollable.target:
+		scrollable.target = -start
+	case start+length > -scrollable.target+view:
+		scrollable.target = -(start + length - view)
+	}
+}
+
+// Scrollbar returns the track and the thumb of the scrollbar, along the right
+// or bottom edge of bounds. ok is false when the content fits and there's
+// nothing to scroll
+func (scrollable *Scrollable) Scrollbar(bounds rl.Rectangle, content, view float32) (track, thumb rl.Rectangle, ok bool) {
+	if content <= view || view <= 0 {
+		return track, thumb, false
+	}
+	vertical := scrollable.ScrollDirection != ScrollDirectionHorizontal
+	if vertical {
+		track = rl.NewRectangle(bounds.X+bounds.Width-ScrollbarSize, bounds.Y, ScrollbarSize, bounds.Height)
+	} else {
+		track = rl.NewRectangle(bounds.X, bounds.Y+bounds.Height-ScrollbarSize, bounds.Width, ScrollbarSize)
+	}
+
+	// The thumb is as long as the part of the content which can be seen, but
+	// not so short that it can't be grabbed
+	length := float32(math.Max(ScrollbarSize*2, float64(view*view/content)))
+	pos := float32(-scrollable.ScrollOffset) / (content - view) * (view - length)
+	if vertical {
+		thumb = rl.NewRectangle(track.X, track.Y+pos, track.Width, length)
+	} else {
+		thumb = rl.NewRectangle(track.X+pos, track.Y, length, track.Height)
+	}
+	return track, thumb, true
+}
+
+// ScrollbarTo scrolls so that the thumb starts at pos, which is along the
+// track from its start
+func (scrollable *Scrollable) ScrollbarTo(pos float32, track, thumb rl.Rectangle, content, view float32) {
+	trackLength, thumbLength := track.Height, thumb.Height
+	if scrollable.ScrollDirection == ScrollDirectionHorizontal {
+		trackLength, thumbLength = track.Width, thumb.Width
+	}
+	if trackLength <= thumbLength {
+		return
+	}
+	scrollable.ScrollOffset = -int32(pos / (trackLength - thumbLength) * (content - view))
+	scrollable.Clamp(content, view)
+}
+
 // Hoverable stores the hovered and seleceted states
 type Hoverable struct {
 	Hovered  bool
@@ -233,10 +479,56 @@ type Hoverable struct {
 	// Prevent multiple leave events
 	DidMouseLeave bool
 
-	// Split selection to display which tool/color is bound to which mouse button
-	// TODO implement
+	// Split selection to display which tool/color is bound to which mouse
+	// button, they're set by RadioGroup
 	SelectedLeft  bool
 	SelectedRight bool
+
+	// Toggle flips Selected when the entity is clicked
+	Toggle bool
+}
+
+// RadioGroup is a set of entities where one is selected for each mouse
+// button, like the tools or the palette's colors
+type RadioGroup struct {
+	left, right *Hoverable
+}
+
+// Select selects the entity for both mouse buttons
+func (g *RadioGroup) Select(entity *Entity) {
+	g.SelectFor(entity, rl.MouseLeftButton)
+	g.SelectFor(entity, rl.MouseRightButton)
+}
+
+// SelectFor selects the entity for the mouse button, the other button keeps
+// its selection
+func (g *RadioGroup) SelectFor(entity *Entity, button MouseButton) {
+	hoverable, ok := entity.GetHoverable()
+	if !ok {
+		return
+	}
+	switch button {
+	case rl.MouseLeftButton:
+		if g.left != nil {
+			g.left.SelectedLeft = false
+			g.left.Selected = g.left.SelectedRight
+		}
+		g.left = hoverable
+		hoverable.SelectedLeft = true
+	case rl.MouseRightButton:
+		if g.right != nil {
+			g.right.SelectedRight = false
+			g.right.Selected = g.right.SelectedLeft
+		}
+		g.right = hoverable
+		hoverable.SelectedRight = true
+	}
+	hoverable.Selected = true
+}
+
+// Clear forgets the selection, for when the entities are rebuilt
+func (g *RadioGroup) Clear() {
+	g.left, g.right = nil, nil
 }
 
 // GetHoverable returns the Hoverable from the Entity
@@ -267,6 +559,53 @@ type Drawable struct {
 
 	// DrawBackground will draw the background if true
 	DrawBackground bool
+
+	// ZIndex draws top level entities above the ones with a lower ZIndex and
+	// lets them get input first. Entities with the same ZIndex stack in the
+	// order they were brought to the front
+	ZIndex int32
+}
+
+// ZIndex values for the kinds of entities which overlap
+const (
+	ZIndexCanvas int32 = (iota - 1) * 10
+	ZIndexDefault
+	ZIndexPanel
+	ZIndexDialog
+	ZIndexMenu
+	ZIndexOverlay
+)
+
+// SetZIndex sets the ZIndex of the entity
+func (entity *Entity) SetZIndex(z int32) *Entity {
+	if drawable, ok := entity.GetDrawable(); ok {
+		drawable.ZIndex = z
+	}
+	return entity
+}
+
+// BringToFront stacks the entity above the others with the same ZIndex
+func (entity *Entity) BringToFront() {
+	if err := entity.Scene.MoveEntityToEnd(entity); err != nil {
+		log.Println(err)
+	}
+}
+
+// UISortByZIndex returns a copy of results which is stably sorted by ZIndex,
+// so the last result is on top
+func UISortByZIndex(results []*QueryResult) []*QueryResult {
+	sorted := make([]*QueryResult, len(results))
+	copy(sorted, results)
+	zIndex := func(result *QueryResult) int32 {
+		if drawable, ok := result.Components[scene.ComponentsMap["drawable"]].(*Drawable); ok {
+			return drawable.ZIndex
+		}
+		return ZIndexDefault
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return zIndex(sorted[i]) < zIndex(sorted[j])
+	})
+	return sorted
 }
 
 // GetDrawable returns the Drawable from the Entity
@@ -328,6 +667,11 @@ type DrawableRenderTexture struct {
 	Texture rl.RenderTexture2D
 }
 
+// DrawableChunks draws a layer's chunks scaled to fit
+type DrawableChunks struct {
+	Chunks *Chunks
+}
+
 // DrawableParent draws its children to its texture if IsPassthrough is true
 type DrawableParent struct {
 	// If true, doesn't draw to the Texture
@@ -337,11 +681,60 @@ type DrawableParent struct {
 	Children []*Entity
 }
 
+// setUIScale scales the UI sizes to the DPI scale
+func setUIScale(scale float32) {
+	if scale <= 0 {
+		return
+	}
+	UIFontSize = UIFontSize / uiScale * scale
+	UIButtonHeight = UIButtonHeight / uiScale * scale
+	uiScale = scale
+}
+
+// UIRescale rebuilds the UI when the DPI scale changes, e.g. when the window is
+// moved to another monitor
+func UIRescale(scale float32) {
+	if scale <= 0 || scale == uiScale {
+		return
+	}
+
+	log.Println("DPI scale changed to", scale)
+	setUIScale(scale)
+	rebuildUI()
+}
+
+// UIRebuild rebuilds the UI at the start of the next update, e.g. when the
+// locale changes. The UI can't be destroyed while it's handling its events
+func UIRebuild() {
+	uiRebuildRequested = true
+}
+
+// rebuildUI destroys the UI and creates it again
+func rebuildUI() {
+	uiRebuildRequested = false
+	keymap := controlSystem.Keymap
+	DestroyUI()
+	// Points to an entity from the old scene
+	currentColorIndicatorEntity = nil
+
+	InitUI(keymap)
+	EditorsUIRebuild()
+
+	// The tour box was destroyed with the rest of the scene
+	if TourUIActive() {
+		tourBox = nil
+		TourUIShowStep(tourStepIndex)
+	}
+}
+
 // InitUI must be called before UI is used
 func InitUI(keymap Keymap) {
 	isInited = true
 
-	Font = rl.LoadFont(GetFile("./res/fonts/Hack-Bold.ttf"))
+	setUIScale(rl.GetWindowScaleDPI().X)
+
+	// Loads the characters needed by the locale as well as ASCII
+	Font = rl.LoadFontEx(GetFile("./res/fonts/Hack-Bold.ttf"), 32, LocaleRunes())
 
 	scene = NewScene()
 
@@ -351,6 +744,7 @@ func InitUI(keymap Keymap) {
 	scrollable = scene.NewComponent("scrollable")
 	hoverable = scene.NewComponent("hoverable")
 	drawable = scene.NewComponent("drawable")
+	tweenable = scene.NewComponent("tweenable")
 
 	drawable.SetDestructor(func(e *Entity, data interface{}) {
 		d, ok := data.(*Drawable)
@@ -372,6 +766,7 @@ func InitUI(keymap Keymap) {
 	scene.BuildTag("scrollable", scrollable)
 	scene.BuildTag("hoverable", hoverable)
 	scene.BuildTag("drawable", drawable)
+	scene.BuildTag("tweenable", tweenable)
 	scene.BuildTag("basic", drawable, moveable, hoverable)
 	scene.BuildTag("basicControl", drawable, moveable, hoverable, interactable)
 
@@ -392,6 +787,12 @@ func DestroyUI() {
 
 // UpdateUI updates the systems (excluding the RenderSystem)
 func UpdateUI() {
+	UIRescale(rl.GetWindowScaleDPI().X)
+	if uiRebuildRequested {
+		rebuildUI()
+	}
+	UpdateMouseCapture()
+
 	controlSystem.Update(rl.GetFrameTime())
 	fileSystem.Update(rl.GetFrameTime())
 }
@@ -445,7 +846,7 @@ func (entity *Entity) Show() error {
 			}
 		}
 
-		scene.MoveEntityToEnd(entity)
+		entity.BringToFront()
 	}
 	return nil
 }
@@ -706,6 +1107,21 @@ func NewRenderTexture(
 	return e
 }
 
+// NewChunksView creates a box which shows a layer's chunks
+func NewChunksView(bounds rl.Rectangle, chunks *Chunks) *Entity {
+	e := scene.NewEntity(0).
+		AddComponent(moveable, &Moveable{bounds, bounds, rl.Vector2{}, FlowDirectionHorizontal, false}).
+		AddComponent(resizeable, &Resizeable{}).
+		AddComponent(hoverable, &Hoverable{Selected: false}).
+		AddComponent(drawable, &Drawable{
+			DrawableType:   &DrawableChunks{chunks},
+			DrawBorder:     true,
+			DrawBackground: true,
+		})
+	e.Name = "chunksView"
+	return e
+}
+
 // NewButtonTexture creates a button which renders a texture
 func NewButtonTexture(
 	bounds rl.Rectangle,
@@ -750,6 +1166,25 @@ func NewButtonText(bounds rl.Rectangle,
 	return e
 }
 
+// NewButtonToggle creates a text button which stays selected or unselected
+// when it's clicked, onToggle is called with the new state
+func NewButtonToggle(bounds rl.Rectangle,
+	label string,
+	align TextAlign,
+	selected bool,
+	onToggle func(entity *Entity, selected bool),
+) *Entity {
+	e := NewButtonText(bounds, label, align, selected, func(entity *Entity, button MouseButton) {
+		if hoverable, ok := entity.GetHoverable(); ok && onToggle != nil {
+			onToggle(entity, hoverable.Selected)
+		}
+	}, nil)
+	if hoverable, ok := e.GetHoverable(); ok {
+		hoverable.Toggle = true
+	}
+	return e
+}
+
 // NewInput creates a button which renders text and can be edited
 func NewInput(
 	bounds rl.Rectangle,
@@ -14,6 +14,20 @@ var (
 	animationsListContainer *Entity
 )
 
+// AnimationsUISetCurrentAnimation selects the animation and scrolls the list
+// to it, like clicking it
+func AnimationsUISetCurrentAnimation(index int32) {
+	entity, ok := animationInteractables[int(index)]
+	if !ok {
+		return
+	}
+	if interactable, ok := entity.GetInteractable(); ok {
+		interactable.OnMouseDown(entity, rl.MouseLeftButton, false)
+		interactable.OnMouseUp(entity, rl.MouseLeftButton)
+	}
+	animationsList.ScrollTo(entity)
+}
+
 // AnimationsUIRebuildList rebuilds the list
 func AnimationsUIRebuildList() {
 	animationsList.DestroyNested()
@@ -57,11 +71,18 @@ func AnimationsUIMakeBox(y int32, animation *Animation) *Entity {
 			}
 		}, nil)
 
+	export := NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight/2, UIButtonHeight/2), GetFile("./res/icons/export_on.png"), false,
+		func(entity *Entity, button MouseButton) {
+			// button up
+			UISetAnimationExport(y)
+		}, nil)
+
 	// Keep the buttons organized
 	buttonBox := NewBox(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
 		[]*Entity{
 			frameSelect,
 			delete,
+			export,
 		},
 		FlowDirectionHorizontal)
 
@@ -152,6 +173,7 @@ func NewAnimationsUI(bounds rl.Rectangle) *Entity {
 			// button up
 			CurrentFile.AddNewAnimation()
 			AnimationsUIRebuildList()
+			AnimationsUISetCurrentAnimation(CurrentFile.CurrentAnimation)
 		}, nil)
 
 	animationsListContainer = NewBox(bounds, []*Entity{
@@ -0,0 +1,51 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	// canvasEntity is where the canvas is drawn. It's under everything else,
+	// so it's only hovered when nothing else is under the mouse
+	canvasEntity *Entity
+)
+
+// CanvasUIHovered returns true if the mouse is over the canvas and not over
+// any of the UI, tools should only get mouse presses when it is
+func CanvasUIHovered() bool {
+	if canvasEntity == nil {
+		return false
+	}
+	hoverable, ok := canvasEntity.GetHoverable()
+	return ok && hoverable.Hovered
+}
+
+// CanvasUIUpdateHovered sets if the canvas is hovered. overUI is true if the
+// mouse is over anything else
+func CanvasUIUpdateHovered(overUI bool) {
+	moveable, ok := canvasEntity.GetMoveable()
+	if !ok {
+		return
+	}
+	hoverable, ok := canvasEntity.GetHoverable()
+	if !ok {
+		return
+	}
+	hoverable.Hovered = !overUI && rl.CheckCollisionPointRec(rl.GetMousePosition(), moveable.Bounds)
+}
+
+// NewCanvasUI returns the canvas entity, it fills the window
+func NewCanvasUI() *Entity {
+	canvasEntity = NewBox(rl.NewRectangle(0, 0, float32(rl.GetScreenWidth()), float32(rl.GetScreenHeight())), []*Entity{}, FlowDirectionHorizontal)
+	canvasEntity.Name = "canvas"
+	canvasEntity.SetZIndex(ZIndexCanvas)
+	if res, ok := canvasEntity.GetResizeable(); ok {
+		res.OnResize = func(entity *Entity) {
+			if moveable, ok := entity.GetMoveable(); ok {
+				moveable.Bounds.Width = float32(rl.GetScreenWidth())
+				moveable.Bounds.Height = float32(rl.GetScreenHeight())
+			}
+		}
+	}
+	return canvasEntity
+}
@@ -0,0 +1,133 @@
+package main
+
+// commandsEnabled checks if a command can run right now. Commands use their
+// keymap name if they have one. Buttons for a command are disabled while it
+// can't run
+var commandsEnabled = map[string]func() bool{
+	"undo": func() bool {
+		return !CurrentFile.InTransaction() && CurrentFile.historyOffset < int32(len(CurrentFile.History))
+	},
+	"redo": func() bool {
+		return !CurrentFile.InTransaction() && CurrentFile.historyOffset > 0
+	},
+	"clipSelection": func() bool {
+		return CurrentFile.DoingSelection
+	},
+	"fitCanvasToSelection": func() bool {
+		return CurrentFile.DoingSelection
+	},
+	"transformSelection": func() bool {
+		return CurrentFile.DoingSelection
+	},
+	"flipLayerHorizontal": func() bool {
+		return !CurrentFile.ReadOnly
+	},
+	"flipLayerVertical": func() bool {
+		return !CurrentFile.ReadOnly
+	},
+	"flipImageHorizontal": func() bool {
+		return !CurrentFile.ReadOnly
+	},
+	"flipImageVertical": func() bool {
+		return !CurrentFile.ReadOnly
+	},
+	"flipSelectionHorizontal": func() bool {
+		return CurrentFile.DoingSelection
+	},
+	"flipSelectionVertical": func() bool {
+		return CurrentFile.DoingSelection
+	},
+	"rotateClockwise": func() bool {
+		return CurrentFile.DoingSelection
+	},
+	"rotateCounterClockwise": func() bool {
+		return CurrentFile.DoingSelection
+	},
+	"stampVisible": func() bool {
+		return !CurrentFile.ReadOnly
+	},
+	"toggleOutlineEffect": func() bool {
+		return !CurrentFile.ReadOnly
+	},
+	"toggleShadowEffect": func() bool {
+		return !CurrentFile.ReadOnly
+	},
+	"toggleOverlayEffect": func() bool {
+		return !CurrentFile.ReadOnly
+	},
+	"applyLayerEffects": func() bool {
+		return !CurrentFile.ReadOnly && CurrentFile.GetCurrentLayer().Effects.Any()
+	},
+	"duplicateFrame": func() bool {
+		return !CurrentFile.ReadOnly && previewAnimationFrame+1 < CurrentFile.frameCount()
+	},
+	"clearFrame": func() bool {
+		return !CurrentFile.ReadOnly
+	},
+	"toolRight": func() bool {
+		return CurrentFile.DoingSelection
+	},
+	"toolLeft": func() bool {
+		return CurrentFile.DoingSelection
+	},
+	"toolDown": func() bool {
+		return CurrentFile.DoingSelection
+	},
+	"toolUp": func() bool {
+		return CurrentFile.DoingSelection
+	},
+	"repeatLast": func() bool {
+		return lastCommand.run != nil
+	},
+}
+
+// lastCommand is the last command run with RunCommand
+var lastCommand struct {
+	name string
+	run  func()
+}
+
+// RunCommand runs the command if it's enabled and remembers it so that
+// RepeatLastCommand can run it again. run mustn't ask for anything, commands
+// from dialogs use the values they were applied with
+func RunCommand(name string, run func()) {
+	if !CommandEnabled(name) {
+		return
+	}
+	run()
+	lastCommand.name = name
+	lastCommand.run = run
+}
+
+// RepeatLastCommand runs the last command run with RunCommand again
+func RepeatLastCommand() {
+	if lastCommand.run == nil {
+		UIWarning(T("There isn't a command to repeat"))
+		return
+	}
+	RunCommand(lastCommand.name, lastCommand.run)
+}
+
+// CommandEnabled returns true if the command can run. Unknown commands are
+// always enabled
+func CommandEnabled(name string) bool {
+	if check, ok := commandsEnabled[name]; ok {
+		return check()
+	}
+	return true
+}
+
+// EnabledWhen disables the entity while check returns false
+func (entity *Entity) EnabledWhen(check func() bool) *Entity {
+	if interactable, ok := entity.GetInteractable(); ok {
+		interactable.EnabledWhen = check
+	}
+	return entity
+}
+
+// SetCommand disables the entity while the command can't run
+func (entity *Entity) SetCommand(name string) *Entity {
+	return entity.EnabledWhen(func() bool {
+		return CommandEnabled(name)
+	})
+}
@@ -17,6 +17,9 @@ var (
 
 	currentColorPlusTexture     rl.Texture2D
 	currentColorNegativeTexture rl.Texture2D
+
+	// The left or right color being dragged to the palette
+	currentColorDragging *Moveable
 )
 
 type colorEditing int32
@@ -260,7 +263,20 @@ func CurrentColorSetRightColor(color rl.Color) {
 	SetUIHexColor(color)
 }
 
-func currentColorUIAddColor(color rl.Color) *Entity {
+// CurrentColorUIDropTarget returns the left or right color if the mouse is
+// over it, or nil
+func CurrentColorUIDropTarget() *Entity {
+	for _, target := range []*Entity{currentColorLeft, currentColorRight} {
+		if moveable, ok := target.GetMoveable(); ok && rl.CheckCollisionPointRec(rl.GetMousePosition(), moveable.Bounds) {
+			return target
+		}
+	}
+	return nil
+}
+
+// currentColorUIAddColor adds a box showing a color, it can be dragged to an
+// empty part of the palette to add color() to it
+func currentColorUIAddColor(color func() rl.Color) *Entity {
 	var w float32
 	var h float32
 	if res, err := scene.QueryID(currentColorBox.ID); err == nil {
@@ -269,7 +285,36 @@ func currentColorUIAddColor(color rl.Color) *Entity {
 		h = moveable.Bounds.Width / 4
 	}
 
-	e := NewRenderTexture(rl.NewRectangle(0, 0, w, h), nil, nil)
+	e := NewRenderTexture(rl.NewRectangle(0, 0, w, h),
+		func(entity *Entity, button MouseButton) {
+			// Up
+			if currentColorDragging == nil {
+				return
+			}
+			currentColorDragging = nil
+			currentColorBox.FlowChildren()
+			if PaletteUIOverEmptySlot() {
+				PaletteUIAppendColor(color())
+			}
+		},
+		func(entity *Entity, button MouseButton, isHeld bool) {
+			// Down
+			if isHeld && button == rl.MouseLeftButton {
+				if currentColorDragging == nil {
+					if moveable, ok := entity.GetMoveable(); ok {
+						currentColorDragging = moveable
+						entity.BringToFront()
+					}
+				}
+				if currentColorDragging != nil {
+					currentColorDragging.Bounds.X = rl.GetMousePosition().X - currentColorDragging.Bounds.Width/2
+					currentColorDragging.Bounds.Y = rl.GetMousePosition().Y - currentColorDragging.Bounds.Height/2
+				}
+			}
+		})
+	if moveable, ok := e.GetMoveable(); ok {
+		moveable.Draggable = true
+	}
 
 	currentColorBox.PushChild(e)
 	currentColorBox.FlowChildren()
@@ -301,8 +346,8 @@ func NewCurrentColorUI(bounds rl.Rectangle) *Entity {
 
 	currentColorBox = NewBox(bounds, []*Entity{}, FlowDirectionHorizontal)
 
-	currentColorLeft = currentColorUIAddColor(LeftColor)
-	currentColorRight = currentColorUIAddColor(RightColor)
+	currentColorLeft = currentColorUIAddColor(func() rl.Color { return LeftColor })
+	currentColorRight = currentColorUIAddColor(func() rl.Color { return RightColor })
 	CurrentColorSetRightColor(RightColor)
 	CurrentColorSetLeftColor(LeftColor)
 
@@ -333,10 +378,12 @@ func NewCurrentColorUI(bounds rl.Rectangle) *Entity {
 						if color == PaletteUICurrentColorEntity {
 							PaletteUIRemoveColor(PaletteUICurrentColorEntity)
 							PaletteUIPreviousColor()
+							prev := append([]rl.Color{}, Settings.PaletteData[CurrentFile.CurrentPalette].data...)
 							Settings.PaletteData[CurrentFile.CurrentPalette].data = append(
 								Settings.PaletteData[CurrentFile.CurrentPalette].data[:index],
 								Settings.PaletteData[CurrentFile.CurrentPalette].data[index+1:]...,
 							)
+							CurrentFile.AppendPaletteHistory(CurrentFile.CurrentPalette, prev)
 							SaveSettings()
 							return
 						}
@@ -347,13 +394,9 @@ func NewCurrentColorUI(bounds rl.Rectangle) *Entity {
 				// add color
 				switch button {
 				case rl.MouseLeftButton:
-					PaletteUIAddColor(LeftColor, int32(len(Settings.PaletteData[CurrentFile.CurrentPalette].Strings)))
-					Settings.PaletteData[CurrentFile.CurrentPalette].data = append(Settings.PaletteData[CurrentFile.CurrentPalette].data, LeftColor)
-					SaveSettings()
+					PaletteUIAppendColor(LeftColor)
 				case rl.MouseRightButton:
-					PaletteUIAddColor(RightColor, int32(len(Settings.PaletteData[CurrentFile.CurrentPalette].Strings)))
-					Settings.PaletteData[CurrentFile.CurrentPalette].data = append(Settings.PaletteData[CurrentFile.CurrentPalette].data, RightColor)
-					SaveSettings()
+					PaletteUIAppendColor(RightColor)
 				}
 			}
 
@@ -24,6 +24,9 @@ func EditorsUIAddButton(file *File) {
 	if file.FileChanged {
 		filename = "*" + filename
 	}
+	if file.ReadOnly {
+		filename += " " + T("(read-only)")
+	}
 
 	fo := rl.MeasureTextEx(Font, filename, UIFontSize, 1)
 	button := NewButtonText(
@@ -43,6 +46,7 @@ func EditorsUIAddButton(file *File) {
 
 			AnimationsUIRebuildList()
 			LayersUIRebuildList()
+			PaletteUIRebuildPalette()
 		}, nil)
 	if isCurrent {
 		// deselect old currentButton
@@ -0,0 +1,140 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	exportBox *Entity
+)
+
+// ExportUIShowDialog shows the export settings dialog. It's rebuilt so that
+// it lists the current export presets
+func ExportUIShowDialog() {
+	exportBox.DestroyNested()
+	exportBox.Destroy()
+	NewExportUI()
+	exportBox.Show()
+}
+
+// ExportUIHideDialog hides the dialog
+func ExportUIHideDialog() {
+	RemoveCapturedInput()
+	exportBox.Hide()
+}
+
+// ExportUIMakeMatteInput is a text input for the hex color of the preset's
+// matte. The matte is only changed while matte is selected
+func ExportUIMakeMatteInput(preset *ExportPreset, matte *Entity, width float32) *Entity {
+	label := preset.Matte
+	if label == "" {
+		label = ColorToHex(LeftColor)
+	}
+	return NewInput(rl.NewRectangle(0, 0, width, UIButtonHeight), label, TextAlignLeft, false,
+		func(entity *Entity, button MouseButton) {
+			// button up
+		}, nil,
+		func(entity *Entity, key Key) {
+			// key pressed
+			drawable, ok := entity.GetDrawable()
+			if !ok {
+				return
+			}
+			drawableText, ok := drawable.DrawableType.(*DrawableText)
+			if !ok {
+				return
+			}
+
+			switch {
+			case key == rl.KeyBackspace:
+				if len(drawableText.Label) > 0 {
+					drawableText.Label = drawableText.Label[:len(drawableText.Label)-1]
+				}
+			case key == rl.KeyEnter || key == rl.KeyTab:
+				RemoveCapturedInput()
+			case key >= rl.KeyA && key <= rl.KeyF:
+				drawableText.Label += string(rune(key + 'a' - 'A'))
+			case key >= rl.KeyZero && key <= rl.KeyNine:
+				drawableText.Label += string(rune(key))
+			}
+
+			if hoverable, ok := matte.GetHoverable(); ok && hoverable.Selected {
+				preset.Matte = drawableText.Label
+				SaveSettings()
+			}
+		})
+}
+
+// NewExportUI returns the export settings dialog, it's hidden until it's
+// opened from the file menu. Each export preset can be matted onto a
+// background color or have its alpha premultiplied
+func NewExportUI() *Entity {
+	names := make([]string, 0, len(Settings.ExportPresets))
+	for _, preset := range Settings.ExportPresets {
+		names = append(names, preset.Name)
+	}
+	labelWidth := menuMeasureLabels(names...).X + 10
+	toggleWidth := menuMeasureLabels("matte", "premultiplied").X + 10
+	inputWidth := UIFontSize * 2 * 5
+
+	width := labelWidth + toggleWidth*2 + inputWidth
+	rows := []*Entity{
+		NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight), T("export settings"), TextAlignCenter, false, nil, nil),
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight), "X", TextAlignCenter, false,
+				func(entity *Entity, button MouseButton) {
+					ExportUIHideDialog()
+				}, nil),
+		}, FlowDirectionHorizontal),
+	}
+	for i := range Settings.ExportPresets {
+		preset := &Settings.ExportPresets[i]
+
+		var input *Entity
+		matte := NewButtonToggle(rl.NewRectangle(0, 0, toggleWidth, UIButtonHeight), T("matte"), TextAlignCenter, preset.Matte != "",
+			func(entity *Entity, selected bool) {
+				preset.Matte = ""
+				if selected {
+					if drawable, ok := input.GetDrawable(); ok {
+						if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+							preset.Matte = dt.Label
+						}
+					}
+				}
+				SaveSettings()
+			})
+		input = ExportUIMakeMatteInput(preset, matte, inputWidth)
+		premultiplied := NewButtonToggle(rl.NewRectangle(0, 0, toggleWidth, UIButtonHeight), T("premultiplied"), TextAlignCenter, preset.Premultiplied,
+			func(entity *Entity, selected bool) {
+				preset.Premultiplied = selected
+				SaveSettings()
+			})
+
+		rows = append(rows, NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, labelWidth, UIButtonHeight), preset.Name, TextAlignLeft, false, nil, nil),
+			matte,
+			input,
+			premultiplied,
+		}, FlowDirectionHorizontal))
+	}
+	for _, row := range rows {
+		row.FlowChildren()
+	}
+
+	height := UIButtonHeight * float32(len(rows))
+	exportBox = NewBox(rl.NewRectangle(
+		float32(rl.GetScreenWidth())/2-width/2,
+		float32(rl.GetScreenHeight())/2-height/2,
+		width,
+		height,
+	), rows, FlowDirectionVertical)
+	if drawable, ok := exportBox.GetDrawable(); ok {
+		drawable.DrawBackground = true
+		drawable.DrawBorder = true
+	}
+	exportBox.FlowChildren()
+	exportBox.SetZIndex(ZIndexDialog)
+	exportBox.Hide()
+
+	return exportBox
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	// helpCategories are the headings the bindings are listed under, in order
+	helpCategories = []string{"File", "Edit", "Selection", "Tools", "Palette", "Layers", "Animation", "Cursor", "View", "Other"}
+	// helpCategory is the heading of each binding, bindings which aren't here
+	// are listed under "Other"
+	helpCategory = map[string]string{
+		"new":         "File",
+		"open":        "File",
+		"close":       "File",
+		"save":        "File",
+		"saveAs":      "File",
+		"export":      "File",
+		"batchExport": "File",
+		"reExport":    "File",
+
+		"undo":                   "Edit",
+		"redo":                   "Edit",
+		"repeatLast":             "Edit",
+		"resize":                 "Edit",
+		"flipHorizontal":         "Edit",
+		"flipVertical":           "Edit",
+		"rotateClockwise":        "Edit",
+		"rotateCounterClockwise": "Edit",
+		"cancel":                 "Edit",
+		"confirm":                "Edit",
+
+		"copy":         "Selection",
+		"paste":        "Selection",
+		"delete":       "Selection",
+		"selectAll":    "Selection",
+		"selectOpaque": "Selection",
+
+		"pixelBrush":   "Tools",
+		"eraser":       "Tools",
+		"fill":         "Tools",
+		"picker":       "Tools",
+		"selector":     "Tools",
+		"scatterBrush": "Tools",
+		"curve":        "Tools",
+		"warp":         "Tools",
+		"nineSlice":    "Tools",
+		"drawLine":     "Tools",
+		"skew":         "Tools",
+
+		"paletteNext":     "Palette",
+		"palettePrevious": "Palette",
+
+		"layerUp":      "Layers",
+		"layerDown":    "Layers",
+		"stampVisible": "Layers",
+
+		"frameNext":      "Animation",
+		"framePrevious":  "Animation",
+		"animationStart": "Animation",
+		"animationEnd":   "Animation",
+		"duplicateFrame": "Animation",
+		"clearFrame":     "Animation",
+
+		"toolLeft":  "Cursor",
+		"toolRight": "Cursor",
+		"toolUp":    "Cursor",
+		"toolDown":  "Cursor",
+
+		"toggleGrid":        "View",
+		"toggleCoordinates": "View",
+		"cycleViewFilter":   "View",
+		"toggleSeamCheck":   "View",
+		"precisionMode":     "View",
+		"showDebug":         "View",
+		"help":              "View",
+		"pan":               "View",
+	}
+)
+
+// KeyName returns a readable name for the key
+func KeyName(key Key) string {
+	switch {
+	case key >= rl.KeyA && key <= rl.KeyZ, key >= rl.KeyZero && key <= rl.KeyNine:
+		return string(rune(key))
+	case key >= rl.KeyF1 && key <= rl.KeyF12:
+		return fmt.Sprintf("F%d", key-rl.KeyF1+1)
+	}
+
+	switch key {
+	case rl.KeyLeftControl, rl.KeyRightControl:
+		return "Ctrl"
+	case rl.KeyLeftShift, rl.KeyRightShift:
+		return "Shift"
+	case rl.KeyLeftAlt, rl.KeyRightAlt:
+		return "Alt"
+	case rl.KeyLeftSuper, rl.KeyRightSuper:
+		return "Super"
+	case rl.KeyEscape:
+		return "Esc"
+	case rl.KeyEnter:
+		return "Enter"
+	case rl.KeyDelete:
+		return "Delete"
+	case rl.KeyBackspace:
+		return "Backspace"
+	case rl.KeySpace:
+		return "Space"
+	case rl.KeyTab:
+		return "Tab"
+	case rl.KeyUp:
+		return "Up"
+	case rl.KeyDown:
+		return "Down"
+	case rl.KeyLeft:
+		return "Left"
+	case rl.KeyRight:
+		return "Right"
+	case rl.KeyLeftBracket:
+		return "["
+	case rl.KeyRightBracket:
+		return "]"
+	case rl.KeySlash:
+		return "/"
+	case rl.KeyComma:
+		return ","
+	case rl.KeyPeriod:
+		return "."
+	case rl.KeyMinus:
+		return "-"
+	case rl.KeyEqual:
+		return "="
+	}
+	return fmt.Sprintf("Key(%d)", key)
+}
+
+// BindingName returns every combination of the binding, e.g. "Ctrl+Z / Ctrl+Y"
+func BindingName(combinations [][]Key) string {
+	names := make([]string, 0, len(combinations))
+	for _, keys := range combinations {
+		combo := make([]string, 0, len(keys))
+		for _, key := range keys {
+			combo = append(combo, KeyName(key))
+		}
+		names = append(names, strings.Join(combo, "+"))
+	}
+	return strings.Join(names, " / ")
+}
+
+// HelpUIDraw draws the overlay listing the bindings from the current keymap
+func HelpUIDraw() {
+	rl.DrawRectangle(0, 0, int32(rl.GetScreenWidth()), int32(rl.GetScreenHeight()), rl.NewColor(0, 0, 0, 224))
+
+	grouped := make(map[string][]string)
+	for name := range Settings.KeymapData {
+		category, ok := helpCategory[name]
+		if !ok {
+			category = "Other"
+		}
+		grouped[category] = append(grouped[category], name)
+	}
+
+	padding := UIFontSize
+	lineHeight := UIFontSize * 1.25
+	x, y := padding, padding
+
+	rl.DrawTextEx(Font, T("Keybindings (F1 or Esc to close)"), rl.NewVector2(x, y), UIFontSize*1.5, 1, rl.White)
+	top := y + lineHeight*2
+	y = top
+
+	// Fixed width columns which wrap when they reach the bottom of the screen
+	columnWidth := rl.MeasureTextEx(Font, strings.Repeat("m", 40), UIFontSize, 1).X
+	nextLine := func() {
+		y += lineHeight
+		if y+lineHeight > float32(rl.GetScreenHeight())-padding {
+			y = top
+			x += columnWidth
+		}
+	}
+
+	for _, category := range helpCategories {
+		names := grouped[category]
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+
+		rl.DrawTextEx(Font, T(category), rl.NewVector2(x, y), UIFontSize, 1, rl.Yellow)
+		nextLine()
+		for _, name := range names {
+			rl.DrawTextEx(Font, name, rl.NewVector2(x+padding, y), UIFontSize, 1, rl.LightGray)
+			rl.DrawTextEx(Font, BindingName(Settings.KeymapData[name]), rl.NewVector2(x+columnWidth/2, y), UIFontSize, 1, rl.White)
+			nextLine()
+		}
+		nextLine()
+	}
+}
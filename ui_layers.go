@@ -12,6 +12,10 @@ var (
 
 	layerList          *Entity
 	layerListContainer *Entity
+
+	// layerRenaming is the layer whose name is being typed, it's -1 when no
+	// layer is being renamed
+	layerRenaming int32 = -1
 )
 
 // LayersUISetCurrentLayer can be used to activate a callback on a layer button
@@ -25,6 +29,7 @@ func LayersUISetCurrentLayer(index int32) {
 			interactable := res.Components[entity.Scene.ComponentsMap["interactable"]].(*Interactable)
 
 			interactable.OnMouseUp(entity, rl.MouseLeftButton)
+			layerList.ScrollTo(entity)
 
 			currentLayerHoverable = hoverable
 		}
@@ -36,10 +41,6 @@ func LayersUIMakeList(bounds rl.Rectangle) {
 	layerList = NewScrollableList(rl.NewRectangle(0, UIButtonHeight, bounds.Width, bounds.Height-UIButtonHeight), []*Entity{}, FlowDirectionVerticalReversed|FlowDirectionNoWrap)
 	// All of the layers
 	for i, layer := range CurrentFile.Layers {
-		if i == len(CurrentFile.Layers)-1 {
-			// ignore hidden layer
-			continue
-		}
 		layerList.PushChild(LayersUIMakeLayerBox(int32(i), layer))
 	}
 	layerList.FlowChildren()
@@ -60,6 +61,12 @@ func LayersUIRebuildList() {
 	}
 }
 
+// layersUIEditable disables the buttons which change the layers while the
+// file is read-only
+func layersUIEditable() bool {
+	return !CurrentFile.ReadOnly
+}
+
 // LayersUIMakeLayerBox makes a box containing controls and the name for a layer
 func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 	var bounds rl.Rectangle
@@ -85,28 +92,39 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 				}
 			}
 		}, nil)
+	exportIcon := func() string {
+		if CurrentFile.Layers[y].NoExport {
+			return "./res/icons/export_off.png"
+		}
+		return "./res/icons/export_on.png"
+	}
+	export := NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight/2, UIButtonHeight/2), GetFile(exportIcon()), false,
+		func(entity *Entity, button MouseButton) {
+			// button up
+			CurrentFile.Layers[y].NoExport = !CurrentFile.Layers[y].NoExport
+			CurrentFile.FileChanged = true
+			if drawable, ok := entity.GetDrawable(); ok {
+				if drawableTexture, ok := drawable.DrawableType.(*DrawableTexture); ok {
+					drawableTexture.SetTexture(GetFile(exportIcon()))
+				}
+			}
+		}, nil)
 	moveUp := NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight/2, UIButtonHeight/2), GetFile("./res/icons/arrow_up.png"), false,
 		func(entity *Entity, button MouseButton) {
 			// button up
 			if err := CurrentFile.MoveLayerUp(y, true); err == nil {
-				if CurrentFile.CurrentLayer == y {
-					CurrentFile.SetCurrentLayer(y + 1)
-				}
 				LayersUIRebuildList()
 				CurrentFile.RedrawRenderLayer()
 			}
-		}, nil)
+		}, nil).EnabledWhen(layersUIEditable)
 	moveDown := NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight/2, UIButtonHeight/2), GetFile("./res/icons/arrow_down.png"), false,
 		func(entity *Entity, button MouseButton) {
 			// button up
 			if err := CurrentFile.MoveLayerDown(y, true); err == nil {
-				if CurrentFile.CurrentLayer == y {
-					CurrentFile.SetCurrentLayer(y - 1)
-				}
 				LayersUIRebuildList()
 				CurrentFile.RedrawRenderLayer()
 			}
-		}, nil)
+		}, nil).EnabledWhen(layersUIEditable)
 	mergeDown := NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight/2, UIButtonHeight/2), GetFile("./res/icons/merge_down.png"), false,
 		func(entity *Entity, button MouseButton) {
 			// button up
@@ -119,7 +137,9 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 			} else {
 				log.Println(err)
 			}
-		}, nil)
+		}, nil).EnabledWhen(func() bool {
+		return layersUIEditable() && y > 0 && len(CurrentFile.Layers) > 1
+	})
 	// getBlendModeFilePath := func(blendMode rl.BlendMode) string {
 	// 	var bm string
 	// 	switch blendMode {
@@ -162,12 +182,13 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 				LayersUIRebuildList()
 				CurrentFile.RedrawRenderLayer()
 			}
-		}, nil)
+		}, nil).EnabledWhen(layersUIEditable)
 
 	// Keep the buttons organized
 	buttonBox := NewBox(rl.NewRectangle(0, 0, UIButtonHeight*1.5, UIButtonHeight),
 		[]*Entity{
 			hidden,
+			export,
 			moveUp,
 			moveDown,
 			mergeDown,
@@ -176,14 +197,7 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 		},
 		FlowDirectionHorizontal)
 
-	preview := NewRenderTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight), nil, nil)
-	if res, err := scene.QueryID(preview.ID); err == nil {
-		drawable := res.Components[preview.Scene.ComponentsMap["drawable"]].(*Drawable)
-		renderTexture, ok := drawable.DrawableType.(*DrawableRenderTexture)
-		if ok {
-			renderTexture.Texture = layer.Canvas
-		}
-	}
+	preview := NewChunksView(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight), layer.Chunks)
 
 	isCurrent := CurrentFile.CurrentLayer == y
 	label := NewInput(rl.NewRectangle(0, 0, bounds.Width-UIButtonHeight*2.5, UIButtonHeight), layer.Name, TextAlignCenter, isCurrent,
@@ -202,6 +216,9 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 		nil,
 		func(entity *Entity, key Key) {
 			// key pressed
+			if layerRenaming != y {
+				return
+			}
 			if drawable, ok := entity.GetDrawable(); ok {
 				if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
 					if key == rl.KeyBackspace && len(drawableText.Label) > 0 {
@@ -224,6 +241,20 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 
 		})
 
+	// Double clicking renames the layer until the label loses focus
+	if interactable, ok := label.GetInteractable(); ok {
+		interactable.OnDoubleClick = func(entity *Entity, button MouseButton) {
+			if button == rl.MouseLeftButton {
+				layerRenaming = y
+			}
+		}
+		interactable.OnBlur = func(entity *Entity) {
+			if layerRenaming == y {
+				layerRenaming = -1
+			}
+		}
+	}
+
 	// Set current layer ref
 	if res, err := scene.QueryID(label.ID); err == nil {
 		hoverable := res.Components[label.Scene.ComponentsMap["hoverable"]].(*Hoverable)
@@ -249,17 +280,21 @@ func NewLayersUI(bounds rl.Rectangle) *Entity {
 	newLayerButton := NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight), GetFile("./res/icons/plus.png"), false,
 		func(entity *Entity, button MouseButton) {
 			// button up
-			CurrentFile.AddNewLayer()
+			if err := CurrentFile.AddNewLayer(); err != nil {
+				log.Println(err)
+				return
+			}
 			max := len(CurrentFile.Layers)
-			last := CurrentFile.Layers[max-2] // ignore the temp layer
+			last := CurrentFile.Layers[max-1]
 
 			if currentLayerHoverable != nil {
 				currentLayerHoverable.Selected = false
 			}
 
-			layerList.PushChild(LayersUIMakeLayerBox(int32(max-2), last))
+			layerList.PushChild(LayersUIMakeLayerBox(int32(max-1), last))
 			LayersUIRebuildList()
-		}, nil)
+			LayersUISetCurrentLayer(CurrentFile.CurrentLayer)
+		}, nil).EnabledWhen(layersUIEditable)
 
 	layerListContainer = NewBox(bounds, []*Entity{
 		newLayerButton,
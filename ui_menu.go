@@ -2,7 +2,6 @@ package main
 
 import (
 	"log"
-	"sync"
 	"time"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
@@ -13,17 +12,67 @@ var (
 	menuButtons *Entity
 )
 
+// menuMeasureLabels measures the widest of the translated labels
+func menuMeasureLabels(labels ...string) rl.Vector2 {
+	var widest rl.Vector2
+	for _, label := range labels {
+		if measured := rl.MeasureTextEx(Font, T(label)+"  ", UIFontSize, 1); measured.X > widest.X {
+			widest = measured
+		}
+	}
+	return widest
+}
+
+// menuItem is a button of a dropdown menu. Command is the keymap command
+// which enables it, if it has one
+type menuItem struct {
+	Label   string
+	Command string
+	OnClick func()
+}
+
+// menuItemLabels returns the labels of the items, for measuring them
+func menuItemLabels(items []menuItem) []string {
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = item.Label
+	}
+	return labels
+}
+
+// menuItemButtons returns the buttons of a dropdown menu, which are all as
+// wide as width
+func menuItemButtons(width float32, items []menuItem) []*Entity {
+	buttons := make([]*Entity, 0, len(items))
+	for _, item := range items {
+		item := item
+		button := NewButtonText(rl.NewRectangle(0, 0, width, UIFontSize*2), T(item.Label), TextAlignLeft, false,
+			func(entity *Entity, button MouseButton) {
+				item.OnClick()
+			}, nil)
+		if item.Command != "" {
+			button.SetCommand(item.Command)
+		}
+		buttons = append(buttons, button)
+	}
+	return buttons
+}
+
 // NewMenuUI returns a new entity
 func NewMenuUI(bounds rl.Rectangle) *Entity {
 	// Top level dropdown buttons
-	var fileButton, editButton, paletteButton *Entity
+	var fileButton, editButton, paletteButton, helpButton, prefsButton *Entity
 	// submenus
-	var fileSubMenu, editSubMenu, paletteSubMenu *Entity
+	var fileSubMenu, editSubMenu, paletteSubMenu, helpSubMenu, prefsSubMenu *Entity
 
 	// button is top level menu button, dropdown is the child elements,
 	showDropdown := func(button *Entity, dropdown *Entity) {
-		dropdown.Show()
-		mutex := &sync.RWMutex{}
+		if drawable, ok := dropdown.GetDrawable(); ok && drawable.Hidden {
+			if scrollable, ok := dropdown.GetScrollable(); ok {
+				scrollable.ScrollOffset = 0
+			}
+		}
+		dropdown.ShowAnimated()
 
 		// Clicked on button should simulate being hovered
 		hovered := map[*Entity]struct{}{
@@ -31,29 +80,19 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 		}
 
 		handleHovered := func(entity *Entity) {
-
 			if hoverable, ok := entity.GetHoverable(); ok {
 				hoverable.OnMouseEnter = func(entity *Entity) {
-					mutex.Lock()
 					hovered[entity] = struct{}{}
-					mutex.Unlock()
+					// Stops it from hiding if it's still being shown
+					if drawable, ok := dropdown.GetDrawable(); ok && !drawable.Hidden {
+						dropdown.ShowAnimated()
+					}
 				}
 				hoverable.OnMouseLeave = func(entity *Entity) {
-					go func() {
-						mutex.Lock()
-						delete(hovered, entity)
-						mutex.Unlock()
-
-						time.Sleep(500 * time.Millisecond)
-						mutex.Lock()
-						if len(hovered) == 0 {
-							if scrollable, ok := dropdown.GetScrollable(); ok {
-								scrollable.ScrollOffset = 0
-							}
-							dropdown.Hide()
-						}
-						mutex.Unlock()
-					}()
+					delete(hovered, entity)
+					if len(hovered) == 0 {
+						dropdown.HideAnimated(500 * time.Millisecond)
+					}
 				}
 			}
 		}
@@ -68,119 +107,313 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 
 	// Parent buttons
 	var measured rl.Vector2
-	measured = rl.MeasureTextEx(Font, " file ", UIFontSize, 1)
+	measured = rl.MeasureTextEx(Font, " "+T("file")+" ", UIFontSize, 1)
 	fileButton = NewButtonText(
 		rl.NewRectangle(100, 100, measured.X+10, UIFontSize*2),
-		" file ", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+		" "+T("file")+" ", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
 			showDropdown(entity, fileSubMenu)
 		}, nil)
 
-	measured = rl.MeasureTextEx(Font, " edit ", UIFontSize, 1)
+	measured = rl.MeasureTextEx(Font, " "+T("edit")+" ", UIFontSize, 1)
 	editButton = NewButtonText(
 		rl.NewRectangle(100, 100, measured.X+10, UIFontSize*2),
-		" edit ", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+		" "+T("edit")+" ", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			if CurrentFile.ReadOnly {
+				PlaySoundCue(SoundError)
+				return
+			}
 			showDropdown(entity, editSubMenu)
 		}, nil)
 
-	measured = rl.MeasureTextEx(Font, " palette ", UIFontSize, 1)
+	measured = rl.MeasureTextEx(Font, " "+T("palette")+" ", UIFontSize, 1)
 	paletteButton = NewButtonText(
 		rl.NewRectangle(100, 100, measured.X+10, UIFontSize*2),
-		" palette ", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+		" "+T("palette")+" ", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
 			showDropdown(entity, paletteSubMenu)
 		}, nil)
 
+	measured = rl.MeasureTextEx(Font, " "+T("help")+" ", UIFontSize, 1)
+	helpButton = NewButtonText(
+		rl.NewRectangle(100, 100, measured.X+10, UIFontSize*2),
+		" "+T("help")+" ", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			showDropdown(entity, helpSubMenu)
+		}, nil)
+
+	measured = rl.MeasureTextEx(Font, " "+T("prefs")+" ", UIFontSize, 1)
+	prefsButton = NewButtonText(
+		rl.NewRectangle(100, 100, measured.X+10, UIFontSize*2),
+		" "+T("prefs")+" ", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			showDropdown(entity, prefsSubMenu)
+		}, nil)
+
 	// Add to the bar
 	menuButtons = NewBox(bounds, []*Entity{
 		fileButton,
 		editButton,
 		paletteButton,
+		helpButton,
+		prefsButton,
 	}, FlowDirectionHorizontal)
 	menuButtons.FlowChildren()
 
 	// File menu
-	measured = rl.MeasureTextEx(Font, "close file    ", UIFontSize, 1)
+	templates := LoadTemplates()
+	templateLabels := make([]string, len(templates))
+	for i, t := range templates {
+		templateLabels[i] = Tf("new: %s", t.Name)
+	}
+	fileItems := []menuItem{
+		{"save", "", func() {
+			if len(CurrentFile.FileDir) > 0 {
+				CurrentFile.SaveAs(CurrentFile.FileDir)
+			} else {
+				UISaveAs()
+			}
+		}},
+		{"save as", "", UISaveAs},
+		{"open", "", UIOpen},
+		{"close file", "", UIClose},
+		{"batch export", "", BatchExport},
+		{"re-export", "", ReExport},
+		{"link export presets", "", func() {
+			CurrentFile.LinkExportProfiles()
+		}},
+		{"unlink export presets", "", func() {
+			CurrentFile.UnlinkExportProfiles()
+		}},
+		{"export settings", "", ExportUIShowDialog},
+		{"export animations", "", ExportAnimations},
+		{"layers to frames", "", func() {
+			UIOpenConverted(CurrentFile.LayersToFrames())
+		}},
+		{"tiles to layers", "", func() {
+			UIOpenConverted(CurrentFile.TilesToLayers())
+		}},
+		{"resize", "", func() {
+			if CurrentFile.ReadOnly {
+				PlaySoundCue(SoundError)
+				return
+			}
+			ResizeUIShowDialog()
+		}},
+		{"read-only", "", func() {
+			CurrentFile.ToggleReadOnly()
+		}},
+		{"properties", "", PropertiesUIShowDialog},
+		{"record timelapse", "", func() {
+			CurrentFile.ToggleTimelapse()
+		}},
+		{"export timelapse", "", UIExportTimelapse},
+		{"host session", "", func() {
+			CollabHost(CollabDefaultAddress)
+		}},
+		{"join session", "", UICollabJoin},
+		{"leave session", "", CollabLeave},
+	}
+	measured = menuMeasureLabels(append(append(menuItemLabels(fileItems), "new"), templateLabels...)...)
 	bounds.Y += UIFontSize * 2
 	bounds.Height = float32(rl.GetScreenHeight())
 	bounds.Width = measured.X + 10
-	fileSubMenu = NewBox(bounds, []*Entity{
-		NewButtonText( // New
-			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"new", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
-				UINew()
-			}, nil),
-		NewButtonText( // Save
-			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"save", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
-				if len(CurrentFile.FileDir) > 0 {
-					CurrentFile.SaveAs(CurrentFile.FileDir)
-				} else {
-					UISaveAs()
-				}
-			}, nil),
-		NewButtonText( // Save As
-			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"save as", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
-				UISaveAs()
-			}, nil),
-		NewButtonText( // Open
-			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"open", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
-				UIOpen()
-			}, nil),
-		NewButtonText( // Close
+	newButtons := menuItemButtons(measured.X+10, []menuItem{{"new", "", UINew}})
+	for i, t := range templates {
+		t := t
+		newButtons = append(newButtons, NewButtonText( // New from template
 			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"close file", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
-				UIClose()
-			}, nil),
-		NewButtonText( // Resize
-			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"resize", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
-				ResizeUIShowDialog()
-			}, nil),
-	}, FlowDirectionVertical)
+			templateLabels[i], TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				UINewFromTemplate(t)
+			}, nil))
+	}
+	fileSubMenu = NewBox(bounds, append(newButtons, menuItemButtons(measured.X+10, fileItems)...), FlowDirectionVertical)
 	fileSubMenu.FlowChildren()
+	fileSubMenu.SetZIndex(ZIndexMenu).SetTween(rl.NewVector2(0, -UIFontSize))
 	fileSubMenu.Hide()
 
 	// Edit menu
-	measured = rl.MeasureTextEx(Font, "flip (horizontal) ", UIFontSize, 1)
+	editItems := []menuItem{
+		{"undo", "undo", func() {
+			CurrentFile.Undo()
+		}},
+		{"redo", "redo", func() {
+			CurrentFile.Redo()
+		}},
+		{"paste from file", "", UIPasteFromFile},
+		{"stamps", "", StampsUIToggle},
+		{"timeline", "", TimelineUIToggle},
+		{"flip layer (horizontal)", "flipLayerHorizontal", func() {
+			RunCommand("flipLayerHorizontal", func() {
+				CurrentFile.FlipLayer(true)
+			})
+		}},
+		{"flip layer (vertical)", "flipLayerVertical", func() {
+			RunCommand("flipLayerVertical", func() {
+				CurrentFile.FlipLayer(false)
+			})
+		}},
+		{"flip image (horizontal)", "flipImageHorizontal", func() {
+			RunCommand("flipImageHorizontal", func() {
+				CurrentFile.FlipAllLayers(true)
+			})
+		}},
+		{"flip image (vertical)", "flipImageVertical", func() {
+			RunCommand("flipImageVertical", func() {
+				CurrentFile.FlipAllLayers(false)
+			})
+		}},
+		{"flip selection (horizontal)", "flipSelectionHorizontal", func() {
+			RunCommand("flipSelectionHorizontal", func() {
+				CurrentFile.PreviewFlipSelection(true)
+			})
+		}},
+		{"flip selection (vertical)", "flipSelectionVertical", func() {
+			RunCommand("flipSelectionVertical", func() {
+				CurrentFile.PreviewFlipSelection(false)
+			})
+		}},
+		{"rotate (clockwise)", "rotateClockwise", func() {
+			RunCommand("rotateClockwise", func() {
+				CurrentFile.RotateClockwise()
+			})
+		}},
+		{"rotate (counter-clockwise)", "rotateCounterClockwise", func() {
+			RunCommand("rotateCounterClockwise", func() {
+				CurrentFile.RotateCounterClockwise()
+			})
+		}},
+		{"outline", "", func() {
+			RunCommand("outline", func() {
+				CurrentFile.Outline()
+			})
+		}},
+		{"select opaque", "", func() {
+			RunCommand("selectOpaque", func() {
+				CurrentFile.SelectOpaque()
+			})
+		}},
+		{"remove bg (edges)", "", func() {
+			RunCommand("removeBackgroundEdges", func() {
+				CurrentFile.RemoveBackground(LeftColor, true)
+			})
+		}},
+		{"remove bg (all)", "", func() {
+			RunCommand("removeBackgroundAll", func() {
+				CurrentFile.RemoveBackground(LeftColor, false)
+			})
+		}},
+		{"pixel aspect", "", func() {
+			CurrentFile.CyclePixelAspect()
+		}},
+		{"stamp visible", "stampVisible", func() {
+			RunCommand("stampVisible", func() {
+				CurrentFile.StampVisible()
+				LayersUIRebuildList()
+			})
+		}},
+		{"effect: outline", "toggleOutlineEffect", func() {
+			RunCommand("toggleOutlineEffect", func() {
+				CurrentFile.ToggleOutlineEffect()
+			})
+		}},
+		{"effect: shadow", "toggleShadowEffect", func() {
+			RunCommand("toggleShadowEffect", func() {
+				CurrentFile.ToggleShadowEffect()
+			})
+		}},
+		{"effect: color overlay", "toggleOverlayEffect", func() {
+			RunCommand("toggleOverlayEffect", func() {
+				CurrentFile.ToggleOverlayEffect()
+			})
+		}},
+		{"apply effects", "applyLayerEffects", func() {
+			RunCommand("applyLayerEffects", func() {
+				CurrentFile.ApplyLayerEffects()
+			})
+		}},
+		{"clip selection", "clipSelection", func() {
+			RunCommand("clipSelection", func() {
+				CurrentFile.ClipSelection()
+			})
+		}},
+		{"fit canvas to selection", "fitCanvasToSelection", func() {
+			CurrentFile.ExpandCanvasToSelection()
+		}},
+		{"transform selection", "transformSelection", TransformUIShowDialog},
+		{"duplicate frame", "duplicateFrame", func() {
+			RunCommand("duplicateFrame", func() {
+				DuplicateCurrentFrame()
+			})
+		}},
+		{"clear frame", "clearFrame", func() {
+			RunCommand("clearFrame", func() {
+				ClearCurrentFrame()
+			})
+		}},
+		{"repeat last", "repeatLast", RepeatLastCommand},
+	}
+	measured = menuMeasureLabels(menuItemLabels(editItems)...)
 	fileButtonMoveable, ok := editButton.GetMoveable()
 	if !ok {
 		log.Panic("fileButton error")
 	}
 	bounds.X += fileButtonMoveable.Bounds.Width
 	bounds.Width = measured.X + 10
-	editSubMenu = NewBox(bounds, []*Entity{
-		NewButtonText( // Flip (horizontal)
-			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"flip (horizontal)", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
-				CurrentFile.FlipHorizontal()
-			}, nil),
-		NewButtonText( // Flip (vertical)
-			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"flip (vertical)", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
-				CurrentFile.FlipVertical()
-			}, nil),
-		NewButtonText( // Outline
-			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"outline", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
-				CurrentFile.Outline()
-			}, nil),
-	}, FlowDirectionVertical)
+	editSubMenu = NewBox(bounds, menuItemButtons(measured.X+10, editItems), FlowDirectionVertical)
 	editSubMenu.FlowChildren()
+	editSubMenu.SetZIndex(ZIndexMenu).SetTween(rl.NewVector2(0, -UIFontSize))
 	editSubMenu.Hide()
 
 	// Palette menu
-	measured = rl.MeasureTextEx(Font, "delete (hold shift) ", UIFontSize, 1)
+	lockLabel := func() string {
+		if Settings.LockToPalette {
+			return T("lock to palette: on")
+		}
+		return T("lock to palette: off")
+	}
+	tileColorsLabel := func() string {
+		if CurrentFile.MaxTileColors <= 0 {
+			return T("tile colors: any")
+		}
+		return Tf("tile colors: %d", CurrentFile.MaxTileColors)
+	}
+	colorTileLabel := func() string {
+		width, height := CurrentFile.ColorTileSize()
+		return Tf("color tiles: %dx%d", width, height)
+	}
+	mergeThresholdLabel := func() string {
+		return Tf("merge threshold: %d", paletteMergeThreshold)
+	}
+	rampHueShiftLabel := func() string {
+		return Tf("ramp hue shift: %d", rampHueShift)
+	}
+	measured = menuMeasureLabels("new", "delete (hold shift)", "color usage", "duplicate", "create from image", "set swap base", "add as alt", "preview alt", "delete alt (shift)", "lock to palette: on", "lock to palette: off", "tile colors: any", Tf("tile colors: %d", 16), Tf("color tiles: %dx%d", 16, 16), "tile color report", "sort by hue", "sort by luminance", "remove duplicates", "remove unused", "merge similar", Tf("merge threshold: %d", 64), "hue shift ramp", Tf("ramp hue shift: %d", 45), "copy to file", "copy to global", "---- Load ----", "---- Built-in ----")
 	editButtonMoveable, ok := editButton.GetMoveable()
 	if !ok {
 		log.Panic("editButton error")
 	}
 	bounds.X += editButtonMoveable.Bounds.Width
 	bounds.Width = measured.X + 10
+
+	// Tile colors cycles through the usual limits
+	tileColorsButton := NewButtonText(
+		rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+		tileColorsLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+			limits := []int32{0, 2, 3, 4, 8, 16}
+			next := limits[0]
+			for i, limit := range limits {
+				if limit == CurrentFile.MaxTileColors && i+1 < len(limits) {
+					next = limits[i+1]
+				}
+			}
+			CurrentFile.SetMaxTileColors(next)
+			if drawable, ok := entity.GetDrawable(); ok {
+				if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+					dt.Label = tileColorsLabel()
+				}
+			}
+		}, nil)
 	paletteSubMenu = NewScrollableList(bounds, []*Entity{
 		NewButtonText( // New
 			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"new", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+			T("new"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
 				Settings.PaletteData = append(Settings.PaletteData, Palette{
 					Name: "new",
 				})
@@ -189,23 +422,25 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 				SaveSettings()
 
 				PaletteUIRebuildPalette()
-				paletteSubMenu.Hide()
+				paletteSubMenu.HideAnimated(0)
 			}, nil),
 		NewButtonText( // Delete
 			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"delete (hold shift)", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+			T("delete (hold shift)"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
 				if (rl.IsKeyDown(rl.KeyLeftShift) || rl.IsKeyDown(rl.KeyRightShift)) && CurrentFile.CurrentPalette != 0 {
-					Settings.PaletteData = append(Settings.PaletteData[:CurrentFile.CurrentPalette], Settings.PaletteData[CurrentFile.CurrentPalette+1:]...)
-					CurrentFile.CurrentPalette = 0
+					if CurrentFile.UsesFilePalette() {
+						CurrentFile.FileChanged = true
+					}
+					removePalette(CurrentFile.CurrentPalette)
 					SaveSettings()
 
 					PaletteUIRebuildPalette()
-					paletteSubMenu.Hide()
+					paletteSubMenu.HideAnimated(0)
 				}
 			}, nil),
 		NewButtonText( // Duplicate
 			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"duplicate", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+			T("duplicate"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
 				Settings.PaletteData = append(Settings.PaletteData, Settings.PaletteData[CurrentFile.CurrentPalette])
 				currentPalette := len(Settings.PaletteData) - 1
 				CurrentFile.CurrentPalette = int32(currentPalette)
@@ -213,11 +448,11 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 				SaveSettings()
 
 				PaletteUIRebuildPalette()
-				paletteSubMenu.Hide()
+				paletteSubMenu.HideAnimated(0)
 			}, nil),
 		NewButtonText( // Create From Image
 			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"create from image", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+			T("create from image"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
 				colors := make(map[rl.Color]struct{})
 				colorsSlice := make([]rl.Color, 0)
 				cl := CurrentFile.GetCurrentLayer().PixelData
@@ -240,16 +475,380 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 				SaveSettings()
 
 				PaletteUIRebuildPalette()
-				paletteSubMenu.Hide()
+				paletteSubMenu.HideAnimated(0)
+			}, nil),
+		NewButtonText( // Set swap base
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("set swap base"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.SetSwapBase(Settings.PaletteData[CurrentFile.CurrentPalette].data)
+			}, nil),
+		NewButtonText( // Add alternate palette
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("add as alt"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				palette := Settings.PaletteData[CurrentFile.CurrentPalette]
+				CurrentFile.AddAltPalette(palette.Name, palette.data)
+			}, nil),
+		NewButtonText( // Preview alternate palettes
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("preview alt"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.CyclePreviewAltPalette()
+			}, nil),
+		NewButtonText( // Delete alternate palette
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("delete alt (shift)"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				if rl.IsKeyDown(rl.KeyLeftShift) || rl.IsKeyDown(rl.KeyRightShift) {
+					CurrentFile.DeleteAltPalette()
+				}
+			}, nil),
+		NewButtonText( // Lock to palette
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			lockLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.LockToPalette = !Settings.LockToPalette
+				SaveSettings()
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = lockLabel()
+					}
+				}
+			}, nil),
+		NewButtonText( // Color usage
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("color usage"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				ShowColorHistogram()
+			}, nil),
+		tileColorsButton,
+		NewButtonText( // Color tiles cycles through the usual hardware tile sizes
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			colorTileLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				sizes := [][2]int32{{8, 8}, {8, 16}, {16, 16}}
+				next := sizes[0]
+				width, height := CurrentFile.ColorTileSize()
+				for i, size := range sizes {
+					if size == [2]int32{width, height} && i+1 < len(sizes) {
+						next = sizes[i+1]
+					}
+				}
+				CurrentFile.SetColorTileSize(next[0], next[1])
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = colorTileLabel()
+					}
+				}
+			}, nil),
+		NewButtonText( // Tile color report
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("tile color report"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				ShowTileColorReport()
+			}, nil),
+		NewButtonText( // Sort by hue
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("sort by hue"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				SortPaletteByHue()
+			}, nil),
+		NewButtonText( // Sort by luminance
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("sort by luminance"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				SortPaletteByLuminance()
+			}, nil),
+		NewButtonText( // Remove duplicates
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("remove duplicates"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				RemovePaletteDuplicates()
+			}, nil),
+		NewButtonText( // Remove unused
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("remove unused"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				RemoveUnusedPaletteColors()
+			}, nil),
+		NewButtonText( // Merge similar
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("merge similar"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				MergeSimilarPaletteColors()
+			}, nil),
+		NewButtonText( // Merge threshold
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			mergeThresholdLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				paletteMergeThreshold = nextHistoryChoice(paletteMergeThresholds, paletteMergeThreshold)
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = mergeThresholdLabel()
+					}
+				}
+			}, nil),
+		NewButtonText( // Hue shift ramp
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("hue shift ramp"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				InsertHueShiftRamp()
+			}, nil),
+		NewButtonText( // Ramp hue shift
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			rampHueShiftLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				rampHueShift = nextHistoryChoice(rampHueShifts, rampHueShift)
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = rampHueShiftLabel()
+					}
+				}
+			}, nil),
+		NewButtonText( // Copy palette to file
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("copy to file"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CopyPaletteToFile()
+			}, nil),
+		NewButtonText( // Copy palette to global
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("copy to global"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CopyPaletteToGlobal()
 			}, nil),
 		NewButtonText( // Load Items Spacer
 			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
-			"---- Load ----", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			T("---- Load ----"), TextAlignCenter, false, func(entity *Entity, button MouseButton) {
 			}, nil),
 	}, FlowDirectionVertical)
 	paletteSubMenu.FlowChildren()
+	paletteSubMenu.SetZIndex(ZIndexMenu).SetTween(rl.NewVector2(0, -UIFontSize))
 	paletteSubMenu.Hide()
 
+	// Help menu
+	measured = menuMeasureLabels("keybindings", "tour")
+	paletteButtonMoveable, ok := paletteButton.GetMoveable()
+	if !ok {
+		log.Panic("paletteButton error")
+	}
+	bounds.X += paletteButtonMoveable.Bounds.Width
+	bounds.Width = measured.X + 10
+	helpSubMenu = NewBox(bounds, []*Entity{
+		NewButtonText( // Keybindings
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("keybindings"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				ShowHelp = !ShowHelp
+			}, nil),
+		NewButtonText( // Tour
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("tour"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				helpSubMenu.HideAnimated(0)
+				TourUIStart()
+			}, nil),
+	}, FlowDirectionVertical)
+	helpSubMenu.FlowChildren()
+	helpSubMenu.SetZIndex(ZIndexMenu).SetTween(rl.NewVector2(0, -UIFontSize))
+	helpSubMenu.Hide()
+
+	// Preferences menu
+	soundsLabel := func() string {
+		if Settings.Sounds {
+			return T("sounds: on")
+		}
+		return T("sounds: off")
+	}
+	blendingLabel := func() string {
+		if Settings.LinearBlending {
+			return T("blending: linear")
+		}
+		return T("blending: sRGB")
+	}
+	undoStepsLabel := func() string {
+		return Tf("undo steps: %d", SettingsHistoryMaxActions())
+	}
+	undoMemoryLabel := func() string {
+		if Settings.HistoryMaxMemory <= 0 {
+			return T("undo memory: unlimited")
+		}
+		return Tf("undo memory: %s", FormatBytes(SettingsHistoryMaxMemory()))
+	}
+	backupsLabel := func() string {
+		return Tf("backups: %d", Settings.Backups)
+	}
+	autoscrollLabel := func() string {
+		if speed := SettingsAutoscrollSpeed(); speed > 0 {
+			return Tf("autoscroll: %d", speed)
+		}
+		return T("autoscroll: off")
+	}
+	hiddenLayersLabel := func() string {
+		if Settings.ExportHiddenLayers {
+			return T("hidden layers: exported")
+		}
+		return T("hidden layers: not exported")
+	}
+	handednessLabel := func() string {
+		if Settings.SwapMouseButtons {
+			return T("mouse: left-handed")
+		}
+		return T("mouse: right-handed")
+	}
+	mouseHistoryLabel := func() string {
+		if Settings.NoMouseHistoryButtons {
+			return T("back/forward: off")
+		}
+		return T("back/forward: undo/redo")
+	}
+	prefsLabels := []string{"sounds: on", "sounds: off", "blending: linear", "blending: sRGB", "view filter", "seam check", "export preview", "precision mode",
+		undoStepsLabel(), undoMemoryLabel(), "undo memory: unlimited", backupsLabel(),
+		Tf("autoscroll: %d", 1200), "autoscroll: off",
+		"hidden layers: exported", "hidden layers: not exported", "mouse: right-handed", "mouse: left-handed",
+		"back/forward: undo/redo", "back/forward: off", "---- Language ----"}
+	for _, code := range Locales() {
+		prefsLabels = append(prefsLabels, LocaleName(code))
+	}
+	measured = menuMeasureLabels(prefsLabels...)
+	helpButtonMoveable, ok := helpButton.GetMoveable()
+	if !ok {
+		log.Panic("helpButton error")
+	}
+	bounds.X += helpButtonMoveable.Bounds.Width
+	bounds.Width = measured.X + 10
+	prefsItems := []*Entity{
+		NewButtonText( // Sounds
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			soundsLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.Sounds = !Settings.Sounds
+				SaveSettings()
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = soundsLabel()
+					}
+				}
+				PlaySoundCue(SoundSaved)
+			}, nil),
+		NewButtonText( // Blending
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			blendingLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.LinearBlending = !Settings.LinearBlending
+				SaveSettings()
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = blendingLabel()
+					}
+				}
+				for _, file := range Files {
+					file.RedrawRenderLayer()
+				}
+			}, nil),
+		NewButtonText( // View filter
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("view filter"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CycleViewFilter()
+			}, nil),
+		NewButtonText( // Seam check
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("seam check"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				ToggleSeamCheck()
+			}, nil),
+		NewButtonText( // Export preview
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("export preview"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				ToggleExportPreview()
+			}, nil),
+		NewButtonText( // Precision mode
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("precision mode"), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				PrecisionMode = !PrecisionMode
+			}, nil),
+		NewButtonText( // Undo steps
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			undoStepsLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.HistoryMaxActions = nextHistoryChoice(historyMaxActionsChoices, SettingsHistoryMaxActions())
+				SaveSettings()
+				ApplyHistoryLimits()
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = undoStepsLabel()
+					}
+				}
+			}, nil),
+		NewButtonText( // Undo memory
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			undoMemoryLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.HistoryMaxMemory = nextHistoryChoice(historyMaxMemoryChoices, Settings.HistoryMaxMemory)
+				SaveSettings()
+				ApplyHistoryLimits()
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = undoMemoryLabel()
+					}
+				}
+			}, nil),
+		NewButtonText( // Backups
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			backupsLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.Backups = nextBackupChoice(Settings.Backups)
+				SaveSettings()
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = backupsLabel()
+					}
+				}
+			}, nil),
+		NewButtonText( // Autoscroll
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			autoscrollLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				current := Settings.AutoscrollSpeed
+				if current == 0 {
+					current = DefaultAutoscrollSpeed
+				}
+				Settings.AutoscrollSpeed = nextHistoryChoice(autoscrollChoices, current)
+				SaveSettings()
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = autoscrollLabel()
+					}
+				}
+			}, nil),
+		NewButtonText( // Hidden layers
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			hiddenLayersLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.ExportHiddenLayers = !Settings.ExportHiddenLayers
+				SaveSettings()
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = hiddenLayersLabel()
+					}
+				}
+			}, nil),
+		NewButtonText( // Swap mouse buttons
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			handednessLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.SwapMouseButtons = !Settings.SwapMouseButtons
+				SaveSettings()
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = handednessLabel()
+					}
+				}
+			}, nil),
+		NewButtonText( // Mouse back and forward buttons
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			mouseHistoryLabel(), TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.NoMouseHistoryButtons = !Settings.NoMouseHistoryButtons
+				SaveSettings()
+				if drawable, ok := entity.GetDrawable(); ok {
+					if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+						dt.Label = mouseHistoryLabel()
+					}
+				}
+			}, nil),
+		NewButtonText( // Language Items Spacer
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			T("---- Language ----"), TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			}, nil),
+	}
+	for _, code := range Locales() {
+		c := code
+		prefsItems = append(prefsItems, NewButtonText(
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			LocaleName(c), TextAlignLeft, locales[c] == currentLocale, func(entity *Entity, button MouseButton) {
+				Settings.Locale = c
+				SetLocale(c)
+				SaveSettings()
+				UIRebuild()
+			}, nil))
+	}
+	prefsSubMenu = NewBox(bounds, prefsItems, FlowDirectionVertical)
+	prefsSubMenu.FlowChildren()
+	prefsSubMenu.SetZIndex(ZIndexMenu).SetTween(rl.NewVector2(0, -UIFontSize))
+	prefsSubMenu.Hide()
+
 	if drawable, ok := paletteSubMenu.GetDrawable(); ok {
 		var originalChildrenLen int32
 		if children, err := paletteSubMenu.GetChildren(); err == nil {
@@ -268,6 +867,10 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 			alreadyShowing = true
 			for i, palette := range Settings.PaletteData {
 				p := i
+				// Other files' palettes can only be used by them
+				if palette.IsFilePalette() && palette.file != CurrentFile {
+					continue
+				}
 				paletteSubMenu.PushChild(
 					NewButtonText(
 						rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
@@ -276,6 +879,27 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 							PaletteUIRebuildPalette()
 						}, nil))
 			}
+			paletteSubMenu.PushChild(
+				NewButtonText(
+					rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+					T("---- Built-in ----"), TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+					}, nil))
+			for i, builtin := range builtinPalettes {
+				b := i
+				paletteSubMenu.PushChild(
+					NewButtonText(
+						rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+						builtin.Palette.Name, TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+							PickBuiltinPalette(b)
+							paletteSubMenu.HideAnimated(0)
+						}, nil))
+			}
+			// The limit belongs to the file, which may have changed
+			if drawable, ok := tileColorsButton.GetDrawable(); ok {
+				if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+					dt.Label = tileColorsLabel()
+				}
+			}
 			paletteSubMenu.FlowChildren()
 		}
 		drawable.OnHide = func(entity *Entity) {
@@ -10,6 +10,8 @@ import (
 var (
 	PaletteUIPaletteEntity *Entity
 	paletteName            *Entity
+	// paletteScope shows if the palette is saved in the file or the settings
+	paletteScope *Entity
 
 	// The palette item being dragged
 	movingColor *Moveable
@@ -22,6 +24,9 @@ var (
 	// This will hide when the color is changed in the color picker or the
 	// color is deleted
 	currentColorIndicatorEntity *Entity
+
+	// paletteGroup highlights the colors picked with each mouse button
+	paletteGroup RadioGroup
 )
 
 // PaletteUIRemoveColor removes an color from the palette
@@ -42,7 +47,7 @@ func PaletteUIUpdateCurrentColorIndicator() {
 		if !ok {
 			return
 		}
-		currentColorIndicatorEntity = NewRenderTexture(cm.Bounds, nil, nil)
+		currentColorIndicatorEntity = NewRenderTexture(cm.Bounds, nil, nil).SetZIndex(ZIndexOverlay)
 
 		if r, ok := currentColorIndicatorEntity.GetResizeable(); ok {
 			r.OnResize = func(entity *Entity) {
@@ -85,7 +90,7 @@ func PaletteUIUpdateCurrentColorIndicator() {
 	}
 
 	// Show on top
-	currentColorIndicatorEntity.Scene.MoveEntityToEnd(currentColorIndicatorEntity)
+	currentColorIndicatorEntity.BringToFront()
 }
 
 // PaletteUIHideCurrentColorIndicator hides the currentColorIndicatorEntity
@@ -128,11 +133,17 @@ func PaletteUIRebuildPalette() {
 	PaletteUINextColorEntity = nil
 	PaletteUICurrentColorEntity = nil
 	PaletteUIHideCurrentColorIndicator()
+	paletteGroup.Clear()
 
 	if drawable, ok := paletteName.GetDrawable(); ok {
 		if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
 			drawableText.Label = Settings.PaletteData[CurrentFile.CurrentPalette].Name
 		}
+		if drawable, ok := paletteScope.GetDrawable(); ok {
+			if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+				drawableText.Label = paletteScopeLabel()
+			}
+		}
 
 		if children, err := PaletteUIPaletteEntity.GetChildren(); err == nil {
 			for i := len(children) - 1; i >= 0; i-- {
@@ -145,6 +156,7 @@ func PaletteUIRebuildPalette() {
 			c := PaletteUIAddColor(color, int32(i))
 			if i == 0 {
 				PaletteUICurrentColorEntity = c
+				paletteGroup.SelectFor(c, rl.MouseLeftButton)
 			} else if i == 1 {
 				PaletteUINextColorEntity = c
 			}
@@ -169,11 +181,23 @@ func PaletteUIAddColor(color rl.Color, index int32) *Entity {
 			// Up
 			switch button {
 			case rl.MouseLeftButton:
+				// Dropped on the right color sets it instead of the left one
+				if movingColor != nil && CurrentColorUIDropTarget() == currentColorRight {
+					movingColor = nil
+					CurrentColorSetRightColor(color)
+					makeBlendArea(color)
+					makeOpacitySliderArea(color)
+					paletteGroup.SelectFor(entity, rl.MouseRightButton)
+					PaletteUIPaletteEntity.FlowChildren()
+					return
+				}
+
 				SetUIColors(color)
 				CurrentColorSetLeftColor(color)
 				makeBlendArea(color)
 				makeOpacitySliderArea(color)
 				PaletteUICurrentColorEntity = entity
+				paletteGroup.SelectFor(entity, rl.MouseLeftButton)
 
 				children, err := PaletteUIPaletteEntity.GetChildren()
 				if err != nil {
@@ -224,6 +248,7 @@ func PaletteUIAddColor(color rl.Color, index int32) *Entity {
 				movingColor = nil
 
 				if collision {
+					prev := append([]rl.Color{}, Settings.PaletteData[CurrentFile.CurrentPalette].data...)
 					moved := children[childPosition]
 					movedData := Settings.PaletteData[CurrentFile.CurrentPalette].data[childPosition]
 					children = append(children[:childPosition], children[childPosition+1:]...)
@@ -239,6 +264,7 @@ func PaletteUIAddColor(color rl.Color, index int32) *Entity {
 						Settings.PaletteData[CurrentFile.CurrentPalette].data[:moveToPosition],
 						append(
 							[]rl.Color{movedData}, Settings.PaletteData[CurrentFile.CurrentPalette].data[moveToPosition:]...)...)
+					CurrentFile.AppendPaletteHistory(CurrentFile.CurrentPalette, prev)
 					SaveSettings()
 				}
 				PaletteUIPaletteEntity.FlowChildren()
@@ -247,6 +273,7 @@ func PaletteUIAddColor(color rl.Color, index int32) *Entity {
 				CurrentColorSetRightColor(color)
 				makeBlendArea(color)
 				makeOpacitySliderArea(color)
+				paletteGroup.SelectFor(entity, rl.MouseRightButton)
 			}
 		},
 		func(entity *Entity, button MouseButton, isHeld bool) {
@@ -291,11 +318,63 @@ func PaletteUIAddColor(color rl.Color, index int32) *Entity {
 	return e
 }
 
+// PaletteUIOverEmptySlot returns true if the mouse is over the palette but not
+// over any of its colors
+func PaletteUIOverEmptySlot() bool {
+	moveable, ok := PaletteUIPaletteEntity.GetMoveable()
+	if !ok || !rl.CheckCollisionPointRec(rl.GetMousePosition(), moveable.Bounds) {
+		return false
+	}
+	children, err := PaletteUIPaletteEntity.GetChildren()
+	if err != nil {
+		return false
+	}
+	for _, child := range children {
+		if childMoveable, ok := child.GetMoveable(); ok {
+			bounds := childMoveable.Bounds
+			bounds.X += childMoveable.Offset.X
+			bounds.Y += childMoveable.Offset.Y
+			if rl.CheckCollisionPointRec(rl.GetMousePosition(), bounds) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// PaletteUIAppendColor adds a color to the end of the current palette
+func PaletteUIAppendColor(color rl.Color) {
+	PaletteUIAddColor(color, int32(len(Settings.PaletteData[CurrentFile.CurrentPalette].data)))
+	prev := append([]rl.Color{}, Settings.PaletteData[CurrentFile.CurrentPalette].data...)
+	Settings.PaletteData[CurrentFile.CurrentPalette].data = append(Settings.PaletteData[CurrentFile.CurrentPalette].data, color)
+	CurrentFile.AppendPaletteHistory(CurrentFile.CurrentPalette, prev)
+	SaveSettings()
+}
+
+// paletteScopeLabel returns where the current palette is saved
+func paletteScopeLabel() string {
+	if CurrentFile.UsesFilePalette() {
+		return T("file")
+	}
+	return T("global")
+}
+
 // NewPaletteUI returns a new PaletteUI
 func NewPaletteUI(bounds rl.Rectangle) *Entity {
 	PaletteUIPaletteEntity = NewScrollableList(rl.NewRectangle(0, 0, bounds.Width, bounds.Height-UIButtonHeight/2), []*Entity{}, FlowDirectionHorizontal)
 
-	paletteName = NewInput(rl.NewRectangle(0, 0, bounds.Width, UIButtonHeight/2),
+	// Clicking the scope copies the palette to the other one
+	scopeWidth := menuMeasureLabels("file", "global").X + 10
+	paletteScope = NewButtonText(rl.NewRectangle(0, 0, scopeWidth, UIButtonHeight/2), paletteScopeLabel(), TextAlignCenter, false,
+		func(entity *Entity, button MouseButton) {
+			if CurrentFile.UsesFilePalette() {
+				CopyPaletteToGlobal()
+			} else {
+				CopyPaletteToFile()
+			}
+		}, nil)
+
+	paletteName = NewInput(rl.NewRectangle(0, 0, bounds.Width-scopeWidth, UIButtonHeight/2),
 		Settings.PaletteData[CurrentFile.CurrentPalette].Name,
 		TextAlignCenter,
 		false, func(entity *Entity, button MouseButton) {}, nil,
@@ -316,12 +395,21 @@ func NewPaletteUI(bounds rl.Rectangle) *Entity {
 		})
 	if interactable, ok := paletteName.GetInteractable(); ok {
 		interactable.OnBlur = func(entity *Entity) {
+			if CurrentFile.UsesFilePalette() {
+				CurrentFile.FileChanged = true
+			}
 			SaveSettings()
 		}
 	}
 
-	paletteContainer := NewBox(bounds, []*Entity{
+	paletteHeader := NewBox(rl.NewRectangle(0, 0, bounds.Width, UIButtonHeight/2), []*Entity{
+		paletteScope,
 		paletteName,
+	}, FlowDirectionHorizontal)
+	paletteHeader.FlowChildren()
+
+	paletteContainer := NewBox(bounds, []*Entity{
+		paletteHeader,
 		PaletteUIPaletteEntity,
 	}, FlowDirectionVertical)
 
@@ -12,6 +12,8 @@ var (
 	previewContainer                 *Entity
 	previewButtonsContainer          *Entity
 	previewAnimationButtonsContainer *Entity
+	previewNineSliceButtonsContainer *Entity
+	previewStackButtonsContainer     *Entity
 
 	previewArea              *Entity
 	currentPreviewMode       previewMode
@@ -20,22 +22,52 @@ var (
 	previewAnimationIsPaused bool    // true if animation is paused
 	previewAnimationFrame    int32   // current frame of animation, accessed by ui_animations
 
+	// previewAnimationSpeeds multiply the animation's timing, only the
+	// preview's playback is changed and not the saved timing
+	previewAnimationSpeeds = []float32{0.25, 0.5, 1, 2}
+	previewAnimationSpeed  = 2 // index of previewAnimationSpeeds
+
+	// previewNineSliceSize is what the tile is stretched to, in pixels
+	previewNineSliceSize = IntVec2{64, 32}
+
 	previewCurrentButton          *Entity
 	previewCurrentSheetButton     *Entity
 	previewCurrentTileButton      *Entity
 	previewCurrentAnimationButton *Entity
 	previewCurrentPixelButton     *Entity
+	previewCurrentNineSliceButton *Entity
+	previewCurrentStackButton     *Entity
 	previewCurrentAnimationTiming *Entity // input which displays the current animation's timing
 )
 
 type previewMode int32
 
+// previewState is what the preview shows besides the pixels, the preview is
+// only drawn again when it changes or when the shown tiles are edited
+type previewState struct {
+	file    *File
+	mode    previewMode
+	source  rl.Rectangle // the part of the canvas which is shown
+	texture uint32       // the preview's texture, it's replaced on resize
+
+	canvasWidth, canvasHeight int32
+	tileWidth, tileHeight     int32
+	pixelAspect               float32
+	nineSlice                 NineSlice
+	nineSliceSize             IntVec2
+	frameCounter              string
+}
+
+var previewLastState previewState
+
 // Preview modes
 const (
 	previewCurrentSheet     previewMode = iota // shows the entire spritesheet, can zoom
 	previewCurrentTile                         // shows the current sprite, tiled
 	previewCurrentPixel                        // follows mouse cursor around
 	previewCurrentAnimation                    // shows the current animation
+	previewCurrentNineSlice                    // shows the current sprite stretched with the nine-slice margins
+	previewCurrentStack                        // shows the current sprite of every layer stacked and turning
 )
 
 // PreviewUISetTiming sets the timing in the preview input
@@ -54,10 +86,21 @@ func PreviewUIDrawTile(x, y int32) {
 	if ok {
 		renderTexture, ok := drawable.DrawableType.(*DrawableRenderTexture)
 		if ok {
+			if currentPreviewMode == previewCurrentAnimation {
+				previewUIAdvanceAnimation()
+			}
+			changed := previewUINeedsRedraw(x, y, renderTexture.Texture.ID)
+			if currentPreviewMode == previewCurrentStack {
+				// The stack turns, so it's drawn every frame
+				previewUIUpdateStack(x, y, changed)
+			} else if !changed {
+				return
+			}
+
 			rl.BeginTextureMode(renderTexture.Texture)
 			rl.ClearBackground(rl.Black)
 
-			ratio := float32(CurrentFile.CanvasWidth) / float32(CurrentFile.CanvasHeight)
+			ratio := float32(CurrentFile.CanvasWidth) / float32(CurrentFile.CanvasHeight) * CurrentFile.PixelAspect
 
 			switch currentPreviewMode {
 			case previewCurrentSheet:
@@ -78,17 +121,9 @@ func PreviewUIDrawTile(x, y int32) {
 				rl.DrawRectangle(0, 0, int32(renderTexture.Texture.Texture.Width), int32(dst.Y), rl.DarkGray)
 				rl.DrawRectangle(0, int32(renderTexture.Texture.Texture.Width)-int32(dst.Y), int32(renderTexture.Texture.Texture.Width), int32(dst.Y), rl.DarkGray)
 
-				rl.DrawTexturePro(
-					CurrentFile.RenderLayer.Canvas.Texture,
-					// rl.NewRectangle(0, 0, float32(CurrentFile.CanvasWidth), -float32(CurrentFile.CanvasHeight)),
-					rl.NewRectangle(
-						0,
-						0,
-						float32(CurrentFile.CanvasWidth),
-						-float32(CurrentFile.CanvasHeight)),
+				CurrentFile.RenderLayer.Chunks.DrawRegion(
+					rl.NewRectangle(0, 0, float32(CurrentFile.CanvasWidth), float32(CurrentFile.CanvasHeight)),
 					dst,
-					rl.NewVector2(0, 0),
-					0,
 					rl.White,
 				)
 
@@ -99,21 +134,17 @@ func PreviewUIDrawTile(x, y int32) {
 
 				for x := 0; x < 3; x++ {
 					for y := 0; y < 3; y++ {
-						rl.DrawTexturePro(
-							CurrentFile.RenderLayer.Canvas.Texture,
-							// rl.NewRectangle(0, 0, float32(CurrentFile.CanvasWidth), -float32(CurrentFile.CanvasHeight)),
+						CurrentFile.RenderLayer.Chunks.DrawRegion(
 							rl.NewRectangle(
 								float32(tilePos.X),
-								-float32(tilePos.Y)-float32(CurrentFile.TileHeight),
+								float32(tilePos.Y),
 								float32(CurrentFile.TileWidth),
-								-float32(CurrentFile.TileHeight)),
+								float32(CurrentFile.TileHeight)),
 							rl.NewRectangle(
 								float32(renderTexture.Texture.Texture.Width)/3*float32(x),
 								float32(renderTexture.Texture.Texture.Height)/3*float32(y),
 								float32(renderTexture.Texture.Texture.Width)/3,
 								float32(renderTexture.Texture.Texture.Height)/3),
-							rl.NewVector2(0, 0),
-							0,
 							rl.White,
 						)
 					}
@@ -122,17 +153,13 @@ func PreviewUIDrawTile(x, y int32) {
 			case previewCurrentPixel:
 				clampedPos := GetClampedCoordinates(x, y)
 
-				rl.DrawTexturePro(
-					CurrentFile.RenderLayer.Canvas.Texture,
-					// rl.NewRectangle(0, 0, float32(CurrentFile.CanvasWidth), -float32(CurrentFile.CanvasHeight)),
+				CurrentFile.RenderLayer.Chunks.DrawRegion(
 					rl.NewRectangle(
 						float32(clampedPos.X)-float32(CurrentFile.TileWidth)/2,
-						-float32(clampedPos.Y)-float32(CurrentFile.TileHeight)/2,
+						float32(clampedPos.Y)-float32(CurrentFile.TileHeight)/2,
 						float32(CurrentFile.TileWidth),
-						-float32(CurrentFile.TileHeight)),
+						float32(CurrentFile.TileHeight)),
 					rl.NewRectangle(0, 0, float32(renderTexture.Texture.Texture.Width), float32(renderTexture.Texture.Texture.Height)),
-					rl.NewVector2(0, 0),
-					0,
 					rl.White,
 				)
 
@@ -159,29 +186,9 @@ func PreviewUIDrawTile(x, y int32) {
 				)
 
 			case previewCurrentAnimation:
+				ratio := float32(CurrentFile.TileWidth) / float32(CurrentFile.TileHeight) * CurrentFile.PixelAspect
 
-				anim := CurrentFile.GetCurrentAnimation()
-				if !previewAnimationIsPaused {
-					previewAnimationTimer += rl.GetFrameTime()
-				}
-				if anim != nil {
-					if previewAnimationTimer > 1.0/anim.Timing {
-						// Get next frame
-						previewAnimationTimer = 0
-						previewAnimationFrame++
-						if previewAnimationFrame > anim.FrameEnd {
-							previewAnimationFrame = anim.FrameStart
-						}
-					}
-				}
-
-				ratio := float32(CurrentFile.TileWidth) / float32(CurrentFile.TileHeight)
-
-				// Convert tile number to coords
-				tilePos := IntVec2{
-					X: (previewAnimationFrame * CurrentFile.TileWidth) % CurrentFile.CanvasWidth,
-					Y: ((previewAnimationFrame * CurrentFile.TileHeight) / (CurrentFile.CanvasWidth)) * CurrentFile.TileHeight,
-				}
+				tilePos := previewUIAnimationTilePosition()
 
 				// Preview ratio
 				dst := rl.NewRectangle(0, 0, float32(renderTexture.Texture.Texture.Width)*ratio, float32(renderTexture.Texture.Texture.Height))
@@ -199,18 +206,61 @@ func PreviewUIDrawTile(x, y int32) {
 				rl.DrawRectangle(0, 0, int32(renderTexture.Texture.Texture.Width), int32(dst.Y), rl.DarkGray)
 				rl.DrawRectangle(0, int32(renderTexture.Texture.Texture.Width)-int32(dst.Y), int32(renderTexture.Texture.Texture.Width), int32(dst.Y), rl.DarkGray)
 
-				rl.DrawTexturePro(
-					CurrentFile.RenderLayer.Canvas.Texture,
+				CurrentFile.RenderLayer.Chunks.DrawRegion(
 					rl.NewRectangle(
 						float32(tilePos.X),
-						-float32(tilePos.Y)-float32(CurrentFile.TileHeight),
+						float32(tilePos.Y),
 						float32(CurrentFile.TileWidth),
-						-float32(CurrentFile.TileHeight)),
+						float32(CurrentFile.TileHeight)),
 					dst,
-					rl.NewVector2(0, 0),
-					0,
 					rl.White,
 				)
+
+				if counter := previewUIFrameCounter(); counter != "" {
+					rl.DrawRectangleRec(rl.NewRectangle(2, 2, rl.MeasureTextEx(Font, counter, UIFontSize, 1).X+UIFontSize, UIFontSize*1.5), rl.NewColor(0, 0, 0, 160))
+					rl.DrawTextEx(Font, counter, rl.NewVector2(2+UIFontSize/2, 2+UIFontSize/4), UIFontSize, 1, rl.White)
+				}
+
+			case previewCurrentNineSlice:
+				clampedPos := GetClampedCoordinates(x, y)
+				tilePos := GetTilePosition(clampedPos.X, clampedPos.Y)
+
+				// Fit the stretched size in the preview
+				texWidth := float32(renderTexture.Texture.Texture.Width)
+				texHeight := float32(renderTexture.Texture.Texture.Height)
+				width := float32(previewNineSliceSize.X) * CurrentFile.PixelAspect
+				height := float32(previewNineSliceSize.Y)
+				scale := texWidth / width
+				if texHeight/height < scale {
+					scale = texHeight / height
+				}
+				offset := rl.NewVector2((texWidth-width*scale)/2, (texHeight-height*scale)/2)
+
+				rl.DrawRectangle(0, 0, int32(texWidth), int32(texHeight), rl.DarkGray)
+				src, dst := CurrentFile.NineSlice.Parts(
+					float32(CurrentFile.TileWidth), float32(CurrentFile.TileHeight),
+					float32(previewNineSliceSize.X), float32(previewNineSliceSize.Y))
+				for i := range src {
+					if src[i].Width <= 0 || src[i].Height <= 0 || dst[i].Width <= 0 || dst[i].Height <= 0 {
+						continue
+					}
+					CurrentFile.RenderLayer.Chunks.DrawRegion(
+						rl.NewRectangle(
+							float32(tilePos.X)+src[i].X,
+							float32(tilePos.Y)+src[i].Y,
+							src[i].Width,
+							src[i].Height),
+						rl.NewRectangle(
+							offset.X+dst[i].X*CurrentFile.PixelAspect*scale,
+							offset.Y+dst[i].Y*scale,
+							dst[i].Width*CurrentFile.PixelAspect*scale,
+							dst[i].Height*scale),
+						rl.White,
+					)
+				}
+
+			case previewCurrentStack:
+				previewUIDrawStack(float32(renderTexture.Texture.Texture.Width), float32(renderTexture.Texture.Texture.Height))
 			}
 
 			rl.DrawRectangleLinesEx(rl.NewRectangle(0, 0, float32(renderTexture.Texture.Texture.Width), float32(renderTexture.Texture.Texture.Height)), 2, rl.Gray)
@@ -220,6 +270,162 @@ func PreviewUIDrawTile(x, y int32) {
 	}
 }
 
+// previewUIAdvanceAnimation moves to the next frame of the current animation
+// once the frame has been shown for long enough
+func previewUIAdvanceAnimation() {
+	anim := CurrentFile.GetCurrentAnimation()
+	if !previewAnimationIsPaused {
+		previewAnimationTimer += rl.GetFrameTime() * previewAnimationSpeeds[previewAnimationSpeed]
+	}
+	if anim != nil {
+		if previewAnimationTimer > 1.0/anim.Timing {
+			// Get next frame
+			previewAnimationTimer = 0
+			previewAnimationFrame++
+			if previewAnimationFrame > anim.FrameEnd {
+				previewAnimationFrame = anim.FrameStart
+			}
+		}
+	}
+}
+
+// PreviewUIStepFrame pauses the animation preview and moves it by step
+// frames. It can go past either end of the animation, so that the range can
+// be grown with PreviewUISetAnimationStart and PreviewUISetAnimationEnd
+func PreviewUIStepFrame(step int32) {
+	if CurrentFile.GetCurrentAnimation() == nil {
+		return
+	}
+	previewAnimationIsPaused = true
+	previewAnimationTimer = 0
+	previewAnimationFrame = MaxInt32(0, MinInt32(previewAnimationFrame+step, CurrentFile.frameCount()-1))
+}
+
+// PreviewUISetAnimationStart makes the shown frame the first frame of the
+// current animation, the last frame is moved too if it's before it
+func PreviewUISetAnimationStart() {
+	anim := CurrentFile.GetCurrentAnimation()
+	if anim == nil {
+		return
+	}
+	CurrentFile.SetAnimationFrames(CurrentFile.CurrentAnimation, previewAnimationFrame, MaxInt32(anim.FrameEnd, previewAnimationFrame))
+}
+
+// PreviewUISetAnimationEnd makes the shown frame the last frame of the
+// current animation, the first frame is moved too if it's after it
+func PreviewUISetAnimationEnd() {
+	anim := CurrentFile.GetCurrentAnimation()
+	if anim == nil {
+		return
+	}
+	CurrentFile.SetAnimationFrames(CurrentFile.CurrentAnimation, MinInt32(anim.FrameStart, previewAnimationFrame), previewAnimationFrame)
+}
+
+// previewUIFrameCounter returns the shown frame's number in the current
+// animation and how many frames it has, the frame is shown on its own when
+// it's outside of the animation
+func previewUIFrameCounter() string {
+	anim := CurrentFile.GetCurrentAnimation()
+	if anim == nil {
+		return ""
+	}
+	if previewAnimationFrame < anim.FrameStart || previewAnimationFrame > anim.FrameEnd {
+		return fmt.Sprintf("%d", previewAnimationFrame)
+	}
+	return fmt.Sprintf("%d/%d", previewAnimationFrame-anim.FrameStart+1, anim.FrameEnd-anim.FrameStart+1)
+}
+
+// previewUICycleSpeed moves to the next playback speed, after the fastest it
+// goes back to the slowest
+func previewUICycleSpeed(entity *Entity) {
+	previewAnimationSpeed = (previewAnimationSpeed + 1) % len(previewAnimationSpeeds)
+	if drawable, ok := entity.GetDrawable(); ok {
+		if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+			drawableText.Label = previewUISpeedLabel()
+		}
+	}
+}
+
+// previewUISpeedLabel returns the playback speed as shown on its button
+func previewUISpeedLabel() string {
+	return strconv.FormatFloat(float64(previewAnimationSpeeds[previewAnimationSpeed]), 'f', -1, 32) + "x"
+}
+
+// previewUIAnimationTilePosition converts the current frame to the top left of
+// its tile
+func previewUIAnimationTilePosition() IntVec2 {
+	return IntVec2{
+		X: (previewAnimationFrame * CurrentFile.TileWidth) % CurrentFile.CanvasWidth,
+		Y: ((previewAnimationFrame * CurrentFile.TileHeight) / (CurrentFile.CanvasWidth)) * CurrentFile.TileHeight,
+	}
+}
+
+// previewUINeedsRedraw returns true if what the preview shows has changed.
+// Edits only cause a redraw if they're in the shown tiles, or in the frames of
+// the current animation
+func previewUINeedsRedraw(x, y int32, texture uint32) bool {
+	state := previewState{
+		file:         CurrentFile,
+		texture:      texture,
+		mode:         currentPreviewMode,
+		canvasWidth:  CurrentFile.CanvasWidth,
+		canvasHeight: CurrentFile.CanvasHeight,
+		tileWidth:    CurrentFile.TileWidth,
+		tileHeight:   CurrentFile.TileHeight,
+		pixelAspect:  CurrentFile.PixelAspect,
+	}
+	if currentPreviewMode == previewCurrentNineSlice {
+		state.nineSlice = CurrentFile.NineSlice
+		state.nineSliceSize = previewNineSliceSize
+	}
+	switch currentPreviewMode {
+	case previewCurrentSheet:
+		state.source = rl.NewRectangle(0, 0, float32(CurrentFile.CanvasWidth), float32(CurrentFile.CanvasHeight))
+	case previewCurrentTile, previewCurrentNineSlice, previewCurrentStack:
+		clampedPos := GetClampedCoordinates(x, y)
+		tilePos := GetTilePosition(clampedPos.X, clampedPos.Y)
+		state.source = rl.NewRectangle(float32(tilePos.X), float32(tilePos.Y), float32(CurrentFile.TileWidth), float32(CurrentFile.TileHeight))
+	case previewCurrentPixel:
+		clampedPos := GetClampedCoordinates(x, y)
+		state.source = rl.NewRectangle(
+			float32(clampedPos.X-CurrentFile.TileWidth/2), float32(clampedPos.Y-CurrentFile.TileHeight/2),
+			float32(CurrentFile.TileWidth), float32(CurrentFile.TileHeight))
+	case previewCurrentAnimation:
+		tilePos := previewUIAnimationTilePosition()
+		state.source = rl.NewRectangle(float32(tilePos.X), float32(tilePos.Y), float32(CurrentFile.TileWidth), float32(CurrentFile.TileHeight))
+		state.frameCounter = previewUIFrameCounter()
+	}
+
+	dirty, all := CurrentFile.TakeDirtyTiles()
+	if all || state != previewLastState {
+		previewLastState = state
+		return true
+	}
+	if len(dirty) == 0 {
+		return false
+	}
+
+	switch currentPreviewMode {
+	case previewCurrentSheet:
+		return true
+	case previewCurrentAnimation:
+		// Edits to tiles which aren't frames of the animation can be skipped
+		// without checking the shown frame
+		anim := CurrentFile.GetCurrentAnimation()
+		affected := false
+		for _, a := range CurrentFile.AnimationsUsingTiles(dirty) {
+			if a == anim {
+				affected = true
+			}
+		}
+		if !affected {
+			return false
+		}
+	}
+	return CurrentFile.anyTileDirty(dirty,
+		int32(state.source.X), int32(state.source.Y), int32(state.source.Width), int32(state.source.Height))
+}
+
 // NewPreviewUI creates the UI for previewing the current animation/tile
 func NewPreviewUI(bounds rl.Rectangle) *Entity {
 	previewArea = NewRenderTexture(bounds, nil, nil)
@@ -243,6 +449,9 @@ func NewPreviewUI(bounds rl.Rectangle) *Entity {
 		}
 
 		previewAnimationButtonsContainer.Hide()
+		previewNineSliceButtonsContainer.Hide()
+		previewStackButtonsContainer.Hide()
+		previewUIUnloadStack()
 	}
 
 	selectCurrentButton := func() {
@@ -294,6 +503,26 @@ func NewPreviewUI(bounds rl.Rectangle) *Entity {
 
 		}, nil)
 
+	previewCurrentNineSliceButton = NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		GetFile("./res/icons/nine_slice.png"), false, func(entity *Entity, button MouseButton) {
+			currentPreviewMode = previewCurrentNineSlice
+			unselectCurrentButton()
+			previewCurrentButton = previewCurrentNineSliceButton
+			selectCurrentButton()
+			// Show the size controls
+			previewNineSliceButtonsContainer.Show()
+		}, nil)
+
+	previewCurrentStackButton = NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		GetFile("./res/icons/sprite_stack.png"), false, func(entity *Entity, button MouseButton) {
+			currentPreviewMode = previewCurrentStack
+			unselectCurrentButton()
+			previewCurrentButton = previewCurrentStackButton
+			selectCurrentButton()
+			// Show the spacing and speed controls
+			previewStackButtonsContainer.Show()
+		}, nil)
+
 	previewCurrentAnimationTiming = NewInput(rl.NewRectangle(0, 0, UIButtonHeight*1.5, UIButtonHeight/2), "10", TextAlignCenter, false,
 		func(entity *Entity, button MouseButton) {
 			// button up
@@ -346,7 +575,7 @@ func NewPreviewUI(bounds rl.Rectangle) *Entity {
 
 	// Animation controls
 	previewAnimationButtonsContainer = NewBox(
-		rl.NewRectangle(0, 0, UIButtonHeight*1.5, UIButtonHeight),
+		rl.NewRectangle(0, 0, UIButtonHeight*2, UIButtonHeight),
 		[]*Entity{
 			NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight/2, UIButtonHeight/2),
 				GetFile("./res/icons/play_pause.png"), false, func(entity *Entity, button MouseButton) {
@@ -374,12 +603,63 @@ func NewPreviewUI(bounds rl.Rectangle) *Entity {
 						previewAnimationFrame = anim.FrameStart
 					}
 				}, nil),
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight/2, UIButtonHeight/2), previewUISpeedLabel(), TextAlignCenter, false,
+				func(entity *Entity, button MouseButton) {
+					previewUICycleSpeed(entity)
+				}, nil),
 
 			previewCurrentAnimationTiming,
 		},
 		FlowDirectionHorizontal)
 	previewAnimationButtonsContainer.Hide()
 
+	// Nine-slice controls, the size the tile is stretched to
+	nineSliceWidthInput := ToolsUIMakeNumberInput(previewNineSliceSize.X, func(value int32) int32 {
+		previewNineSliceSize.X = MaxInt32(1, MinInt32(value, 1024))
+		return previewNineSliceSize.X
+	})
+	nineSliceHeightInput := ToolsUIMakeNumberInput(previewNineSliceSize.Y, func(value int32) int32 {
+		previewNineSliceSize.Y = MaxInt32(1, MinInt32(value, 1024))
+		return previewNineSliceSize.Y
+	})
+	for _, input := range []*Entity{nineSliceWidthInput, nineSliceHeightInput} {
+		if moveable, ok := input.GetMoveable(); ok {
+			moveable.Bounds.Height = UIButtonHeight / 2
+		}
+	}
+	previewNineSliceButtonsContainer = NewBox(
+		rl.NewRectangle(0, 0, UIButtonHeight*1.25, UIButtonHeight),
+		[]*Entity{
+			nineSliceWidthInput,
+			nineSliceHeightInput,
+		},
+		FlowDirectionVertical)
+	previewNineSliceButtonsContainer.Hide()
+
+	// Sprite stacking controls, the spacing between layers and how fast the
+	// stack turns
+	stackSpacingInput := ToolsUIMakeNumberInput(previewStackSpacing, func(value int32) int32 {
+		previewStackSpacing = MaxInt32(0, MinInt32(value, 64))
+		return previewStackSpacing
+	})
+	stackSpeedInput := ToolsUIMakeNumberInput(previewStackSpeed, func(value int32) int32 {
+		previewStackSpeed = MaxInt32(-720, MinInt32(value, 720))
+		return previewStackSpeed
+	})
+	for _, input := range []*Entity{stackSpacingInput, stackSpeedInput} {
+		if moveable, ok := input.GetMoveable(); ok {
+			moveable.Bounds.Height = UIButtonHeight / 2
+		}
+	}
+	previewStackButtonsContainer = NewBox(
+		rl.NewRectangle(0, 0, UIButtonHeight*1.25, UIButtonHeight),
+		[]*Entity{
+			stackSpacingInput,
+			stackSpeedInput,
+		},
+		FlowDirectionVertical)
+	previewStackButtonsContainer.Hide()
+
 	previewCurrentButton = previewCurrentSheetButton
 	selectCurrentButton()
 
@@ -395,7 +675,11 @@ func NewPreviewUI(bounds rl.Rectangle) *Entity {
 			previewCurrentTileButton,
 			previewCurrentPixelButton,
 			previewCurrentAnimationButton,
+			previewCurrentNineSliceButton,
+			previewCurrentStackButton,
 			previewAnimationButtonsContainer,
+			previewNineSliceButtonsContainer,
+			previewStackButtonsContainer,
 		},
 		FlowDirectionHorizontal,
 	)
@@ -0,0 +1,180 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	propertiesBox *Entity
+)
+
+// PropertiesUIShowDialog shows the file properties dialog. It's rebuilt so
+// that the memory readout is up to date
+func PropertiesUIShowDialog() {
+	propertiesBox.DestroyNested()
+	propertiesBox.Destroy()
+	NewPropertiesUI()
+	propertiesBox.Show()
+}
+
+// PropertiesUIHideDialog hides the dialog
+func PropertiesUIHideDialog() {
+	RemoveCapturedInput()
+	propertiesBox.Hide()
+}
+
+// PropertiesUIMakeInput is a text input which is bound to a metadata field.
+// Optionally, an *Entity can be provided to switch focus to when tab is pressed
+func PropertiesUIMakeInput(linkedValueCallback func() *string, width float32, tabNext *Entity) *Entity {
+	i := NewInput(rl.NewRectangle(0, 0, width, UIButtonHeight), *linkedValueCallback(), TextAlignLeft, false,
+		func(entity *Entity, button MouseButton) {
+			// button up
+		}, nil,
+		func(entity *Entity, key Key) {
+			// key pressed
+			drawable, ok := entity.GetDrawable()
+			if !ok {
+				return
+			}
+			drawableText, ok := drawable.DrawableType.(*DrawableText)
+			if !ok {
+				return
+			}
+
+			runes := []rune(drawableText.Label)
+			switch {
+			case key == rl.KeyBackspace:
+				if len(runes) > 0 {
+					drawableText.Label = string(runes[:len(runes)-1])
+				}
+			case key == rl.KeyTab:
+				RemoveCapturedInput()
+
+				// Set control to tabNext
+				if tabNext != nil {
+					if interactable, ok := tabNext.GetInteractable(); ok {
+						SetCapturedInput(tabNext, interactable)
+					}
+				}
+			case key == rl.KeyEnter:
+				RemoveCapturedInput()
+			case key >= rl.KeyA && key <= rl.KeyZ:
+				if !rl.IsKeyDown(rl.KeyLeftShift) && !rl.IsKeyDown(rl.KeyRightShift) {
+					key += 'a' - 'A'
+				}
+				fallthrough
+			case key >= 32 && key < 256: // printable, keys above are special keys
+				drawableText.Label += string(rune(key))
+			}
+
+			*linkedValueCallback() = drawableText.Label
+			CurrentFile.FileChanged = true
+		})
+	if drawable, ok := i.GetDrawable(); ok {
+		drawable.OnShow = func(entity *Entity) {
+			if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+				dt.Label = *linkedValueCallback()
+			}
+		}
+	}
+	return i
+}
+
+// PropertiesUIMakeMemoryRows makes rows showing roughly how much memory each
+// layer and the history use, layers are listed top first like the layers panel
+func PropertiesUIMakeMemoryRows(labelWidth, valueWidth float32) []*Entity {
+	row := func(label, value string) *Entity {
+		return NewBox(rl.NewRectangle(0, 0, labelWidth+valueWidth, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, labelWidth, UIButtonHeight), label, TextAlignLeft, false, nil, nil),
+			NewButtonText(rl.NewRectangle(0, 0, valueWidth, UIButtonHeight), value, TextAlignLeft, false, nil, nil),
+		}, FlowDirectionHorizontal)
+	}
+
+	rows := []*Entity{
+		NewButtonText(rl.NewRectangle(0, 0, labelWidth+valueWidth, UIButtonHeight),
+			Tf("memory (%d layers)", len(CurrentFile.Layers)), TextAlignCenter, false, nil, nil),
+	}
+	for i := len(CurrentFile.Layers) - 1; i >= 0; i-- {
+		layer := CurrentFile.Layers[i]
+		pixels, texture := layer.MemoryUsage()
+		rows = append(rows, row(layer.Name, Tf("%s (%s pixels, %s texture)",
+			FormatBytes(pixels+texture), FormatBytes(pixels), FormatBytes(texture))))
+	}
+	layers := CurrentFile.LayersMemoryUsage()
+	history := CurrentFile.HistoryMemoryUsage()
+	rows = append(rows,
+		row(T("history"), Tf("%s (%d actions, %d dropped)", FormatBytes(history), len(CurrentFile.History), CurrentFile.HistoryEvicted())),
+		row(T("total"), FormatBytes(layers+history)),
+	)
+	return rows
+}
+
+// NewPropertiesUI returns the file properties dialog, it's hidden until it's
+// opened from the file menu
+func NewPropertiesUI() *Entity {
+	fields := []struct {
+		label string
+		value func() *string
+	}{
+		{"author", func() *string { return &CurrentFile.Metadata.Author }},
+		{"license", func() *string { return &CurrentFile.Metadata.License }},
+		{"description", func() *string { return &CurrentFile.Metadata.Description }},
+	}
+	labels := make([]string, len(fields))
+	for i, field := range fields {
+		labels[i] = field.label
+	}
+	layerNames := make([]string, 0, len(CurrentFile.Layers))
+	for _, layer := range CurrentFile.Layers {
+		layerNames = append(layerNames, layer.Name)
+	}
+	measured := menuMeasureLabels(append(append(layerNames, labels...), "history", "total")...)
+	labelWidth := measured.X + 10
+	inputWidth := UIFontSize * 2 * 14
+
+	// Made in reverse so that tab can go to the next one
+	inputs := make([]*Entity, len(fields))
+	var next *Entity
+	for i := len(fields) - 1; i >= 0; i-- {
+		inputs[i] = PropertiesUIMakeInput(fields[i].value, inputWidth, next)
+		next = inputs[i]
+	}
+
+	width := labelWidth + inputWidth
+	rows := []*Entity{
+		NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight), T("file properties"), TextAlignCenter, false, nil, nil),
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight), "X", TextAlignCenter, false,
+				func(entity *Entity, button MouseButton) {
+					PropertiesUIHideDialog()
+				}, nil),
+		}, FlowDirectionHorizontal),
+	}
+	for i, label := range labels {
+		rows = append(rows, NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, labelWidth, UIButtonHeight), T(label), TextAlignLeft, false, nil, nil),
+			inputs[i],
+		}, FlowDirectionHorizontal))
+	}
+	rows = append(rows, PropertiesUIMakeMemoryRows(labelWidth, inputWidth)...)
+	for _, row := range rows {
+		row.FlowChildren()
+	}
+
+	height := UIButtonHeight * float32(len(rows))
+	propertiesBox = NewBox(rl.NewRectangle(
+		float32(rl.GetScreenWidth())/2-width/2,
+		float32(rl.GetScreenHeight())/2-height/2,
+		width,
+		height,
+	), rows, FlowDirectionVertical)
+	if drawable, ok := propertiesBox.GetDrawable(); ok {
+		drawable.DrawBackground = true
+		drawable.DrawBorder = true
+	}
+	propertiesBox.FlowChildren()
+	propertiesBox.SetZIndex(ZIndexDialog)
+	propertiesBox.Hide()
+
+	return propertiesBox
+}
@@ -175,7 +175,7 @@ func NewResizeUI() *Entity {
 		heightInput,
 		NewButtonText(
 			rl.NewRectangle(0, 0, UIFontSize*2*10, UIButtonHeight),
-			"Resize Canvas", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			T("Resize Canvas"), TextAlignCenter, false, func(entity *Entity, button MouseButton) {
 				CurrentFile.ResizeCanvas(CurrentFile.CanvasWidthResizePreview, CurrentFile.CanvasHeightResizePreview, CurrentFile.CanvasDirectionResizePreview)
 			}, nil),
 	}, FlowDirectionVertical)
@@ -190,7 +190,7 @@ func NewResizeUI() *Entity {
 		tileHeightInput,
 		NewButtonText(
 			rl.NewRectangle(0, 0, UIFontSize*2*10, UIButtonHeight),
-			"Resize Tiles", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			T("Resize Tiles"), TextAlignCenter, false, func(entity *Entity, button MouseButton) {
 				CurrentFile.ResizeTileSize(CurrentFile.TileWidthResizePreview, CurrentFile.TileHeightResizePreview)
 			}, nil),
 	}, FlowDirectionVertical)
@@ -207,6 +207,7 @@ func NewResizeUI() *Entity {
 		FlowDirectionHorizontal,
 	)
 	resizeButtons.FlowChildren()
+	resizeButtons.SetZIndex(ZIndexDialog)
 
 	ResizeUIHideDialog()
 
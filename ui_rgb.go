@@ -30,8 +30,29 @@ var (
 
 	// hexColor is the current color being displayed
 	hexColor rl.Color
+
+	// rgbTransactionFile is the file which had a transaction started while a
+	// slider is being dragged
+	rgbTransactionFile *File
 )
 
+// rgbBeginTransaction groups the changes made while dragging a slider into one
+// undo step
+func rgbBeginTransaction() {
+	if rgbTransactionFile == nil {
+		rgbTransactionFile = CurrentFile
+		rgbTransactionFile.BeginTransaction()
+	}
+}
+
+// rgbEndTransaction ends the transaction started when the slider was clicked
+func rgbEndTransaction() {
+	if rgbTransactionFile != nil {
+		rgbTransactionFile.EndTransaction()
+		rgbTransactionFile = nil
+	}
+}
+
 // SetUIHexColor sets the hex color label
 func SetUIHexColor(color rl.Color) {
 	hexColor = color
@@ -287,9 +308,11 @@ func NewRGBUI(bounds rl.Rectangle) *Entity {
 	rgbArea = NewRenderTexture(areaBounds,
 		func(entity *Entity, button MouseButton) {
 			// button up
+			rgbEndTransaction()
 		},
 		func(entity *Entity, button MouseButton, isHeld bool) {
 			// button down
+			rgbBeginTransaction()
 			PaletteUIHideCurrentColorIndicator()
 			if moveable, ok := rgbArea.GetMoveable(); ok {
 				mx := rl.GetMouseX()
@@ -344,9 +367,11 @@ func NewRGBUI(bounds rl.Rectangle) *Entity {
 	colorSlider = NewRenderTexture(sliderBounds,
 		func(entity *Entity, button MouseButton) {
 			// button up
+			rgbEndTransaction()
 		},
 		func(entity *Entity, button MouseButton, isHeld bool) {
 			// button down
+			rgbBeginTransaction()
 			PaletteUIHideCurrentColorIndicator()
 			if moveable, ok := colorSlider.GetMoveable(); ok {
 				mx := rl.GetMouseX()
@@ -392,9 +417,11 @@ func NewRGBUI(bounds rl.Rectangle) *Entity {
 	opacitySlider = NewRenderTexture(sliderBounds,
 		func(entity *Entity, button MouseButton) {
 			// button up
+			rgbEndTransaction()
 		},
 		func(entity *Entity, button MouseButton, isHeld bool) {
 			// button down
+			rgbBeginTransaction()
 			if moveable, ok := opacitySlider.GetMoveable(); ok {
 				mx := rl.GetMouseX()
 				mx -= int32(moveable.Bounds.X)
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"log"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	stampsPanel *Entity
+	stampsList  *Entity
+	// stampsThumbs aren't unloaded by the scene since they're
+	// DrawableRenderTextures, so they're unloaded when the list is rebuilt
+	stampsThumbs []rl.RenderTexture2D
+)
+
+// StampsUIShow loads the stamps and shows the panel
+func StampsUIShow() {
+	StampsUIRebuildList()
+	stampsPanel.ShowAnimated()
+}
+
+// StampsUIHide hides the panel
+func StampsUIHide() {
+	stampsPanel.HideAnimated(0)
+}
+
+// StampsUIToggle shows or hides the panel
+func StampsUIToggle() {
+	if drawable, ok := stampsPanel.GetDrawable(); ok && drawable.Hidden {
+		StampsUIShow()
+		return
+	}
+	StampsUIHide()
+}
+
+// StampsUIRebuildList reloads the stamps from StampsDir
+func StampsUIRebuildList() {
+	for _, thumb := range stampsThumbs {
+		rl.UnloadRenderTexture(thumb)
+	}
+	stampsThumbs = stampsThumbs[:0]
+	if children, err := stampsList.GetChildren(); err == nil {
+		children = append([]*Entity{}, children...)
+		stampsList.RemoveChildren()
+		for _, child := range children {
+			child.DestroyNested()
+		}
+	}
+
+	stamps, err := LoadStamps()
+	if err != nil {
+		log.Println(err)
+	}
+	for _, stamp := range stamps {
+		stampsList.PushChild(stampsUIMakeButton(stamp))
+	}
+	stampsList.FlowChildren()
+}
+
+// stampsUIMakeButton makes a button showing the stamp's thumbnail which pastes
+// the stamp when it's clicked
+func stampsUIMakeButton(stamp *Stamp) *Entity {
+	button := NewRenderTexture(rl.NewRectangle(0, 0, UIButtonHeight*2, UIButtonHeight*2),
+		func(entity *Entity, button MouseButton) {
+			CurrentFile.PasteImage(stamp.Image)
+		}, nil)
+	button.Name = "stamp: " + stamp.Name
+
+	if drawable, ok := button.GetDrawable(); ok {
+		if renderTexture, ok := drawable.DrawableType.(*DrawableRenderTexture); ok {
+			stampsUIDrawThumb(renderTexture.Texture, stamp)
+			stampsThumbs = append(stampsThumbs, renderTexture.Texture)
+		}
+	}
+	return button
+}
+
+// stampsUIDrawThumb draws the stamp scaled to fit the texture, keeping its
+// aspect ratio
+func stampsUIDrawThumb(target rl.RenderTexture2D, stamp *Stamp) {
+	tex := LoadTextureFromNRGBA(stamp.Image)
+
+	tw, th := float32(target.Texture.Width), float32(target.Texture.Height)
+	sw, sh := float32(tex.Width), float32(tex.Height)
+	scale := tw / sw
+	if th/sh < scale {
+		scale = th / sh
+	}
+	w, h := sw*scale, sh*scale
+
+	rl.BeginTextureMode(target)
+	rl.ClearBackground(rl.Blank)
+	rl.DrawTexturePro(tex,
+		rl.NewRectangle(0, 0, sw, sh),
+		rl.NewRectangle((tw-w)/2, (th-h)/2, w, h),
+		rl.NewVector2(0, 0),
+		0,
+		rl.White)
+	rl.EndTextureMode()
+	rl.UnloadTexture(tex)
+}
+
+// NewStampsUI creates the stamps panel, which is hidden until it's opened from
+// the edit menu
+func NewStampsUI(bounds rl.Rectangle) *Entity {
+	buttonWidth := (bounds.Width - UIButtonHeight) / 2
+	buttons := NewBox(rl.NewRectangle(0, 0, bounds.Width, UIButtonHeight), []*Entity{
+		NewButtonText(rl.NewRectangle(0, 0, buttonWidth, UIButtonHeight), T("save selection"), TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				dest, err := CurrentFile.SaveSelectionAsStamp()
+				if err != nil {
+					log.Println(err)
+					PlaySoundCue(SoundError)
+					return
+				}
+				log.Println("Saved stamp to", dest)
+				PlaySoundCue(SoundSaved)
+				StampsUIRebuildList()
+			}, nil),
+		NewButtonText(rl.NewRectangle(0, 0, buttonWidth, UIButtonHeight), T("reload"), TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				StampsUIRebuildList()
+			}, nil),
+		NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight), "X", TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				StampsUIHide()
+			}, nil),
+	}, FlowDirectionHorizontal)
+	buttons.FlowChildren()
+
+	stampsList = NewScrollableList(rl.NewRectangle(0, 0, bounds.Width, bounds.Height-UIButtonHeight), []*Entity{}, FlowDirectionHorizontal)
+
+	stampsPanel = NewBox(bounds, []*Entity{buttons, stampsList}, FlowDirectionVertical)
+	if drawable, ok := stampsPanel.GetDrawable(); ok {
+		drawable.DrawBackground = true
+		drawable.DrawBorder = true
+	}
+	stampsPanel.FlowChildren()
+	stampsPanel.SetZIndex(ZIndexPanel).SetTween(rl.NewVector2(UIButtonHeight, 0))
+	stampsPanel.Hide()
+
+	return stampsPanel
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// timelineState is what the timeline shows, it's only redrawn when it changes
+type timelineState struct {
+	file                    *File
+	history                 int
+	offset, layers, current int32
+	frame, frames           int32
+	pixels, links           int
+}
+
+var (
+	timelinePanel *Entity
+	timelineGrid  *Entity
+	timelineLast  timelineState
+)
+
+// TimelineUIShow shows the panel
+func TimelineUIShow() {
+	timelineLast = timelineState{}
+	timelinePanel.ShowAnimated()
+}
+
+// TimelineUIHide hides the panel
+func TimelineUIHide() {
+	timelinePanel.HideAnimated(0)
+}
+
+// TimelineUIToggle shows or hides the panel
+func TimelineUIToggle() {
+	if drawable, ok := timelinePanel.GetDrawable(); ok && drawable.Hidden {
+		TimelineUIShow()
+		return
+	}
+	TimelineUIHide()
+}
+
+// timelineUICellSize returns the size of each cel in the grid and how many
+// frames and layers there are
+func timelineUICellSize(width, height float32) (size float32, frames, layers int32) {
+	frames = CurrentFile.frameCount()
+	layers = int32(len(CurrentFile.Layers))
+	size = UIButtonHeight / 2
+	if s := width / float32(frames); s < size {
+		size = s
+	}
+	if s := height / float32(layers); s < size {
+		size = s
+	}
+	if size < 2 {
+		size = 2
+	}
+	return size, frames, layers
+}
+
+// TimelineUIUpdate redraws the timeline if the cels have changed
+func TimelineUIUpdate() {
+	if drawable, ok := timelinePanel.GetDrawable(); !ok || drawable.Hidden {
+		return
+	}
+
+	state := timelineState{
+		file:    CurrentFile,
+		history: len(CurrentFile.History),
+		offset:  CurrentFile.historyOffset,
+		layers:  int32(len(CurrentFile.Layers)),
+		current: CurrentFile.CurrentLayer,
+		frame:   previewAnimationFrame,
+		frames:  CurrentFile.frameCount(),
+	}
+	for _, layer := range CurrentFile.Layers {
+		state.pixels += len(layer.PixelData)
+		state.links += len(layer.CelLinks)
+	}
+	if state == timelineLast {
+		return
+	}
+	timelineLast = state
+
+	drawable, ok := timelineGrid.GetDrawable()
+	if !ok {
+		return
+	}
+	renderTexture, ok := drawable.DrawableType.(*DrawableRenderTexture)
+	if !ok {
+		return
+	}
+	target := renderTexture.Texture
+	size, frames, layers := timelineUICellSize(float32(target.Texture.Width), float32(target.Texture.Height))
+
+	rl.BeginTextureMode(target)
+	rl.ClearBackground(rl.Blank)
+	rl.DrawRectangleRec(rl.NewRectangle(float32(previewAnimationFrame)*size, 0, size, size*float32(layers)), rl.NewColor(255, 255, 255, 32))
+	for l := int32(0); l < layers; l++ {
+		layer := CurrentFile.Layers[l]
+		// The top layer is the first row, like in the layers list
+		y := float32(layers-1-l) * size
+		if l == CurrentFile.CurrentLayer {
+			rl.DrawRectangleRec(rl.NewRectangle(0, y, size*float32(frames), size), rl.NewColor(255, 255, 255, 32))
+		}
+		painted := CurrentFile.celsPainted(layer)
+		for frame := int32(0); frame < frames; frame++ {
+			cel := rl.NewRectangle(float32(frame)*size+1, y+1, size-2, size-2)
+			if painted[frame] {
+				rl.DrawRectangleRec(cel, rl.Gray)
+			} else {
+				rl.DrawRectangleLinesEx(cel, 1, rl.Gray)
+			}
+			// Linked cels next to each other are joined
+			if frame > 0 && layer.CelLinked(frame) && layer.CelRoot(frame) == layer.CelRoot(frame-1) {
+				rl.DrawRectangleRec(rl.NewRectangle(float32(frame)*size-2, y+size/2-1, 4, 2), rl.Yellow)
+			}
+			if layer.CelLinked(frame) {
+				rl.DrawRectangleRec(rl.NewRectangle(cel.X, cel.Y+cel.Height-2, cel.Width, 2), rl.Yellow)
+			}
+		}
+	}
+	rl.EndTextureMode()
+}
+
+// timelineUICelAt returns the layer and frame of the cel under the mouse
+func timelineUICelAt(entity *Entity) (layer, frame int32, ok bool) {
+	moveable, ok := entity.GetMoveable()
+	if !ok {
+		return 0, 0, false
+	}
+	size, frames, layers := timelineUICellSize(moveable.Bounds.Width, moveable.Bounds.Height)
+	mouse := rl.GetMousePosition()
+	frame = int32((mouse.X - moveable.Bounds.X) / size)
+	row := int32((mouse.Y - moveable.Bounds.Y) / size)
+	if frame < 0 || frame >= frames || row < 0 || row >= layers {
+		return 0, 0, false
+	}
+	return layers - 1 - row, frame, true
+}
+
+// timelineUILinkToPrevious links the current cel to the one before it
+func timelineUILinkToPrevious() {
+	if err := CurrentFile.LinkCel(CurrentFile.CurrentLayer, previewAnimationFrame, previewAnimationFrame-1); err != nil {
+		UIWarning(err.Error())
+	}
+}
+
+// NewTimelineUI creates the timeline panel, which shows the cels of every
+// layer and frame. It's hidden until it's opened from the edit menu
+func NewTimelineUI(bounds rl.Rectangle) *Entity {
+	buttonWidth := (bounds.Width - UIButtonHeight) / 2
+	buttons := NewBox(rl.NewRectangle(0, 0, bounds.Width, UIButtonHeight), []*Entity{
+		NewButtonText(rl.NewRectangle(0, 0, buttonWidth, UIButtonHeight), T("link to previous"), TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				timelineUILinkToPrevious()
+			}, nil).EnabledWhen(layersUIEditable),
+		NewButtonText(rl.NewRectangle(0, 0, buttonWidth, UIButtonHeight), T("unlink"), TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				CurrentFile.UnlinkCel(CurrentFile.CurrentLayer, previewAnimationFrame)
+			}, nil).EnabledWhen(layersUIEditable),
+		NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight), "X", TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				TimelineUIHide()
+			}, nil),
+	}, FlowDirectionHorizontal)
+	buttons.FlowChildren()
+
+	timelineGrid = NewRenderTexture(rl.NewRectangle(0, 0, bounds.Width, bounds.Height-UIButtonHeight),
+		func(entity *Entity, button MouseButton) {
+			layer, frame, ok := timelineUICelAt(entity)
+			if !ok {
+				return
+			}
+			previewAnimationIsPaused = true
+			previewAnimationFrame = frame
+			if layer != CurrentFile.CurrentLayer {
+				LayersUISetCurrentLayer(layer)
+			}
+		}, nil)
+	timelineGrid.Name = "timeline"
+
+	timelinePanel = NewBox(bounds, []*Entity{buttons, timelineGrid}, FlowDirectionVertical)
+	if drawable, ok := timelinePanel.GetDrawable(); ok {
+		drawable.DrawBackground = true
+		drawable.DrawBorder = true
+	}
+	timelinePanel.FlowChildren()
+	timelinePanel.SetZIndex(ZIndexPanel).SetTween(rl.NewVector2(0, UIButtonHeight))
+	timelinePanel.Hide()
+
+	return timelinePanel
+}
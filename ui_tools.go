@@ -8,27 +8,28 @@ import (
 )
 
 var (
-	currentToolHoverable *Hoverable
-	toolsButtons         *Entity
-	toolPencil           *Entity
-	toolEraser           *Entity
-	toolFill             *Entity
-	toolPicker           *Entity
-	toolSelector         *Entity
-	toolSettings         *Entity // extra space which can be used by other ui
+	toolsGroup    RadioGroup
+	toolsButtons  *Entity
+	toolPencil    *Entity
+	toolEraser    *Entity
+	toolFill      *Entity
+	toolPicker    *Entity
+	toolSelector  *Entity
+	toolScatter   *Entity
+	toolCurve     *Entity
+	toolWarp      *Entity
+	toolNineSlice *Entity
+	toolSettings  *Entity // extra space which can be used by other ui
+	// toolsUICurrent is the button of the tool being used
+	toolsUICurrent *Entity
 )
 
 // ToolsUISetCurrentToolSelected makes the tool have the selected appearance
 // It also changes the UI to show additional items in the empty space to the
 // right of the tools
 func ToolsUISetCurrentToolSelected(entity *Entity) {
-	if hoverable, ok := entity.GetHoverable(); ok {
-		if currentToolHoverable != nil {
-			currentToolHoverable.Selected = false
-		}
-		currentToolHoverable = hoverable
-		hoverable.Selected = true
-	}
+	toolsGroup.Select(entity)
+	toolsUICurrent = entity
 
 	toolSettings.RemoveChildren()
 
@@ -121,13 +122,284 @@ func ToolsUISetCurrentToolSelected(entity *Entity) {
 		}
 		toolSettings.PushChild(brushShapeBox)
 		toolSettings.PushChild(brushWidthInput)
+
+		// Stroke smoothing
+		var stabilizer Stabilizer
+		if lt, ok := LeftTool.(*PixelBrushTool); ok {
+			stabilizer = lt.GetStabilizer()
+		}
+		setStabilizer := func(stabilizer Stabilizer) {
+			if lt, ok := LeftTool.(*PixelBrushTool); ok {
+				lt.SetStabilizer(stabilizer)
+			}
+		}
+		toolSettings.PushChild(NewButtonToggle(rl.NewRectangle(0, 0, UIButtonHeight*2, UIButtonHeight), T("smooth"), TextAlignCenter, stabilizer.Enabled,
+			func(e *Entity, selected bool) {
+				stabilizer.Enabled = selected
+				setStabilizer(stabilizer)
+				ToolsUISetCurrentToolSelected(entity)
+			}))
+		if stabilizer.Enabled {
+			toolSettings.PushChild(ToolsUIMakeNumberInput(stabilizer.Length, func(value int32) int32 {
+				stabilizer.Length = MaxInt32(0, MinInt32(value, maxStabilizerLength))
+				setStabilizer(stabilizer)
+				return stabilizer.Length
+			}))
+		}
+
+		// Stroke opacity, in percent
+		if entity == toolPencil {
+			toolSettings.PushChild(ToolsUIMakeNumberInput(GlobalBrushOpacity, func(value int32) int32 {
+				GlobalBrushOpacity = MaxInt32(0, MinInt32(value, 100))
+				return GlobalBrushOpacity
+			}))
+		}
+
+		// Image brushes
+		imageBrushBox := NewBox(rl.NewRectangle(0, 0, UIButtonHeight*2.5, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight*2.5, UIButtonHeight/2), T("image brush"), TextAlignCenter, shape == BrushShapeImage,
+				func(e *Entity, button MouseButton) {
+					// button up
+					switch {
+					case GlobalBrushImage == nil:
+						UILoadBrush()
+					case shape != BrushShapeImage:
+						ToolsUISetBrushShape(BrushShapeImage)
+					case entity == toolEraser:
+						ToolsUISetBrushShape(BrushShapeSquare)
+					default:
+						ToolsUISetBrushShape(BrushShapeCircle)
+					}
+				}, nil),
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight*2.5, UIButtonHeight/2), T("load brush"), TextAlignCenter, false,
+				func(e *Entity, button MouseButton) {
+					// button up
+					UILoadBrush()
+				}, nil),
+		}, FlowDirectionVertical)
+		toolSettings.PushChild(imageBrushBox)
+		if shape == BrushShapeImage {
+			if entity == toolPencil {
+				toolSettings.PushChild(NewButtonToggle(rl.NewRectangle(0, 0, UIButtonHeight*2, UIButtonHeight), T("recolor"), TextAlignCenter, GlobalBrushRecolor,
+					func(e *Entity, selected bool) {
+						GlobalBrushRecolor = selected
+						ToolsUISetCurrentToolSelected(entity)
+					}))
+			}
+			// Spacing between stamps
+			toolSettings.PushChild(ToolsUIMakeNumberInput(GlobalBrushSpacing, func(value int32) int32 {
+				GlobalBrushSpacing = MaxInt32(1, MinInt32(value, maxBrushImageSize*2))
+				return GlobalBrushSpacing
+			}))
+		}
+	case toolFill:
+		var mode FillMode
+		var align FillPatternAlign
+		if lt, ok := LeftTool.(*FillTool); ok {
+			mode = lt.GetMode()
+			align = lt.GetPatternAlign()
+		}
+		alignLabel := T("at origin")
+		if align == FillPatternAlignClick {
+			alignLabel = T("at click")
+		}
+		fillModeBox := NewBox(rl.NewRectangle(0, 0, UIButtonHeight*2.5, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight*2.5, UIButtonHeight/2), T("pattern"), TextAlignCenter, mode == FillModePattern,
+				func(e *Entity, button MouseButton) {
+					// button up
+					newMode := FillModePattern
+					if mode == FillModePattern {
+						newMode = FillModeColor
+					}
+					if lt, ok := LeftTool.(*FillTool); ok {
+						lt.SetMode(newMode)
+					}
+					if rt, ok := RightTool.(*FillTool); ok {
+						rt.SetMode(newMode)
+					}
+					ToolsUISetCurrentToolSelected(entity)
+				}, nil),
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight*2.5, UIButtonHeight/2), alignLabel, TextAlignCenter, false,
+				func(e *Entity, button MouseButton) {
+					// button up
+					newAlign := FillPatternAlignClick
+					if align == FillPatternAlignClick {
+						newAlign = FillPatternAlignOrigin
+					}
+					if lt, ok := LeftTool.(*FillTool); ok {
+						lt.SetPatternAlign(newAlign)
+					}
+					if rt, ok := RightTool.(*FillTool); ok {
+						rt.SetPatternAlign(newAlign)
+					}
+					ToolsUISetCurrentToolSelected(entity)
+				}, nil),
+		}, FlowDirectionVertical)
+		toolSettings.PushChild(fillModeBox)
+		// Filling every pixel of the clicked color replaces that color
+		toolSettings.PushChild(NewButtonToggle(rl.NewRectangle(0, 0, UIButtonHeight*2.5, UIButtonHeight), T("contiguous"), TextAlignCenter, GlobalFillContiguous,
+			func(e *Entity, selected bool) {
+				if lt, ok := LeftTool.(*FillTool); ok {
+					lt.SetContiguous(selected)
+				}
+				if rt, ok := RightTool.(*FillTool); ok {
+					rt.SetContiguous(selected)
+				}
+				ToolsUISetCurrentToolSelected(entity)
+			}))
+	case toolSelector:
+		var shape SelectionShape
+		var snap bool
+		var width, height int32
+		if lt, ok := LeftTool.(*SelectorTool); ok {
+			shape = lt.GetShape()
+			snap = lt.GetSnap()
+			width, height = lt.GetFixedSize()
+		}
+		shapeLabel := T("free")
+		switch shape {
+		case SelectionShapeFixedSize:
+			shapeLabel = T("fixed size")
+		case SelectionShapeFixedAspect:
+			shapeLabel = T("fixed ratio")
+		}
+		setSelector := func(set func(t *SelectorTool)) {
+			if lt, ok := LeftTool.(*SelectorTool); ok {
+				set(lt)
+			}
+			if rt, ok := RightTool.(*SelectorTool); ok {
+				set(rt)
+			}
+		}
+		selectionShapeBox := NewBox(rl.NewRectangle(0, 0, UIButtonHeight*2.5, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight*2.5, UIButtonHeight/2), shapeLabel, TextAlignCenter, shape != SelectionShapeFree,
+				func(e *Entity, button MouseButton) {
+					// button up
+					setSelector(func(t *SelectorTool) {
+						t.SetShape((shape + 1) % (SelectionShapeFixedAspect + 1))
+					})
+					ToolsUISetCurrentToolSelected(entity)
+				}, nil),
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight*2.5, UIButtonHeight/2), T("snap to tiles"), TextAlignCenter, snap,
+				func(e *Entity, button MouseButton) {
+					// button up
+					setSelector(func(t *SelectorTool) {
+						t.SetSnap(!snap)
+					})
+					ToolsUISetCurrentToolSelected(entity)
+				}, nil),
+		}, FlowDirectionVertical)
+		toolSettings.PushChild(selectionShapeBox)
+		toolSettings.PushChild(ToolsUIMakeNumberInput(width, func(value int32) int32 {
+			setSelector(func(t *SelectorTool) {
+				t.SetFixedSize(value, GlobalSelectionHeight)
+			})
+			return GlobalSelectionWidth
+		}))
+		toolSettings.PushChild(ToolsUIMakeNumberInput(height, func(value int32) int32 {
+			setSelector(func(t *SelectorTool) {
+				t.SetFixedSize(GlobalSelectionWidth, value)
+			})
+			return GlobalSelectionHeight
+		}))
+	case toolNineSlice:
+		// Left, top, right and bottom
+		for i := 0; i < 4; i++ {
+			i := i
+			toolSettings.PushChild(ToolsUIMakeNumberInput(*CurrentFile.NineSlice.margin(i), func(value int32) int32 {
+				n := CurrentFile.NineSlice
+				*n.margin(i) = value
+				CurrentFile.SetNineSlice(n)
+				return *CurrentFile.NineSlice.margin(i)
+			}))
+		}
+	case toolScatter:
+		var size, density int32
+		if lt, ok := LeftTool.(*ScatterBrushTool); ok {
+			size = lt.GetSize()
+			density = lt.GetDensity()
+		}
+		toolSettings.PushChild(ToolsUIMakeNumberInput(size, func(value int32) int32 {
+			if lt, ok := LeftTool.(*ScatterBrushTool); ok {
+				lt.SetSize(value)
+				return lt.GetSize()
+			}
+			return value
+		}))
+		toolSettings.PushChild(ToolsUIMakeNumberInput(density, func(value int32) int32 {
+			if lt, ok := LeftTool.(*ScatterBrushTool); ok {
+				lt.SetDensity(value)
+				return lt.GetDensity()
+			}
+			return value
+		}))
 	}
 
 	toolSettings.FlowChildren()
 }
 
+// ToolsUISetBrushShape sets the shape of the pixel brush or eraser and updates
+// the tool settings
+func ToolsUISetBrushShape(shape BrushShape) {
+	lt, ok := LeftTool.(*PixelBrushTool)
+	if !ok {
+		GlobalBrushShape = shape
+		return
+	}
+	lt.SetShape(shape)
+	if rt, ok := RightTool.(*PixelBrushTool); ok {
+		rt.SetShape(shape)
+	}
+	if lt.eraser {
+		ToolsUISetCurrentToolSelected(toolEraser)
+	} else {
+		ToolsUISetCurrentToolSelected(toolPencil)
+	}
+}
+
+// ToolsUIMakeNumberInput makes a small input for a tool setting. set is called
+// when the value is typed or scrolled and returns the value which was accepted
+func ToolsUIMakeNumberInput(value int32, set func(value int32) int32) *Entity {
+	input := NewInput(rl.NewRectangle(0, 0, UIButtonHeight*1.25, UIButtonHeight), fmt.Sprintf("%d", value), TextAlignCenter, false,
+		func(entity *Entity, button MouseButton) {
+			// button up
+		},
+		nil,
+		func(entity *Entity, key Key) {
+			// key pressed
+			if drawable, ok := entity.GetDrawable(); ok {
+				if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+					if key == rl.KeyBackspace && len(drawableText.Label) > 0 {
+						drawableText.Label = drawableText.Label[:len(drawableText.Label)-1]
+					} else if len(drawableText.Label) < 4 {
+						if key >= 48 && key <= 57 { // 0 to 9
+							drawableText.Label += string(rune(key))
+						}
+
+						if i, err := strconv.ParseInt(drawableText.Label, 10, 64); err == nil {
+							value = set(int32(i))
+							drawableText.Label = fmt.Sprintf("%d", value)
+						}
+					}
+				}
+			}
+		})
+	if interactable, ok := input.GetInteractable(); ok {
+		interactable.OnScroll = func(direction int32) {
+			if drawable, ok := input.GetDrawable(); ok {
+				if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+					value = set(value + direction)
+					drawableText.Label = fmt.Sprintf("%d", value)
+				}
+			}
+		}
+	}
+	return input
+}
+
 // NewToolsUI creates and returns the tools UI entity
 func NewToolsUI(bounds rl.Rectangle) *Entity {
+	bounds.Height = UIButtonHeight
 	toolsButtons = NewBox(bounds, []*Entity{}, FlowDirectionHorizontal)
 
 	// TODO allow right click to be replaced with selector if alt is pressed
@@ -177,9 +449,52 @@ func NewToolsUI(bounds rl.Rectangle) *Entity {
 			RightTool = NewSelectorTool("Selector")
 			ToolsUISetCurrentToolSelected(entity)
 		}, nil)
+	toolScatter = NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		GetFile("./res/icons/spray.png"), false, func(entity *Entity, button MouseButton) {
+			// Commit the selection, stop showing selection preview etc
+			if len(CurrentFile.Selection) > 0 {
+				CurrentFile.CommitSelection()
+			}
+			LeftTool = NewScatterBrushTool("Scatter Brush")
+			RightTool = NewScatterBrushTool("Scatter Brush")
+			ToolsUISetCurrentToolSelected(entity)
+		}, nil)
+	toolCurve = NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		GetFile("./res/icons/curve.png"), false, func(entity *Entity, button MouseButton) {
+			// Commit the selection, stop showing selection preview etc
+			if len(CurrentFile.Selection) > 0 {
+				CurrentFile.CommitSelection()
+			}
+			// Shared so the curve can be started and finished with either
+			// mouse button
+			curve := NewCurveTool("Curve")
+			LeftTool = curve
+			RightTool = curve
+			ToolsUISetCurrentToolSelected(entity)
+		}, nil)
+	toolWarp = NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		GetFile("./res/icons/warp.png"), false, func(entity *Entity, button MouseButton) {
+			// Keeps the selection floating so that it can be warped
+			warp := NewWarpTool("Warp")
+			LeftTool = warp
+			RightTool = warp
+			ToolsUISetCurrentToolSelected(entity)
+		}, nil)
+
+	toolNineSlice = NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		GetFile("./res/icons/nine_slice.png"), false, func(entity *Entity, button MouseButton) {
+			// Commit the selection, stop showing selection preview etc
+			if len(CurrentFile.Selection) > 0 {
+				CurrentFile.CommitSelection()
+			}
+			nineSlice := NewNineSliceTool("Nine-slice")
+			LeftTool = nineSlice
+			RightTool = nineSlice
+			ToolsUISetCurrentToolSelected(entity)
+		}, nil)
 
-	// currently only 5 buttons
-	// bounds.Width = UIButtonHeight
+	// The settings sit on their own row below the buttons
+	bounds.Height = UIButtonHeight
 	toolSettings = NewBox(bounds, []*Entity{}, FlowDirectionHorizontal)
 
 	toolsButtons.PushChild(toolPencil)
@@ -187,10 +502,18 @@ func NewToolsUI(bounds rl.Rectangle) *Entity {
 	toolsButtons.PushChild(toolFill)
 	toolsButtons.PushChild(toolPicker)
 	toolsButtons.PushChild(toolSelector)
-	toolsButtons.PushChild(toolSettings)
-	toolsButtons.FlowChildren()
+	toolsButtons.PushChild(toolScatter)
+	toolsButtons.PushChild(toolCurve)
+	toolsButtons.PushChild(toolWarp)
+	toolsButtons.PushChild(toolNineSlice)
+
+	tools := NewBox(rl.NewRectangle(0, 0, bounds.Width, UIButtonHeight*2), []*Entity{
+		toolsButtons,
+		toolSettings,
+	}, FlowDirectionVertical)
+	tools.FlowChildren()
 
 	ToolsUISetCurrentToolSelected(toolPencil)
 
-	return toolsButtons
+	return tools
 }
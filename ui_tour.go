@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// TourStep is a single step of the tour. Target is the panel which is
+// highlighted and Action is a sample action which can be tried, both are
+// optional
+type TourStep struct {
+	Caption []string
+	Target  func() *Entity
+	Action  func()
+}
+
+var (
+	// tourBox holds the caption and the buttons for the current step
+	tourBox *Entity
+	// tourStepIndex is -1 when the tour isn't running
+	tourStepIndex = -1
+
+	tourSteps = []TourStep{
+		{
+			Caption: []string{
+				"Welcome to MelonPixel!",
+				"This tour shows where everything is.",
+			},
+		},
+		{
+			Caption: []string{
+				"Tools",
+				"Pick a tool here, its settings show up",
+				"in the row below the buttons.",
+			},
+			Target: func() *Entity { return toolsButtons },
+			Action: func() {
+				if interactable, ok := toolPencil.GetInteractable(); ok {
+					interactable.OnMouseUp(toolPencil, rl.MouseLeftButton)
+				}
+			},
+		},
+		{
+			Caption: []string{
+				"Palette",
+				"Click a color to draw with it.",
+				"Shift+[ and Shift+] go through the colors.",
+			},
+			Target: func() *Entity { return PaletteUIPaletteEntity },
+			Action: PaletteUINextColor,
+		},
+		{
+			Caption: []string{
+				"Layers",
+				"Add, hide, reorder and merge layers.",
+			},
+			Target: func() *Entity { return layerListContainer },
+			Action: func() {
+				CurrentFile.AddNewLayer()
+				LayersUIRebuildList()
+			},
+		},
+		{
+			Caption: []string{
+				"Animations",
+				"Each animation plays a range of tiles.",
+				"Pick the frames with the frame selector.",
+			},
+			Target: func() *Entity { return animationsListContainer },
+			Action: func() {
+				CurrentFile.AddNewAnimation()
+				AnimationsUIRebuildList()
+			},
+		},
+		{
+			Caption: []string{
+				"Preview",
+				"Shows the whole sheet, a tile,",
+				"or the current animation.",
+			},
+			Target: func() *Entity { return previewContainer },
+		},
+		{
+			Caption: []string{
+				"That's everything!",
+				"Press F1 to see every keybinding.",
+			},
+		},
+	}
+)
+
+// TourUIStart starts the tour from the first step
+func TourUIStart() {
+	TourUIShowStep(0)
+}
+
+// TourUIEnd closes the tour and remembers that it has been seen
+func TourUIEnd() {
+	tourStepIndex = -1
+	tourUIDestroyBox()
+
+	Settings.TourSeen = true
+	if err := SaveSettings(); err != nil {
+		log.Println(err)
+	}
+}
+
+// TourUIActive returns true if the tour is running
+func TourUIActive() bool {
+	return tourStepIndex >= 0
+}
+
+func tourUIDestroyBox() {
+	if tourBox != nil {
+		tourBox.DestroyNested()
+		tourBox.Destroy()
+		tourBox = nil
+	}
+}
+
+// tourUITargetBounds returns the bounds of the step's target
+func tourUITargetBounds(step TourStep) (rl.Rectangle, bool) {
+	if step.Target == nil {
+		return rl.Rectangle{}, false
+	}
+	target := step.Target()
+	if target == nil {
+		return rl.Rectangle{}, false
+	}
+	if moveable, ok := target.GetMoveable(); ok {
+		return moveable.Bounds, true
+	}
+	return rl.Rectangle{}, false
+}
+
+// TourUIShowStep builds the caption box for the step, next to its target
+func TourUIShowStep(index int) {
+	tourUIDestroyBox()
+	if index < 0 || index >= len(tourSteps) {
+		TourUIEnd()
+		return
+	}
+	tourStepIndex = index
+	step := tourSteps[index]
+
+	var width float32
+	for _, line := range step.Caption {
+		if m := rl.MeasureTextEx(Font, T(line), UIFontSize, 1); m.X+20 > width {
+			width = m.X + 20
+		}
+	}
+	lineHeight := UIFontSize * 1.5
+	height := lineHeight*float32(len(step.Caption)) + UIButtonHeight
+
+	children := make([]*Entity, 0, len(step.Caption)+4)
+	for _, line := range step.Caption {
+		children = append(children, NewButtonText(rl.NewRectangle(0, 0, width, lineHeight), T(line), TextAlignLeft, false, nil, nil))
+	}
+
+	buttons := []*Entity{}
+	buttonWidth := width / 4
+	if index > 0 {
+		buttons = append(buttons, NewButtonText(rl.NewRectangle(0, 0, buttonWidth, UIButtonHeight), T("back"), TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				TourUIShowStep(index - 1)
+			}, nil))
+	}
+	if step.Action != nil {
+		buttons = append(buttons, NewButtonText(rl.NewRectangle(0, 0, buttonWidth, UIButtonHeight), T("try it"), TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				step.Action()
+			}, nil))
+	}
+	nextLabel := "next"
+	if index == len(tourSteps)-1 {
+		nextLabel = "done"
+	}
+	buttons = append(buttons, NewButtonText(rl.NewRectangle(0, 0, buttonWidth, UIButtonHeight), T(nextLabel), TextAlignCenter, false,
+		func(entity *Entity, button MouseButton) {
+			TourUIShowStep(index + 1)
+		}, nil))
+	if index < len(tourSteps)-1 {
+		buttons = append(buttons, NewButtonText(rl.NewRectangle(0, 0, buttonWidth, UIButtonHeight), T("skip"), TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				TourUIEnd()
+			}, nil))
+	}
+	buttonRow := NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), buttons, FlowDirectionHorizontal)
+	children = append(children, buttonRow)
+
+	// Next to the target, on whichever side has more room
+	sw, sh := float32(rl.GetScreenWidth()), float32(rl.GetScreenHeight())
+	x, y := (sw-width)/2, (sh-height)/2
+	if bounds, ok := tourUITargetBounds(step); ok {
+		y = bounds.Y
+		if bounds.X+bounds.Width/2 > sw/2 {
+			x = bounds.X - width - UIFontSize
+		} else {
+			x = bounds.X + bounds.Width + UIFontSize
+		}
+	}
+	if x+width > sw {
+		x = sw - width
+	}
+	if x < 0 {
+		x = 0
+	}
+	if y+height > sh {
+		y = sh - height
+	}
+	if y < 0 {
+		y = 0
+	}
+
+	tourBox = NewBox(rl.NewRectangle(x, y, width, height), children, FlowDirectionVertical)
+	if drawable, ok := tourBox.GetDrawable(); ok {
+		drawable.DrawBackground = true
+		drawable.DrawBorder = true
+	}
+	tourBox.FlowChildren()
+	tourBox.SetZIndex(ZIndexOverlay).BringToFront()
+}
+
+// TourUIDraw highlights the target of the current step
+func TourUIDraw() {
+	if !TourUIActive() {
+		return
+	}
+
+	if bounds, ok := tourUITargetBounds(tourSteps[tourStepIndex]); ok {
+		rl.DrawRectangleLinesEx(rl.NewRectangle(bounds.X-4, bounds.Y-4, bounds.Width+8, bounds.Height+8), 4, rl.Yellow)
+	}
+	rl.DrawTextEx(Font, fmt.Sprintf("%d/%d", tourStepIndex+1, len(tourSteps)),
+		rl.NewVector2(UIFontSize, float32(rl.GetScreenHeight())-UIFontSize*2), UIFontSize, 1, rl.Yellow)
+}
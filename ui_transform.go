@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	transformBox *Entity
+
+	// transformValues are X, Y, W, H, dX and dY
+	transformValues [6]int32
+)
+
+// TransformUIShowDialog shows the numeric transform dialog, filled in with the
+// current selection
+func TransformUIShowDialog() {
+	if !CurrentFile.DoingSelection {
+		return
+	}
+	minX, minY, maxX, maxY := CurrentFile.selectionRect()
+	transformValues = [6]int32{minX, minY, maxX - minX + 1, maxY - minY + 1, 0, 0}
+	// Show refreshes the inputs
+	transformBox.Show()
+}
+
+// TransformUIHideDialog hides the dialog
+func TransformUIHideDialog() {
+	RemoveCapturedInput()
+	transformBox.Hide()
+}
+
+// TransformUIMakeInput is a number input which is bound to one of the
+// transformValues. Unlike ResizeUIMakeInput it accepts negative numbers, the
+// value is clamped between min and max.
+// Optionally, an *Entity can be provided to switch focus to when tab is pressed
+func TransformUIMakeInput(value *int32, min, max int32, width float32, tabNext *Entity) *Entity {
+	i := NewInput(rl.NewRectangle(0, 0, width, UIButtonHeight), fmt.Sprint(*value), TextAlignCenter, false,
+		func(entity *Entity, button MouseButton) {
+			// button up
+		}, nil,
+		func(entity *Entity, key Key) {
+			// key pressed
+			drawable, ok := entity.GetDrawable()
+			if !ok {
+				return
+			}
+			drawableText, ok := drawable.DrawableType.(*DrawableText)
+			if !ok {
+				return
+			}
+
+			switch {
+			case key >= rl.KeyZero && key <= rl.KeyNine:
+				drawableText.Label += string(rune(key))
+			case key == rl.KeyMinus && len(drawableText.Label) == 0:
+				drawableText.Label = "-"
+			case key == rl.KeyBackspace && len(drawableText.Label) > 0:
+				drawableText.Label = drawableText.Label[:len(drawableText.Label)-1]
+			case key == rl.KeyTab:
+				RemoveCapturedInput()
+
+				// Set control to tabNext
+				if tabNext != nil {
+					if interactable, ok := tabNext.GetInteractable(); ok {
+						SetCapturedInput(tabNext, interactable)
+					}
+				}
+			case key == rl.KeyEnter:
+				RemoveCapturedInput()
+			}
+
+			if parsed, err := strconv.ParseInt(drawableText.Label, 10, 32); err == nil {
+				*value = MaxInt32(min, MinInt32(max, int32(parsed)))
+			}
+		})
+	if drawable, ok := i.GetDrawable(); ok {
+		drawable.OnShow = func(entity *Entity) {
+			if dt, ok := drawable.DrawableType.(*DrawableText); ok {
+				dt.Label = fmt.Sprint(*value)
+			}
+		}
+	}
+	return i
+}
+
+// NewTransformUI returns the numeric transform dialog, it's hidden until it's
+// opened from the edit menu
+func NewTransformUI() *Entity {
+	labels := []string{"x", "y", "width", "height", "move x by", "move y by"}
+	measured := menuMeasureLabels(labels...)
+	labelWidth := measured.X + 10
+	inputWidth := UIFontSize * 2 * 8
+	width := labelWidth + inputWidth
+
+	// Made in reverse so that tab can go to the next one
+	inputs := make([]*Entity, len(labels))
+	var next *Entity
+	for i := len(labels) - 1; i >= 0; i-- {
+		min, max := int32(-maxTransformSize), int32(maxTransformSize)
+		if i == 2 || i == 3 {
+			// The width and height
+			min = 1
+		}
+		inputs[i] = TransformUIMakeInput(&transformValues[i], min, max, inputWidth, next)
+		next = inputs[i]
+	}
+
+	rows := []*Entity{
+		NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight), T("transform selection"), TextAlignCenter, false, nil, nil),
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight), "X", TextAlignCenter, false,
+				func(entity *Entity, button MouseButton) {
+					TransformUIHideDialog()
+				}, nil),
+		}, FlowDirectionHorizontal),
+	}
+	for i, label := range labels {
+		rows = append(rows, NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, labelWidth, UIButtonHeight), T(label), TextAlignLeft, false, nil, nil),
+			inputs[i],
+		}, FlowDirectionHorizontal))
+	}
+	rows = append(rows, NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+		NewButtonText(rl.NewRectangle(0, 0, width/2, UIButtonHeight), T("apply"), TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				// Repeating it moves the selection by as much again and
+				// resizes it to the same size
+				v := transformValues
+				minX, minY, _, _ := CurrentFile.selectionRect()
+				dx, dy := v[0]+v[4]-minX, v[1]+v[5]-minY
+				RunCommand("transformSelection", func() {
+					minX, minY, _, _ := CurrentFile.selectionRect()
+					CurrentFile.TransformSelection(minX+dx, minY+dy, v[2], v[3])
+				})
+				TransformUIShowDialog()
+			}, nil),
+		NewButtonText(rl.NewRectangle(0, 0, width/2, UIButtonHeight), T("reset"), TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				TransformUIShowDialog()
+			}, nil),
+	}, FlowDirectionHorizontal))
+	for _, row := range rows {
+		row.FlowChildren()
+	}
+
+	height := UIButtonHeight * float32(len(rows))
+	transformBox = NewBox(rl.NewRectangle(
+		float32(rl.GetScreenWidth())/2-width/2,
+		float32(rl.GetScreenHeight())/2-height/2,
+		width,
+		height,
+	), rows, FlowDirectionVertical)
+	if drawable, ok := transformBox.GetDrawable(); ok {
+		drawable.DrawBackground = true
+		drawable.DrawBorder = true
+	}
+	transformBox.FlowChildren()
+	transformBox.SetZIndex(ZIndexDialog)
+	transformBox.Hide()
+
+	return transformBox
+}
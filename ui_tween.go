@@ -0,0 +1,111 @@
+package main
+
+import (
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// DefaultTweenDuration is how long entities take to fade in or out
+const DefaultTweenDuration = 100 * time.Millisecond
+
+// Tweenable fades and slides a top level entity in when it's shown and out
+// before it's hidden
+type Tweenable struct {
+	// Slide is where the entity slides in from, relative to where it is
+	Slide    rl.Vector2
+	Duration time.Duration
+
+	// progress goes from 0 when hidden to 1 when shown, showing is the
+	// direction it's going in
+	progress float32
+	showing  bool
+	// hideAt is when hiding starts, so that it can be cancelled
+	hideAt time.Time
+}
+
+// GetTweenable returns the Tweenable from the Entity
+func (entity *Entity) GetTweenable() (t *Tweenable, ok bool) {
+	if result, err := entity.Scene.QueryID(entity.ID); err == nil {
+		t, ok = result.Components[scene.ComponentsMap["tweenable"]].(*Tweenable)
+	}
+	return t, ok
+}
+
+// SetTween makes the entity fade in and out, sliding from slide
+func (entity *Entity) SetTween(slide rl.Vector2) *Entity {
+	entity.AddComponent(tweenable, &Tweenable{
+		Slide:    slide,
+		Duration: DefaultTweenDuration,
+		progress: 1,
+		showing:  true,
+	})
+	return entity
+}
+
+// ShowAnimated shows the entity and fades it in, it also cancels hiding. It's
+// the same as Show for entities without a Tweenable
+func (entity *Entity) ShowAnimated() {
+	t, ok := entity.GetTweenable()
+	if !ok {
+		entity.Show()
+		return
+	}
+	if drawable, ok := entity.GetDrawable(); ok && drawable.Hidden {
+		t.progress = 0
+		entity.Show()
+	}
+	t.showing = true
+}
+
+// HideAnimated fades the entity out after delay and then hides it. It's the
+// same as Hide for entities without a Tweenable
+func (entity *Entity) HideAnimated(delay time.Duration) {
+	t, ok := entity.GetTweenable()
+	if !ok {
+		entity.Hide()
+		return
+	}
+	t.showing = false
+	t.hideAt = time.Now().Add(delay)
+}
+
+// Step moves the tween along by dt seconds, hidden is true once it has faded
+// out
+func (t *Tweenable) Step(dt float32) (hidden bool) {
+	step := float32(1)
+	if t.Duration > 0 {
+		step = dt / float32(t.Duration.Seconds())
+	}
+	switch {
+	case t.showing:
+		t.progress += step
+	case time.Now().After(t.hideAt):
+		t.progress -= step
+	}
+	if t.progress > 1 {
+		t.progress = 1
+	}
+	if t.progress <= 0 {
+		t.progress = 0
+		return true
+	}
+	return false
+}
+
+// Animating returns true while the entity isn't fully shown
+func (t *Tweenable) Animating() bool {
+	return t.progress < 1 || !t.showing
+}
+
+// Alpha returns the opacity which the entity is drawn with
+func (t *Tweenable) Alpha() float32 {
+	return t.progress
+}
+
+// Offset returns how far the entity is drawn from where it is, it eases out
+// so that it slows down as it arrives
+func (t *Tweenable) Offset() rl.Vector2 {
+	remaining := 1 - t.progress
+	return rl.Vector2Scale(t.Slide, remaining*remaining)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"embed"
 	"fmt"
+	"image"
 	"log"
 	"math"
 	"os"
@@ -63,6 +64,25 @@ func Line(x0, y0, x1, y1 int32, drawFunc func(x, y int32)) {
 	}
 }
 
+// PixelPerfect removes the corner pixels from a connected path so that the
+// line is only ever one pixel thick
+func PixelPerfect(points []IntVec2) []IntVec2 {
+	out := make([]IntVec2, 0, len(points))
+	for i, p := range points {
+		if len(out) > 0 && i+1 < len(points) {
+			prev := out[len(out)-1]
+			next := points[i+1]
+			if (prev.X == p.X || prev.Y == p.Y) &&
+				(next.X == p.X || next.Y == p.Y) &&
+				prev.X != next.X && prev.Y != next.Y {
+				continue
+			}
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
 // Rotate rotates v by phi
 func (v IntVec2) Rotate(phi float64) IntVec2 {
 	c, s := math.Cos(phi), math.Sin(phi)
@@ -96,14 +116,7 @@ func BlendWithOpacity(a, b rl.Color, blendMode rl.BlendMode) rl.Color {
 
 	switch blendMode {
 	case rl.BlendAlpha:
-		a.A = AddAndClampUint8(MaxUint8(a.A, b.A), MinUint8(a.A, b.A)/2)
-		blendRatio := (float32(a.A) - float32(b.A)) / float32(a.A)
-		return rl.Color{
-			A: a.A,
-			R: uint8(float32(a.R)*blendRatio + float32(b.R)*(1-blendRatio)),
-			G: uint8(float32(a.G)*blendRatio + float32(b.G)*(1-blendRatio)),
-			B: uint8(float32(a.B)*blendRatio + float32(b.B)*(1-blendRatio)),
-		}
+		return AlphaOver(a, b)
 	case rl.BlendAddColors:
 		return rl.Color{
 			A: AddAndClampUint8(a.A, b.A),
@@ -118,6 +131,63 @@ func BlendWithOpacity(a, b rl.Color, blendMode rl.BlendMode) rl.Color {
 	return b
 }
 
+// AlphaOver composites b over a using straight (non-premultiplied) alpha,
+// the same way the GPU blends rl.BlendAlpha. If Settings.LinearBlending is on
+// the colors are mixed in linear light instead of sRGB
+func AlphaOver(a, b rl.Color) rl.Color {
+	if b.A == 255 || a.A == 0 {
+		return b
+	}
+	if b.A == 0 {
+		return a
+	}
+
+	sa := float32(b.A) / 255
+	da := float32(a.A) / 255 * (1 - sa)
+	outA := sa + da
+
+	mix := func(src, dst uint8) uint8 {
+		return uint8((float32(src)*sa+float32(dst)*da)/outA + 0.5)
+	}
+	if Settings != nil && Settings.LinearBlending {
+		mix = func(src, dst uint8) uint8 {
+			return LinearToSRGB((srgbToLinear[src]*sa + srgbToLinear[dst]*da) / outA)
+		}
+	}
+
+	return rl.Color{
+		R: mix(b.R, a.R),
+		G: mix(b.G, a.G),
+		B: mix(b.B, a.B),
+		A: uint8(outA*255 + 0.5),
+	}
+}
+
+// srgbToLinear converts an sRGB channel to linear light (0-1)
+var srgbToLinear [256]float32
+
+func init() {
+	for i := range srgbToLinear {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			srgbToLinear[i] = float32(c / 12.92)
+		} else {
+			srgbToLinear[i] = float32(math.Pow((c+0.055)/1.055, 2.4))
+		}
+	}
+}
+
+// LinearToSRGB converts a linear light channel (0-1) back to sRGB
+func LinearToSRGB(v float32) uint8 {
+	c := float64(v)
+	if c <= 0.0031308 {
+		c *= 12.92
+	} else {
+		c = 1.055*math.Pow(c, 1/2.4) - 0.055
+	}
+	return uint8(math.Max(0, math.Min(255, c*255+0.5)))
+}
+
 // ColorToHex converts an rl.Color into a hex string
 func ColorToHex(color rl.Color) string {
 	return fmt.Sprintf("%02x%02x%02x%02x", color.R, color.G, color.B, color.A)
@@ -228,6 +298,54 @@ func GetFile(resPath string) string {
 	return cachePath
 }
 
+// PixelToScreen returns the screen position of the top left corner of the
+// canvas pixel at x, y
+func PixelToScreen(x, y int32, camera rl.Camera2D) rl.Vector2 {
+	return rl.GetWorldToScreen2D(rl.NewVector2(
+		(float32(x)-float32(CurrentFile.CanvasWidth)/2)*CurrentFile.PixelAspect,
+		float32(y)-float32(CurrentFile.CanvasHeight)/2,
+	), camera)
+}
+
+// ScreenToPixel returns the canvas pixel which is under the screen position
+func ScreenToPixel(pos rl.Vector2, camera rl.Camera2D) IntVec2 {
+	world := rl.GetScreenToWorld2D(pos, camera)
+	return IntVec2{
+		int32(world.X/CurrentFile.PixelAspect + float32(CurrentFile.CanvasWidth)/2),
+		int32(world.Y + float32(CurrentFile.CanvasHeight)/2),
+	}
+}
+
+// PixelScreenSize returns the size of a canvas pixel on the screen
+func PixelScreenSize(camera rl.Camera2D) rl.Vector2 {
+	return rl.NewVector2(camera.Zoom*CurrentFile.PixelAspect, camera.Zoom)
+}
+
+// DrawPixelHighlight outlines the canvas pixel at x, y in screen space
+func DrawPixelHighlight(x, y int32, camera rl.Camera2D) {
+	pos := PixelToScreen(x, y, camera)
+	p := PixelScreenSize(camera)
+	rl.DrawRectangleLinesEx(rl.NewRectangle(pos.X-1, pos.Y-1, p.X+2, p.Y+2), 1, rl.Black)
+	rl.DrawRectangleLinesEx(rl.NewRectangle(pos.X, pos.Y, p.X, p.Y), 1, rl.White)
+}
+
+// DrawCrosshair draws a crosshair centered on pos in screen space
+func DrawCrosshair(pos rl.Vector2) {
+	var gap, length float32 = 4, 12
+	lines := [][2]rl.Vector2{
+		{rl.NewVector2(pos.X-length, pos.Y), rl.NewVector2(pos.X-gap, pos.Y)},
+		{rl.NewVector2(pos.X+gap, pos.Y), rl.NewVector2(pos.X+length, pos.Y)},
+		{rl.NewVector2(pos.X, pos.Y-length), rl.NewVector2(pos.X, pos.Y-gap)},
+		{rl.NewVector2(pos.X, pos.Y+gap), rl.NewVector2(pos.X, pos.Y+length)},
+	}
+	for _, line := range lines {
+		rl.DrawLineEx(line[0], line[1], 3, rl.Black)
+	}
+	for _, line := range lines {
+		rl.DrawLineEx(line[0], line[1], 1, rl.White)
+	}
+}
+
 // GetClampedCoordinates limits the x and y to the width/height of the canvas
 func GetClampedCoordinates(x, y int32) IntVec2 {
 	if x < 0 {
@@ -269,6 +387,22 @@ func MinInt32(a, b int32) int32 {
 	return b
 }
 
+// MinFloat32 returns the smaller float32 of the two args
+func MinFloat32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// AbsInt32 returns the absolute value of the int32
+func AbsInt32(a int32) int32 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
 // MaxUint8 returs the bigger uint8 of the two args
 func MaxUint8(a, b uint8) uint8 {
 	if a > b {
@@ -284,3 +418,25 @@ func MinUint8(a, b uint8) uint8 {
 	}
 	return b
 }
+
+// LoadTextureFromNRGBA uploads img as a texture. rl.NewImageFromImage can't be
+// used for images with transparency, it truncates the 16 bit premultiplied
+// colors instead of converting them
+func LoadTextureFromNRGBA(img *image.NRGBA) rl.Texture2D {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	if w == 0 || h == 0 {
+		return rl.Texture2D{}
+	}
+	pix := img.Pix
+	// The rows have to be next to each other
+	if img.Stride != w*4 || img.Rect.Min != (image.Point{}) {
+		compact := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			start := img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y)
+			copy(compact.Pix[y*w*4:(y+1)*w*4], img.Pix[start:start+w*4])
+		}
+		pix = compact.Pix
+	}
+	// The image points at pix, so it's uploaded but not unloaded
+	return rl.LoadTextureFromImage(rl.NewImage(pix, int32(w), int32(h), 1, rl.UncompressedR8g8b8a8))
+}
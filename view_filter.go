@@ -0,0 +1,134 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// ViewFilter simulates how the canvas looks to colorblind players, or shows
+// where the left color is used. It's only applied when the canvas is drawn,
+// the pixels aren't changed
+type ViewFilter int32
+
+// ViewFilters
+const (
+	ViewFilterNone ViewFilter = iota
+	ViewFilterProtanopia
+	ViewFilterDeuteranopia
+	ViewFilterTritanopia
+	ViewFilterGrayscale
+	ViewFilterColorUsage
+	viewFilterCount
+)
+
+var (
+	// CurrentViewFilter is the filter the canvas is drawn with
+	CurrentViewFilter = ViewFilterNone
+
+	viewFilterNames = map[ViewFilter]string{
+		ViewFilterNone:         "none",
+		ViewFilterProtanopia:   "protanopia",
+		ViewFilterDeuteranopia: "deuteranopia",
+		ViewFilterTritanopia:   "tritanopia",
+		ViewFilterGrayscale:    "grayscale",
+		ViewFilterColorUsage:   "color usage",
+	}
+
+	// viewFilterMatrices are the rows of the color matrix of each filter. The
+	// colorblindness ones are from Machado et al. (2009) at full severity
+	viewFilterMatrices = map[ViewFilter][3][3]float32{
+		ViewFilterProtanopia: {
+			{0.152286, 1.052583, -0.204868},
+			{0.114503, 0.786281, 0.099216},
+			{-0.003882, -0.048116, 1.051998},
+		},
+		ViewFilterDeuteranopia: {
+			{0.367322, 0.860646, -0.227968},
+			{0.280085, 0.672501, 0.047413},
+			{-0.011820, 0.042940, 0.968881},
+		},
+		ViewFilterTritanopia: {
+			{1.255528, -0.076749, -0.178779},
+			{-0.078411, 0.930809, 0.147602},
+			{0.004733, 0.691367, 0.303900},
+		},
+		ViewFilterGrayscale: {
+			{0.299, 0.587, 0.114},
+			{0.299, 0.587, 0.114},
+			{0.299, 0.587, 0.114},
+		},
+	}
+
+	viewFilterShader       rl.Shader
+	viewFilterShaderLoaded bool
+	viewFilterRowLocs      [3]int32
+)
+
+// viewFilterFragmentShader multiplies the color by the matrix, the rest is
+// raylib's default fragment shader
+const viewFilterFragmentShader = `#version 330
+in vec2 fragTexCoord;
+in vec4 fragColor;
+uniform sampler2D texture0;
+uniform vec4 colDiffuse;
+uniform vec3 rowR;
+uniform vec3 rowG;
+uniform vec3 rowB;
+out vec4 finalColor;
+
+void main() {
+	vec4 c = texture(texture0, fragTexCoord)*colDiffuse*fragColor;
+	vec3 filtered = vec3(dot(rowR, c.rgb), dot(rowG, c.rgb), dot(rowB, c.rgb));
+	finalColor = vec4(clamp(filtered, 0.0, 1.0), c.a);
+}
+`
+
+// String returns the translated name of the filter
+func (v ViewFilter) String() string {
+	return T(viewFilterNames[v])
+}
+
+// CycleViewFilter switches to the next filter, after the last one the filter
+// is turned off
+func CycleViewFilter() {
+	CurrentViewFilter = (CurrentViewFilter + 1) % viewFilterCount
+}
+
+// BeginViewFilter starts drawing with the current filter, it has to be called
+// after the window has been created
+func BeginViewFilter() {
+	if CurrentViewFilter == ViewFilterColorUsage {
+		beginColorUsage()
+		return
+	}
+	matrix, ok := viewFilterMatrices[CurrentViewFilter]
+	if !ok {
+		return
+	}
+	if !viewFilterShaderLoaded {
+		viewFilterShader = rl.LoadShaderFromMemory("", viewFilterFragmentShader)
+		for i, name := range []string{"rowR", "rowG", "rowB"} {
+			viewFilterRowLocs[i] = rl.GetShaderLocation(viewFilterShader, name)
+		}
+		viewFilterShaderLoaded = true
+	}
+	for i, row := range matrix {
+		rl.SetShaderValue(viewFilterShader, viewFilterRowLocs[i], row[:], rl.ShaderUniformVec3)
+	}
+	rl.BeginShaderMode(viewFilterShader)
+}
+
+// EndViewFilter stops drawing with the filter
+func EndViewFilter() {
+	if _, ok := viewFilterMatrices[CurrentViewFilter]; ok || CurrentViewFilter == ViewFilterColorUsage {
+		rl.EndShaderMode()
+	}
+}
+
+// UnloadViewFilter unloads the shader
+func UnloadViewFilter() {
+	if viewFilterShaderLoaded {
+		rl.UnloadShader(viewFilterShader)
+		viewFilterShaderLoaded = false
+	}
+	unloadColorUsage()
+}